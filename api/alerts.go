@@ -0,0 +1,113 @@
+package api
+
+import (
+	"encoding/json"
+	"sync"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/host/alerts"
+	"go.sia.tech/jape"
+	"go.uber.org/zap"
+	"nhooyr.io/websocket"
+)
+
+// alertEventBuffer is the number of alert events buffered for each
+// subscriber before it is disconnected. Updates to an alert that has not been
+// sent yet replace the queued event, so frequent progress updates do not
+// fill the buffer.
+const alertEventBuffer = 64
+
+// alertSubscriber buffers alert events so the alerts manager is not blocked
+// by slow websocket connections.
+type alertSubscriber struct {
+	notify chan struct{}
+
+	mu       sync.Mutex
+	queue    []alerts.Event
+	queued   map[types.Hash256]int // index of the alert's unsent event
+	overflow bool
+}
+
+func (as *alertSubscriber) ReceiveAlertEvent(event alerts.Event) {
+	as.mu.Lock()
+	if i, ok := as.queued[event.Alert.ID]; ok && event.Type == alerts.EventTypeUpdated {
+		// replace the unsent event with the latest version of the alert,
+		// keeping its type so a registration is not reported as an update
+		as.queue[i].Alert = event.Alert
+		as.queue[i].Timestamp = event.Timestamp
+	} else if len(as.queue) >= alertEventBuffer {
+		// the connection is not keeping up, close it rather than
+		// silently dropping events
+		as.overflow = true
+	} else {
+		as.queue = append(as.queue, event)
+		if event.Type == alerts.EventTypeDismissed {
+			delete(as.queued, event.Alert.ID)
+		} else {
+			as.queued[event.Alert.ID] = len(as.queue) - 1
+		}
+	}
+	as.mu.Unlock()
+
+	select {
+	case as.notify <- struct{}{}:
+	default:
+	}
+}
+
+// drain returns and clears the queued events. It returns false if events
+// were dropped because the queue was full.
+func (as *alertSubscriber) drain() ([]alerts.Event, bool) {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	events := as.queue
+	as.queue = nil
+	as.queued = make(map[types.Hash256]int)
+	return events, !as.overflow
+}
+
+func (a *api) handleGETAlertsSubscribe(c jape.Context) {
+	wsc, err := websocket.Accept(c.ResponseWriter, c.Request, &websocket.AcceptOptions{
+		OriginPatterns: []string{"*"},
+	})
+	if err != nil {
+		a.log.Warn("failed to accept websocket connection", zap.Error(err))
+		return
+	}
+	defer wsc.Close(websocket.StatusNormalClosure, "")
+
+	// the subscriber does not send messages, CloseRead cancels the context
+	// when the connection is closed
+	ctx := wsc.CloseRead(c.Request.Context())
+
+	// subscribe the websocket conn
+	sub := &alertSubscriber{
+		notify: make(chan struct{}, 1),
+		queued: make(map[types.Hash256]int),
+	}
+	a.alerts.Subscribe(sub)
+	defer a.alerts.Unsubscribe(sub)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sub.notify:
+		}
+
+		events, ok := sub.drain()
+		if !ok {
+			wsc.Close(websocket.StatusPolicyViolation, "too many pending events")
+			return
+		}
+		for _, event := range events {
+			buf, err := json.Marshal(event)
+			if err != nil {
+				a.log.Error("failed to encode alert event", zap.Error(err))
+				continue
+			} else if err := wsc.Write(ctx, websocket.MessageText, buf); err != nil {
+				return
+			}
+		}
+	}
+}
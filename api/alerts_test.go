@@ -0,0 +1,62 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/host/alerts"
+	"lukechampine.com/frand"
+)
+
+func TestAlertSubscriberCoalesce(t *testing.T) {
+	sub := &alertSubscriber{
+		notify: make(chan struct{}, 1),
+		queued: make(map[types.Hash256]int),
+	}
+
+	progress := alerts.Alert{ID: frand.Entropy256(), Severity: alerts.SeverityInfo, Timestamp: time.Now()}
+	other := alerts.Alert{ID: frand.Entropy256(), Severity: alerts.SeverityWarning, Timestamp: time.Now()}
+
+	sub.ReceiveAlertEvent(alerts.Event{Type: alerts.EventTypeRegistered, Alert: progress})
+	sub.ReceiveAlertEvent(alerts.Event{Type: alerts.EventTypeRegistered, Alert: other})
+	// more updates than the buffer can hold should be coalesced
+	for i := 0; i < 2*alertEventBuffer; i++ {
+		progress.Data = map[string]any{"progress": i}
+		sub.ReceiveAlertEvent(alerts.Event{Type: alerts.EventTypeUpdated, Alert: progress})
+	}
+	sub.ReceiveAlertEvent(alerts.Event{Type: alerts.EventTypeDismissed, Alert: progress})
+
+	events, ok := sub.drain()
+	if !ok {
+		t.Fatal("expected no overflow")
+	}
+	expected := []struct {
+		eventType string
+		id        types.Hash256
+	}{
+		{alerts.EventTypeRegistered, progress.ID},
+		{alerts.EventTypeRegistered, other.ID},
+		{alerts.EventTypeDismissed, progress.ID},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("expected %v events, got %v", len(expected), len(events))
+	}
+	for i, exp := range expected {
+		if events[i].Type != exp.eventType || events[i].Alert.ID != exp.id {
+			t.Fatalf("event %d: expected %v %v, got %v %v", i, exp.eventType, exp.id, events[i].Type, events[i].Alert.ID)
+		}
+	}
+	// the registration should carry the latest data
+	if events[0].Alert.Data["progress"] != 2*alertEventBuffer-1 {
+		t.Fatalf("expected latest progress, got %v", events[0].Alert.Data)
+	}
+
+	// distinct alerts should overflow the buffer
+	for i := 0; i <= alertEventBuffer; i++ {
+		sub.ReceiveAlertEvent(alerts.Event{Type: alerts.EventTypeRegistered, Alert: alerts.Alert{ID: frand.Entropy256()}})
+	}
+	if _, ok := sub.drain(); ok {
+		t.Fatal("expected overflow")
+	}
+}
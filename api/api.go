@@ -12,6 +12,10 @@ import (
 	"go.sia.tech/hostd/host/alerts"
 	"go.sia.tech/hostd/host/contracts"
 	"go.sia.tech/hostd/host/metrics"
+	"go.sia.tech/hostd/host/pricing"
+	"go.sia.tech/hostd/host/registry"
+	"go.sia.tech/hostd/host/reports"
+	"go.sia.tech/hostd/host/rules"
 	"go.sia.tech/hostd/host/settings"
 	"go.sia.tech/hostd/host/storage"
 	"go.sia.tech/hostd/rhp"
@@ -28,9 +32,16 @@ type (
 		ScanHeight() uint64
 		Balance() (spendable, confirmed, unconfirmed types.Currency, err error)
 		UnconfirmedTransactions() ([]wallet.Transaction, error)
+		LockedOutputs() ([]wallet.OutputLock, error)
+		ReleaseOutputs(ids ...types.SiacoinOutputID) error
 		FundTransaction(txn *types.Transaction, amount types.Currency) (toSign []types.Hash256, release func(), err error)
+		FundTransactionWithInputs(txn *types.Transaction, amount types.Currency, inputs []types.SiacoinOutputID) (toSign []types.Hash256, release func(), err error)
+		Redistribute(outputs int, amount, feePerByte types.Currency) (txn types.Transaction, toSign []types.Hash256, release func(), err error)
 		SignTransaction(cs consensus.State, txn *types.Transaction, toSign []types.Hash256, cf types.CoveredFields) error
 		Transactions(limit, offset int) ([]wallet.Transaction, error)
+		SearchTransactions(wallet.TransactionFilter) ([]wallet.Transaction, error)
+		SetTransactionLabel(id types.TransactionID, label string) error
+		Rescan(height uint64) error
 	}
 
 	// Settings updates and retrieves the host's settings
@@ -43,12 +54,35 @@ type (
 		UpdateDDNS(force bool) error
 	}
 
-	// Metrics retrieves metrics related to the host
+	// A PricingEngine adjusts the host's prices based on utilization
+	PricingEngine interface {
+		Config() pricing.Config
+		UpdateConfig(pricing.Config) error
+		PriceChanges(limit, offset int) ([]pricing.PriceChange, error)
+	}
+
+	// Metrics retrieves metrics related to the host. Spending from ephemeral
+	// accounts is journaled and applied to the metrics in batches, so account
+	// balances and revenue may lag behind RPCs by up to a quarter of a
+	// second.
 	Metrics interface {
 		// PeriodMetrics returns metrics for n periods starting at start.
 		PeriodMetrics(start time.Time, periods int, interval metrics.Interval) (period []metrics.Metrics, err error)
 		// Metrics returns aggregated metrics for the host as of the timestamp.
 		Metrics(time.Time) (m metrics.Metrics, err error)
+		// Forecast projects when the potential revenue of the host's
+		// contracts will be earned and their collateral released.
+		Forecast(height uint64, now time.Time, interval metrics.Interval, periods int, discount bool) (metrics.Forecast, error)
+	}
+
+	// Reports generates accounting reports
+	Reports interface {
+		// Revenue returns the revenue realized and the expenses incurred by
+		// the host in the range [from, to).
+		Revenue(from, to time.Time) (reports.Report, error)
+		// WalletSummary returns the wallet's inflows and outflows in the
+		// range [from, to) grouped by period and transaction source.
+		WalletSummary(from, to time.Time, interval metrics.Interval) (reports.WalletSummary, error)
 	}
 
 	// A VolumeManager manages the host's storage volumes
@@ -73,18 +107,53 @@ type (
 		// disk. The result of each sector checked is sent on the returned
 		// channel. Read errors are logged.
 		CheckIntegrity(ctx context.Context, contractID types.FileContractID) (<-chan contracts.IntegrityResult, uint64, error)
+
+		// ScanHeight returns the height of the last block processed by the
+		// contract manager.
+		ScanHeight() uint64
+		// Rescan rescans the blockchain beginning with the block at height.
+		Rescan(height uint64) error
 	}
 
 	// An AccountManager manages ephemeral accounts
 	AccountManager interface {
 		Accounts(limit, offset int) ([]accounts.Account, error)
 		AccountFunding(accountID rhp3.Account) ([]accounts.FundingSource, error)
+		AccountHistory(accountID rhp3.Account, limit, offset int) ([]accounts.LedgerEntry, error)
+		FilterAccounts(filter accounts.AccountFilter) ([]accounts.Account, int, error)
+		AuditLog(accountID rhp3.Account, limit, offset int) ([]accounts.AuditEntry, error)
+		SetFrozen(accountID rhp3.Account, frozen bool, reason string) error
+		SetMaxBalance(accountID rhp3.Account, maxBalance types.Currency, reason string) error
+		Expire(accountID rhp3.Account, reason string) (types.Currency, error)
+	}
+
+	// A RegistryManager manages the host's registry entries
+	RegistryManager interface {
+		Entries() (count uint64, total uint64, err error)
+		ListEntries(filter registry.EntryFilter) ([]registry.Entry, int, error)
+		Entry(key types.Hash256) (registry.Entry, error)
+		DeleteEntry(key types.Hash256) error
+		DeleteEntries(publicKey types.PublicKey) (int, error)
+		KeyUsage(limit, offset int) ([]registry.KeyUsage, error)
 	}
 
 	// Alerts retrieves and dismisses notifications
 	Alerts interface {
 		Active() []alerts.Alert
-		Dismiss(...types.Hash256)
+		DismissBy(by string, ids ...types.Hash256)
+		History(alerts.HistoryFilter) ([]alerts.HistoryAlert, int, error)
+
+		Subscribe(alerts.Subscriber)
+		Unsubscribe(alerts.Subscriber)
+	}
+
+	// AlertRules manages user-defined alert rules
+	AlertRules interface {
+		Rules() []rules.Rule
+		Rule(id int64) (rules.Rule, error)
+		AddRule(rules.Rule) (rules.Rule, error)
+		UpdateRule(rules.Rule) error
+		DeleteRule(id int64) error
 	}
 
 	// A Syncer can connect to other peers and synchronize the blockchain.
@@ -115,6 +184,19 @@ type (
 		Active() []rhp.Session
 	}
 
+	// A BanManager reports and lifts temporary bans of misbehaving peers
+	BanManager interface {
+		Bans() []rhp.Ban
+		Unban(peer string) error
+	}
+
+	// A Drainer drains the host's RHP sessions for planned maintenance
+	Drainer interface {
+		Drain(timeout time.Duration)
+		Resume()
+		State() rhp.DrainState
+	}
+
 	// An api provides an HTTP API for the host
 	api struct {
 		hostKey types.PublicKey
@@ -123,16 +205,22 @@ type (
 		log *zap.Logger
 
 		alerts    Alerts
+		rules     AlertRules
 		syncer    Syncer
 		chain     ChainManager
 		tpool     TPool
 		accounts  AccountManager
+		registry  RegistryManager
 		contracts ContractManager
 		volumes   VolumeManager
 		wallet    Wallet
 		metrics   Metrics
+		reports   Reports
 		settings  Settings
+		pricing   PricingEngine
 		sessions  RHPSessionReporter
+		bans      BanManager
+		drain     Drainer
 
 		volumeJobs volumeJobs
 		checks     integrityCheckJobs
@@ -140,22 +228,28 @@ type (
 )
 
 // NewServer initializes the API
-func NewServer(name string, hostKey types.PublicKey, a Alerts, g Syncer, chain ChainManager, tp TPool, cm ContractManager, am AccountManager, vm VolumeManager, rsr RHPSessionReporter, m Metrics, s Settings, w Wallet, log *zap.Logger) http.Handler {
+func NewServer(name string, hostKey types.PublicKey, a Alerts, ar AlertRules, g Syncer, chain ChainManager, tp TPool, cm ContractManager, am AccountManager, rm RegistryManager, vm VolumeManager, rsr RHPSessionReporter, bm BanManager, d Drainer, m Metrics, rp Reports, s Settings, pe PricingEngine, w Wallet, log *zap.Logger) http.Handler {
 	api := &api{
 		hostKey: hostKey,
 		name:    name,
 
 		alerts:    a,
+		rules:     ar,
 		syncer:    g,
 		chain:     chain,
 		tpool:     tp,
 		contracts: cm,
 		accounts:  am,
+		registry:  rm,
 		volumes:   vm,
 		metrics:   m,
+		reports:   rp,
 		settings:  s,
+		pricing:   pe,
 		wallet:    w,
 		sessions:  rsr,
+		bans:      bm,
+		drain:     d,
 		log:       log,
 
 		checks: integrityCheckJobs{
@@ -171,31 +265,63 @@ func NewServer(name string, hostKey types.PublicKey, a Alerts, g Syncer, chain C
 		// state endpoints
 		"GET /state/host":      api.handleGETHostState,
 		"GET /state/consensus": api.handleGETConsensusState,
+		"POST /state/drain":    api.handlePOSTDrain,
+		"DELETE /state/drain":  api.handleDELETEDrain,
 		// gateway endpoints
 		"GET /syncer/address":           api.handleGETSyncerAddr,
 		"GET /syncer/peers":             api.handleGETSyncerPeers,
 		"PUT /syncer/peers":             api.handlePUTSyncerPeer,
 		"DELETE /syncer/peers/:address": api.handleDeleteSyncerPeer,
 		// alerts endpoints
-		"GET /alerts":          api.handleGETAlerts,
-		"POST /alerts/dismiss": api.handlePOSTAlertsDismiss,
+		"GET /alerts":           api.handleGETAlerts,
+		"GET /alerts/history":   api.handleGETAlertHistory,
+		"GET /alerts/subscribe": api.handleGETAlertsSubscribe,
+		"POST /alerts/dismiss":  api.handlePOSTAlertsDismiss,
+		// alert rule endpoints
+		"GET /alerts/rules":        api.handleGETAlertRules,
+		"POST /alerts/rules":       api.handlePOSTAlertRules,
+		"GET /alerts/rules/:id":    api.handleGETAlertRule,
+		"PUT /alerts/rules/:id":    api.handlePUTAlertRule,
+		"DELETE /alerts/rules/:id": api.handleDELETEAlertRule,
 		// settings endpoints
 		"GET /settings":             api.handleGETSettings,
 		"PATCH /settings":           api.handlePATCHSettings,
 		"POST /settings/announce":   api.handlePOSTAnnounce,
 		"PUT /settings/ddns/update": api.handlePUTDDNSUpdate,
+		// pricing endpoints
+		"GET /settings/pricing":         api.handleGETPricing,
+		"PUT /settings/pricing":         api.handlePUTPricing,
+		"GET /settings/pricing/history": api.handleGETPricingHistory,
 		// metrics endpoints
 		"GET /metrics":         api.handleGETMetrics,
 		"GET /metrics/:period": api.handleGETPeriodMetrics,
+		// report endpoints
+		"GET /reports/revenue": api.handleGETRevenueReport,
+		"GET /reports/wallet":  api.handleGETWalletReport,
 		// contract endpoints
 		"POST /contracts":                 api.handlePostContracts,
+		"POST /contracts/rescan":          api.handlePOSTContractsRescan,
 		"GET /contracts/:id":              api.handleGETContract,
 		"GET /contracts/:id/integrity":    api.handleGETContractCheck,
 		"PUT /contracts/:id/integrity":    api.handlePUTContractCheck,
 		"DELETE /contracts/:id/integrity": api.handleDeleteContractCheck,
 		// account endpoints
-		"GET /accounts":                  api.handleGETAccounts,
-		"GET /accounts/:account/funding": api.handleGETAccountFunding,
+		"GET /accounts":                     api.handleGETAccounts,
+		"POST /accounts":                    api.handlePOSTAccounts,
+		"GET /accounts/:account/funding":    api.handleGETAccountFunding,
+		"GET /accounts/:account/history":    api.handleGETAccountHistory,
+		"GET /accounts/:account/audit":      api.handleGETAccountAudit,
+		"PUT /accounts/:account/frozen":     api.handlePUTAccountFrozen,
+		"PUT /accounts/:account/maxbalance": api.handlePUTAccountMaxBalance,
+		"POST /accounts/:account/expire":    api.handlePOSTAccountExpire,
+		// registry endpoints
+		"GET /registry":                    api.handleGETRegistry,
+		"POST /registry/entries":           api.handlePOSTRegistryEntries,
+		"GET /registry/entries/:key":       api.handleGETRegistryEntry,
+		"DELETE /registry/entries/:key":    api.handleDELETERegistryEntry,
+		"GET /registry/keys":               api.handleGETRegistryKeys,
+		"DELETE /registry/keys/:publickey": api.handleDELETERegistryKey,
+		"GET /registry/export":             api.handleGETRegistryExport,
 		// sector endpoints
 		"DELETE /sectors/:root": api.handleDeleteSector,
 		// volume endpoints
@@ -209,13 +335,23 @@ func NewServer(name string, hostKey types.PublicKey, a Alerts, g Syncer, chain C
 		// session endpoints
 		"GET /sessions":           api.handleGETSessions,
 		"GET /sessions/subscribe": api.handleGETSessionsSubscribe,
+		// ban endpoints
+		"GET /bans":          api.handleGETBans,
+		"DELETE /bans/:peer": api.handleDELETEBan,
 		// tpool endpoints
 		"GET /tpool/fee": api.handleGETTPoolFee,
 		// wallet endpoints
-		"GET /wallet":              api.handleGETWallet,
-		"GET /wallet/transactions": api.handleGETWalletTransactions,
-		"GET /wallet/pending":      api.handleGETWalletPending,
-		"POST /wallet/send":        api.handlePOSTWalletSend,
+		"GET /wallet":                        api.handleGETWallet,
+		"GET /wallet/transactions":           api.handleGETWalletTransactions,
+		"PUT /wallet/transactions/:id/label": api.handlePUTWalletTransactionLabel,
+		"GET /wallet/pending":                api.handleGETWalletPending,
+		"GET /wallet/locked":                 api.handleGETWalletLocked,
+		"POST /wallet/send":                  api.handlePOSTWalletSend,
+		"POST /wallet/prepare":               api.handlePOSTWalletPrepare,
+		"POST /wallet/release":               api.handlePOSTWalletRelease,
+		"POST /wallet/broadcast":             api.handlePOSTWalletBroadcast,
+		"POST /wallet/redistribute":          api.handlePOSTWalletRedistribute,
+		"POST /wallet/rescan":                api.handlePOSTWalletRescan,
 		// system endpoints
 		"GET /system/dir": api.handleGETSystemDir,
 		"PUT /system/dir": api.handlePUTSystemDir,
@@ -0,0 +1,72 @@
+package api_test
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/api"
+	"go.sia.tech/hostd/rhp"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestBans(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	bm := rhp.NewBanManager(log.Named("bans"))
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	srv := api.NewServer("", types.PublicKey{}, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, bm, nil, nil, nil, nil, nil, nil, log.Named("api"))
+	go http.Serve(l, srv)
+	client := api.NewClient("http://"+l.Addr().String(), "")
+
+	if bans, err := client.Bans(); err != nil {
+		t.Fatal(err)
+	} else if len(bans) != 0 {
+		t.Fatalf("expected no bans, got %v", len(bans))
+	}
+
+	// ban a peer by IP and a renter by public key
+	const peerIP = "192.0.2.1"
+	renterKey := types.GeneratePrivateKey().PublicKey().String()
+	reason := errors.New("invalid signature")
+	for i := 0; i < 5; i++ {
+		bm.RecordOffense(reason, peerIP, renterKey)
+	}
+
+	bans, err := client.Bans()
+	if err != nil {
+		t.Fatal(err)
+	} else if len(bans) != 2 {
+		t.Fatalf("expected 2 bans, got %v", len(bans))
+	}
+	for _, ban := range bans {
+		if ban.Peer != peerIP && ban.Peer != renterKey {
+			t.Fatalf("unexpected banned peer %q", ban.Peer)
+		} else if ban.Reason != reason.Error() {
+			t.Fatalf("expected reason %q, got %q", reason, ban.Reason)
+		}
+	}
+
+	// lift both bans
+	if err := client.Unban(peerIP); err != nil {
+		t.Fatal(err)
+	} else if err := client.Unban(renterKey); err != nil {
+		t.Fatal(err)
+	} else if bans, err := client.Bans(); err != nil {
+		t.Fatal(err)
+	} else if len(bans) != 0 {
+		t.Fatalf("expected no bans, got %v", len(bans))
+	}
+
+	// lifting a ban that does not exist should fail
+	if err := client.Unban(peerIP); err == nil || !strings.Contains(err.Error(), rhp.ErrNotBanned.Error()) {
+		t.Fatalf("expected %q, got %v", rhp.ErrNotBanned, err)
+	}
+}
@@ -4,13 +4,22 @@ import (
 	"fmt"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
+	rhp3 "go.sia.tech/core/rhp/v3"
 	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/host/accounts"
+	"go.sia.tech/hostd/host/alerts"
 	"go.sia.tech/hostd/host/contracts"
 	"go.sia.tech/hostd/host/metrics"
+	"go.sia.tech/hostd/host/pricing"
+	"go.sia.tech/hostd/host/registry"
+	"go.sia.tech/hostd/host/reports"
+	"go.sia.tech/hostd/host/rules"
 	"go.sia.tech/hostd/host/settings"
 	"go.sia.tech/hostd/host/storage"
+	"go.sia.tech/hostd/rhp"
 	"go.sia.tech/hostd/wallet"
 	"go.sia.tech/jape"
 )
@@ -81,7 +90,29 @@ func (c *Client) TestDDNS() error {
 	return c.c.PUT("/settings/ddns/update", nil)
 }
 
-// Metrics returns the metrics of the host at the specified time.
+// PricingConfig returns the configuration of the host's dynamic pricing
+// engine.
+func (c *Client) PricingConfig() (config pricing.Config, err error) {
+	err = c.c.GET("/settings/pricing", &config)
+	return
+}
+
+// UpdatePricingConfig updates the configuration of the host's dynamic
+// pricing engine.
+func (c *Client) UpdatePricingConfig(config pricing.Config) error {
+	return c.c.PUT("/settings/pricing", config)
+}
+
+// PriceChanges returns the price adjustments made by the host's dynamic
+// pricing engine, ordered from newest to oldest.
+func (c *Client) PriceChanges(limit, offset int) (changes []pricing.PriceChange, err error) {
+	err = c.c.GET(fmt.Sprintf("/settings/pricing/history?limit=%d&offset=%d", limit, offset), &changes)
+	return
+}
+
+// Metrics returns the metrics of the host at the specified time. Ephemeral
+// account spending is applied to the metrics in batches and may take up to a
+// quarter of a second to be reflected.
 func (c *Client) Metrics(at time.Time) (metrics metrics.Metrics, err error) {
 	v := url.Values{
 		"timestamp": []string{at.Format(time.RFC3339)},
@@ -90,6 +121,44 @@ func (c *Client) Metrics(at time.Time) (metrics metrics.Metrics, err error) {
 	return
 }
 
+// MetricsForecast returns the revenue expected to be earned and the collateral
+// expected to be released by the host's contracts for n periods. If n is 0,
+// enough periods are returned to include every contract.
+func (c *Client) MetricsForecast(interval metrics.Interval, n int, discount bool) (forecast metrics.Forecast, err error) {
+	v := url.Values{
+		"interval": []string{interval.String()},
+		"periods":  []string{strconv.Itoa(n)},
+		"discount": []string{strconv.FormatBool(discount)},
+	}
+	err = c.c.GET("/metrics/forecast?"+v.Encode(), &forecast)
+	return
+}
+
+// RevenueReport returns the revenue realized and the expenses incurred by the
+// host in the range [from, to).
+func (c *Client) RevenueReport(from, to time.Time) (report reports.Report, err error) {
+	v := url.Values{
+		"from":   []string{from.Format(time.RFC3339)},
+		"to":     []string{to.Format(time.RFC3339)},
+		"format": []string{"json"},
+	}
+	err = c.c.GET("/reports/revenue?"+v.Encode(), &report)
+	return
+}
+
+// WalletReport returns the wallet's inflows and outflows in the range
+// [from, to) grouped by period and transaction source.
+func (c *Client) WalletReport(from, to time.Time, interval metrics.Interval) (summary reports.WalletSummary, err error) {
+	v := url.Values{
+		"from":     []string{from.Format(time.RFC3339)},
+		"to":       []string{to.Format(time.RFC3339)},
+		"interval": []string{interval.String()},
+		"format":   []string{"json"},
+	}
+	err = c.c.GET("/reports/wallet?"+v.Encode(), &summary)
+	return
+}
+
 // PeriodMetrics returns the metrics of the host for n periods starting at start.
 func (c *Client) PeriodMetrics(start time.Time, n int, interval metrics.Interval) (periods []metrics.Metrics, err error) {
 	v := url.Values{
@@ -131,6 +200,151 @@ func (c *Client) DeleteIntegrityCheck(id types.FileContractID) error {
 	return c.c.DELETE(fmt.Sprintf("/contracts/%v/integrity", id))
 }
 
+// RescanContracts rescans the blockchain beginning with the block at height
+// to update the host's contracts.
+func (c *Client) RescanContracts(height uint64) error {
+	return c.c.POST("/contracts/rescan", RescanRequest{Height: height}, nil)
+}
+
+// AccountHistory returns the ledger entries of the specified ephemeral
+// account, newest first.
+func (c *Client) AccountHistory(account rhp3.Account, limit, offset int) (entries []accounts.LedgerEntry, err error) {
+	err = c.c.GET(fmt.Sprintf("/accounts/%v/history?limit=%d&offset=%d", account, limit, offset), &entries)
+	return
+}
+
+// Accounts returns the ephemeral accounts of the host matching the filter.
+func (c *Client) Accounts(filter accounts.AccountFilter) ([]accounts.Account, int, error) {
+	var resp AccountsResponse
+	err := c.c.POST("/accounts", filter, &resp)
+	return resp.Accounts, resp.Count, err
+}
+
+// AccountAuditLog returns the admin actions performed on the specified
+// ephemeral account, newest first.
+func (c *Client) AccountAuditLog(account rhp3.Account, limit, offset int) (entries []accounts.AuditEntry, err error) {
+	err = c.c.GET(fmt.Sprintf("/accounts/%v/audit?limit=%d&offset=%d", account, limit, offset), &entries)
+	return
+}
+
+// SetAccountFrozen freezes or unfreezes the specified ephemeral account.
+func (c *Client) SetAccountFrozen(account rhp3.Account, frozen bool, reason string) error {
+	return c.c.PUT(fmt.Sprintf("/accounts/%v/frozen", account), AccountFrozenRequest{Frozen: frozen, Reason: reason})
+}
+
+// SetAccountMaxBalance overrides the maximum balance of the specified
+// ephemeral account. A zero max balance removes the override.
+func (c *Client) SetAccountMaxBalance(account rhp3.Account, maxBalance types.Currency, reason string) error {
+	return c.c.PUT(fmt.Sprintf("/accounts/%v/maxbalance", account), AccountMaxBalanceRequest{MaxBalance: maxBalance, Reason: reason})
+}
+
+// ExpireAccount immediately expires the specified ephemeral account and
+// returns the removed balance.
+func (c *Client) ExpireAccount(account rhp3.Account, reason string) (types.Currency, error) {
+	var resp AccountExpireResponse
+	err := c.c.POST(fmt.Sprintf("/accounts/%v/expire", account), AccountExpireRequest{Reason: reason}, &resp)
+	return resp.Removed, err
+}
+
+// Registry returns the current and maximum number of registry entries.
+func (c *Client) Registry() (resp RegistryResponse, err error) {
+	err = c.c.GET("/registry", &resp)
+	return
+}
+
+// RegistryEntries returns the registry entries matching the filter and the
+// total number of matching entries.
+func (c *Client) RegistryEntries(filter registry.EntryFilter) ([]registry.Entry, int, error) {
+	var resp RegistryEntriesResponse
+	err := c.c.POST("/registry/entries", filter, &resp)
+	return resp.Entries, resp.Count, err
+}
+
+// Alerts returns the host's active alerts.
+func (c *Client) Alerts() (active []alerts.Alert, err error) {
+	err = c.c.GET("/alerts", &active)
+	return
+}
+
+// DismissAlerts dismisses the alerts with the given IDs and records who
+// dismissed them.
+func (c *Client) DismissAlerts(by string, ids ...types.Hash256) error {
+	return c.c.POST("/alerts/dismiss?by="+url.QueryEscape(by), ids, nil)
+}
+
+// AlertHistory returns the alerts matching the filter, including dismissed
+// alerts, and the total number of matching alerts.
+func (c *Client) AlertHistory(filter alerts.HistoryFilter) ([]alerts.HistoryAlert, int, error) {
+	v := url.Values{
+		"limit":  []string{strconv.Itoa(filter.Limit)},
+		"offset": []string{strconv.Itoa(filter.Offset)},
+	}
+	if filter.Severity != 0 {
+		v.Set("severity", filter.Severity.String())
+	}
+	if !filter.From.IsZero() {
+		v.Set("from", filter.From.Format(time.RFC3339))
+	}
+	if !filter.To.IsZero() {
+		v.Set("to", filter.To.Format(time.RFC3339))
+	}
+	var resp AlertHistoryResponse
+	err := c.c.GET("/alerts/history?"+v.Encode(), &resp)
+	return resp.Alerts, resp.Count, err
+}
+
+// AlertRules returns the host's alert rules.
+func (c *Client) AlertRules() (alertRules []rules.Rule, err error) {
+	err = c.c.GET("/alerts/rules", &alertRules)
+	return
+}
+
+// AlertRule returns the alert rule with the given ID.
+func (c *Client) AlertRule(id int64) (rule rules.Rule, err error) {
+	err = c.c.GET(fmt.Sprintf("/alerts/rules/%d", id), &rule)
+	return
+}
+
+// AddAlertRule adds an alert rule and returns it with its assigned ID.
+func (c *Client) AddAlertRule(rule rules.Rule) (added rules.Rule, err error) {
+	err = c.c.POST("/alerts/rules", rule, &added)
+	return
+}
+
+// UpdateAlertRule replaces the alert rule with the rule's ID.
+func (c *Client) UpdateAlertRule(rule rules.Rule) error {
+	return c.c.PUT(fmt.Sprintf("/alerts/rules/%d", rule.ID), rule)
+}
+
+// DeleteAlertRule removes the alert rule with the given ID.
+func (c *Client) DeleteAlertRule(id int64) error {
+	return c.c.DELETE(fmt.Sprintf("/alerts/rules/%d", id))
+}
+
+// RegistryEntry returns the registry entry with the specified key.
+func (c *Client) RegistryEntry(key types.Hash256) (entry registry.Entry, err error) {
+	err = c.c.GET(fmt.Sprintf("/registry/entries/%v", key), &entry)
+	return
+}
+
+// DeleteRegistryEntry removes the registry entry with the specified key.
+func (c *Client) DeleteRegistryEntry(key types.Hash256) error {
+	return c.c.DELETE(fmt.Sprintf("/registry/entries/%v", key))
+}
+
+// RegistryKeyUsage returns the number of registry entries owned by each
+// public key.
+func (c *Client) RegistryKeyUsage(limit, offset int) (usage []registry.KeyUsage, err error) {
+	err = c.c.GET(fmt.Sprintf("/registry/keys?limit=%d&offset=%d", limit, offset), &usage)
+	return
+}
+
+// DeleteRegistryEntries removes all registry entries owned by the specified
+// public key.
+func (c *Client) DeleteRegistryEntries(publicKey types.PublicKey) error {
+	return c.c.DELETE(fmt.Sprintf("/registry/keys/%v", publicKey))
+}
+
 // DeleteSector deletes the sector with the specified root. This can cause
 // contract failures if the sector is still in use.
 func (c *Client) DeleteSector(root types.Hash256) error {
@@ -177,6 +391,30 @@ func (c *Client) ResizeVolume(id int, sectors uint64) error {
 	return c.c.PUT(fmt.Sprintf("/volumes/%v/resize", id), req)
 }
 
+// Drain stops the host from accepting new RHP sessions, contract formations
+// and renewals. Existing sessions have until timeout to finish.
+func (c *Client) Drain(timeout time.Duration) error {
+	return c.c.POST("/state/drain", DrainRequest{Timeout: timeout}, nil)
+}
+
+// ResumeSessions stops draining the host and resumes accepting new RHP
+// sessions.
+func (c *Client) ResumeSessions() error {
+	return c.c.DELETE("/state/drain")
+}
+
+// Bans returns the peers that are currently banned.
+func (c *Client) Bans() (bans []rhp.Ban, err error) {
+	err = c.c.GET("/bans", &bans)
+	return
+}
+
+// Unban lifts the ban of the specified peer. Peers are identified by either
+// their IP address or their public key.
+func (c *Client) Unban(peer string) error {
+	return c.c.DELETE("/bans/" + url.PathEscape(peer))
+}
+
 // Wallet returns the state of the host's wallet.
 func (c *Client) Wallet() (resp WalletResponse, err error) {
 	err = c.c.GET("/wallet", &resp)
@@ -195,6 +433,19 @@ func (c *Client) PendingTransactions() (transactions []wallet.Transaction, err e
 	return
 }
 
+// LockedOutputs returns the outputs locked by the host's wallet and the reason
+// for each lock.
+func (c *Client) LockedOutputs() (locks []wallet.OutputLock, err error) {
+	err = c.c.GET("/wallet/locked", &locks)
+	return
+}
+
+// ReleaseOutputs releases the wallet's locks on the outputs, such as the
+// inputs of a prepared transaction that will not be broadcast.
+func (c *Client) ReleaseOutputs(ids ...types.SiacoinOutputID) error {
+	return c.c.POST("/wallet/release", WalletReleaseRequest{Outputs: ids}, nil)
+}
+
 // SendSiacoins sends siacoins to the specified address. If subtractFee is true,
 // the miner fee is subtracted from the amount.
 func (c *Client) SendSiacoins(address types.Address, amount types.Currency, subtractFee bool) (id types.TransactionID, err error) {
@@ -207,6 +458,96 @@ func (c *Client) SendSiacoins(address types.Address, amount types.Currency, subt
 	return
 }
 
+// SendSiacoinsWithOptions sends siacoins using the options in the request,
+// such as multiple outputs, explicit inputs, a custom fee, or a label.
+func (c *Client) SendSiacoinsWithOptions(req WalletSendSiacoinsRequest) (id types.TransactionID, err error) {
+	err = c.c.POST("/wallet/send", req, &id)
+	return
+}
+
+// SearchTransactions returns the wallet transactions matching the filter.
+func (c *Client) SearchTransactions(filter wallet.TransactionFilter) (transactions []wallet.Transaction, err error) {
+	v := url.Values{
+		"limit":  []string{strconv.Itoa(filter.Limit)},
+		"offset": []string{strconv.Itoa(filter.Offset)},
+	}
+	if filter.Label != "" {
+		v.Set("label", filter.Label)
+	}
+	if len(filter.Sources) > 0 {
+		sources := make([]string, len(filter.Sources))
+		for i, source := range filter.Sources {
+			sources[i] = string(source)
+		}
+		v.Set("source", strings.Join(sources, ","))
+	}
+	if filter.Direction != "" {
+		v.Set("direction", string(filter.Direction))
+	}
+	if filter.MinHeight > 0 {
+		v.Set("minHeight", strconv.FormatUint(filter.MinHeight, 10))
+	}
+	if filter.MaxHeight > 0 {
+		v.Set("maxHeight", strconv.FormatUint(filter.MaxHeight, 10))
+	}
+	if !filter.After.IsZero() {
+		v.Set("after", filter.After.Format(time.RFC3339))
+	}
+	if !filter.Before.IsZero() {
+		v.Set("before", filter.Before.Format(time.RFC3339))
+	}
+	err = c.c.GET("/wallet/transactions?"+v.Encode(), &transactions)
+	return
+}
+
+// SetTransactionLabel sets the label of a wallet transaction.
+func (c *Client) SetTransactionLabel(id types.TransactionID, label string) error {
+	return c.c.PUT("/wallet/transactions/"+id.String()+"/label", WalletTransactionLabelRequest{Label: label})
+}
+
+// PrepareSiacoins funds an unsigned transaction sending siacoins to the
+// specified address. The transaction can be signed offline and submitted with
+// Broadcast. If subtractFee is true, the miner fee is subtracted from the
+// amount.
+func (c *Client) PrepareSiacoins(address types.Address, amount types.Currency, subtractFee bool) (txn wallet.UnsignedTransaction, err error) {
+	req := WalletSendSiacoinsRequest{
+		Address:          address,
+		Amount:           amount,
+		SubtractMinerFee: subtractFee,
+	}
+	err = c.c.POST("/wallet/prepare", req, &txn)
+	return
+}
+
+// Broadcast broadcasts a signed transaction to the network. If label is not
+// empty, it is stored with the transaction once it is broadcast.
+func (c *Client) Broadcast(txn types.Transaction, label string) (id types.TransactionID, err error) {
+	path := "/wallet/broadcast"
+	if label != "" {
+		path += "?" + url.Values{"label": []string{label}}.Encode()
+	}
+	err = c.c.POST(path, txn, &id)
+	return
+}
+
+// Redistribute splits the wallet's balance into outputs worth amount until the
+// wallet has the requested number of outputs.
+func (c *Client) Redistribute(outputs int, amount types.Currency) (id types.TransactionID, err error) {
+	req := WalletRedistributeRequest{
+		Outputs: outputs,
+		Amount:  amount,
+	}
+	err = c.c.POST("/wallet/redistribute", req, &id)
+	return
+}
+
+// RescanWallet resets the wallet and rescans the blockchain beginning with the
+// block at height. The height must not be above the first block relevant to
+// the wallet.
+func (c *Client) RescanWallet(height uint64) error {
+	return c.c.POST("/wallet/rescan", RescanRequest{Height: height}, nil)
+}
+
 // LocalDir returns the contents of the specified directory on the host.
 func (c *Client) LocalDir(path string) (resp SystemDirResponse, err error) {
 	v := url.Values{
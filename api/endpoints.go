@@ -1,6 +1,7 @@
 package api
 
 import (
+	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -8,22 +9,38 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"time"
 
 	rhp3 "go.sia.tech/core/rhp/v3"
 	"go.sia.tech/core/types"
 	"go.sia.tech/hostd/build"
+	"go.sia.tech/hostd/host/accounts"
+	"go.sia.tech/hostd/host/alerts"
 	"go.sia.tech/hostd/host/contracts"
 	"go.sia.tech/hostd/host/metrics"
+	"go.sia.tech/hostd/host/pricing"
+	"go.sia.tech/hostd/host/registry"
+	"go.sia.tech/hostd/host/rules"
 	"go.sia.tech/hostd/host/settings"
 	"go.sia.tech/hostd/host/storage"
 	"go.sia.tech/hostd/internal/disk"
+	"go.sia.tech/hostd/rhp"
+	"go.sia.tech/hostd/wallet"
 	"go.sia.tech/jape"
 	"go.sia.tech/siad/modules"
 	"go.uber.org/zap"
 )
 
-const stdTxnSize = 1200 // bytes
+const (
+	stdTxnSize    = 1200 // bytes
+	stdOutputSize = 64   // bytes
+	stdInputSize  = 250  // bytes, including the input's signature
+
+	// defaultDrainTimeout is the time existing sessions have to finish
+	// before they are rejected if no timeout is specified.
+	defaultDrainTimeout = 10 * time.Minute
+)
 
 var startTime = time.Now()
 
@@ -43,6 +60,7 @@ func (a *api) handleGETHostState(c jape.Context) {
 		PublicKey:     a.hostKey,
 		WalletAddress: a.wallet.Address(),
 		StartTime:     startTime,
+		Drain:         a.drain.State(),
 		BuildState: BuildState{
 			Network:   build.NetworkName(),
 			Version:   build.Version(),
@@ -53,10 +71,30 @@ func (a *api) handleGETHostState(c jape.Context) {
 	})
 }
 
+func (a *api) handlePOSTDrain(c jape.Context) {
+	var req DrainRequest
+	if err := c.Decode(&req); err != nil {
+		return
+	} else if req.Timeout < 0 {
+		c.Error(errors.New("timeout must be positive"), http.StatusBadRequest)
+		return
+	} else if req.Timeout == 0 {
+		req.Timeout = defaultDrainTimeout
+	}
+	a.drain.Drain(req.Timeout)
+}
+
+func (a *api) handleDELETEDrain(c jape.Context) {
+	a.drain.Resume()
+}
+
 func (a *api) handleGETConsensusState(c jape.Context) {
 	c.Encode(ConsensusState{
 		Synced:     a.chain.Synced(),
 		ChainIndex: a.chain.TipState().Index,
+
+		WalletScanHeight:   a.wallet.ScanHeight(),
+		ContractScanHeight: a.contracts.ScanHeight(),
 	})
 }
 
@@ -98,15 +136,118 @@ func (a *api) handleGETAlerts(c jape.Context) {
 	c.Encode(a.alerts.Active())
 }
 
+func (a *api) handleGETAlertHistory(c jape.Context) {
+	var filter alerts.HistoryFilter
+	if err := c.DecodeForm("severity", &filter.Severity); err != nil {
+		return
+	} else if err := c.DecodeForm("from", &filter.From); err != nil {
+		return
+	} else if err := c.DecodeForm("to", &filter.To); err != nil {
+		return
+	} else if err := c.DecodeForm("limit", &filter.Limit); err != nil {
+		return
+	} else if err := c.DecodeForm("offset", &filter.Offset); err != nil {
+		return
+	} else if err := filter.Validate(); err != nil {
+		c.Error(err, http.StatusBadRequest)
+		return
+	}
+
+	history, count, err := a.alerts.History(filter)
+	if !a.checkServerError(c, "failed to get alert history", err) {
+		return
+	}
+	c.Encode(AlertHistoryResponse{
+		Count:  count,
+		Alerts: history,
+	})
+}
+
 func (a *api) handlePOSTAlertsDismiss(c jape.Context) {
+	// the dismisser is optional for compatibility with existing clients
+	by := "api"
 	var ids []types.Hash256
-	if err := c.Decode(&ids); err != nil {
+	if err := c.DecodeForm("by", &by); err != nil {
+		return
+	} else if err := c.Decode(&ids); err != nil {
 		return
 	} else if len(ids) == 0 {
 		c.Error(errors.New("no alerts to dismiss"), http.StatusBadRequest)
 		return
 	}
-	a.alerts.Dismiss(ids...)
+	a.alerts.DismissBy(by, ids...)
+}
+
+func (a *api) handleGETAlertRules(c jape.Context) {
+	c.Encode(a.rules.Rules())
+}
+
+func (a *api) handlePOSTAlertRules(c jape.Context) {
+	var rule rules.Rule
+	if err := c.Decode(&rule); err != nil {
+		return
+	} else if err := rule.Validate(); err != nil {
+		c.Error(err, http.StatusBadRequest)
+		return
+	}
+
+	rule, err := a.rules.AddRule(rule)
+	if !a.checkServerError(c, "failed to add alert rule", err) {
+		return
+	}
+	c.Encode(rule)
+}
+
+func (a *api) handleGETAlertRule(c jape.Context) {
+	var id int64
+	if err := c.DecodeParam("id", &id); err != nil {
+		return
+	}
+
+	rule, err := a.rules.Rule(id)
+	if errors.Is(err, rules.ErrRuleNotFound) {
+		c.Error(err, http.StatusNotFound)
+		return
+	} else if !a.checkServerError(c, "failed to get alert rule", err) {
+		return
+	}
+	c.Encode(rule)
+}
+
+func (a *api) handlePUTAlertRule(c jape.Context) {
+	var id int64
+	var rule rules.Rule
+	if err := c.DecodeParam("id", &id); err != nil {
+		return
+	} else if err := c.Decode(&rule); err != nil {
+		return
+	}
+	rule.ID = id
+	if err := rule.Validate(); err != nil {
+		c.Error(err, http.StatusBadRequest)
+		return
+	}
+
+	err := a.rules.UpdateRule(rule)
+	if errors.Is(err, rules.ErrRuleNotFound) {
+		c.Error(err, http.StatusNotFound)
+		return
+	}
+	a.checkServerError(c, "failed to update alert rule", err)
+}
+
+func (a *api) handleDELETEAlertRule(c jape.Context) {
+	var id int64
+	if err := c.DecodeParam("id", &id); err != nil {
+		return
+	}
+
+	err := a.rules.DeleteRule(id)
+	if errors.Is(err, rules.ErrRuleNotFound) {
+		c.Error(err, http.StatusNotFound)
+		return
+	}
+	a.checkServerError(c, "failed to delete alert rule", err)
 }
 
 func (a *api) handlePOSTAnnounce(c jape.Context) {
@@ -166,6 +307,31 @@ func (a *api) handlePUTDDNSUpdate(c jape.Context) {
 	a.checkServerError(c, "failed to update dynamic DNS", err)
 }
 
+func (a *api) handleGETPricing(c jape.Context) {
+	c.Encode(a.pricing.Config())
+}
+
+func (a *api) handlePUTPricing(c jape.Context) {
+	var config pricing.Config
+	if err := c.Decode(&config); err != nil {
+		return
+	} else if err := config.Validate(); err != nil {
+		c.Error(err, http.StatusBadRequest)
+		return
+	}
+	err := a.pricing.UpdateConfig(config)
+	a.checkServerError(c, "failed to update pricing config", err)
+}
+
+func (a *api) handleGETPricingHistory(c jape.Context) {
+	limit, offset := parseLimitParams(c, 100, 500)
+	changes, err := a.pricing.PriceChanges(limit, offset)
+	if !a.checkServerError(c, "failed to get price changes", err) {
+		return
+	}
+	c.Encode(changes)
+}
+
 func (a *api) handleGETMetrics(c jape.Context) {
 	var timestamp time.Time
 	if err := c.DecodeForm("timestamp", &timestamp); err != nil {
@@ -181,7 +347,42 @@ func (a *api) handleGETMetrics(c jape.Context) {
 	c.Encode(metrics)
 }
 
+func (a *api) handleGETMetricsForecast(c jape.Context) {
+	interval := metrics.IntervalDaily
+	var periods int
+	var discount bool
+	if err := c.DecodeForm("interval", &interval); err != nil {
+		return
+	} else if err := c.DecodeForm("periods", &periods); err != nil {
+		return
+	} else if err := c.DecodeForm("discount", &discount); err != nil {
+		return
+	}
+
+	switch {
+	case interval != metrics.IntervalDaily && interval != metrics.IntervalWeekly && interval != metrics.IntervalMonthly:
+		c.Error(fmt.Errorf("unsupported interval %q", interval), http.StatusBadRequest)
+		return
+	case periods < 0:
+		c.Error(errors.New("periods must be positive"), http.StatusBadRequest)
+		return
+	}
+
+	forecast, err := a.metrics.Forecast(a.chain.TipState().Index.Height, time.Now(), interval, periods, discount)
+	if !a.checkServerError(c, "failed to get forecast", err) {
+		return
+	}
+	c.Encode(forecast)
+}
+
 func (a *api) handleGETPeriodMetrics(c jape.Context) {
+	// the router does not allow a static route to share a segment with a
+	// parameter
+	if c.PathParam("period") == "forecast" {
+		a.handleGETMetricsForecast(c)
+		return
+	}
+
 	var interval metrics.Interval
 	if err := c.DecodeParam("period", &interval); err != nil {
 		return
@@ -276,6 +477,17 @@ func (a *api) handleGETContract(c jape.Context) {
 	c.Encode(contract)
 }
 
+func (a *api) handlePOSTContractsRescan(c jape.Context) {
+	var req RescanRequest
+	if err := c.Decode(&req); err != nil {
+		return
+	} else if tip := a.chain.TipState().Index.Height; req.Height > tip {
+		c.Error(fmt.Errorf("rescan height %d is above the current height %d", req.Height, tip), http.StatusBadRequest)
+		return
+	}
+	a.checkServerError(c, "failed to rescan contracts", a.contracts.Rescan(req.Height))
+}
+
 func (a *api) handleGETVolume(c jape.Context) {
 	var id int
 	if err := c.DecodeParam("id", &id); err != nil {
@@ -326,6 +538,23 @@ func (a *api) handleDeleteSector(c jape.Context) {
 	a.checkServerError(c, "failed to remove sector", err)
 }
 
+func (a *api) handleGETBans(c jape.Context) {
+	c.Encode(a.bans.Bans())
+}
+
+func (a *api) handleDELETEBan(c jape.Context) {
+	var peer string
+	if err := c.DecodeParam("peer", &peer); err != nil {
+		return
+	}
+	err := a.bans.Unban(peer)
+	if errors.Is(err, rhp.ErrNotBanned) {
+		c.Error(err, http.StatusNotFound)
+		return
+	}
+	a.checkServerError(c, "failed to lift ban", err)
+}
+
 func (a *api) handleGETWallet(c jape.Context) {
 	spendable, confirmed, unconfirmed, err := a.wallet.Balance()
 	if !a.checkServerError(c, "failed to get wallet", err) {
@@ -343,11 +572,91 @@ func (a *api) handleGETWallet(c jape.Context) {
 func (a *api) handleGETWalletTransactions(c jape.Context) {
 	limit, offset := parseLimitParams(c, 100, 500)
 
-	transactions, err := a.wallet.Transactions(limit, offset)
+	filter := wallet.TransactionFilter{
+		Limit:  limit,
+		Offset: offset,
+	}
+	var sources, direction string
+	var minHeight, maxHeight int
+	format := reportFormatJSON
+	if err := c.DecodeForm("label", &filter.Label); err != nil {
+		return
+	} else if err := c.DecodeForm("source", &sources); err != nil {
+		return
+	} else if err := c.DecodeForm("direction", &direction); err != nil {
+		return
+	} else if err := c.DecodeForm("minHeight", &minHeight); err != nil {
+		return
+	} else if err := c.DecodeForm("maxHeight", &maxHeight); err != nil {
+		return
+	} else if err := c.DecodeForm("after", &filter.After); err != nil {
+		return
+	} else if err := c.DecodeForm("before", &filter.Before); err != nil {
+		return
+	} else if err := c.DecodeForm("format", &format); err != nil {
+		return
+	}
+
+	if sources != "" {
+		for _, source := range strings.Split(sources, ",") {
+			switch source := wallet.TransactionSource(source); source {
+			case wallet.TxnSourceTransaction, wallet.TxnSourceMinerPayout, wallet.TxnSourceSiafundClaim, wallet.TxnSourceContract, wallet.TxnSourceFoundationPayout:
+				filter.Sources = append(filter.Sources, source)
+			default:
+				c.Error(fmt.Errorf("unknown source %q", source), http.StatusBadRequest)
+				return
+			}
+		}
+	}
+	filter.Direction = wallet.TransactionDirection(direction)
+
+	switch {
+	case filter.Direction != "" && filter.Direction != wallet.TxnDirectionIncoming && filter.Direction != wallet.TxnDirectionOutgoing:
+		c.Error(fmt.Errorf("unknown direction %q", direction), http.StatusBadRequest)
+		return
+	case minHeight < 0 || maxHeight < 0:
+		c.Error(errors.New("heights must be positive"), http.StatusBadRequest)
+		return
+	case maxHeight > 0 && minHeight > maxHeight:
+		c.Error(errors.New("minHeight must not be greater than maxHeight"), http.StatusBadRequest)
+		return
+	case !filter.After.IsZero() && !filter.Before.IsZero() && !filter.After.Before(filter.Before):
+		c.Error(errors.New("after must be earlier than before"), http.StatusBadRequest)
+		return
+	case format != reportFormatJSON && format != reportFormatCSV:
+		c.Error(fmt.Errorf("unsupported format %q", format), http.StatusBadRequest)
+		return
+	}
+	filter.MinHeight, filter.MaxHeight = uint64(minHeight), uint64(maxHeight)
+
+	transactions, err := a.wallet.SearchTransactions(filter)
 	if !a.checkServerError(c, "failed to get wallet transactions", err) {
 		return
 	}
-	c.Encode(transactions)
+
+	switch format {
+	case reportFormatCSV:
+		c.ResponseWriter.Header().Set("Content-Type", "text/csv")
+		c.ResponseWriter.Header().Set("Content-Disposition", `attachment; filename="transactions.csv"`)
+		if err := writeWalletTransactionsCSV(csv.NewWriter(c.ResponseWriter), transactions); err != nil {
+			// headers have already been written, log the error
+			a.log.Warn("failed to write wallet transactions", zap.Error(err))
+		}
+	default:
+		c.Encode(transactions)
+	}
+}
+
+func (a *api) handlePUTWalletTransactionLabel(c jape.Context) {
+	var id types.TransactionID
+	if err := c.DecodeParam("id", &id); err != nil {
+		return
+	}
+	var req WalletTransactionLabelRequest
+	if err := c.Decode(&req); err != nil {
+		return
+	}
+	a.checkServerError(c, "failed to set transaction label", a.wallet.SetTransactionLabel(id, req.Label))
 }
 
 func (a *api) handleGETWalletPending(c jape.Context) {
@@ -358,37 +667,218 @@ func (a *api) handleGETWalletPending(c jape.Context) {
 	c.Encode(pending)
 }
 
-func (a *api) handlePOSTWalletSend(c jape.Context) {
+func (a *api) handleGETWalletLocked(c jape.Context) {
+	locked, err := a.wallet.LockedOutputs()
+	if !a.checkServerError(c, "failed to get locked outputs", err) {
+		return
+	}
+	c.Encode(locked)
+}
+
+// A fundedSend is a funded transaction paying the outputs of a send request.
+type fundedSend struct {
+	txn     types.Transaction
+	toSign  []types.Hash256
+	release func()
+	label   string
+	// change is the indices of the change outputs added by the wallet
+	change []int
+}
+
+// fundSendTransaction decodes a send request and funds a transaction paying
+// the requested amount. If ok is false, an error has already been written to
+// the response.
+func (a *api) fundSendTransaction(c jape.Context) (fs fundedSend, ok bool) {
 	var req WalletSendSiacoinsRequest
 	if err := c.Decode(&req); err != nil {
 		return
-	} else if req.Address == types.VoidAddress {
-		c.Error(errors.New("cannot send to void address"), http.StatusBadRequest)
+	}
+
+	outputs := req.Outputs
+	if len(outputs) == 0 {
+		outputs = []types.SiacoinOutput{{Address: req.Address, Value: req.Amount}}
+	} else if req.Address != types.VoidAddress || !req.Amount.IsZero() {
+		c.Error(errors.New("address and amount cannot be combined with outputs"), http.StatusBadRequest)
+		return
+	} else if req.SubtractMinerFee && len(outputs) > 1 {
+		c.Error(errors.New("cannot subtract miner fee from multiple outputs"), http.StatusBadRequest)
 		return
 	}
+	for _, sco := range outputs {
+		if sco.Address == types.VoidAddress {
+			c.Error(errors.New("cannot send to void address"), http.StatusBadRequest)
+			return
+		}
+	}
 
 	// estimate miner fee
-	feePerByte := a.tpool.RecommendedFee()
-	minerFee := feePerByte.Mul64(stdTxnSize)
+	minerFee := req.MinerFee
+	if minerFee.IsZero() {
+		feePerByte := a.tpool.RecommendedFee()
+		size := stdTxnSize + uint64(len(outputs)-1)*stdOutputSize
+		// explicit inputs can make the transaction much larger than a
+		// standard transaction
+		size += uint64(len(req.Inputs)) * stdInputSize
+		minerFee = feePerByte.Mul64(size)
+	}
 	if req.SubtractMinerFee {
 		var underflow bool
-		req.Amount, underflow = req.Amount.SubWithUnderflow(minerFee)
+		outputs[0].Value, underflow = outputs[0].Value.SubWithUnderflow(minerFee)
 		if underflow {
 			c.Error(fmt.Errorf("amount must be greater than miner fee: %s", minerFee), http.StatusBadRequest)
 			return
 		}
 	}
+	for i, sco := range outputs {
+		if sco.Value.IsZero() {
+			c.Error(fmt.Errorf("output %d value must be greater than zero", i), http.StatusBadRequest)
+			return
+		}
+	}
 
 	// build transaction
-	txn := types.Transaction{
-		MinerFees: []types.Currency{minerFee},
-		SiacoinOutputs: []types.SiacoinOutput{
-			{Address: req.Address, Value: req.Amount},
-		},
+	fs.txn = types.Transaction{
+		MinerFees:      []types.Currency{minerFee},
+		SiacoinOutputs: outputs,
+	}
+	amount := minerFee
+	for _, sco := range outputs {
+		amount = amount.Add(sco.Value)
+	}
+	// fund transaction
+	var err error
+	if len(req.Inputs) > 0 {
+		fs.toSign, fs.release, err = a.wallet.FundTransactionWithInputs(&fs.txn, amount, req.Inputs)
+	} else {
+		fs.toSign, fs.release, err = a.wallet.FundTransaction(&fs.txn, amount)
+	}
+	if errors.Is(err, wallet.ErrNotEnoughFunds) {
+		c.Error(err, http.StatusBadRequest)
+		return
+	} else if !a.checkServerError(c, "failed to fund transaction", err) {
+		return
+	}
+	// the wallet appends change after the requested outputs
+	for i := len(outputs); i < len(fs.txn.SiacoinOutputs); i++ {
+		fs.change = append(fs.change, i)
+	}
+	fs.label = req.Label
+	return fs, true
+}
+
+func (a *api) handlePOSTWalletSend(c jape.Context) {
+	fs, ok := a.fundSendTransaction(c)
+	if !ok {
+		return
+	}
+	defer fs.release()
+	txn := fs.txn
+	// sign transaction
+	err := a.wallet.SignTransaction(a.chain.TipState(), &txn, fs.toSign, types.CoveredFields{WholeTransaction: true})
+	if !a.checkServerError(c, "failed to sign transaction", err) {
+		return
 	}
-	// fund and sign transaction
-	toSign, release, err := a.wallet.FundTransaction(&txn, req.Amount.Add(minerFee))
-	if !a.checkServerError(c, "failed to fund transaction", err) {
+	// broadcast transaction
+	err = a.tpool.AcceptTransactionSet([]types.Transaction{txn})
+	if !a.checkServerError(c, "failed to broadcast transaction", err) {
+		return
+	}
+	// the label is only stored once the transaction is broadcast so that
+	// failed sends do not leave orphaned labels
+	if fs.label != "" {
+		if err := a.wallet.SetTransactionLabel(txn.ID(), fs.label); err != nil {
+			a.log.Error("failed to set transaction label", zap.Stringer("id", txn.ID()), zap.Error(err))
+		}
+	}
+	c.Encode(txn.ID())
+}
+
+func (a *api) handlePOSTWalletRescan(c jape.Context) {
+	var req RescanRequest
+	if err := c.Decode(&req); err != nil {
+		return
+	} else if tip := a.chain.TipState().Index.Height; req.Height > tip {
+		c.Error(fmt.Errorf("rescan height %d is above the current height %d", req.Height, tip), http.StatusBadRequest)
+		return
+	}
+	err := a.wallet.Rescan(req.Height)
+	if errors.Is(err, wallet.ErrRescanAboveBirthHeight) {
+		c.Error(err, http.StatusBadRequest)
+		return
+	}
+	a.checkServerError(c, "failed to rescan wallet", err)
+}
+
+func (a *api) handlePOSTWalletPrepare(c jape.Context) {
+	// the inputs stay locked until the transaction is confirmed, the lock
+	// expires, or they are released with [POST] /wallet/release
+	fs, ok := a.fundSendTransaction(c)
+	if !ok {
+		return
+	} else if fs.label != "" {
+		// prepared transactions may never be broadcast, the label is set by
+		// [POST] /wallet/broadcast instead
+		fs.release()
+		c.Error(errors.New("labels must be set when the transaction is broadcast"), http.StatusBadRequest)
+		return
+	}
+	c.Encode(wallet.NewUnsignedTransaction(a.chain.TipState(), fs.txn, fs.toSign, fs.change))
+}
+
+func (a *api) handlePOSTWalletRelease(c jape.Context) {
+	var req WalletReleaseRequest
+	if err := c.Decode(&req); err != nil {
+		return
+	} else if len(req.Outputs) == 0 {
+		c.Error(errors.New("no outputs specified"), http.StatusBadRequest)
+		return
+	}
+	a.checkServerError(c, "failed to release outputs", a.wallet.ReleaseOutputs(req.Outputs...))
+}
+
+func (a *api) handlePOSTWalletBroadcast(c jape.Context) {
+	var label string
+	if err := c.DecodeForm("label", &label); err != nil {
+		return
+	}
+	var txn types.Transaction
+	if err := c.Decode(&txn); err != nil {
+		return
+	} else if len(txn.Signatures) == 0 {
+		c.Error(errors.New("transaction is not signed"), http.StatusBadRequest)
+		return
+	}
+	err := a.tpool.AcceptTransactionSet([]types.Transaction{txn})
+	if !a.checkServerError(c, "failed to broadcast transaction", err) {
+		return
+	}
+	// the label is only stored once the transaction is broadcast so that
+	// transactions that are never broadcast do not leave orphaned labels
+	if label != "" {
+		if err := a.wallet.SetTransactionLabel(txn.ID(), label); err != nil {
+			a.log.Error("failed to set transaction label", zap.Stringer("id", txn.ID()), zap.Error(err))
+		}
+	}
+	c.Encode(txn.ID())
+}
+
+func (a *api) handlePOSTWalletRedistribute(c jape.Context) {
+	var req WalletRedistributeRequest
+	if err := c.Decode(&req); err != nil {
+		return
+	} else if req.Outputs <= 0 {
+		c.Error(errors.New("outputs must be greater than zero"), http.StatusBadRequest)
+		return
+	} else if req.Amount.IsZero() {
+		c.Error(errors.New("amount must be greater than zero"), http.StatusBadRequest)
+		return
+	}
+
+	txn, toSign, release, err := a.wallet.Redistribute(req.Outputs, req.Amount, a.tpool.RecommendedFee())
+	if errors.Is(err, wallet.ErrAlreadyRedistributed) {
+		c.Error(err, http.StatusBadRequest)
+		return
+	} else if !a.checkServerError(c, "failed to fund redistribution", err) {
 		return
 	}
 	defer release()
@@ -396,7 +886,6 @@ func (a *api) handlePOSTWalletSend(c jape.Context) {
 	if !a.checkServerError(c, "failed to sign transaction", err) {
 		return
 	}
-	// broadcast transaction
 	err = a.tpool.AcceptTransactionSet([]types.Transaction{txn})
 	if !a.checkServerError(c, "failed to broadcast transaction", err) {
 		return
@@ -518,6 +1007,226 @@ func (a *api) handleGETAccountFunding(c jape.Context) {
 	c.Encode(funding)
 }
 
+func (a *api) handleGETAccountHistory(c jape.Context) {
+	var account rhp3.Account
+	if err := c.DecodeParam("account", &account); err != nil {
+		return
+	}
+	limit, offset := parseLimitParams(c, 100, 500)
+	history, err := a.accounts.AccountHistory(account, limit, offset)
+	if !a.checkServerError(c, "failed to get account history", err) {
+		return
+	}
+	c.Encode(history)
+}
+
+func (a *api) handlePOSTAccounts(c jape.Context) {
+	var filter accounts.AccountFilter
+	if err := c.Decode(&filter); err != nil {
+		return
+	} else if err := filter.Validate(); err != nil {
+		c.Error(err, http.StatusBadRequest)
+		return
+	}
+
+	if filter.Limit <= 0 || filter.Limit > 500 {
+		filter.Limit = 500
+	}
+
+	accounts, count, err := a.accounts.FilterAccounts(filter)
+	if !a.checkServerError(c, "failed to get accounts", err) {
+		return
+	}
+	c.Encode(AccountsResponse{
+		Accounts: accounts,
+		Count:    count,
+	})
+}
+
+func (a *api) handleGETAccountAudit(c jape.Context) {
+	var account rhp3.Account
+	if err := c.DecodeParam("account", &account); err != nil {
+		return
+	}
+	limit, offset := parseLimitParams(c, 100, 500)
+	entries, err := a.accounts.AuditLog(account, limit, offset)
+	if !a.checkServerError(c, "failed to get account audit log", err) {
+		return
+	}
+	c.Encode(entries)
+}
+
+func (a *api) handlePUTAccountFrozen(c jape.Context) {
+	var account rhp3.Account
+	var req AccountFrozenRequest
+	if err := c.DecodeParam("account", &account); err != nil {
+		return
+	} else if err := c.Decode(&req); err != nil {
+		return
+	}
+
+	err := a.accounts.SetFrozen(account, req.Frozen, req.Reason)
+	if errors.Is(err, accounts.ErrNotFound) {
+		c.Error(err, http.StatusNotFound)
+		return
+	}
+	a.checkServerError(c, "failed to update account", err)
+}
+
+func (a *api) handlePUTAccountMaxBalance(c jape.Context) {
+	var account rhp3.Account
+	var req AccountMaxBalanceRequest
+	if err := c.DecodeParam("account", &account); err != nil {
+		return
+	} else if err := c.Decode(&req); err != nil {
+		return
+	}
+
+	err := a.accounts.SetMaxBalance(account, req.MaxBalance, req.Reason)
+	if errors.Is(err, accounts.ErrNotFound) {
+		c.Error(err, http.StatusNotFound)
+		return
+	}
+	a.checkServerError(c, "failed to update account", err)
+}
+
+func (a *api) handlePOSTAccountExpire(c jape.Context) {
+	var account rhp3.Account
+	var req AccountExpireRequest
+	if err := c.DecodeParam("account", &account); err != nil {
+		return
+	} else if err := c.Decode(&req); err != nil {
+		return
+	}
+
+	removed, err := a.accounts.Expire(account, req.Reason)
+	if errors.Is(err, accounts.ErrNotFound) {
+		c.Error(err, http.StatusNotFound)
+		return
+	} else if errors.Is(err, accounts.ErrAccountInUse) {
+		c.Error(err, http.StatusConflict)
+		return
+	} else if !a.checkServerError(c, "failed to expire account", err) {
+		return
+	}
+	c.Encode(AccountExpireResponse{Removed: removed})
+}
+
+func (a *api) handleGETRegistry(c jape.Context) {
+	count, max, err := a.registry.Entries()
+	if !a.checkServerError(c, "failed to get registry entries", err) {
+		return
+	}
+	c.Encode(RegistryResponse{
+		Entries:    count,
+		MaxEntries: max,
+	})
+}
+
+func (a *api) handlePOSTRegistryEntries(c jape.Context) {
+	var filter registry.EntryFilter
+	if err := c.Decode(&filter); err != nil {
+		return
+	} else if err := filter.Validate(); err != nil {
+		c.Error(err, http.StatusBadRequest)
+		return
+	}
+
+	if filter.Limit <= 0 || filter.Limit > 500 {
+		filter.Limit = 500
+	}
+
+	entries, count, err := a.registry.ListEntries(filter)
+	if !a.checkServerError(c, "failed to get registry entries", err) {
+		return
+	}
+	c.Encode(RegistryEntriesResponse{
+		Entries: entries,
+		Count:   count,
+	})
+}
+
+func (a *api) handleGETRegistryEntry(c jape.Context) {
+	var key types.Hash256
+	if err := c.DecodeParam("key", &key); err != nil {
+		return
+	}
+
+	entry, err := a.registry.Entry(key)
+	if errors.Is(err, registry.ErrEntryNotFound) {
+		c.Error(err, http.StatusNotFound)
+		return
+	} else if !a.checkServerError(c, "failed to get registry entry", err) {
+		return
+	}
+	c.Encode(entry)
+}
+
+func (a *api) handleDELETERegistryEntry(c jape.Context) {
+	var key types.Hash256
+	if err := c.DecodeParam("key", &key); err != nil {
+		return
+	}
+
+	err := a.registry.DeleteEntry(key)
+	if errors.Is(err, registry.ErrEntryNotFound) {
+		c.Error(err, http.StatusNotFound)
+		return
+	}
+	a.checkServerError(c, "failed to delete registry entry", err)
+}
+
+func (a *api) handleGETRegistryKeys(c jape.Context) {
+	limit, offset := parseLimitParams(c, 100, 500)
+	usage, err := a.registry.KeyUsage(limit, offset)
+	if !a.checkServerError(c, "failed to get registry key usage", err) {
+		return
+	}
+	c.Encode(usage)
+}
+
+func (a *api) handleDELETERegistryKey(c jape.Context) {
+	var publicKey types.PublicKey
+	if err := c.DecodeParam("publickey", &publicKey); err != nil {
+		return
+	}
+
+	deleted, err := a.registry.DeleteEntries(publicKey)
+	if !a.checkServerError(c, "failed to delete registry entries", err) {
+		return
+	}
+	a.log.Info("deleted registry entries", zap.Stringer("publicKey", publicKey), zap.Int("deleted", deleted))
+}
+
+// handleGETRegistryExport streams every registry entry as newline-delimited
+// JSON.
+func (a *api) handleGETRegistryExport(c jape.Context) {
+	const batchSize = 500
+
+	c.ResponseWriter.Header().Set("Content-Type", "application/x-ndjson")
+	c.ResponseWriter.Header().Set("Content-Disposition", `attachment; filename="registry.ndjson"`)
+	enc := json.NewEncoder(c.ResponseWriter)
+	for offset := 0; ; offset += batchSize {
+		entries, _, err := a.registry.ListEntries(registry.EntryFilter{
+			Limit:  batchSize,
+			Offset: offset,
+		})
+		if err != nil {
+			// headers may have already been written, log the error
+			a.log.Warn("failed to export registry entries", zap.Error(err))
+			return
+		}
+		for _, entry := range entries {
+			if err := enc.Encode(entry); err != nil {
+				return
+			}
+		}
+		if len(entries) < batchSize {
+			return
+		}
+	}
+}
+
 func parseLimitParams(c jape.Context, defaultLimit, maxLimit int) (limit, offset int) {
 	if err := c.DecodeForm("limit", &limit); err != nil {
 		return
@@ -0,0 +1,195 @@
+package api
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.sia.tech/hostd/host/metrics"
+	"go.sia.tech/hostd/host/reports"
+	"go.sia.tech/hostd/wallet"
+	"go.sia.tech/jape"
+	"go.uber.org/zap"
+)
+
+// report formats supported by the report endpoints
+const (
+	reportFormatJSON = "json"
+	reportFormatCSV  = "csv"
+)
+
+// writeRevenueCSV writes the report as CSV. Amounts are written in both
+// hastings and siacoins so the exact value is preserved.
+func writeRevenueCSV(w *csv.Writer, report reports.Report) error {
+	if err := w.Write([]string{"timestamp", "type", "id", "hastings", "siacoins", "fiat", "currency"}); err != nil {
+		return err
+	}
+	for _, line := range report.Lines {
+		var fiat string
+		if line.Fiat != nil {
+			fiat = strconv.FormatFloat(*line.Fiat, 'f', -1, 64)
+		}
+		record := []string{
+			line.Timestamp.UTC().Format(time.RFC3339),
+			string(line.Type),
+			line.ID,
+			line.Amount.ExactString(),
+			line.Siacoins().FloatString(24),
+			fiat,
+			report.Currency,
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// writeWalletTransactionsCSV writes the transactions as CSV. Amounts are
+// written in hastings.
+func writeWalletTransactionsCSV(w *csv.Writer, txns []wallet.Transaction) error {
+	if err := w.Write([]string{"timestamp", "height", "id", "source", "direction", "inflow", "outflow", "label"}); err != nil {
+		return err
+	}
+	for _, txn := range txns {
+		record := []string{
+			txn.Timestamp.UTC().Format(time.RFC3339),
+			strconv.FormatUint(txn.Index.Height, 10),
+			txn.ID.String(),
+			string(txn.Source),
+			string(txn.Direction()),
+			txn.Inflow.ExactString(),
+			txn.Outflow.ExactString(),
+			txn.Label,
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// writeWalletSummaryCSV writes one record per period and source. Amounts are
+// written in hastings.
+func writeWalletSummaryCSV(w *csv.Writer, summary reports.WalletSummary) error {
+	if err := w.Write([]string{"timestamp", "source", "count", "inflow", "outflow"}); err != nil {
+		return err
+	}
+	for _, period := range summary.Periods {
+		for _, category := range period.Categories {
+			record := []string{
+				period.Timestamp.UTC().Format(time.RFC3339),
+				string(category.Source),
+				strconv.Itoa(category.Count),
+				category.Inflow.ExactString(),
+				category.Outflow.ExactString(),
+			}
+			if err := w.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func (a *api) handleGETRevenueReport(c jape.Context) {
+	var from, to time.Time
+	format := reportFormatJSON
+	if err := c.DecodeForm("from", &from); err != nil {
+		return
+	} else if err := c.DecodeForm("to", &to); err != nil {
+		return
+	} else if err := c.DecodeForm("format", &format); err != nil {
+		return
+	}
+
+	if to.IsZero() {
+		to = time.Now()
+	}
+	switch {
+	case from.IsZero():
+		c.Error(errors.New("from is required"), http.StatusBadRequest)
+		return
+	case !from.Before(to):
+		c.Error(errors.New("from must be before to"), http.StatusBadRequest)
+		return
+	case format != reportFormatJSON && format != reportFormatCSV:
+		c.Error(fmt.Errorf("unsupported format %q", format), http.StatusBadRequest)
+		return
+	}
+
+	report, err := a.reports.Revenue(from, to)
+	if !a.checkServerError(c, "failed to generate revenue report", err) {
+		return
+	}
+
+	switch format {
+	case reportFormatCSV:
+		filename := fmt.Sprintf("revenue-%s-%s.csv", from.UTC().Format("20060102"), to.UTC().Format("20060102"))
+		c.ResponseWriter.Header().Set("Content-Type", "text/csv")
+		c.ResponseWriter.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		if err := writeRevenueCSV(csv.NewWriter(c.ResponseWriter), report); err != nil {
+			// headers have already been written, log the error
+			a.log.Warn("failed to write revenue report", zap.Error(err))
+		}
+	default:
+		c.Encode(report)
+	}
+}
+
+func (a *api) handleGETWalletReport(c jape.Context) {
+	var from, to time.Time
+	interval := metrics.IntervalDaily
+	format := reportFormatJSON
+	if err := c.DecodeForm("from", &from); err != nil {
+		return
+	} else if err := c.DecodeForm("to", &to); err != nil {
+		return
+	} else if err := c.DecodeForm("interval", &interval); err != nil {
+		return
+	} else if err := c.DecodeForm("format", &format); err != nil {
+		return
+	}
+
+	if to.IsZero() {
+		to = time.Now()
+	}
+	switch {
+	case from.IsZero():
+		c.Error(errors.New("from is required"), http.StatusBadRequest)
+		return
+	case !from.Before(to):
+		c.Error(errors.New("from must be before to"), http.StatusBadRequest)
+		return
+	case interval != metrics.IntervalDaily && interval != metrics.IntervalWeekly && interval != metrics.IntervalMonthly && interval != metrics.IntervalYearly:
+		c.Error(fmt.Errorf("unsupported interval %q", interval), http.StatusBadRequest)
+		return
+	case format != reportFormatJSON && format != reportFormatCSV:
+		c.Error(fmt.Errorf("unsupported format %q", format), http.StatusBadRequest)
+		return
+	}
+
+	summary, err := a.reports.WalletSummary(from, to, interval)
+	if !a.checkServerError(c, "failed to generate wallet report", err) {
+		return
+	}
+
+	switch format {
+	case reportFormatCSV:
+		filename := fmt.Sprintf("wallet-%s-%s.csv", from.UTC().Format("20060102"), to.UTC().Format("20060102"))
+		c.ResponseWriter.Header().Set("Content-Type", "text/csv")
+		c.ResponseWriter.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		if err := writeWalletSummaryCSV(csv.NewWriter(c.ResponseWriter), summary); err != nil {
+			// headers have already been written, log the error
+			a.log.Warn("failed to write wallet report", zap.Error(err))
+		}
+	default:
+		c.Encode(summary)
+	}
+}
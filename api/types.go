@@ -8,8 +8,12 @@ import (
 	"time"
 
 	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/host/accounts"
+	"go.sia.tech/hostd/host/alerts"
 	"go.sia.tech/hostd/host/contracts"
+	"go.sia.tech/hostd/host/registry"
 	"go.sia.tech/hostd/host/storage"
+	"go.sia.tech/hostd/rhp"
 )
 
 // JSON keys for host setting fields
@@ -54,13 +58,24 @@ type (
 		PublicKey     types.PublicKey `json:"publicKey"`
 		WalletAddress types.Address   `json:"walletAddress"`
 		StartTime     time.Time       `json:"startTime"`
+		Drain         rhp.DrainState  `json:"drain"`
 		BuildState
 	}
 
+	// DrainRequest is the request body for the [POST] /state/drain endpoint.
+	DrainRequest struct {
+		// Timeout is the time existing sessions have to finish before
+		// they are rejected. Defaults to 10 minutes.
+		Timeout time.Duration `json:"timeout"`
+	}
+
 	// ConsensusState is the response body for the [GET] /consensus endpoint.
 	ConsensusState struct {
 		Synced     bool             `json:"synced"`
 		ChainIndex types.ChainIndex `json:"chainIndex"`
+
+		WalletScanHeight   uint64 `json:"walletScanHeight"`
+		ContractScanHeight uint64 `json:"contractScanHeight"`
 	}
 
 	// ContractIntegrityResponse is the response body for the [POST] /contracts/:id/check endpoint.
@@ -102,6 +117,59 @@ type (
 		Contracts []contracts.Contract `json:"contracts"`
 	}
 
+	// AccountsResponse is the response body for the [POST] /accounts endpoint.
+	AccountsResponse struct {
+		Count    int                `json:"count"`
+		Accounts []accounts.Account `json:"accounts"`
+	}
+
+	// AccountFrozenRequest is the request body for the [PUT]
+	// /accounts/:account/frozen endpoint.
+	AccountFrozenRequest struct {
+		Frozen bool   `json:"frozen"`
+		Reason string `json:"reason"`
+	}
+
+	// AccountMaxBalanceRequest is the request body for the [PUT]
+	// /accounts/:account/maxbalance endpoint. A zero max balance removes the
+	// override.
+	AccountMaxBalanceRequest struct {
+		MaxBalance types.Currency `json:"maxBalance"`
+		Reason     string         `json:"reason"`
+	}
+
+	// AccountExpireRequest is the request body for the [POST]
+	// /accounts/:account/expire endpoint.
+	AccountExpireRequest struct {
+		Reason string `json:"reason"`
+	}
+
+	// AccountExpireResponse is the response body for the [POST]
+	// /accounts/:account/expire endpoint.
+	AccountExpireResponse struct {
+		Removed types.Currency `json:"removed"`
+	}
+
+	// RegistryResponse is the response body for the [GET] /registry endpoint.
+	RegistryResponse struct {
+		Entries    uint64 `json:"entries"`
+		MaxEntries uint64 `json:"maxEntries"`
+	}
+
+	// RegistryEntriesResponse is the response body for the [POST]
+	// /registry/entries endpoint.
+	RegistryEntriesResponse struct {
+		Count   int              `json:"count"`
+		Entries []registry.Entry `json:"entries"`
+	}
+
+	// AlertHistoryResponse is the response body for the [GET]
+	// /alerts/history endpoint.
+	AlertHistoryResponse struct {
+		Count  int                   `json:"count"`
+		Alerts []alerts.HistoryAlert `json:"alerts"`
+	}
+
 	// WalletResponse is the response body for the [GET] /wallet endpoint.
 	WalletResponse struct {
 		ScanHeight  uint64         `json:"scanHeight"`
@@ -116,6 +184,45 @@ type (
 		Address          types.Address  `json:"address"`
 		Amount           types.Currency `json:"amount"`
 		SubtractMinerFee bool           `json:"subtractMinerFee"`
+
+		// Outputs pays multiple outputs in a single transaction. It cannot
+		// be combined with Address and Amount.
+		Outputs []types.SiacoinOutput `json:"outputs,omitempty"`
+		// Inputs are the outputs used to fund the transaction. If empty,
+		// inputs are selected automatically.
+		Inputs []types.SiacoinOutputID `json:"inputs,omitempty"`
+		// MinerFee is the transaction's miner fee. If zero, the fee is
+		// estimated from the transaction pool.
+		MinerFee types.Currency `json:"minerFee"`
+		// Label is a user-defined label stored with the transaction. It is
+		// not accepted by [POST] /wallet/prepare; prepared transactions are
+		// labeled when they are broadcast.
+		Label string `json:"label,omitempty"`
+	}
+
+	// WalletReleaseRequest is the request body for the [POST] /wallet/release
+	// endpoint.
+	WalletReleaseRequest struct {
+		Outputs []types.SiacoinOutputID `json:"outputs"`
+	}
+
+	// WalletTransactionLabelRequest is the request body for the [PUT]
+	// /wallet/transactions/:id/label endpoint.
+	WalletTransactionLabelRequest struct {
+		Label string `json:"label"`
+	}
+
+	// WalletRedistributeRequest is the request body for the [POST]
+	// /wallet/redistribute endpoint.
+	WalletRedistributeRequest struct {
+		Outputs int            `json:"outputs"`
+		Amount  types.Currency `json:"amount"`
+	}
+
+	// RescanRequest is the request body for the [POST] /wallet/rescan and
+	// [POST] /contracts/rescan endpoints.
+	RescanRequest struct {
+		Height uint64 `json:"height"`
 	}
 
 	// A Peer is a peer in the network.
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"fmt"
@@ -28,6 +29,10 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
+// shutdownDrainTimeout is the time existing RHP sessions have to finish when
+// the host is shutting down.
+const shutdownDrainTimeout = 2 * time.Minute
+
 var (
 	cfg = config.Config{
 		Directory:      ".",                              // default to current directory
@@ -48,6 +53,17 @@ var (
 			TCPAddress:       defaultRHP3TCPAddr,
 			WebSocketAddress: defaultRHP3WSAddr,
 		},
+		Storage: config.Storage{
+			SubtreeCacheSize: 1024, // 32 MiB
+		},
+		Metrics: config.Metrics{
+			RawRetention:    7 * 24 * time.Hour,
+			HourlyRetention: 90 * 24 * time.Hour,
+		},
+		Wallet: config.Wallet{
+			OutputValue:         "500 SC",
+			MaintenanceInterval: 30 * time.Minute,
+		},
 		Log: config.Log{
 			Level: "info",
 			Path:  os.Getenv(logPathEnvVariable),
@@ -236,13 +252,20 @@ func main() {
 		var seed [32]byte
 		phrase := wallet.NewSeedPhrase()
 		if err := wallet.SeedFromPhrase(&seed, phrase); err != nil {
-			fmt.Println(err)
+			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
 		}
 		key := wallet.KeyFromSeed(&seed, 0)
 		fmt.Println("Recovery Phrase:", phrase)
 		fmt.Println("Address", types.StandardUnlockHash(key.PublicKey()))
 		return
+	case "sign":
+		mustSetWalletkey(log)
+		if err := signUnsignedTransaction(cfg.RecoveryPhrase, flag.Args()[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
 	}
 
 	// check that the API password and wallet seed are set
@@ -329,7 +352,7 @@ func main() {
 	auth := jape.BasicAuth(cfg.HTTP.Password)
 	web := http.Server{
 		Handler: webRouter{
-			api: auth(api.NewServer(cfg.Name, hostKey.PublicKey(), node.a, node.g, node.cm, node.tp, node.contracts, node.accounts, node.storage, node.sessions, node.metrics, node.settings, node.w, log.Named("api"))),
+			api: auth(api.NewServer(cfg.Name, hostKey.PublicKey(), node.a, node.rules, node.g, node.cm, node.tp, node.contracts, node.accounts, node.registry, node.storage, node.sessions, node.bans, node.drain, node.metrics, node.reports, node.settings, node.pricing, node.w, log.Named("api"))),
 			ui:  hostd.Handler(),
 		},
 		ReadTimeout: 30 * time.Second,
@@ -367,4 +390,18 @@ func main() {
 	time.AfterFunc(5*time.Minute, func() {
 		log.Fatal("failed to shut down within 5 minutes")
 	})
+
+	// stop accepting new sessions and give existing sessions time to finish
+	// before the session handlers wait for in-flight RPCs. A second signal
+	// skips the drain.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-signalCh
+		cancel()
+	}()
+	node.drain.Drain(shutdownDrainTimeout)
+	if err := node.drain.Wait(ctx); err != nil {
+		log.Warn("skipped draining sessions", zap.Error(err))
+	}
 }
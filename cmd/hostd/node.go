@@ -8,11 +8,15 @@ import (
 	"strings"
 
 	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/config"
 	"go.sia.tech/hostd/host/accounts"
 	"go.sia.tech/hostd/host/alerts"
 	"go.sia.tech/hostd/host/contracts"
 	"go.sia.tech/hostd/host/metrics"
+	"go.sia.tech/hostd/host/pricing"
 	"go.sia.tech/hostd/host/registry"
+	"go.sia.tech/hostd/host/reports"
+	"go.sia.tech/hostd/host/rules"
 	"go.sia.tech/hostd/host/settings"
 	"go.sia.tech/hostd/host/storage"
 	"go.sia.tech/hostd/internal/chain"
@@ -34,16 +38,22 @@ type node struct {
 	cm    *chain.Manager
 	tp    *chain.TransactionPool
 	w     *wallet.SingleAddressWallet
+	om    *wallet.OutputMaintainer
 	store *sqlite.Store
 
 	metrics   *metrics.MetricManager
+	reports   *reports.Manager
 	settings  *settings.ConfigManager
+	pricing   *pricing.Manager
+	rules     *rules.Manager
 	accounts  *accounts.AccountManager
 	contracts *contracts.ContractManager
 	registry  *registry.Manager
 	storage   *storage.VolumeManager
 
 	sessions    *rhp.SessionReporter
+	bans        *rhp.BanManager
+	drain       *rhp.Drainer
 	rhp2Monitor *rhp.DataRecorder
 	rhp2        *rhp2.SessionHandler
 	rhp3Monitor *rhp.DataRecorder
@@ -55,18 +65,84 @@ func (n *node) Close() error {
 	n.rhp2.Close()
 	n.rhp2Monitor.Close()
 	n.rhp3Monitor.Close()
+	n.rules.Close()
+	n.pricing.Close()
+	n.metrics.Close()
+	n.accounts.Close()
+	n.registry.Close()
 	n.storage.Close()
 	n.contracts.Close()
+	if n.om != nil {
+		n.om.Close()
+	}
 	n.w.Close()
 	n.tp.Close()
 	n.cm.Close()
 	n.g.Close()
+	n.a.Close()
 	n.store.Close()
 	return nil
 }
 
-func startRHP2(l net.Listener, hostKey types.PrivateKey, rhp3Addr string, cs rhp2.ChainManager, tp rhp2.TransactionPool, w rhp2.Wallet, cm rhp2.ContractManager, sr rhp2.SettingsReporter, sm rhp2.StorageManager, monitor rhp.DataMonitor, sessions *rhp.SessionReporter, log *zap.Logger) (*rhp2.SessionHandler, error) {
-	rhp2, err := rhp2.NewSessionHandler(l, hostKey, rhp3Addr, cs, tp, w, cm, sr, sm, monitor, sessions, log)
+// alertSinks returns the notification sinks in the alert config.
+func alertSinks(cfg config.Alerts) ([]alerts.Sink, error) {
+	newSink := func(name string, sc config.AlertSink, n alerts.Notifier) (alerts.Sink, error) {
+		minSeverity := alerts.SeverityWarning
+		if sc.MinSeverity != "" {
+			if err := minSeverity.UnmarshalText([]byte(sc.MinSeverity)); err != nil {
+				return alerts.Sink{}, fmt.Errorf("%s: %w", name, err)
+			}
+		}
+		return alerts.Sink{
+			Name:        name,
+			Notifier:    n,
+			MinSeverity: minSeverity,
+			RateLimit:   sc.RateLimit,
+		}, nil
+	}
+
+	var sinks []alerts.Sink
+	for i, wc := range cfg.Webhooks {
+		if wc.URL == "" {
+			return nil, fmt.Errorf("webhook %d: url is required", i)
+		}
+		sink, err := newSink(fmt.Sprintf("webhook-%d", i), wc.AlertSink, alerts.NewWebhookNotifier(wc.URL, wc.Retries, wc.RetryInterval))
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+	for i, sc := range cfg.SMTP {
+		if sc.Address == "" || sc.From == "" || len(sc.To) == 0 {
+			return nil, fmt.Errorf("smtp %d: address, from, and to are required", i)
+		}
+		sink, err := newSink(fmt.Sprintf("smtp-%d", i), sc.AlertSink, &alerts.SMTPNotifier{
+			Address:  sc.Address,
+			Username: sc.Username,
+			Password: sc.Password,
+			From:     sc.From,
+			To:       sc.To,
+		})
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+	for i, sc := range cfg.Scripts {
+		if sc.Path == "" {
+			return nil, fmt.Errorf("script %d: path is required", i)
+		}
+		sink, err := newSink(fmt.Sprintf("script-%d", i), sc.AlertSink, &alerts.ScriptNotifier{Path: sc.Path, Args: sc.Args})
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+	return sinks, nil
+}
+
+func startRHP2(l net.Listener, hostKey types.PrivateKey, rhp3Addr string, cs rhp2.ChainManager, tp rhp2.TransactionPool, w rhp2.Wallet, cm rhp2.ContractManager, sr rhp2.SettingsReporter, sm rhp2.StorageManager, monitor rhp.DataMonitor, sessions *rhp.SessionReporter, bans *rhp.BanManager, drain *rhp.Drainer, log *zap.Logger) (*rhp2.SessionHandler, error) {
+	rhp2, err := rhp2.NewSessionHandler(l, hostKey, rhp3Addr, cs, tp, w, cm, sr, sm, monitor, sessions, bans, drain, log)
 	if err != nil {
 		return nil, err
 	}
@@ -74,8 +150,8 @@ func startRHP2(l net.Listener, hostKey types.PrivateKey, rhp3Addr string, cs rhp
 	return rhp2, nil
 }
 
-func startRHP3(l net.Listener, hostKey types.PrivateKey, cs rhp3.ChainManager, tp rhp3.TransactionPool, w rhp3.Wallet, am rhp3.AccountManager, cm rhp3.ContractManager, rm rhp3.RegistryManager, sr rhp3.SettingsReporter, sm rhp3.StorageManager, monitor rhp.DataMonitor, sessions *rhp.SessionReporter, log *zap.Logger) (*rhp3.SessionHandler, error) {
-	rhp3, err := rhp3.NewSessionHandler(l, hostKey, cs, tp, w, am, cm, rm, sm, sr, monitor, sessions, log)
+func startRHP3(l net.Listener, hostKey types.PrivateKey, cs rhp3.ChainManager, tp rhp3.TransactionPool, w rhp3.Wallet, am rhp3.AccountManager, cm rhp3.ContractManager, rm rhp3.RegistryManager, sr rhp3.SettingsReporter, sm rhp3.StorageManager, monitor rhp.DataMonitor, sessions *rhp.SessionReporter, bans *rhp.BanManager, drain *rhp.Drainer, log *zap.Logger) (*rhp3.SessionHandler, error) {
+	rhp3, err := rhp3.NewSessionHandler(l, hostKey, cs, tp, w, am, cm, rm, sm, sr, monitor, sessions, bans, drain, log)
 	if err != nil {
 		return nil, err
 	}
@@ -145,6 +221,22 @@ func newNode(walletKey types.PrivateKey, logger *zap.Logger) (*node, types.Priva
 		return nil, types.PrivateKey{}, fmt.Errorf("failed to create wallet: %w", err)
 	}
 
+	var om *wallet.OutputMaintainer
+	if cfg.Wallet.MinOutputs > 0 {
+		outputValue, err := types.ParseCurrency(cfg.Wallet.OutputValue)
+		if err != nil {
+			return nil, types.PrivateKey{}, fmt.Errorf("failed to parse wallet output value: %w", err)
+		}
+		om, err = wallet.NewOutputMaintainer(w, tp, wallet.MaintenanceConfig{
+			MinOutputs:  cfg.Wallet.MinOutputs,
+			OutputValue: outputValue,
+			Interval:    cfg.Wallet.MaintenanceInterval,
+		}, logger.Named("wallet.outputs"))
+		if err != nil {
+			return nil, types.PrivateKey{}, fmt.Errorf("failed to create output maintainer: %w", err)
+		}
+	}
+
 	rhp2Listener, err := net.Listen("tcp", cfg.RHP2.Address)
 	if err != nil {
 		return nil, types.PrivateKey{}, fmt.Errorf("failed to listen on rhp2 addr: %w", err)
@@ -167,49 +259,104 @@ func newNode(walletKey types.PrivateKey, logger *zap.Logger) (*node, types.Priva
 		return nil, types.PrivateKey{}, fmt.Errorf("failed to create settings manager: %w", err)
 	}
 
-	accountManager := accounts.NewManager(db, sr)
-	am := alerts.NewManager()
+	accountManager, err := accounts.NewManager(db, sr, logger.Named("accounts"))
+	if err != nil {
+		return nil, types.PrivateKey{}, fmt.Errorf("failed to create account manager: %w", err)
+	}
+	sinks, err := alertSinks(cfg.Alerts)
+	if err != nil {
+		return nil, types.PrivateKey{}, fmt.Errorf("failed to configure alert sinks: %w", err)
+	}
+	am, err := alerts.NewManager(db, logger.Named("alerts"), sinks...)
+	if err != nil {
+		return nil, types.PrivateKey{}, fmt.Errorf("failed to create alerts manager: %w", err)
+	}
 	sm, err := storage.NewVolumeManager(db, am, cm, logger.Named("volumes"), sr.Settings().SectorCacheSize)
 	if err != nil {
 		return nil, types.PrivateKey{}, fmt.Errorf("failed to create storage manager: %w", err)
 	}
+	sm.ResizeSubtreeCache(cfg.Storage.SubtreeCacheSize)
 
 	contractManager, err := contracts.NewManager(db, am, sm, cm, tp, w, logger.Named("contracts"))
 	if err != nil {
 		return nil, types.PrivateKey{}, fmt.Errorf("failed to create contract manager: %w", err)
 	}
-	registryManager := registry.NewManager(hostKey, db, logger.Named("registry"))
+	registryManager, err := registry.NewManager(hostKey, cm, db, logger.Named("registry"))
+	if err != nil {
+		return nil, types.PrivateKey{}, fmt.Errorf("failed to create registry manager: %w", err)
+	}
 
 	sessions := rhp.NewSessionReporter()
+	bans := rhp.NewBanManager(logger.Named("bans"))
+	drain := rhp.NewDrainer(logger.Named("drain"))
 
 	rhp2Monitor := rhp.NewDataRecorder(&rhp2MonitorStore{db}, logger.Named("rhp2Monitor"))
-	rhp2, err := startRHP2(rhp2Listener, hostKey, rhp3Listener.Addr().String(), cm, tp, w, contractManager, sr, sm, rhp2Monitor, sessions, logger.Named("rhp2"))
+	rhp3Monitor := rhp.NewDataRecorder(&rhp3MonitorStore{db}, logger.Named("rhp3Monitor"))
+
+	pm, err := pricing.NewManager(db, sr, sm, contractManager, []pricing.DataRecorder{rhp2Monitor, rhp3Monitor}, logger.Named("pricing"))
+	if err != nil {
+		return nil, types.PrivateKey{}, fmt.Errorf("failed to create pricing manager: %w", err)
+	}
+	ps := &pricedSettings{sr, pm}
+
+	rhp2, err := startRHP2(rhp2Listener, hostKey, rhp3Listener.Addr().String(), cm, tp, w, contractManager, ps, sm, rhp2Monitor, sessions, bans, drain, logger.Named("rhp2"))
 	if err != nil {
 		return nil, types.PrivateKey{}, fmt.Errorf("failed to start rhp2: %w", err)
 	}
 
-	rhp3Monitor := rhp.NewDataRecorder(&rhp3MonitorStore{db}, logger.Named("rhp3Monitor"))
-	rhp3, err := startRHP3(rhp3Listener, hostKey, cm, tp, w, accountManager, contractManager, registryManager, sr, sm, rhp3Monitor, sessions, logger.Named("rhp3"))
+	rhp3, err := startRHP3(rhp3Listener, hostKey, cm, tp, w, accountManager, contractManager, registryManager, ps, sm, rhp3Monitor, sessions, bans, drain, logger.Named("rhp3"))
 	if err != nil {
 		return nil, types.PrivateKey{}, fmt.Errorf("failed to start rhp3: %w", err)
 	}
 
+	mm := metrics.NewManager(db, metrics.RetentionConfig{
+		RawRetention:    cfg.Metrics.RawRetention,
+		HourlyRetention: cfg.Metrics.HourlyRetention,
+		DailyRetention:  cfg.Metrics.DailyRetention,
+		VacuumInterval:  cfg.Metrics.VacuumInterval,
+	}, logger.Named("metrics"))
+
+	rm, err := rules.NewManager(db, am, mm, sm, w, cm, contractManager, sessions, logger.Named("rules"))
+	if err != nil {
+		return nil, types.PrivateKey{}, fmt.Errorf("failed to create alert rule manager: %w", err)
+	}
+
+	var rates reports.RateSource
+	switch {
+	case cfg.Reports.RatesFile != "":
+		rates, err = reports.NewFileRates(cfg.Reports.RatesFile, cfg.Reports.Currency)
+		if err != nil {
+			return nil, types.PrivateKey{}, fmt.Errorf("failed to load exchange rates: %w", err)
+		}
+	case cfg.Reports.RatesURL != "":
+		rates, err = reports.NewHTTPRates(cfg.Reports.RatesURL, cfg.Reports.Currency)
+		if err != nil {
+			return nil, types.PrivateKey{}, fmt.Errorf("failed to create exchange rate source: %w", err)
+		}
+	}
+
 	return &node{
 		g:     g,
 		a:     am,
 		cm:    cm,
 		tp:    tp,
 		w:     w,
+		om:    om,
 		store: db,
 
-		metrics:   metrics.NewManager(db),
+		metrics:   mm,
+		reports:   reports.NewManager(db, w, rates, logger.Named("reports")),
 		settings:  sr,
+		pricing:   pm,
+		rules:     rm,
 		accounts:  accountManager,
 		contracts: contractManager,
 		storage:   sm,
 		registry:  registryManager,
 
 		sessions:    sessions,
+		bans:        bans,
+		drain:       drain,
 		rhp2Monitor: rhp2Monitor,
 		rhp2:        rhp2,
 		rhp3Monitor: rhp3Monitor,
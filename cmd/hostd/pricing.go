@@ -0,0 +1,19 @@
+package main
+
+import (
+	"go.sia.tech/hostd/host/pricing"
+	"go.sia.tech/hostd/host/settings"
+)
+
+// pricedSettings wraps the settings manager and replaces the host's static
+// prices with the prices calculated by the dynamic pricing engine. It
+// implements the rhp2 and rhp3 SettingsReporter interfaces
+type pricedSettings struct {
+	*settings.ConfigManager
+	pricing *pricing.Manager
+}
+
+// Settings implements the SettingsReporter interface
+func (ps *pricedSettings) Settings() settings.Settings {
+	return ps.pricing.Settings()
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/core/wallet"
+	"go.sia.tech/hostd/build"
+	hwallet "go.sia.tech/hostd/wallet"
+)
+
+// printTransactionSummary writes the outputs and miner fee of an unsigned
+// transaction to w so they can be checked before the transaction is signed.
+func printTransactionSummary(w io.Writer, ut hwallet.UnsignedTransaction, addr types.Address) {
+	change := make(map[int]bool)
+	for _, i := range ut.Change {
+		change[i] = true
+	}
+
+	var sent, fee types.Currency
+	fmt.Fprintln(w, "Outputs:")
+	for i, sco := range ut.Transaction.SiacoinOutputs {
+		var note string
+		switch {
+		case change[i]:
+			note = " (change)"
+		case sco.Address == addr:
+			note = " (wallet)"
+		default:
+			sent = sent.Add(sco.Value)
+		}
+		fmt.Fprintf(w, "  %d: %v to %v%s\n", i, sco.Value, sco.Address, note)
+	}
+	for _, v := range ut.Transaction.MinerFees {
+		fee = fee.Add(v)
+	}
+	fmt.Fprintln(w, "Miner fee:", fee)
+	fmt.Fprintln(w, "Total sent:", sent.Add(fee))
+}
+
+// confirmSign asks the user to confirm signing the transaction on stderr.
+func confirmSign() (bool, error) {
+	fmt.Fprint(os.Stderr, "Sign this transaction? [y/N]: ")
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, fmt.Errorf("failed to read confirmation: %w", err)
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
+
+// signUnsignedTransaction reads an unsigned transaction exported by the
+// [POST] /wallet/prepare endpoint from inPath, signs it with the wallet's
+// key, and writes the signed transaction to outPath. If outPath is empty, the
+// signed transaction is written to stdout. The transaction's outputs and fee
+// are printed to stderr and must be confirmed before signing unless yes is
+// true. It does not require network access.
+func signUnsignedTransaction(recoveryPhrase string, args []string) error {
+	fs := flag.NewFlagSet("sign", flag.ContinueOnError)
+	yes := fs.Bool("yes", false, "sign without asking for confirmation")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	inPath, outPath := fs.Arg(0), fs.Arg(1)
+	if inPath == "" {
+		return fmt.Errorf("usage: hostd sign [--yes] <unsigned.json> [signed.json]")
+	}
+
+	var seed [32]byte
+	if err := wallet.SeedFromPhrase(&seed, recoveryPhrase); err != nil {
+		return fmt.Errorf("failed to load wallet: %w", err)
+	}
+	key := wallet.KeyFromSeed(&seed, 0)
+
+	buf, err := os.ReadFile(inPath)
+	if err != nil {
+		return fmt.Errorf("failed to read unsigned transaction: %w", err)
+	}
+	var ut hwallet.UnsignedTransaction
+	if err := json.Unmarshal(buf, &ut); err != nil {
+		return fmt.Errorf("failed to decode unsigned transaction: %w", err)
+	}
+
+	n, _ := build.Network()
+	addr := types.StandardUnlockHash(key.PublicKey())
+	if err := ut.Validate(addr, n); err != nil {
+		return fmt.Errorf("refusing to sign transaction: %w", err)
+	}
+	printTransactionSummary(os.Stderr, ut, addr)
+	if !*yes {
+		if disableStdin {
+			return errors.New("signing must be confirmed with --yes when --env flag is set")
+		} else if ok, err := confirmSign(); err != nil {
+			return err
+		} else if !ok {
+			return errors.New("signing cancelled")
+		}
+	}
+
+	txn, err := ut.Sign(key, n)
+	if err != nil {
+		return fmt.Errorf("failed to sign transaction: %w", err)
+	}
+
+	var w io.Writer = os.Stdout
+	if outPath != "" {
+		f, err := os.OpenFile(outPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer f.Close()
+		w = f
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(txn); err != nil {
+		return fmt.Errorf("failed to write signed transaction: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Signed transaction %v\n", txn.ID())
+	return nil
+}
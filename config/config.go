@@ -1,5 +1,7 @@
 package config
 
+import "time"
+
 type (
 	// HTTP contains the configuration for the HTTP server.
 	HTTP struct {
@@ -33,6 +35,106 @@ type (
 		Level string `yaml:"level"`
 	}
 
+	// Storage contains the configuration for the storage manager.
+	Storage struct {
+		// SubtreeCacheSize is the number of sectors whose subtree roots are
+		// cached to build range proofs without reading the full sector. A
+		// size of 0 disables the cache.
+		SubtreeCacheSize uint32 `yaml:"subtreeCacheSize"`
+	}
+
+	// Metrics contains the configuration for metric retention. A retention
+	// of 0 keeps the data at that resolution forever.
+	Metrics struct {
+		// RawRetention is how long 5-minute data is kept before it is
+		// rolled up into hourly data.
+		RawRetention time.Duration `yaml:"rawRetention"`
+		// HourlyRetention is how long hourly data is kept before it is
+		// rolled up into daily data. Daily buckets are aligned to UTC
+		// midnight, so daily metrics queried in another time zone are
+		// offset by the zone's UTC offset once hourly data has expired.
+		HourlyRetention time.Duration `yaml:"hourlyRetention"`
+		// DailyRetention is how long daily data is kept before it is
+		// removed.
+		DailyRetention time.Duration `yaml:"dailyRetention"`
+		// VacuumInterval is how often the database is vacuumed to reclaim
+		// space. Vacuuming rewrites the whole database and blocks other
+		// writes while it runs, so it is disabled by default. A value of 0
+		// disables vacuuming.
+		VacuumInterval time.Duration `yaml:"vacuumInterval"`
+	}
+
+	// Reports contains the configuration for accounting reports. If neither
+	// RatesFile nor RatesURL is set, reports do not include fiat values.
+	Reports struct {
+		// Currency is the fiat currency of the exchange rates.
+		Currency string `yaml:"currency"`
+		// RatesFile is the path of a CSV file containing daily exchange
+		// rates.
+		RatesFile string `yaml:"ratesFile"`
+		// RatesURL is the address of an HTTP endpoint that returns
+		// exchange rates. It is only used if RatesFile is empty.
+		RatesURL string `yaml:"ratesURL"`
+	}
+
+	// AlertSink contains the options shared by all alert notification
+	// sinks.
+	AlertSink struct {
+		// MinSeverity is the minimum severity of alerts sent to the sink.
+		// One of "info", "warning", "error", or "critical". Defaults to
+		// "warning".
+		MinSeverity string `yaml:"minSeverity"`
+		// RateLimit is the minimum time between notifications.
+		RateLimit time.Duration `yaml:"rateLimit"`
+	}
+
+	// AlertWebhook contains the configuration for an alert webhook.
+	AlertWebhook struct {
+		AlertSink     `yaml:",inline"`
+		URL           string        `yaml:"url"`
+		Retries       int           `yaml:"retries"`
+		RetryInterval time.Duration `yaml:"retryInterval"`
+	}
+
+	// AlertSMTP contains the configuration for sending alerts by email.
+	AlertSMTP struct {
+		AlertSink `yaml:",inline"`
+		Address   string   `yaml:"address"` // host:port of the SMTP server
+		Username  string   `yaml:"username"`
+		Password  string   `yaml:"password"`
+		From      string   `yaml:"from"`
+		To        []string `yaml:"to"`
+	}
+
+	// AlertScript contains the configuration for running a local program
+	// when an alert is registered.
+	AlertScript struct {
+		AlertSink `yaml:",inline"`
+		Path      string   `yaml:"path"`
+		Args      []string `yaml:"args"`
+	}
+
+	// Wallet contains the configuration for the wallet.
+	Wallet struct {
+		// MinOutputs is the minimum number of spendable outputs the wallet
+		// keeps available for concurrent contract formations. A value of 0
+		// disables automatic redistribution.
+		MinOutputs int `yaml:"minOutputs"`
+		// OutputValue is the value of each output created by a
+		// redistribution, e.g. "500 SC".
+		OutputValue string `yaml:"outputValue"`
+		// MaintenanceInterval is how often the wallet's outputs are
+		// checked.
+		MaintenanceInterval time.Duration `yaml:"maintenanceInterval"`
+	}
+
+	// Alerts contains the configuration for alert notifications.
+	Alerts struct {
+		Webhooks []AlertWebhook `yaml:"webhooks"`
+		SMTP     []AlertSMTP    `yaml:"smtp"`
+		Scripts  []AlertScript  `yaml:"scripts"`
+	}
+
 	// Config contains the configuration for the host.
 	Config struct {
 		Name           string `yaml:"name"`
@@ -43,6 +145,11 @@ type (
 		Consensus Consensus `yaml:"consensus"`
 		RHP2      RHP2      `yaml:"rhp2"`
 		RHP3      RHP3      `yaml:"rhp3"`
+		Storage   Storage   `yaml:"storage"`
+		Metrics   Metrics   `yaml:"metrics"`
+		Reports   Reports   `yaml:"reports"`
+		Wallet    Wallet    `yaml:"wallet"`
+		Alerts    Alerts    `yaml:"alerts"`
 		Log       Log       `yaml:"log"`
 	}
 )
@@ -10,6 +10,21 @@ import (
 	"go.sia.tech/core/types"
 	"go.sia.tech/hostd/host/contracts"
 	"go.sia.tech/hostd/host/settings"
+	"go.sia.tech/hostd/internal/threadgroup"
+	"go.uber.org/zap"
+)
+
+const (
+	// pruneInterval is the interval between removing expired accounts.
+	pruneInterval = time.Hour
+	// flushInterval is the interval between flushing journaled debits to
+	// the store. Debits committed during the interval are applied in a
+	// single transaction. The interval is kept short so that contract usage
+	// and revenue metrics do not noticeably lag behind RPCs.
+	flushInterval = 250 * time.Millisecond
+	// flushThreshold is the number of journaled debits that triggers a
+	// flush before the next interval.
+	flushThreshold = 1000
 )
 
 var (
@@ -19,6 +34,26 @@ var (
 	// ErrBalanceExceeded is returned when an account's balance exceeds the
 	// maximum balance.
 	ErrBalanceExceeded = errors.New("ephemeral account maximum balance exceeded") // note: text is required for compatibility with siad
+	// ErrAccountFrozen is returned when a frozen account is funded or
+	// spent from.
+	ErrAccountFrozen = errors.New("ephemeral account is frozen")
+	// ErrNotFound is returned when an account does not exist.
+	ErrNotFound = errors.New("account not found")
+)
+
+// LedgerEntryType is the type of an account ledger entry.
+const (
+	// LedgerEntryDeposit is recorded when a renter funds an account with a
+	// contract.
+	LedgerEntryDeposit = "deposit"
+	// LedgerEntryRefund is recorded when the host refunds a contract
+	// payment to an account.
+	LedgerEntryRefund = "refund"
+	// LedgerEntryWithdrawal is recorded when an account's funds are spent.
+	LedgerEntryWithdrawal = "withdrawal"
+	// LedgerEntryExpiry is recorded when an account's remaining balance is
+	// removed because the account expired.
+	LedgerEntryExpiry = "expiry"
 )
 
 type (
@@ -26,15 +61,48 @@ type (
 	AccountStore interface {
 		// AccountFunding returns the remaining funding sources for an account.
 		AccountFunding(accountID rhp3.Account) ([]FundingSource, error)
-		// Accounts returns a list of active ephemeral accounts
-		Accounts(limit, offset int) ([]Account, error)
+		// Accounts returns the accounts matching the filter and the total
+		// number of matching accounts.
+		Accounts(filter AccountFilter) ([]Account, int, error)
 		// AccountBalance returns the balance of the account with the given ID.
 		AccountBalance(accountID rhp3.Account) (types.Currency, error)
+		// Account returns the account with the given ID. If the account does
+		// not exist, ErrNotFound is returned.
+		Account(accountID rhp3.Account) (Account, error)
 		// CreditAccountWithContract adds the specified amount to the account with the given ID.
 		CreditAccountWithContract(FundAccountWithContract) (types.Currency, error)
-		// DebitAccount subtracts the specified amount from the account with the given
-		// ID. Returns the remaining balance of the account.
-		DebitAccount(accountID rhp3.Account, usage Usage) (types.Currency, error)
+		// AppendAccountDebits durably records debits in the journal. The
+		// debits are not applied to the account balances until the journal
+		// is flushed.
+		AppendAccountDebits([]Debit) error
+		// FlushAccountDebits applies the journaled debits to the account
+		// balances and removes them from the journal. Debits that cannot be
+		// applied are discarded and recorded in the account's audit log.
+		// Returns the total amount removed from the journal for each
+		// account.
+		FlushAccountDebits() (map[rhp3.Account]types.Currency, error)
+		// AccountHistory returns the ledger entries of the account with the
+		// given ID, newest first.
+		AccountHistory(accountID rhp3.Account, limit, offset int) ([]LedgerEntry, error)
+
+		// SetAccountFrozen freezes or unfreezes the account with the given
+		// ID and records the action in the audit log.
+		SetAccountFrozen(accountID rhp3.Account, frozen bool, reason string) error
+		// SetAccountMaxBalance overrides the maximum balance of the account
+		// with the given ID and records the action in the audit log. A zero
+		// maximum balance removes the override.
+		SetAccountMaxBalance(accountID rhp3.Account, maxBalance types.Currency, reason string) error
+		// ExpireAccount removes the account with the given ID and records
+		// the action in the audit log. The removed balance is returned.
+		ExpireAccount(accountID rhp3.Account, reason string) (types.Currency, error)
+		// PruneAccounts removes all accounts that expired before the given
+		// time, except for the accounts in skip. The remaining balances are
+		// recognized as revenue of the contracts that funded them. Returns
+		// the number of accounts removed.
+		PruneAccounts(before time.Time, skip []rhp3.Account) (int, error)
+		// AccountAuditLog returns the admin actions performed on the
+		// account with the given ID, newest first.
+		AccountAuditLog(accountID rhp3.Account, limit, offset int) ([]AuditEntry, error)
 	}
 
 	// Settings returns the host's current settings.
@@ -43,8 +111,22 @@ type (
 	}
 
 	accountState struct {
-		balance  types.Currency
-		openTxns int
+		balance    types.Currency
+		frozen     bool
+		maxBalance types.Currency
+		openTxns   int
+		// pending is the sum of the account's journaled debits that have
+		// not been flushed to the store.
+		pending types.Currency
+	}
+
+	// A Debit is a withdrawal from an account that has been committed but
+	// not yet applied to the account's balance.
+	Debit struct {
+		Account   rhp3.Account
+		Usage     Usage
+		Source    LedgerSource
+		Timestamp time.Time
 	}
 
 	// FundingSource tracks a funding source for an account.
@@ -59,6 +141,11 @@ type (
 		ID         rhp3.Account   `json:"ID"`
 		Balance    types.Currency `json:"balance"`
 		Expiration time.Time      `json:"expiration"`
+		// Frozen accounts can not be funded or spent from.
+		Frozen bool `json:"frozen"`
+		// MaxBalance overrides the host's maximum account balance if it is
+		// not zero.
+		MaxBalance types.Currency `json:"maxBalance"`
 	}
 
 	// FundAccountWithContract is a helper struct for funding an account with a
@@ -69,6 +156,32 @@ type (
 		Amount     types.Currency
 		Revision   contracts.SignedRevision
 		Expiration time.Time
+
+		// Refund is true if the deposit refunds a contract payment
+		Refund bool
+		// Source is the RPC that caused the deposit
+		Source LedgerSource
+	}
+
+	// A LedgerSource identifies the RPC, and the program for RPCExecuteProgram,
+	// that caused a change to an account's balance.
+	LedgerSource struct {
+		RPC     types.Specifier `json:"rpc"`
+		Program string          `json:"program,omitempty"`
+	}
+
+	// A LedgerEntry is a single change to an account's balance. Ledger
+	// entries are append-only and are kept after the account expires.
+	LedgerEntry struct {
+		ID         int64                `json:"id"`
+		Account    rhp3.Account         `json:"account"`
+		Type       string               `json:"type"`
+		Amount     types.Currency       `json:"amount"`
+		Balance    types.Currency       `json:"balance"`
+		ContractID types.FileContractID `json:"contractID,omitempty"`
+		Usage      Usage                `json:"usage"`
+		Timestamp  time.Time            `json:"timestamp"`
+		LedgerSource
 	}
 
 	// An AccountManager manages deposits and withdrawals for accounts. It is
@@ -76,32 +189,87 @@ type (
 	AccountManager struct {
 		store    AccountStore
 		settings Settings
+		log      *zap.Logger
+		tg       *threadgroup.ThreadGroup
+
+		// flush is signaled when the number of journaled debits reaches
+		// flushThreshold.
+		flush chan struct{}
+
+		// journalMu serializes writes to the journal so that debits are
+		// journaled in the order they were committed. Debits committed while
+		// the journal is being written are written together in the next
+		// batch.
+		journalMu sync.Mutex
 
 		mu sync.Mutex // guards the fields below
 		// balances is a map of account IDs to their current balance. It
 		// is used for consistency before a budget is synced to the underlying
-		// store.
+		// store and until the account's journaled debits are flushed.
 		balances map[rhp3.Account]accountState
+		// buffered are the committed debits that have not been written to
+		// the journal.
+		buffered []Debit
+		// bufferedSeq, journaledSeq, and flushedSeq are the sequence
+		// numbers of the last debit that was buffered, written to the
+		// journal, and flushed to the store.
+		bufferedSeq  uint64
+		journaledSeq uint64
+		flushedSeq   uint64
 	}
 )
 
-func (am *AccountManager) getBalance(accountID rhp3.Account) (types.Currency, error) {
+// getState returns the in-memory state of an account if it has open
+// transactions. Otherwise, the state is loaded from the store. Accounts that
+// do not exist have a zero balance. The caller must hold the lock.
+func (am *AccountManager) getState(accountID rhp3.Account) (accountState, error) {
 	if state, ok := am.balances[accountID]; ok {
-		return state.balance, nil
+		return state, nil
+	}
+	acc, err := am.store.Account(accountID)
+	if errors.Is(err, ErrNotFound) {
+		return accountState{}, nil
+	} else if err != nil {
+		return accountState{}, err
 	}
-	return am.store.AccountBalance(accountID)
+	return accountState{
+		balance:    acc.Balance,
+		frozen:     acc.Frozen,
+		maxBalance: acc.MaxBalance,
+	}, nil
 }
 
 // Balance returns the balance of the account with the given ID.
 func (am *AccountManager) Balance(accountID rhp3.Account) (types.Currency, error) {
 	am.mu.Lock()
 	defer am.mu.Unlock()
-	return am.getBalance(accountID)
+	state, err := am.getState(accountID)
+	return state.balance, err
 }
 
 // Accounts returns a list of active ephemeral accounts
 func (am *AccountManager) Accounts(limit, offset int) (acc []Account, err error) {
-	return am.store.Accounts(limit, offset)
+	acc, _, err = am.store.Accounts(AccountFilter{Limit: limit, Offset: offset})
+	return am.withPending(acc), err
+}
+
+// withPending subtracts the accounts' unflushed debits from the balances
+// loaded from the store.
+func (am *AccountManager) withPending(accs []Account) []Account {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	for i := range accs {
+		state, ok := am.balances[accs[i].ID]
+		if !ok {
+			continue
+		}
+		balance, underflow := accs[i].Balance.SubWithUnderflow(state.pending)
+		if underflow {
+			balance = types.ZeroCurrency
+		}
+		accs[i].Balance = balance
+	}
+	return accs
 }
 
 // AccountFunding returns the remaining funding sources for an account.
@@ -109,23 +277,47 @@ func (am *AccountManager) AccountFunding(account rhp3.Account) (srcs []FundingSo
 	return am.store.AccountFunding(account)
 }
 
+// AccountHistory returns the ledger entries of an account, newest first.
+func (am *AccountManager) AccountHistory(account rhp3.Account, limit, offset int) ([]LedgerEntry, error) {
+	return am.store.AccountHistory(account, limit, offset)
+}
+
 // Credit adds the specified amount to the account with the given ID. Credits
 // are synced to the underlying store immediately.
 func (am *AccountManager) Credit(req FundAccountWithContract, refund bool) (types.Currency, error) {
+	// apply the account's unflushed debits first so the deposit's ledger
+	// entry records the account's actual balance
+	am.mu.Lock()
+	pending := !am.balances[req.Account].pending.IsZero()
+	am.mu.Unlock()
+	if pending {
+		if err := am.Flush(); err != nil {
+			return types.ZeroCurrency, err
+		}
+	}
+
 	am.mu.Lock()
 	defer am.mu.Unlock()
 
+	req.Refund = refund
+
 	if req.Expiration.Before(time.Now()) {
 		return types.ZeroCurrency, fmt.Errorf("account expiration cannot be in the past")
 	}
 
-	balance, err := am.getBalance(req.Account)
+	state, err := am.getState(req.Account)
 	if err != nil {
 		return types.ZeroCurrency, fmt.Errorf("failed to get account balance: %w", err)
+	} else if state.frozen {
+		return types.ZeroCurrency, ErrAccountFrozen
 	}
 
-	creditBalance := balance.Add(req.Amount)
-	if !refund && creditBalance.Cmp(am.settings.Settings().MaxAccountBalance) > 0 {
+	maxBalance := am.settings.Settings().MaxAccountBalance
+	if !state.maxBalance.IsZero() {
+		maxBalance = state.maxBalance
+	}
+	creditBalance := state.balance.Add(req.Amount)
+	if !refund && creditBalance.Cmp(maxBalance) > 0 {
 		return types.ZeroCurrency, ErrBalanceExceeded
 	}
 
@@ -147,20 +339,13 @@ func (am *AccountManager) Budget(accountID rhp3.Account, amount types.Currency)
 	am.mu.Lock()
 	defer am.mu.Unlock()
 
-	// if there are currently outstanding debits, use the in-memory balance
-	state, ok := am.balances[accountID]
-	if !ok {
-		var err error
-		// otherwise, get the balance from the store
-		balance, err := am.store.AccountBalance(accountID)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get account balance: %w", err)
-		}
-
-		// add the account to the map of balances
-		state = accountState{
-			balance: balance,
-		}
+	// if there are currently outstanding debits, use the in-memory balance.
+	// Otherwise, get the balance from the store.
+	state, err := am.getState(accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account balance: %w", err)
+	} else if state.frozen {
+		return nil, ErrAccountFrozen
 	}
 
 	// if the account has enough balance, deduct the amount from memory and
@@ -182,12 +367,181 @@ func (am *AccountManager) Budget(accountID rhp3.Account, amount types.Currency)
 	}, nil
 }
 
-// NewManager creates a new account manager
-func NewManager(store AccountStore, settings Settings) *AccountManager {
-	return &AccountManager{
+// PruneExpired removes all expired accounts that do not have open
+// transactions. The remaining balances are recognized as revenue. Returns the
+// number of accounts removed.
+func (am *AccountManager) PruneExpired() (int, error) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	// accounts with outstanding budgets or unflushed debits must not be
+	// removed before the debits are applied
+	inUse := make([]rhp3.Account, 0, len(am.balances))
+	for id := range am.balances {
+		inUse = append(inUse, id)
+	}
+	return am.store.PruneAccounts(time.Now(), inUse)
+}
+
+// writeJournal writes the buffered debits to the journal in a single batch
+// if the debit with sequence number seq has not already been written. If the
+// write fails, the debits are kept and retried by the next write.
+func (am *AccountManager) writeJournal(seq uint64) error {
+	am.journalMu.Lock()
+	defer am.journalMu.Unlock()
+
+	am.mu.Lock()
+	if am.journaledSeq >= seq {
+		// the debit was written as part of another batch
+		am.mu.Unlock()
+		return nil
+	}
+	batch, last := am.buffered, am.bufferedSeq
+	am.buffered = nil
+	am.mu.Unlock()
+	if len(batch) == 0 {
+		return nil
+	}
+
+	if err := am.store.AppendAccountDebits(batch); err != nil {
+		// keep the debits so the write is retried
+		am.mu.Lock()
+		am.buffered = append(batch, am.buffered...)
+		am.mu.Unlock()
+		return fmt.Errorf("failed to journal account debits: %w", err)
+	}
+
+	am.mu.Lock()
+	am.journaledSeq = last
+	am.mu.Unlock()
+	return nil
+}
+
+// Flush applies the journaled debits to the store. Accounts without open
+// transactions or unflushed debits are removed from memory.
+func (am *AccountManager) Flush() error {
+	// every debit up to seq is written to the journal before it is flushed
+	am.mu.Lock()
+	seq := am.bufferedSeq
+	am.mu.Unlock()
+	if err := am.writeJournal(seq); err != nil {
+		return err
+	}
+
+	// the manager is not locked while flushing. Debits journaled during the
+	// flush are either included in the result or flushed next time.
+	flushed, err := am.store.FlushAccountDebits()
+	if err != nil {
+		return fmt.Errorf("failed to flush account debits: %w", err)
+	}
+
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	for accountID, amount := range flushed {
+		state, ok := am.balances[accountID]
+		if !ok {
+			// debits journaled before a restart are not tracked in memory
+			continue
+		}
+		if amount.Cmp(state.pending) > 0 {
+			amount = state.pending
+		}
+		state.pending = state.pending.Sub(amount)
+		if state.openTxns <= 0 && state.pending.IsZero() {
+			delete(am.balances, accountID)
+			continue
+		}
+		am.balances[accountID] = state
+	}
+	if seq > am.flushedSeq {
+		am.flushedSeq = seq
+	}
+	return nil
+}
+
+// bufferDebit buffers a committed debit until it is written to the journal.
+// The debit's sequence number is returned. The caller must hold the lock.
+func (am *AccountManager) bufferDebit(debit Debit) uint64 {
+	am.buffered = append(am.buffered, debit)
+	am.bufferedSeq++
+	if am.bufferedSeq-am.flushedSeq >= flushThreshold {
+		select {
+		case am.flush <- struct{}{}:
+		default:
+		}
+	}
+	return am.bufferedSeq
+}
+
+// flushPending flushes the journal if any debits were committed since the
+// last flush.
+func (am *AccountManager) flushPending() {
+	am.mu.Lock()
+	pending := am.bufferedSeq > am.flushedSeq
+	am.mu.Unlock()
+	if !pending {
+		return
+	}
+
+	if err := am.Flush(); err != nil {
+		am.log.Error("failed to flush account debits", zap.Error(err))
+	}
+}
+
+func (am *AccountManager) run() {
+	done, err := am.tg.Add()
+	if err != nil {
+		return
+	}
+	defer done()
+
+	pruneTicker := time.NewTicker(pruneInterval)
+	defer pruneTicker.Stop()
+	flushTicker := time.NewTicker(flushInterval)
+	defer flushTicker.Stop()
+
+	for {
+		select {
+		case <-am.tg.Done():
+			return
+		case <-am.flush:
+			am.flushPending()
+		case <-flushTicker.C:
+			am.flushPending()
+		case <-pruneTicker.C:
+			if n, err := am.PruneExpired(); err != nil {
+				am.log.Error("failed to prune expired accounts", zap.Error(err))
+			} else if n > 0 {
+				am.log.Debug("pruned expired accounts", zap.Int("count", n))
+			}
+		}
+	}
+}
+
+// Close stops the account manager and flushes any journaled debits.
+func (am *AccountManager) Close() error {
+	am.tg.Stop()
+	return am.Flush()
+}
+
+// NewManager creates a new account manager. Debits journaled before the
+// host was shut down are applied before the manager is returned.
+func NewManager(store AccountStore, settings Settings, log *zap.Logger) (*AccountManager, error) {
+	am := &AccountManager{
 		store:    store,
 		settings: settings,
+		log:      log,
+		tg:       threadgroup.New(),
+
+		flush: make(chan struct{}, 1),
 
 		balances: make(map[rhp3.Account]accountState),
 	}
+	// balances are loaded from the store, so the journal must be replayed
+	// before any budgets are created
+	if err := am.Flush(); err != nil {
+		return nil, err
+	}
+	go am.run()
+	return am, nil
 }
@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	rhp3 "go.sia.tech/core/rhp/v3"
 	"go.sia.tech/core/types"
 	"go.sia.tech/hostd/host/accounts"
 	"go.sia.tech/hostd/host/alerts"
@@ -71,7 +72,10 @@ func TestCredit(t *testing.T) {
 	}
 	defer w.Close()
 
-	a := alerts.NewManager()
+	a, err := alerts.NewManager(db, log.Named("alerts"))
+	if err != nil {
+		t.Fatal(err)
+	}
 	sm, err := storage.NewVolumeManager(db, a, cm, log.Named("storage"), 0)
 	if err != nil {
 		t.Fatal(err)
@@ -99,7 +103,11 @@ func TestCredit(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	am := accounts.NewManager(db, ephemeralSettings{maxBalance: types.NewCurrency64(100)})
+	am, err := accounts.NewManager(db, ephemeralSettings{maxBalance: types.NewCurrency64(100)}, log.Named("accounts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer am.Close()
 	accountID := frand.Entropy256()
 
 	// attempt to credit the account
@@ -208,3 +216,232 @@ func TestCredit(t *testing.T) {
 		t.Fatalf("expected 1 active account, got %v", m.Accounts.Active)
 	}
 }
+
+func TestAccountAdmin(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	dir := t.TempDir()
+	db, err := sqlite.OpenDatabase(filepath.Join(dir, "hostd.db"), log.Named("accounts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	g, err := gateway.New(":0", false, filepath.Join(dir, "gateway"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+
+	cs, errCh := consensus.New(g, false, filepath.Join(dir, "consensus"))
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+	defer cs.Close()
+
+	stp, err := transactionpool.New(cs, g, filepath.Join(dir, "transactionpool"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tp := chain.NewTPool(stp)
+	defer tp.Close()
+
+	cm, err := chain.NewManager(cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cm.Close()
+
+	w, err := wallet.NewSingleAddressWallet(types.NewPrivateKeyFromSeed(frand.Bytes(32)), cm, tp, db, log.Named("wallet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	a, err := alerts.NewManager(db, log.Named("alerts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sm, err := storage.NewVolumeManager(db, a, cm, log.Named("storage"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sm.Close()
+
+	com, err := contracts.NewManager(db, a, sm, cm, tp, w, log.Named("contracts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer com.Close()
+
+	rev := contracts.SignedRevision{
+		Revision: types.FileContractRevision{
+			ParentID: frand.Entropy256(),
+			UnlockConditions: types.UnlockConditions{
+				PublicKeys: []types.UnlockKey{
+					{Algorithm: types.SpecifierEd25519, Key: frand.Bytes(32)},
+					{Algorithm: types.SpecifierEd25519, Key: frand.Bytes(32)},
+				},
+			},
+		},
+	}
+	if err := com.AddContract(rev, []types.Transaction{{}}, types.Siacoins(1), contracts.Usage{}); err != nil {
+		t.Fatal(err)
+	}
+
+	am, err := accounts.NewManager(db, ephemeralSettings{maxBalance: types.NewCurrency64(100)}, log.Named("accounts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer am.Close()
+	fund := func(accountID rhp3.Account, amount uint64) error {
+		_, err := am.Credit(accounts.FundAccountWithContract{
+			Account:    accountID,
+			Amount:     types.NewCurrency64(amount),
+			Revision:   rev,
+			Expiration: time.Now().Add(time.Minute),
+		}, false)
+		return err
+	}
+
+	// fund a few accounts with different balances
+	ids := []rhp3.Account{frand.Entropy256(), frand.Entropy256(), frand.Entropy256()}
+	for i, id := range ids {
+		if err := fund(id, uint64(30-10*i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// accounts should be sorted numerically by balance
+	acc, count, err := am.FilterAccounts(accounts.AccountFilter{SortField: accounts.AccountSortBalance})
+	if err != nil {
+		t.Fatal(err)
+	} else if count != 3 || len(acc) != 3 {
+		t.Fatalf("expected 3 accounts, got %v (%v)", len(acc), count)
+	}
+	for i := range acc {
+		if acc[i].ID != ids[2-i] {
+			t.Fatalf("expected account %v at index %v, got %v", ids[2-i], i, acc[i].ID)
+		}
+	}
+	_, count, err = am.FilterAccounts(accounts.AccountFilter{MinBalance: types.NewCurrency64(15)})
+	if err != nil {
+		t.Fatal(err)
+	} else if count != 2 {
+		t.Fatalf("expected 2 accounts, got %v", count)
+	}
+
+	// a frozen account cannot be funded or spent from
+	if err := am.SetFrozen(ids[0], true, "suspicious activity"); err != nil {
+		t.Fatal(err)
+	} else if err := fund(ids[0], 1); err != accounts.ErrAccountFrozen {
+		t.Fatalf("expected ErrAccountFrozen, got %v", err)
+	} else if _, err := am.Budget(ids[0], types.NewCurrency64(1)); err != accounts.ErrAccountFrozen {
+		t.Fatalf("expected ErrAccountFrozen, got %v", err)
+	}
+	frozen := true
+	if _, count, err := am.FilterAccounts(accounts.AccountFilter{Frozen: &frozen}); err != nil {
+		t.Fatal(err)
+	} else if count != 1 {
+		t.Fatalf("expected 1 frozen account, got %v", count)
+	}
+	if err := am.SetFrozen(ids[0], false, ""); err != nil {
+		t.Fatal(err)
+	} else if _, err := am.Budget(ids[0], types.NewCurrency64(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	// the max balance override should replace the host's default
+	if err := am.SetMaxBalance(ids[1], types.NewCurrency64(200), "trusted renter"); err != nil {
+		t.Fatal(err)
+	} else if err := fund(ids[1], 150); err != nil {
+		t.Fatal(err)
+	} else if err := am.SetMaxBalance(ids[2], types.NewCurrency64(15), ""); err != nil {
+		t.Fatal(err)
+	} else if err := fund(ids[2], 10); err != accounts.ErrBalanceExceeded {
+		t.Fatalf("expected ErrBalanceExceeded, got %v", err)
+	}
+
+	// an account with open transactions cannot be expired
+	if _, err := am.Expire(ids[0], "test"); err != accounts.ErrAccountInUse {
+		t.Fatalf("expected ErrAccountInUse, got %v", err)
+	}
+
+	removed, err := am.Expire(ids[1], "closing account")
+	if err != nil {
+		t.Fatal(err)
+	} else if !removed.Equals(types.NewCurrency64(170)) {
+		t.Fatalf("expected 170 to be removed, got %v", removed)
+	} else if _, err := db.Account(ids[1]); err != accounts.ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	} else if _, err := am.Expire(ids[1], ""); err != accounts.ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	if history, err := am.AccountHistory(ids[1], 100, 0); err != nil {
+		t.Fatal(err)
+	} else if len(history) == 0 || history[0].Type != accounts.LedgerEntryExpiry {
+		t.Fatalf("expected expiry ledger entry, got %+v", history)
+	} else if !history[0].Amount.Equals(removed) || !history[0].Balance.IsZero() {
+		t.Fatalf("unexpected expiry ledger entry %+v", history[0])
+	}
+
+	if m, err := db.Metrics(time.Now()); err != nil {
+		t.Fatal(err)
+	} else if m.Accounts.Active != 2 {
+		t.Fatalf("expected 2 active accounts, got %v", m.Accounts.Active)
+	} else if !m.Accounts.Balance.Equals(types.NewCurrency64(40)) {
+		t.Fatalf("expected account balance to be 40, got %v", m.Accounts.Balance)
+	} else if !m.Revenue.Potential.AccountExpiry.Equals(removed) {
+		t.Fatalf("expected account expiry revenue to be %v, got %v", removed, m.Revenue.Potential.AccountExpiry)
+	}
+
+	// the expired balance should be attributed to the funding contract
+	contract, err := com.Contract(rev.Revision.ParentID)
+	if err != nil {
+		t.Fatal(err)
+	} else if !contract.Usage.AccountExpiry.Equals(removed) {
+		t.Fatalf("expected contract account expiry revenue to be %v, got %v", removed, contract.Usage.AccountExpiry)
+	} else if !contract.Usage.AccountFunding.Equals(types.NewCurrency64(40)) {
+		t.Fatalf("expected contract account funding to be 40, got %v", contract.Usage.AccountFunding)
+	}
+
+	// prune the remaining accounts, skipping the account with an open budget
+	if n, err := db.PruneAccounts(time.Now().Add(time.Hour), []rhp3.Account{ids[0]}); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatalf("expected 1 account to be pruned, got %v", n)
+	} else if _, err := db.Account(ids[0]); err != nil {
+		t.Fatal(err)
+	} else if _, err := db.Account(ids[2]); err != accounts.ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	if m, err := db.Metrics(time.Now()); err != nil {
+		t.Fatal(err)
+	} else if m.Accounts.Active != 1 {
+		t.Fatalf("expected 1 active account, got %v", m.Accounts.Active)
+	} else if !m.Revenue.Potential.AccountExpiry.Equals(types.NewCurrency64(180)) {
+		t.Fatalf("expected account expiry revenue to be 180, got %v", m.Revenue.Potential.AccountExpiry)
+	}
+	if history, err := am.AccountHistory(ids[2], 1, 0); err != nil {
+		t.Fatal(err)
+	} else if len(history) != 1 || history[0].Type != accounts.LedgerEntryExpiry || !history[0].Amount.Equals(types.NewCurrency64(10)) {
+		t.Fatalf("expected expiry ledger entry, got %+v", history)
+	}
+
+	// check the audit trail
+	if entries, err := am.AuditLog(ids[0], 100, 0); err != nil {
+		t.Fatal(err)
+	} else if len(entries) != 2 {
+		t.Fatalf("expected 2 audit entries, got %v", len(entries))
+	} else if entries[0].Action != accounts.AuditActionUnfreeze || entries[1].Action != accounts.AuditActionFreeze {
+		t.Fatalf("unexpected audit entries %+v", entries)
+	} else if entries[1].Reason != "suspicious activity" {
+		t.Fatalf("expected reason to be recorded, got %q", entries[1].Reason)
+	}
+	if entries, err := am.AuditLog(ids[1], 100, 0); err != nil {
+		t.Fatal(err)
+	} else if len(entries) != 2 || entries[0].Action != accounts.AuditActionExpire || entries[1].Action != accounts.AuditActionSetMaxBalance {
+		t.Fatalf("unexpected audit entries %+v", entries)
+	}
+}
@@ -0,0 +1,140 @@
+package accounts
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	rhp3 "go.sia.tech/core/rhp/v3"
+	"go.sia.tech/core/types"
+)
+
+// AuditAction is the type of an admin action performed on an account.
+const (
+	AuditActionFreeze        = "freeze"
+	AuditActionUnfreeze      = "unfreeze"
+	AuditActionSetMaxBalance = "setMaxBalance"
+	AuditActionExpire        = "expire"
+	// AuditActionDiscardDebits is recorded when journaled debits can not be
+	// applied to an account and are removed from the journal.
+	AuditActionDiscardDebits = "discardDebits"
+)
+
+// AccountSortField is the field used to sort accounts.
+const (
+	AccountSortBalance    = "balance"
+	AccountSortExpiration = "expiration"
+)
+
+// ErrAccountInUse is returned when an account can not be expired because it
+// has open transactions.
+var ErrAccountInUse = errors.New("account has open transactions")
+
+type (
+	// An AccountFilter filters and sorts accounts.
+	AccountFilter struct {
+		// filters
+		Frozen        *bool          `json:"frozen,omitempty"`
+		MinBalance    types.Currency `json:"minBalance"`
+		MaxBalance    types.Currency `json:"maxBalance"` // zero means no maximum
+		ExpiresBefore time.Time      `json:"expiresBefore"`
+		ExpiresAfter  time.Time      `json:"expiresAfter"`
+
+		// pagination
+		Limit  int `json:"limit"`
+		Offset int `json:"offset"`
+
+		// sorting
+		SortField string `json:"sortField"`
+		SortDesc  bool   `json:"sortDesc"`
+	}
+
+	// An AuditEntry records an admin action performed on an account.
+	AuditEntry struct {
+		ID        int64        `json:"id"`
+		Account   rhp3.Account `json:"account"`
+		Action    string       `json:"action"`
+		Detail    string       `json:"detail,omitempty"`
+		Reason    string       `json:"reason,omitempty"`
+		Timestamp time.Time    `json:"timestamp"`
+	}
+)
+
+// Validate returns an error if the filter is invalid.
+func (f AccountFilter) Validate() error {
+	switch f.SortField {
+	case "", AccountSortBalance, AccountSortExpiration:
+	default:
+		return fmt.Errorf("invalid sort field %q", f.SortField)
+	}
+	if !f.MaxBalance.IsZero() && f.MinBalance.Cmp(f.MaxBalance) > 0 {
+		return errors.New("min balance must be less than max balance")
+	}
+	return nil
+}
+
+// FilterAccounts returns the accounts matching the filter and the total
+// number of matching accounts.
+func (am *AccountManager) FilterAccounts(filter AccountFilter) ([]Account, int, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, 0, err
+	}
+	accs, count, err := am.store.Accounts(filter)
+	return am.withPending(accs), count, err
+}
+
+// AuditLog returns the admin actions performed on an account, newest first.
+func (am *AccountManager) AuditLog(accountID rhp3.Account, limit, offset int) ([]AuditEntry, error) {
+	return am.store.AccountAuditLog(accountID, limit, offset)
+}
+
+// SetFrozen freezes or unfreezes an account. Frozen accounts can not be funded
+// or spent from. Budgets created before the account was frozen can still be
+// committed.
+func (am *AccountManager) SetFrozen(accountID rhp3.Account, frozen bool, reason string) error {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	if err := am.store.SetAccountFrozen(accountID, frozen, reason); err != nil {
+		return err
+	}
+	if state, ok := am.balances[accountID]; ok {
+		state.frozen = frozen
+		am.balances[accountID] = state
+	}
+	return nil
+}
+
+// SetMaxBalance overrides the host's maximum balance for an account. A zero
+// maximum balance removes the override.
+func (am *AccountManager) SetMaxBalance(accountID rhp3.Account, maxBalance types.Currency, reason string) error {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	if err := am.store.SetAccountMaxBalance(accountID, maxBalance, reason); err != nil {
+		return err
+	}
+	if state, ok := am.balances[accountID]; ok {
+		state.maxBalance = maxBalance
+		am.balances[accountID] = state
+	}
+	return nil
+}
+
+// Expire immediately expires an account, removing its remaining balance. The
+// account must not have any open transactions. The removed balance is
+// returned.
+func (am *AccountManager) Expire(accountID rhp3.Account, reason string) (types.Currency, error) {
+	// apply the account's journaled debits before removing it
+	if err := am.Flush(); err != nil {
+		return types.ZeroCurrency, err
+	}
+
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	if _, ok := am.balances[accountID]; ok {
+		return types.ZeroCurrency, ErrAccountInUse
+	}
+	return am.store.ExpireAccount(accountID, reason)
+}
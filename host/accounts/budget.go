@@ -2,6 +2,7 @@ package accounts
 
 import (
 	"fmt"
+	"time"
 
 	rhp3 "go.sia.tech/core/rhp/v3"
 	"go.sia.tech/core/types"
@@ -24,6 +25,7 @@ type (
 		accountID rhp3.Account
 		max       types.Currency
 		usage     Usage
+		source    LedgerSource
 		committed bool
 		am        *AccountManager
 	}
@@ -63,6 +65,12 @@ func (a Usage) Sub(b Usage) Usage {
 	}
 }
 
+// SetSource sets the RPC and program recorded in the account's ledger when
+// the budget is committed.
+func (b *Budget) SetSource(source LedgerSource) {
+	b.source = source
+}
+
 // Remaining returns the amount remaining in the budget
 func (b *Budget) Remaining() types.Currency {
 	return b.max.Sub(b.usage.Total())
@@ -105,9 +113,9 @@ func (b *Budget) Rollback() error {
 	}
 	b.committed = true
 	state.openTxns--
-	if state.openTxns <= 0 {
-		// if there are no more open transactions, we can remove the account
-		// from memory without doing anything else
+	if state.openTxns <= 0 && state.pending.IsZero() {
+		// if there are no more open transactions or unflushed debits, we
+		// can remove the account from memory without doing anything else
 		delete(b.am.balances, b.accountID)
 		return nil
 	}
@@ -117,22 +125,29 @@ func (b *Budget) Rollback() error {
 	return nil
 }
 
-// Commit commits the budget's spending to the account. If the budget has
-// already been committed, Commit will panic.
+// Commit commits the budget's spending to the account. Commit returns once
+// the debit has been written to the journal; the account's balance in the
+// store is updated when the journal is flushed. If the debit could not be
+// journaled, it is kept in memory and retried by the next write.
 func (b *Budget) Commit() error {
 	b.am.mu.Lock()
-	defer b.am.mu.Unlock()
-
 	if b.committed {
+		b.am.mu.Unlock()
 		return nil
 	}
-	// debit the account
-	_, err := b.am.store.DebitAccount(b.accountID, b.usage)
-	if err != nil {
-		return fmt.Errorf("failed to debit account: %w", err)
+	// buffer the debit. Debits committed concurrently are written to the
+	// journal in a single batch.
+	var seq uint64
+	spent := b.usage.Total()
+	if !spent.IsZero() {
+		seq = b.am.bufferDebit(Debit{
+			Account:   b.accountID,
+			Usage:     b.usage,
+			Source:    b.source,
+			Timestamp: time.Now(),
+		})
 	}
 	// calculate the remainder and zero out the budget
-	spent := b.usage.Total()
 	rem := b.max.Sub(spent)
 	// zero the budget
 	b.max = types.ZeroCurrency
@@ -145,14 +160,21 @@ func (b *Budget) Commit() error {
 		panic("account missing from memory")
 	}
 	state.openTxns--
-	if state.openTxns <= 0 {
-		// if there are no more open transactions, remove the account
-		// from memory.
+	state.pending = state.pending.Add(spent)
+	if state.openTxns <= 0 && state.pending.IsZero() {
+		// if there are no more open transactions or unflushed debits,
+		// remove the account from memory.
 		delete(b.am.balances, b.accountID)
+	} else {
+		// add the remaining balance back to the spendable balance
+		state.balance = state.balance.Add(rem)
+		b.am.balances[b.accountID] = state
+	}
+	b.am.mu.Unlock()
+
+	if seq == 0 {
 		return nil
 	}
-	// add the remaining balance back to the spendable balance
-	state.balance = state.balance.Add(rem)
-	b.am.balances[b.accountID] = state
-	return nil
+	// wait for the debit to be journaled
+	return b.am.writeJournal(seq)
 }
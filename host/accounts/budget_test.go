@@ -8,6 +8,7 @@ import (
 	"testing"
 	"time"
 
+	rhp3 "go.sia.tech/core/rhp/v3"
 	"go.sia.tech/core/types"
 	"go.sia.tech/hostd/host/accounts"
 	"go.sia.tech/hostd/host/alerts"
@@ -19,6 +20,7 @@ import (
 	"go.sia.tech/siad/modules/consensus"
 	"go.sia.tech/siad/modules/gateway"
 	"go.sia.tech/siad/modules/transactionpool"
+	"go.uber.org/zap"
 	"go.uber.org/zap/zaptest"
 	"lukechampine.com/frand"
 )
@@ -108,7 +110,10 @@ func TestBudget(t *testing.T) {
 	}
 	defer w.Close()
 
-	a := alerts.NewManager()
+	a, err := alerts.NewManager(db, log.Named("alerts"))
+	if err != nil {
+		t.Fatal(err)
+	}
 	sm, err := storage.NewVolumeManager(db, a, cm, log.Named("storage"), 0)
 	if err != nil {
 		t.Fatal(err)
@@ -137,7 +142,11 @@ func TestBudget(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	am := accounts.NewManager(db, ephemeralSettings{maxBalance: types.NewCurrency64(100)})
+	am, err := accounts.NewManager(db, ephemeralSettings{maxBalance: types.NewCurrency64(100)}, log.Named("accounts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer am.Close()
 	accountID := frand.Entropy256()
 	expectedFunding := amount
 	req := accounts.FundAccountWithContract{
@@ -224,7 +233,18 @@ func TestBudget(t *testing.T) {
 	expectedFunding = expectedFunding.Sub(spendAmount)
 	if err := budget.Commit(); err != nil {
 		t.Fatal(err)
-	} else if sources, err := am.AccountFunding(accountID); err != nil {
+	}
+
+	// the debit should be journaled and reflected in the in-memory balance
+	if balance, err := am.Balance(accountID); err != nil {
+		t.Fatal(err)
+	} else if !balance.Equals(amount.Sub(spendAmount)) {
+		t.Fatalf("expected in-memory balance to be %v, got %v", amount.Sub(spendAmount), balance)
+	} else if err := am.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if sources, err := am.AccountFunding(accountID); err != nil {
 		t.Fatal("expected successful funding", err)
 	} else if len(sources) != 1 {
 		t.Fatalf("expected 1 funding source, got %v", len(sources))
@@ -278,6 +298,8 @@ func TestBudget(t *testing.T) {
 		t.Fatal(err)
 	} else if err := budget.Commit(); err != nil {
 		t.Fatal(err)
+	} else if err := am.Flush(); err != nil {
+		t.Fatal(err)
 	} else if sources, err := am.AccountFunding(accountID); err != nil {
 		t.Fatal("expected successful funding", err)
 	} else if len(sources) != 0 { // exhausted funding source should be deleted
@@ -299,4 +321,321 @@ func TestBudget(t *testing.T) {
 	} else if m.Accounts.Active != 1 {
 		t.Fatalf("expected 1 active accounts, got %v", m.Accounts.Active)
 	}
+
+	// check that the deposit and both withdrawals were recorded in the
+	// account's ledger, newest first
+	history, err := am.AccountHistory(accountID, 100, 0)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(history) != 3 {
+		t.Fatalf("expected 3 ledger entries, got %v", len(history))
+	}
+	expectedEntries := []struct {
+		entryType string
+		amount    types.Currency
+		balance   types.Currency
+	}{
+		{accounts.LedgerEntryWithdrawal, expectedBalance, types.ZeroCurrency},
+		{accounts.LedgerEntryWithdrawal, spendAmount, expectedBalance},
+		{accounts.LedgerEntryDeposit, amount, amount},
+	}
+	for i, expected := range expectedEntries {
+		entry := history[i]
+		switch {
+		case entry.Type != expected.entryType:
+			t.Fatalf("entry %d: expected type %q, got %q", i, expected.entryType, entry.Type)
+		case !entry.Amount.Equals(expected.amount):
+			t.Fatalf("entry %d: expected amount %v, got %v", i, expected.amount, entry.Amount)
+		case !entry.Balance.Equals(expected.balance):
+			t.Fatalf("entry %d: expected balance %v, got %v", i, expected.balance, entry.Balance)
+		}
+	}
+	if !history[1].Usage.RPCRevenue.Equals(spendAmount) {
+		t.Fatalf("expected withdrawal usage %v, got %v", spendAmount, history[1].Usage.RPCRevenue)
+	} else if history[2].ContractID != rev.Revision.ParentID {
+		t.Fatalf("expected deposit from contract %v, got %v", rev.Revision.ParentID, history[2].ContractID)
+	}
+}
+
+// newFundedAccount initializes a store with a contract and an account funded
+// with amount.
+func newFundedAccount(tb testing.TB, amount types.Currency) (*sqlite.Store, rhp3.Account) {
+	db, err := sqlite.OpenDatabase(filepath.Join(tb.TempDir(), "hostd.db"), zap.NewNop())
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { db.Close() })
+
+	rev := contracts.SignedRevision{
+		Revision: types.FileContractRevision{
+			ParentID: frand.Entropy256(),
+			UnlockConditions: types.UnlockConditions{
+				PublicKeys: []types.UnlockKey{
+					{Algorithm: types.SpecifierEd25519, Key: frand.Bytes(32)},
+					{Algorithm: types.SpecifierEd25519, Key: frand.Bytes(32)},
+				},
+			},
+		},
+	}
+	if err := db.AddContract(rev, []types.Transaction{{}}, types.Siacoins(1), contracts.Usage{}, 0); err != nil {
+		tb.Fatal(err)
+	}
+
+	accountID := frand.Entropy256()
+	_, err = db.CreditAccountWithContract(accounts.FundAccountWithContract{
+		Account:    accountID,
+		Amount:     amount,
+		Revision:   rev,
+		Expiration: time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return db, accountID
+}
+
+func TestJournalReplay(t *testing.T) {
+	amount := types.Siacoins(1)
+	db, accountID := newFundedAccount(t, amount)
+
+	// journal debits without flushing them to simulate a crash
+	debits := make([]accounts.Debit, 10)
+	for i := range debits {
+		debits[i] = accounts.Debit{
+			Account:   accountID,
+			Usage:     accounts.Usage{RPCRevenue: types.NewCurrency64(100)},
+			Timestamp: time.Now(),
+		}
+	}
+	if err := db.AppendAccountDebits(debits); err != nil {
+		t.Fatal(err)
+	} else if balance, err := db.AccountBalance(accountID); err != nil {
+		t.Fatal(err)
+	} else if !balance.Equals(amount) {
+		t.Fatalf("expected debits to be unflushed, got balance %v", balance)
+	}
+
+	// the journal should be replayed when the manager is initialized
+	am, err := accounts.NewManager(db, ephemeralSettings{maxBalance: amount}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer am.Close()
+
+	expected := amount.Sub(types.NewCurrency64(1000))
+	if balance, err := db.AccountBalance(accountID); err != nil {
+		t.Fatal(err)
+	} else if !balance.Equals(expected) {
+		t.Fatalf("expected balance %v after replay, got %v", expected, balance)
+	} else if balance, err := am.Balance(accountID); err != nil {
+		t.Fatal(err)
+	} else if !balance.Equals(expected) {
+		t.Fatalf("expected balance %v after replay, got %v", expected, balance)
+	}
+
+	// flushing again should not apply the debits twice
+	if err := am.Flush(); err != nil {
+		t.Fatal(err)
+	} else if balance, err := db.AccountBalance(accountID); err != nil {
+		t.Fatal(err)
+	} else if !balance.Equals(expected) {
+		t.Fatalf("expected balance %v after second flush, got %v", expected, balance)
+	}
+
+	if history, err := am.AccountHistory(accountID, 100, 0); err != nil {
+		t.Fatal(err)
+	} else if len(history) != 11 {
+		t.Fatalf("expected 11 ledger entries, got %v", len(history))
+	} else if !history[0].Balance.Equals(expected) {
+		t.Fatalf("expected last withdrawal to leave %v, got %v", expected, history[0].Balance)
+	}
+}
+
+func TestJournalCommit(t *testing.T) {
+	amount := types.Siacoins(1)
+	db, accountID := newFundedAccount(t, amount)
+
+	am, err := accounts.NewManager(db, ephemeralSettings{maxBalance: amount}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// commit budgets concurrently
+	const n = 50
+	spend := types.NewCurrency64(100)
+	errCh := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			budget, err := am.Budget(accountID, spend)
+			if err != nil {
+				errCh <- err
+				return
+			} else if err := budget.Spend(accounts.Usage{RPCRevenue: spend}); err != nil {
+				errCh <- err
+				return
+			}
+			errCh <- budget.Commit()
+		}()
+	}
+	for i := 0; i < n; i++ {
+		if err := <-errCh; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// every committed debit must be in the journal even if the manager is
+	// never closed. A new manager replays the journal.
+	am2, err := accounts.NewManager(db, ephemeralSettings{maxBalance: amount}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer am2.Close()
+
+	expected := amount.Sub(spend.Mul64(n))
+	if balance, err := db.AccountBalance(accountID); err != nil {
+		t.Fatal(err)
+	} else if !balance.Equals(expected) {
+		t.Fatalf("expected balance %v, got %v", expected, balance)
+	}
+
+	// the original manager's in-memory state is now stale; flushing it
+	// should not apply the debits twice
+	if err := am.Close(); err != nil {
+		t.Fatal(err)
+	} else if balance, err := db.AccountBalance(accountID); err != nil {
+		t.Fatal(err)
+	} else if !balance.Equals(expected) {
+		t.Fatalf("expected balance %v after second flush, got %v", expected, balance)
+	}
+}
+
+func TestJournalDiscard(t *testing.T) {
+	amount := types.NewCurrency64(1000)
+	db, accountID := newFundedAccount(t, amount)
+
+	am, err := accounts.NewManager(db, ephemeralSettings{maxBalance: amount}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer am.Close()
+
+	// journal debits that can never be applied: one for an account that does
+	// not exist and one that exceeds the account's balance
+	missingID := rhp3.Account(frand.Entropy256())
+	debits := []accounts.Debit{
+		{Account: missingID, Usage: accounts.Usage{RPCRevenue: types.NewCurrency64(10)}, Timestamp: time.Now()},
+		{Account: accountID, Usage: accounts.Usage{RPCRevenue: amount.Add(types.NewCurrency64(1))}, Timestamp: time.Now()},
+	}
+	if err := db.AppendAccountDebits(debits); err != nil {
+		t.Fatal(err)
+	}
+
+	flushed, err := db.FlushAccountDebits()
+	if err != nil {
+		t.Fatal(err)
+	} else if len(flushed) != 2 {
+		t.Fatalf("expected 2 accounts to be removed from the journal, got %v", len(flushed))
+	} else if !flushed[accountID].Equals(debits[1].Usage.Total()) {
+		t.Fatalf("expected %v to be removed from the journal, got %v", debits[1].Usage.Total(), flushed[accountID])
+	}
+
+	// the discarded debits should not be retried
+	if flushed, err := db.FlushAccountDebits(); err != nil {
+		t.Fatal(err)
+	} else if len(flushed) != 0 {
+		t.Fatalf("expected the journal to be empty, got %v accounts", len(flushed))
+	}
+
+	// the balance should be unchanged and the discarded debits should be in
+	// the audit log
+	if balance, err := db.AccountBalance(accountID); err != nil {
+		t.Fatal(err)
+	} else if !balance.Equals(amount) {
+		t.Fatalf("expected balance %v, got %v", amount, balance)
+	}
+	for _, id := range []rhp3.Account{missingID, accountID} {
+		entries, err := am.AuditLog(id, 100, 0)
+		if err != nil {
+			t.Fatal(err)
+		} else if len(entries) != 1 {
+			t.Fatalf("expected 1 audit entry, got %v", len(entries))
+		} else if entries[0].Action != accounts.AuditActionDiscardDebits {
+			t.Fatalf("expected action %q, got %q", accounts.AuditActionDiscardDebits, entries[0].Action)
+		}
+	}
+}
+
+// BenchmarkDebit compares applying each debit to the store when it is
+// committed with journaling debits and flushing them in batches.
+func BenchmarkDebit(b *testing.B) {
+	usage := accounts.Usage{RPCRevenue: types.NewCurrency64(1)}
+
+	newManager := func(b *testing.B) (*accounts.AccountManager, rhp3.Account) {
+		db, accountID := newFundedAccount(b, types.Siacoins(1))
+		am, err := accounts.NewManager(db, ephemeralSettings{maxBalance: types.Siacoins(1)}, zap.NewNop())
+		if err != nil {
+			b.Fatal(err)
+		}
+		return am, accountID
+	}
+
+	debit := func(am *accounts.AccountManager, accountID rhp3.Account) error {
+		budget, err := am.Budget(accountID, usage.Total())
+		if err != nil {
+			return err
+		} else if err := budget.Spend(usage); err != nil {
+			return err
+		}
+		return budget.Commit()
+	}
+
+	b.Run("direct", func(b *testing.B) {
+		am, accountID := newManager(b)
+		defer am.Close()
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if err := debit(am, accountID); err != nil {
+				b.Fatal(err)
+			} else if err := am.Flush(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("journal", func(b *testing.B) {
+		am, accountID := newManager(b)
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if err := debit(am, accountID); err != nil {
+				b.Fatal(err)
+			}
+		}
+		// include the final flush in the measurement
+		if err := am.Close(); err != nil {
+			b.Fatal(err)
+		}
+	})
+
+	b.Run("journal parallel", func(b *testing.B) {
+		am, accountID := newManager(b)
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				if err := debit(am, accountID); err != nil {
+					b.Error(err)
+					return
+				}
+			}
+		})
+		// include the final flush in the measurement
+		if err := am.Close(); err != nil {
+			b.Fatal(err)
+		}
+	})
 }
@@ -1,6 +1,8 @@
 package alerts
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"sort"
 	"strings"
@@ -8,6 +10,8 @@ import (
 	"time"
 
 	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/internal/threadgroup"
+	"go.uber.org/zap"
 )
 
 const (
@@ -24,6 +28,34 @@ const (
 	severityWarningStr  = "warning"
 	severityErrorStr    = "error"
 	severityCriticalStr = "critical"
+
+	// EventTypeRegistered is the type of the event sent when a new alert
+	// is registered.
+	EventTypeRegistered = "registered"
+	// EventTypeUpdated is the type of the event sent when an active alert
+	// is registered again.
+	EventTypeUpdated = "updated"
+	// EventTypeDismissed is the type of the event sent when an alert is
+	// dismissed.
+	EventTypeDismissed = "dismissed"
+
+	// DismissedBySystem is recorded as the dismisser of alerts that are
+	// dismissed by the host, such as progress alerts that are removed
+	// when an operation completes.
+	DismissedBySystem = "system"
+
+	// defaultHistoryLimit is the number of alerts returned by a history
+	// query if no limit is specified.
+	defaultHistoryLimit = 100
+	// maxHistoryLimit is the maximum number of alerts returned by a single
+	// history query.
+	maxHistoryLimit = 1000
+
+	// persistInterval is the minimum time between persisted updates of an
+	// active alert whose severity and message have not changed. Progress
+	// alerts are registered again for every unit of work, so persisting each
+	// update would add a database write to every sector.
+	persistInterval = 30 * time.Second
 )
 
 type (
@@ -44,11 +76,87 @@ type (
 		Timestamp time.Time      `json:"timestamp"`
 	}
 
+	// A HistoryAlert is a single occurrence of an alert. An alert that is
+	// registered again after being dismissed starts a new occurrence.
+	HistoryAlert struct {
+		Alert
+		// FirstSeen is the time the alert was first registered.
+		FirstSeen time.Time `json:"firstSeen"`
+		// LastSeen is the time the alert was last registered.
+		LastSeen time.Time `json:"lastSeen"`
+		// Occurrences is the number of times the alert was registered
+		// before it was dismissed.
+		Occurrences uint64 `json:"occurrences"`
+		// DismissedBy is who dismissed the alert. It is empty if the alert
+		// is still active.
+		DismissedBy string `json:"dismissedBy,omitempty"`
+		// DismissedAt is the time the alert was dismissed. It is nil if the
+		// alert is still active.
+		DismissedAt *time.Time `json:"dismissedAt,omitempty"`
+	}
+
+	// A HistoryFilter filters the alert history.
+	HistoryFilter struct {
+		// Severity limits the results to alerts with the severity. If zero,
+		// alerts of every severity are returned.
+		Severity Severity `json:"severity,omitempty"`
+		// From and To limit the results to alerts that were active at any
+		// time in the range [From, To). A zero value is unbounded.
+		From time.Time `json:"from"`
+		To   time.Time `json:"to"`
+
+		// pagination
+		Limit  int `json:"limit"`
+		Offset int `json:"offset"`
+	}
+
+	// An Event is a change to one of the host's alerts.
+	Event struct {
+		Type  string `json:"type"`
+		Alert Alert  `json:"alert"`
+		// DismissedBy is who dismissed the alert. It is only set for
+		// dismissed events.
+		DismissedBy string    `json:"dismissedBy,omitempty"`
+		Timestamp   time.Time `json:"timestamp"`
+	}
+
+	// A Subscriber receives alert events. Events are sent synchronously
+	// while the manager's lock is held so that every subscriber receives
+	// them in the order they were applied. Subscribers must not block or
+	// call the manager.
+	Subscriber interface {
+		ReceiveAlertEvent(Event)
+	}
+
+	// A Store persists the host's alerts.
+	Store interface {
+		// ActiveAlerts returns the alerts that have not been dismissed.
+		ActiveAlerts() ([]Alert, error)
+		// RegisterAlert adds an alert or records another occurrence of an
+		// active alert.
+		RegisterAlert(Alert) error
+		// DismissAlerts marks the active alerts with the given IDs as
+		// dismissed.
+		DismissAlerts(by string, timestamp time.Time, ids ...types.Hash256) error
+		// AlertHistory returns the alerts matching the filter, most recently
+		// seen first, and the total number of matching alerts.
+		AlertHistory(HistoryFilter) ([]HistoryAlert, int, error)
+	}
+
 	// A Manager manages the host's alerts.
 	Manager struct {
+		store   Store
+		log     *zap.Logger
+		tg      *threadgroup.ThreadGroup
+		workers []*sinkWorker
+
 		mu sync.Mutex
 		// alerts is a map of alert IDs to their current alert.
 		alerts map[types.Hash256]Alert
+		// persisted is the timestamp of the last persisted version of each
+		// active alert.
+		persisted   map[types.Hash256]time.Time
+		subscribers map[Subscriber]struct{}
 	}
 )
 
@@ -73,9 +181,9 @@ func (s Severity) MarshalJSON() ([]byte, error) {
 	return []byte(fmt.Sprintf(`%q`, s.String())), nil
 }
 
-// UnmarshalJSON implements the json.Unmarshaler interface.
-func (s *Severity) UnmarshalJSON(b []byte) error {
-	status := strings.Trim(string(b), `"`)
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (s *Severity) UnmarshalText(b []byte) error {
+	status := string(b)
 	switch status {
 	case severityInfoStr:
 		*s = SeverityInfo
@@ -91,28 +199,164 @@ func (s *Severity) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
-// Register registers a new alert with the manager
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (s *Severity) UnmarshalJSON(b []byte) error {
+	return s.UnmarshalText([]byte(strings.Trim(string(b), `"`)))
+}
+
+// Validate returns an error if the filter is invalid.
+func (f HistoryFilter) Validate() error {
+	switch {
+	case f.Severity > SeverityCritical:
+		return fmt.Errorf("invalid severity %d", f.Severity)
+	case !f.From.IsZero() && !f.To.IsZero() && !f.From.Before(f.To):
+		return errors.New("from must be before to")
+	case f.Limit < 0 || f.Limit > maxHistoryLimit:
+		return fmt.Errorf("limit must be between 0 and %d", maxHistoryLimit)
+	case f.Offset < 0:
+		return errors.New("offset must be positive")
+	}
+	return nil
+}
+
+// cloneData returns a deep copy of an alert's data. Callers commonly update
+// an alert's data and register it again, so the manager must not share the
+// map with them while it is being encoded by sinks or subscribers.
+func cloneData(data map[string]any) map[string]any {
+	if data == nil {
+		return nil
+	}
+	clone := make(map[string]any, len(data))
+	for k, v := range data {
+		clone[k] = cloneValue(v)
+	}
+	return clone
+}
+
+func cloneValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		return cloneData(v)
+	case []any:
+		clone := make([]any, len(v))
+		for i := range v {
+			clone[i] = cloneValue(v[i])
+		}
+		return clone
+	default:
+		return v
+	}
+}
+
+// Register registers a new alert with the manager. If an alert with the same
+// ID is already active, it is replaced and its occurrence count is
+// incremented.
+//
+// New alerts are sent to the manager's sinks. Reoccurring alerts are only
+// sent again if their severity increased.
+//
+// Updates to an active alert that do not change its severity or message are
+// persisted at most once per persistInterval of the alert's timestamp, so the
+// stored data and occurrence count of frequently updated alerts may lag
+// behind the active alert.
 func (m *Manager) Register(a Alert) {
 	if a.ID == (types.Hash256{}) {
 		panic("cannot register alert with empty ID") // developer error
 	} else if a.Timestamp.IsZero() {
 		panic("cannot register alert with zero timestamp") // developer error
 	}
+	a.Data = cloneData(a.Data)
 
 	m.mu.Lock()
+	prev, exists := m.alerts[a.ID]
 	m.alerts[a.ID] = a
+	// the store is updated under the lock so that concurrent registrations
+	// and dismissals are persisted in the same order they are applied
+	if !exists || a.Severity != prev.Severity || a.Message != prev.Message || a.Timestamp.Sub(m.persisted[a.ID]) >= persistInterval {
+		if err := m.store.RegisterAlert(a); err != nil {
+			m.log.Error("failed to persist alert", zap.Stringer("id", a.ID), zap.Error(err))
+		} else {
+			m.persisted[a.ID] = a.Timestamp
+		}
+	}
+
+	event := Event{
+		Type:      EventTypeRegistered,
+		Alert:     a,
+		Timestamp: time.Now(),
+	}
+	if exists {
+		event.Type = EventTypeUpdated
+	}
+	for sub := range m.subscribers {
+		sub.ReceiveAlertEvent(event)
+	}
+
+	if !exists || a.Severity > prev.Severity {
+		for _, w := range m.workers {
+			w.enqueue(a)
+		}
+	}
 	m.mu.Unlock()
 }
 
-// Dismiss removes the alerts with the given IDs.
+// Dismiss removes the alerts with the given IDs. The alerts are recorded as
+// dismissed by the system.
 func (m *Manager) Dismiss(ids ...types.Hash256) {
+	m.DismissBy(DismissedBySystem, ids...)
+}
+
+// DismissBy removes the alerts with the given IDs and records who dismissed
+// them.
+func (m *Manager) DismissBy(by string, ids ...types.Hash256) {
+	var dismissed []Alert
+	timestamp := time.Now()
 	m.mu.Lock()
 	for _, id := range ids {
-		delete(m.alerts, id)
+		if a, ok := m.alerts[id]; ok {
+			dismissed = append(dismissed, a)
+			delete(m.alerts, id)
+			delete(m.persisted, id)
+		}
+	}
+	if err := m.store.DismissAlerts(by, timestamp, ids...); err != nil {
+		m.log.Error("failed to persist dismissed alerts", zap.Error(err))
+	}
+	for _, a := range dismissed {
+		event := Event{
+			Type:        EventTypeDismissed,
+			Alert:       a,
+			DismissedBy: by,
+			Timestamp:   timestamp,
+		}
+		for sub := range m.subscribers {
+			sub.ReceiveAlertEvent(event)
+		}
+	}
+
+	// dismissed alerts that have not been delivered are no longer relevant
+	for _, w := range m.workers {
+		for _, id := range ids {
+			w.remove(id)
+		}
 	}
 	m.mu.Unlock()
 }
 
+// Subscribe subscribes to alert events.
+func (m *Manager) Subscribe(sub Subscriber) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.subscribers[sub] = struct{}{}
+}
+
+// Unsubscribe unsubscribes from alert events.
+func (m *Manager) Unsubscribe(sub Subscriber) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.subscribers, sub)
+}
+
 // Active returns the host's active alerts.
 func (m *Manager) Active() []Alert {
 	m.mu.Lock()
@@ -128,9 +372,56 @@ func (m *Manager) Active() []Alert {
 	return alerts
 }
 
-// NewManager initializes a new alerts manager.
-func NewManager() *Manager {
-	return &Manager{
-		alerts: make(map[types.Hash256]Alert),
+// History returns the alerts matching the filter, including dismissed
+// alerts, and the total number of matching alerts.
+func (m *Manager) History(filter HistoryFilter) ([]HistoryAlert, int, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, 0, err
+	}
+	if filter.Limit == 0 {
+		filter.Limit = defaultHistoryLimit
+	}
+	return m.store.AlertHistory(filter)
+}
+
+// Close stops delivering alerts to the manager's sinks.
+func (m *Manager) Close() error {
+	m.tg.Stop()
+	return nil
+}
+
+// NewManager initializes a new alerts manager. Alerts that were active when
+// the host was last stopped are restored from the store. They are not sent
+// to the sinks again.
+func NewManager(store Store, log *zap.Logger, sinks ...Sink) (*Manager, error) {
+	active, err := store.ActiveAlerts()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load active alerts: %w", err)
+	}
+
+	m := &Manager{
+		store:       store,
+		log:         log,
+		tg:          threadgroup.New(),
+		alerts:      make(map[types.Hash256]Alert),
+		persisted:   make(map[types.Hash256]time.Time),
+		subscribers: make(map[Subscriber]struct{}),
+	}
+	for _, a := range active {
+		m.alerts[a.ID] = a
+	}
+
+	for _, sink := range sinks {
+		ctx, cancel, err := m.tg.AddContext(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		w := newSinkWorker(sink, log.Named("sinks"))
+		m.workers = append(m.workers, w)
+		go func() {
+			defer cancel()
+			w.run(ctx)
+		}()
 	}
+	return m, nil
 }
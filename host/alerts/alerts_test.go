@@ -0,0 +1,117 @@
+package alerts_test
+
+import (
+	"testing"
+	"time"
+
+	"go.sia.tech/hostd/host/alerts"
+	"go.uber.org/zap/zaptest"
+)
+
+// eventRecorder records the alert events it receives
+type eventRecorder struct {
+	events []alerts.Event
+}
+
+func (er *eventRecorder) ReceiveAlertEvent(e alerts.Event) {
+	er.events = append(er.events, e)
+}
+
+// countingStore counts the alerts persisted
+type countingStore struct {
+	noopStore
+	registered int
+}
+
+func (cs *countingStore) RegisterAlert(alerts.Alert) error {
+	cs.registered++
+	return nil
+}
+
+func TestRegisterThrottle(t *testing.T) {
+	var store countingStore
+	am, err := alerts.NewManager(&store, zaptest.NewLogger(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer am.Close()
+
+	// progress updates should not be persisted individually
+	a := newAlert(alerts.SeverityInfo)
+	for i := 0; i < 100; i++ {
+		a.Data = map[string]any{"progress": i}
+		a.Timestamp = a.Timestamp.Add(time.Millisecond)
+		am.Register(a)
+	}
+	if store.registered != 1 {
+		t.Fatalf("expected 1 persisted alert, got %v", store.registered)
+	}
+
+	// a change in severity should be persisted immediately
+	a.Severity = alerts.SeverityWarning
+	am.Register(a)
+	if store.registered != 2 {
+		t.Fatalf("expected 2 persisted alerts, got %v", store.registered)
+	}
+
+	// updates should be persisted again after the interval
+	a.Timestamp = a.Timestamp.Add(time.Hour)
+	am.Register(a)
+	if store.registered != 3 {
+		t.Fatalf("expected 3 persisted alerts, got %v", store.registered)
+	}
+
+	// the alert should be persisted after being dismissed and registered
+	// again
+	am.Dismiss(a.ID)
+	am.Register(a)
+	if store.registered != 4 {
+		t.Fatalf("expected 4 persisted alerts, got %v", store.registered)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	am, err := alerts.NewManager(noopStore{}, zaptest.NewLogger(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer am.Close()
+
+	var rec eventRecorder
+	am.Subscribe(&rec)
+
+	a := newAlert(alerts.SeverityWarning)
+	am.Register(a)
+	a.Severity = alerts.SeverityError
+	am.Register(a)
+	am.DismissBy("alice", a.ID)
+	// dismissing an inactive alert should not send an event
+	am.Dismiss(a.ID)
+
+	expected := []struct {
+		eventType string
+		severity  alerts.Severity
+	}{
+		{alerts.EventTypeRegistered, alerts.SeverityWarning},
+		{alerts.EventTypeUpdated, alerts.SeverityError},
+		{alerts.EventTypeDismissed, alerts.SeverityError},
+	}
+	if len(rec.events) != len(expected) {
+		t.Fatalf("expected %v events, got %v", len(expected), len(rec.events))
+	}
+	for i, exp := range expected {
+		if e := rec.events[i]; e.Type != exp.eventType || e.Alert.ID != a.ID || e.Alert.Severity != exp.severity {
+			t.Fatalf("event %d: expected %v %v, got %+v", i, exp.eventType, exp.severity, e)
+		}
+	}
+	if rec.events[2].DismissedBy != "alice" {
+		t.Fatalf("expected dismissed by alice, got %q", rec.events[2].DismissedBy)
+	}
+
+	// unsubscribed subscribers should not receive events
+	am.Unsubscribe(&rec)
+	am.Register(newAlert(alerts.SeverityInfo))
+	if len(rec.events) != len(expected) {
+		t.Fatalf("expected no new events, got %v", len(rec.events))
+	}
+}
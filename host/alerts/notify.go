@@ -0,0 +1,136 @@
+package alerts
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.sia.tech/core/types"
+	"go.uber.org/zap"
+)
+
+// notifyTimeout is the maximum time a notifier has to deliver an alert,
+// including retries.
+const notifyTimeout = 2 * time.Minute
+
+type (
+	// A Notifier delivers alerts to an external service.
+	Notifier interface {
+		Notify(ctx context.Context, a Alert) error
+	}
+
+	// A Sink delivers alerts at or above a minimum severity to a notifier.
+	Sink struct {
+		// Name identifies the sink in logs.
+		Name     string
+		Notifier Notifier
+		// MinSeverity is the minimum severity of alerts delivered to the
+		// sink.
+		MinSeverity Severity
+		// RateLimit is the minimum time between notifications. Alerts
+		// registered while the sink is rate limited are queued and
+		// delivered once the limit expires. If an alert is registered
+		// again before it is delivered, only the latest version is sent.
+		RateLimit time.Duration
+	}
+
+	// sinkWorker delivers queued alerts to a sink in the background.
+	sinkWorker struct {
+		sink Sink
+		log  *zap.Logger
+
+		wake chan struct{}
+
+		mu      sync.Mutex
+		order   []types.Hash256
+		pending map[types.Hash256]Alert
+	}
+)
+
+// enqueue adds an alert to the queue. Alerts already in the queue are
+// replaced so that only the latest version is delivered.
+func (w *sinkWorker) enqueue(a Alert) {
+	if a.Severity < w.sink.MinSeverity {
+		return
+	}
+
+	w.mu.Lock()
+	if _, ok := w.pending[a.ID]; !ok {
+		w.order = append(w.order, a.ID)
+	}
+	w.pending[a.ID] = a
+	w.mu.Unlock()
+
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// remove removes an alert from the queue.
+func (w *sinkWorker) remove(id types.Hash256) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.pending, id)
+}
+
+// next pops the oldest alert from the queue.
+func (w *sinkWorker) next() (Alert, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for len(w.order) > 0 {
+		id := w.order[0]
+		w.order = w.order[1:]
+		if a, ok := w.pending[id]; ok {
+			delete(w.pending, id)
+			return a, true
+		}
+	}
+	return Alert{}, false
+}
+
+// run delivers queued alerts until the context is cancelled.
+func (w *sinkWorker) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.wake:
+		}
+
+		for {
+			a, ok := w.next()
+			if !ok {
+				break
+			}
+
+			log := w.log.With(zap.Stringer("id", a.ID), zap.Stringer("severity", a.Severity))
+			nctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+			err := w.sink.Notifier.Notify(nctx, a)
+			cancel()
+			if err != nil {
+				log.Error("failed to deliver alert", zap.Error(err))
+			} else {
+				log.Debug("delivered alert")
+			}
+
+			if w.sink.RateLimit <= 0 {
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(w.sink.RateLimit):
+			}
+		}
+	}
+}
+
+func newSinkWorker(sink Sink, log *zap.Logger) *sinkWorker {
+	return &sinkWorker{
+		sink:    sink,
+		log:     log.Named(sink.Name),
+		wake:    make(chan struct{}, 1),
+		pending: make(map[types.Hash256]Alert),
+	}
+}
@@ -0,0 +1,356 @@
+package alerts_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/host/alerts"
+	"go.uber.org/zap/zaptest"
+	"lukechampine.com/frand"
+)
+
+// noopStore is an alert store that does not persist alerts
+type noopStore struct{}
+
+func (noopStore) ActiveAlerts() ([]alerts.Alert, error) { return nil, nil }
+func (noopStore) RegisterAlert(alerts.Alert) error      { return nil }
+func (noopStore) DismissAlerts(string, time.Time, ...types.Hash256) error {
+	return nil
+}
+func (noopStore) AlertHistory(alerts.HistoryFilter) ([]alerts.HistoryAlert, int, error) {
+	return nil, 0, nil
+}
+
+// chanNotifier sends alerts to a channel
+type chanNotifier chan alerts.Alert
+
+func (cn chanNotifier) Notify(_ context.Context, a alerts.Alert) error {
+	cn <- a
+	return nil
+}
+
+func newAlert(severity alerts.Severity) alerts.Alert {
+	return alerts.Alert{
+		ID:        frand.Entropy256(),
+		Severity:  severity,
+		Message:   "disk failing",
+		Data:      map[string]any{"volume": "/mnt/disk1"},
+		Timestamp: time.Now(),
+	}
+}
+
+func receive(t *testing.T, ch <-chan alerts.Alert) alerts.Alert {
+	t.Helper()
+	select {
+	case a := <-ch:
+		return a
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for notification")
+		panic("unreachable")
+	}
+}
+
+func expectNone(t *testing.T, ch <-chan alerts.Alert) {
+	t.Helper()
+	select {
+	case a := <-ch:
+		t.Fatalf("unexpected notification %+v", a)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestSinkDelivery(t *testing.T) {
+	notifications := make(chanNotifier, 10)
+	am, err := alerts.NewManager(noopStore{}, zaptest.NewLogger(t), alerts.Sink{
+		Name:        "test",
+		Notifier:    notifications,
+		MinSeverity: alerts.SeverityWarning,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer am.Close()
+
+	// alerts below the minimum severity should not be delivered
+	am.Register(newAlert(alerts.SeverityInfo))
+	expectNone(t, notifications)
+
+	a := newAlert(alerts.SeverityWarning)
+	am.Register(a)
+	if got := receive(t, notifications); got.ID != a.ID {
+		t.Fatalf("expected alert %v, got %v", a.ID, got.ID)
+	}
+
+	// reoccurring alerts should be deduplicated
+	am.Register(a)
+	expectNone(t, notifications)
+
+	// unless the severity increased
+	a.Severity = alerts.SeverityCritical
+	am.Register(a)
+	if got := receive(t, notifications); got.Severity != alerts.SeverityCritical {
+		t.Fatalf("expected critical alert, got %v", got.Severity)
+	}
+
+	// a dismissed alert should be delivered again if it reoccurs
+	am.Dismiss(a.ID)
+	am.Register(a)
+	if got := receive(t, notifications); got.ID != a.ID {
+		t.Fatalf("expected alert %v, got %v", a.ID, got.ID)
+	}
+
+	// changing the data after registering should not change the delivered
+	// alert
+	am.Dismiss(a.ID)
+	am.Register(a)
+	a.Data["volume"] = "/mnt/disk2"
+	if got := receive(t, notifications); got.Data["volume"] != "/mnt/disk1" {
+		t.Fatalf("expected original data, got %v", got.Data)
+	}
+}
+
+func TestSinkRateLimit(t *testing.T) {
+	const rateLimit = 500 * time.Millisecond
+
+	notifications := make(chanNotifier, 10)
+	am, err := alerts.NewManager(noopStore{}, zaptest.NewLogger(t), alerts.Sink{
+		Name:      "test",
+		Notifier:  notifications,
+		RateLimit: rateLimit,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer am.Close()
+
+	first, second := newAlert(alerts.SeverityWarning), newAlert(alerts.SeverityWarning)
+	start := time.Now()
+	am.Register(first)
+	am.Register(second)
+	// escalate the second alert while it is queued, only the latest
+	// version should be delivered
+	second.Severity = alerts.SeverityCritical
+	am.Register(second)
+
+	if got := receive(t, notifications); got.ID != first.ID {
+		t.Fatalf("expected alert %v, got %v", first.ID, got.ID)
+	}
+	if got := receive(t, notifications); got.ID != second.ID || got.Severity != alerts.SeverityCritical {
+		t.Fatalf("expected critical alert %v, got %+v", second.ID, got)
+	} else if elapsed := time.Since(start); elapsed < rateLimit {
+		t.Fatalf("expected notifications to be rate limited, took %v", elapsed)
+	}
+	expectNone(t, notifications)
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var mu sync.Mutex
+	var attempts int
+	payloads := make(chan alerts.WebhookPayload, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		attempts++
+		n := attempts
+		mu.Unlock()
+		// fail the first attempt to test retries
+		if n == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		var payload alerts.WebhookPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		payloads <- payload
+	}))
+	defer srv.Close()
+
+	a := newAlert(alerts.SeverityError)
+	wn := alerts.NewWebhookNotifier(srv.URL, 1, 10*time.Millisecond)
+	if err := wn.Notify(context.Background(), a); err != nil {
+		t.Fatal(err)
+	}
+	payload := <-payloads
+	if payload.Event != "alert" || payload.Alert.ID != a.ID || payload.Alert.Severity != a.Severity || payload.Alert.Data["volume"] != "/mnt/disk1" {
+		t.Fatalf("unexpected payload %+v", payload)
+	}
+
+	// the notification should fail once the retries are exhausted
+	mu.Lock()
+	attempts = 0
+	mu.Unlock()
+	wn = alerts.NewWebhookNotifier(srv.URL, 0, 10*time.Millisecond)
+	if err := wn.Notify(context.Background(), a); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+// serveSMTP accepts a single SMTP session on l and sends the received message
+// to ch. It implements just enough of the protocol for net/smtp.
+func serveSMTP(l net.Listener, ch chan<- string) {
+	conn, err := l.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(s string) {
+		conn.Write([]byte(s + "\r\n"))
+	}
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(cmd, "MAIL"), strings.HasPrefix(cmd, "RCPT"):
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 end data with <CR><LF>.<CR><LF>")
+			var msg strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				} else if line == ".\r\n" {
+					break
+				}
+				msg.WriteString(line)
+			}
+			ch <- msg.String()
+			reply("250 OK")
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	messages := make(chan string, 1)
+	go serveSMTP(l, messages)
+
+	a := newAlert(alerts.SeverityCritical)
+	sn := &alerts.SMTPNotifier{
+		Address: l.Addr().String(),
+		From:    "host@example.com",
+		To:      []string{"ops@example.com"},
+	}
+	if err := sn.Notify(context.Background(), a); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := <-messages
+	for _, s := range []string{"Subject: [hostd] CRITICAL: disk failing", "To: ops@example.com", a.ID.String(), "/mnt/disk1"} {
+		if !strings.Contains(msg, s) {
+			t.Fatalf("expected message to contain %q, got %q", s, msg)
+		}
+	}
+}
+
+func TestSMTPNotifierTimeout(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// accept a connection, but never reply
+	closed := make(chan struct{})
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.Copy(io.Discard, conn)
+		close(closed)
+	}()
+
+	sn := &alerts.SMTPNotifier{
+		Address: l.Addr().String(),
+		From:    "host@example.com",
+		To:      []string{"ops@example.com"},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := sn.Notify(ctx, newAlert(alerts.SeverityCritical)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+
+	// the connection should be closed when the notification is abandoned
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("expected connection to be closed")
+	}
+}
+
+func TestScriptNotifier(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell scripts are not supported on windows")
+	}
+
+	dir := t.TempDir()
+	outPath := filepath.Join(dir, "out.json")
+	scriptPath := filepath.Join(dir, "notify.sh")
+	script := "#!/bin/sh\necho \"$HOSTD_ALERT_SEVERITY\" > \"$1.severity\"\ncat > \"$1\"\n"
+	if err := os.WriteFile(scriptPath, []byte(script), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	a := newAlert(alerts.SeverityError)
+	sn := &alerts.ScriptNotifier{Path: scriptPath, Args: []string{outPath}}
+	if err := sn.Notify(context.Background(), a); err != nil {
+		t.Fatal(err)
+	}
+
+	var got alerts.Alert
+	if buf, err := os.ReadFile(outPath); err != nil {
+		t.Fatal(err)
+	} else if err := json.Unmarshal(buf, &got); err != nil {
+		t.Fatal(err)
+	} else if got.ID != a.ID || got.Message != a.Message {
+		t.Fatalf("unexpected alert %+v", got)
+	}
+	if buf, err := os.ReadFile(outPath + ".severity"); err != nil {
+		t.Fatal(err)
+	} else if strings.TrimSpace(string(buf)) != "error" {
+		t.Fatalf("expected severity error, got %q", buf)
+	}
+
+	// a failing script should return an error
+	sn = &alerts.ScriptNotifier{Path: "/bin/false"}
+	if err := sn.Notify(context.Background(), a); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -0,0 +1,48 @@
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// A ScriptNotifier delivers alerts by executing a local program. The alert is
+// written to the program's stdin as JSON and its ID, severity, message, and
+// timestamp are set in the HOSTD_ALERT_* environment variables.
+type ScriptNotifier struct {
+	Path string
+	Args []string
+}
+
+// Notify implements Notifier.
+func (sn *ScriptNotifier) Notify(ctx context.Context, a Alert) error {
+	input, err := json.Marshal(a)
+	if err != nil {
+		return fmt.Errorf("failed to encode alert: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, sn.Path, sn.Args...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Env = append(os.Environ(),
+		"HOSTD_ALERT_ID="+a.ID.String(),
+		"HOSTD_ALERT_SEVERITY="+a.Severity.String(),
+		"HOSTD_ALERT_MESSAGE="+a.Message,
+		"HOSTD_ALERT_TIMESTAMP="+a.Timestamp.UTC().Format(time.RFC3339),
+	)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	if err := cmd.Run(); err != nil {
+		if out := strings.TrimSpace(output.String()); out != "" {
+			return fmt.Errorf("failed to run %q: %w: %s", sn.Path, err, out)
+		}
+		return fmt.Errorf("failed to run %q: %w", sn.Path, err)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package alerts
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// An SMTPNotifier delivers alerts by email.
+type SMTPNotifier struct {
+	// Address is the host and port of the SMTP server.
+	Address string
+	// Username and Password are used for PLAIN authentication. If Username
+	// is empty, no authentication is performed.
+	Username string
+	Password string
+
+	From string
+	To   []string
+}
+
+// message returns the email message for an alert.
+func (sn *SMTPNotifier) message(a Alert) ([]byte, error) {
+	var body strings.Builder
+	fmt.Fprintf(&body, "Severity: %s\r\n", a.Severity)
+	fmt.Fprintf(&body, "Time: %s\r\n", a.Timestamp.UTC().Format(time.RFC3339))
+	fmt.Fprintf(&body, "ID: %s\r\n\r\n", a.ID)
+	fmt.Fprintf(&body, "%s\r\n", a.Message)
+	if len(a.Data) > 0 {
+		data, err := json.MarshalIndent(a.Data, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode alert data: %w", err)
+		}
+		body.WriteString("\r\n")
+		body.WriteString(strings.ReplaceAll(string(data), "\n", "\r\n"))
+		body.WriteString("\r\n")
+	}
+
+	// strip newlines from the subject to prevent header injection
+	subject := strings.NewReplacer("\r", " ", "\n", " ").Replace(a.Message)
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", sn.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(sn.To, ", "))
+	fmt.Fprintf(&msg, "Subject: [hostd] %s: %s\r\n", strings.ToUpper(a.Severity.String()), subject)
+	fmt.Fprintf(&msg, "Date: %s\r\n", a.Timestamp.UTC().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(body.String())
+	return []byte(msg.String()), nil
+}
+
+// Notify implements Notifier.
+func (sn *SMTPNotifier) Notify(ctx context.Context, a Alert) error {
+	if len(sn.To) == 0 {
+		return errors.New("no recipients")
+	}
+	msg, err := sn.message(a)
+	if err != nil {
+		return err
+	}
+
+	host, _, err := net.SplitHostPort(sn.Address)
+	if err != nil {
+		return fmt.Errorf("failed to parse SMTP address: %w", err)
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", sn.Address)
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	// net/smtp does not support contexts, close the connection when the
+	// context is cancelled to unblock the client
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	if err := sn.send(conn, host, msg); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
+
+// send sends msg over conn. It follows the same steps as smtp.SendMail.
+func (sn *SMTPNotifier) send(conn net.Conn, host string, msg []byte) error {
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if sn.Username != "" {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("server does not support AUTH")
+		} else if err := c.Auth(smtp.PlainAuth("", sn.Username, sn.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(sn.From); err != nil {
+		return err
+	}
+	for _, addr := range sn.To {
+		if err := c.Rcpt(addr); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	} else if _, err := w.Write(msg); err != nil {
+		return err
+	} else if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
@@ -0,0 +1,100 @@
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// defaultWebhookRetryInterval is the initial time between webhook delivery
+// attempts if no interval is specified.
+const defaultWebhookRetryInterval = 5 * time.Second
+
+type (
+	// A WebhookPayload is the body of a webhook notification.
+	WebhookPayload struct {
+		Event string `json:"event"`
+		Alert Alert  `json:"alert"`
+	}
+
+	// A WebhookNotifier delivers alerts by sending a JSON payload to an HTTP
+	// endpoint.
+	WebhookNotifier struct {
+		URL string
+		// Retries is the number of times delivery is retried if the
+		// endpoint is unreachable or does not respond with a 2xx status.
+		Retries int
+		// RetryInterval is the time before the first retry. The interval
+		// doubles after each attempt.
+		RetryInterval time.Duration
+
+		client *http.Client
+	}
+)
+
+// post sends the payload to the webhook's endpoint.
+func (wn *WebhookNotifier) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wn.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := wn.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Notify implements Notifier.
+func (wn *WebhookNotifier) Notify(ctx context.Context, a Alert) error {
+	body, err := json.Marshal(WebhookPayload{
+		Event: "alert",
+		Alert: a,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode payload: %w", err)
+	}
+
+	interval := wn.RetryInterval
+	for attempt := 0; ; attempt++ {
+		err = wn.post(ctx, body)
+		if err == nil {
+			return nil
+		} else if attempt >= wn.Retries {
+			return fmt.Errorf("failed to deliver webhook after %d attempts: %w", attempt+1, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("failed to deliver webhook: %w", err)
+		case <-time.After(interval):
+		}
+		interval *= 2
+	}
+}
+
+// NewWebhookNotifier returns a notifier that posts alerts to url, retrying
+// failed deliveries up to retries times.
+func NewWebhookNotifier(url string, retries int, retryInterval time.Duration) *WebhookNotifier {
+	if retryInterval <= 0 {
+		retryInterval = defaultWebhookRetryInterval
+	}
+	return &WebhookNotifier{
+		URL:           url,
+		Retries:       retries,
+		RetryInterval: retryInterval,
+		client:        &http.Client{Timeout: 30 * time.Second},
+	}
+}
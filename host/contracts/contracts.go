@@ -75,6 +75,7 @@ type (
 		IngressRevenue   types.Currency `json:"ingress"`
 		RegistryRead     types.Currency `json:"registryRead"`
 		RegistryWrite    types.Currency `json:"registryWrite"`
+		AccountExpiry    types.Currency `json:"accountExpiry"`
 		AccountFunding   types.Currency `json:"accountFunding"`
 		RiskedCollateral types.Currency `json:"riskedCollateral"`
 	}
@@ -175,6 +176,7 @@ func (u Usage) Add(b Usage) (c Usage) {
 		RiskedCollateral: u.RiskedCollateral.Add(b.RiskedCollateral),
 		RegistryRead:     u.RegistryRead.Add(b.RegistryRead),
 		RegistryWrite:    u.RegistryWrite.Add(b.RegistryWrite),
+		AccountExpiry:    u.AccountExpiry.Add(b.AccountExpiry),
 	}
 }
 
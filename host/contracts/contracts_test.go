@@ -36,7 +36,10 @@ func TestContractUpdater(t *testing.T) {
 	}
 	defer node.Close()
 
-	am := alerts.NewManager()
+	am, err := alerts.NewManager(db, log.Named("alerts"))
+	if err != nil {
+		t.Fatal(err)
+	}
 	s, err := storage.NewVolumeManager(db, am, node.ChainManager(), log.Named("storage"), sectorCacheSize)
 	if err != nil {
 		t.Fatal(err)
@@ -79,7 +79,10 @@ func TestCheckIntegrity(t *testing.T) {
 	}
 	defer node.Close()
 
-	am := alerts.NewManager()
+	am, err := alerts.NewManager(node.Store(), log.Named("alerts"))
+	if err != nil {
+		t.Fatal(err)
+	}
 	s, err := storage.NewVolumeManager(node.Store(), am, node.ChainManager(), log.Named("storage"), 0)
 	if err != nil {
 		t.Fatal(err)
@@ -42,6 +42,8 @@ type (
 		TipState() consensus.State
 		IndexAtHeight(height uint64) (types.ChainIndex, error)
 		Subscribe(s modules.ConsensusSetSubscriber, ccID modules.ConsensusChangeID, cancel <-chan struct{}) error
+		SubscribeAtHeight(s modules.ConsensusSetSubscriber, height uint64, cancel <-chan struct{}) error
+		Unsubscribe(s modules.ConsensusSetSubscriber)
 	}
 
 	// A Wallet manages Siacoins and funds transactions
@@ -96,6 +98,14 @@ type (
 		// small number of contracts to limit memory usage.
 		rootsCache *lru.TwoQueueCache[types.FileContractID, []types.Hash256]
 
+		subMu sync.Mutex // protects the consensus subscription
+		// subCancel cancels the initial sync of the current consensus
+		// subscription
+		subCancel context.CancelFunc
+		// subDone is closed when the initial sync of the current consensus
+		// subscription returns
+		subDone chan struct{}
+
 		mu    sync.Mutex                       // guards the following fields
 		locks map[types.FileContractID]*locker // contracts must be locked while they are being modified
 	}
@@ -319,7 +329,14 @@ func (cm *ContractManager) ProcessConsensusChange(cc modules.ConsensusChange) {
 		blockHeight++
 	}
 
+	// alerts are persisted, so they are registered and dismissed after the
+	// state transaction is committed
+	var registerAlerts []alerts.Alert
+	var dismissAlerts []types.Hash256
 	err = cm.store.UpdateContractState(cc.ID, uint64(cc.BlockHeight), func(tx UpdateStateTransaction) error {
+		// reset in case the transaction is retried
+		registerAlerts, dismissAlerts = nil, nil
+
 		for _, reverted := range revertedFormations {
 			if relevant, err := tx.ContractRelevant(reverted.id); err != nil {
 				return fmt.Errorf("failed to check if contract %v is relevant: %w", reverted, err)
@@ -330,7 +347,7 @@ func (cm *ContractManager) ProcessConsensusChange(cc modules.ConsensusChange) {
 			}
 
 			log.Warn("contract formation reverted", zap.Stringer("contractID", reverted.id), zap.Stringer("block", reverted.index))
-			cm.alerts.Register(alerts.Alert{
+			registerAlerts = append(registerAlerts, alerts.Alert{
 				ID:       types.Hash256(reverted.id),
 				Severity: alerts.SeverityWarning,
 				Message:  "Contract formation reverted",
@@ -352,7 +369,7 @@ func (cm *ContractManager) ProcessConsensusChange(cc modules.ConsensusChange) {
 			}
 
 			log.Warn("contract revision reverted", zap.Stringer("contractID", reverted.id), zap.Stringer("block", reverted.index))
-			cm.alerts.Register(alerts.Alert{
+			registerAlerts = append(registerAlerts, alerts.Alert{
 				ID:       types.Hash256(reverted.id),
 				Severity: alerts.SeverityWarning,
 				Message:  "Contract revision reverted",
@@ -374,7 +391,7 @@ func (cm *ContractManager) ProcessConsensusChange(cc modules.ConsensusChange) {
 			}
 
 			log.Warn("contract resolution reverted", zap.Stringer("contractID", reverted.id), zap.Stringer("block", reverted.index))
-			cm.alerts.Register(alerts.Alert{
+			registerAlerts = append(registerAlerts, alerts.Alert{
 				ID:       types.Hash256(reverted.id),
 				Severity: alerts.SeverityWarning,
 				Message:  "Contract resolution reverted",
@@ -396,7 +413,7 @@ func (cm *ContractManager) ProcessConsensusChange(cc modules.ConsensusChange) {
 			}
 
 			log.Info("contract formation confirmed", zap.Stringer("contractID", applied.id), zap.Stringer("block", applied.index))
-			cm.alerts.Dismiss(types.Hash256(applied.id)) // dismiss any lifecycle alerts for this contract
+			dismissAlerts = append(dismissAlerts, types.Hash256(applied.id)) // dismiss any lifecycle alerts for this contract
 		}
 
 		for _, applied := range appliedRevisions {
@@ -409,7 +426,7 @@ func (cm *ContractManager) ProcessConsensusChange(cc modules.ConsensusChange) {
 			}
 
 			log.Info("contract revision confirmed", zap.Stringer("contractID", applied.ParentID), zap.Uint64("revisionNumber", applied.RevisionNumber))
-			cm.alerts.Dismiss(types.Hash256(applied.ParentID)) // dismiss any lifecycle alerts for this contract
+			dismissAlerts = append(dismissAlerts, types.Hash256(applied.ParentID)) // dismiss any lifecycle alerts for this contract
 		}
 
 		for _, applied := range appliedResolutions {
@@ -422,7 +439,7 @@ func (cm *ContractManager) ProcessConsensusChange(cc modules.ConsensusChange) {
 			}
 
 			log.Info("contract resolution confirmed", zap.Stringer("contractID", applied.id), zap.Stringer("block", applied.index))
-			cm.alerts.Dismiss(types.Hash256(applied.id)) // dismiss any lifecycle alerts for this contract
+			dismissAlerts = append(dismissAlerts, types.Hash256(applied.id)) // dismiss any lifecycle alerts for this contract
 		}
 		return nil
 	})
@@ -431,6 +448,13 @@ func (cm *ContractManager) ProcessConsensusChange(cc modules.ConsensusChange) {
 		return
 	}
 
+	for _, a := range registerAlerts {
+		cm.alerts.Register(a)
+	}
+	if len(dismissAlerts) > 0 {
+		cm.alerts.Dismiss(dismissAlerts...)
+	}
+
 	scanHeight := uint64(cc.BlockHeight)
 	atomic.StoreUint64(&cm.blockHeight, scanHeight)
 	log.Debug("consensus change applied", zap.Uint64("height", scanHeight), zap.String("changeID", cc.ID.String()))
@@ -473,6 +497,58 @@ func (cm *ContractManager) ReviseContract(contractID types.FileContractID) (*Con
 	}, nil
 }
 
+// subscribe subscribes the contract manager to consensus changes in a
+// separate goroutine. The subscription's initial sync is cancelled when the
+// manager is closed or rescanned. The caller must hold subMu.
+func (cm *ContractManager) subscribe(fn func(cancel <-chan struct{}) error) {
+	ctx, cancel := cm.tg.WithContext(context.Background())
+	done := make(chan struct{})
+	cm.subCancel, cm.subDone = cancel, done
+
+	go func() {
+		defer close(done)
+		if err := fn(ctx.Done()); err != nil && ctx.Err() == nil {
+			cm.log.Error("failed to subscribe to consensus set", zap.Error(err))
+		}
+	}()
+}
+
+// ScanHeight returns the height of the last block processed by the contract
+// manager.
+func (cm *ContractManager) ScanHeight() uint64 {
+	return atomic.LoadUint64(&cm.blockHeight)
+}
+
+// Rescan rescans the blockchain beginning with the block at height. Contract
+// state is not reset, blocks that were already processed are applied again.
+// The rescan runs in the background, its progress is reported by ScanHeight.
+func (cm *ContractManager) Rescan(height uint64) error {
+	done, err := cm.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if tip := cm.chain.TipState().Index.Height; height > tip {
+		return fmt.Errorf("rescan height %d is above the current height %d", height, tip)
+	}
+
+	cm.subMu.Lock()
+	defer cm.subMu.Unlock()
+
+	// stop the current subscription. If the initial sync is still running,
+	// wait for it to return before unsubscribing.
+	cm.subCancel()
+	<-cm.subDone
+	cm.chain.Unsubscribe(cm)
+
+	cm.log.Info("rescanning contracts", zap.Uint64("height", height))
+	cm.subscribe(func(cancel <-chan struct{}) error {
+		return cm.chain.SubscribeAtHeight(cm, height, cancel)
+	})
+	return nil
+}
+
 // Close closes the contract manager.
 func (cm *ContractManager) Close() error {
 	cm.tg.Stop()
@@ -535,17 +611,19 @@ func NewManager(store ContractStore, alerts Alerts, storage StorageManager, c Ch
 
 	// subscribe to the consensus set in a separate goroutine to prevent
 	// blocking startup
-	go func() {
-		err := cm.chain.Subscribe(cm, changeID, cm.tg.Done())
-		if err != nil {
+	cm.subMu.Lock()
+	cm.subscribe(func(cancel <-chan struct{}) error {
+		err := cm.chain.Subscribe(cm, changeID, cancel)
+		if errors.Is(err, chain.ErrInvalidChangeID) {
 			cm.log.Error("failed to subscribe to consensus set", zap.Error(err))
-			if errors.Is(err, chain.ErrInvalidChangeID) {
-				if err := cm.chain.Subscribe(cm, modules.ConsensusChangeBeginning, cm.tg.Done()); err != nil {
-					cm.log.Fatal("failed to reset consensus change subscription", zap.Error(err))
-				}
+			if err := cm.chain.Subscribe(cm, modules.ConsensusChangeBeginning, cancel); err != nil {
+				cm.log.Fatal("failed to reset consensus change subscription", zap.Error(err))
 			}
+			return nil
 		}
-	}()
+		return err
+	})
+	cm.subMu.Unlock()
 
 	return cm, nil
 }
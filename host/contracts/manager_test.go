@@ -90,7 +90,10 @@ func TestContractLockUnlock(t *testing.T) {
 	}
 	defer node.Close()
 
-	am := alerts.NewManager()
+	am, err := alerts.NewManager(db, log.Named("alerts"))
+	if err != nil {
+		t.Fatal(err)
+	}
 	s, err := storage.NewVolumeManager(db, am, node.ChainManager(), log.Named("storage"), sectorCacheSize)
 	if err != nil {
 		t.Fatal(err)
@@ -169,7 +172,10 @@ func TestContractLifecycle(t *testing.T) {
 		}
 		defer node.Close()
 
-		am := alerts.NewManager()
+		am, err := alerts.NewManager(node.Store(), log.Named("alerts"))
+		if err != nil {
+			t.Fatal(err)
+		}
 		s, err := storage.NewVolumeManager(node.Store(), am, node.ChainManager(), log.Named("storage"), sectorCacheSize)
 		if err != nil {
 			t.Fatal(err)
@@ -374,6 +380,37 @@ func TestContractLifecycle(t *testing.T) {
 		} else if !m.Contracts.RiskedCollateral.IsZero() {
 			t.Fatalf("expected %v risked collateral, got %v", types.ZeroCurrency, m.Contracts.RiskedCollateral)
 		}
+
+		// rescan from before the contract was formed. Replaying the formation
+		// and revision should not change the status of the resolved contract.
+		if err := c.Rescan(0); err != nil {
+			t.Fatal(err)
+		}
+		tip := node.TipState().Index.Height
+		for i := 0; c.ScanHeight() < tip; i++ {
+			if i >= 100 {
+				t.Fatal("rescan did not complete")
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		time.Sleep(100 * time.Millisecond) // sync time
+
+		contract, err = c.Contract(rev.Revision.ParentID)
+		if err != nil {
+			t.Fatal(err)
+		} else if contract.Status != contracts.ContractStatusSuccessful {
+			t.Fatalf("expected contract to be successful, got %v", contract.Status)
+		} else if !contract.RevisionConfirmed {
+			t.Fatal("expected revision to be confirmed")
+		} else if m, err := node.Store().Metrics(time.Now()); err != nil {
+			t.Fatal(err)
+		} else if m.Contracts.Active != 0 {
+			t.Fatal("expected 0 active contracts")
+		} else if m.Contracts.Successful != 1 {
+			t.Fatal("expected 1 successful contract")
+		} else if !m.Contracts.LockedCollateral.IsZero() {
+			t.Fatalf("expected %v locked collateral, got %v", types.ZeroCurrency, m.Contracts.LockedCollateral)
+		}
 	})
 
 	t.Run("successful no proof", func(t *testing.T) {
@@ -387,7 +424,10 @@ func TestContractLifecycle(t *testing.T) {
 		}
 		defer node.Close()
 
-		am := alerts.NewManager()
+		am, err := alerts.NewManager(node.Store(), log.Named("alerts"))
+		if err != nil {
+			t.Fatal(err)
+		}
 		s, err := storage.NewVolumeManager(node.Store(), am, node.ChainManager(), log.Named("storage"), sectorCacheSize)
 		if err != nil {
 			t.Fatal(err)
@@ -574,7 +614,10 @@ func TestContractLifecycle(t *testing.T) {
 		}
 		defer node.Close()
 
-		am := alerts.NewManager()
+		am, err := alerts.NewManager(node.Store(), log.Named("alerts"))
+		if err != nil {
+			t.Fatal(err)
+		}
 		s, err := storage.NewVolumeManager(node.Store(), am, node.ChainManager(), log.Named("storage"), sectorCacheSize)
 		if err != nil {
 			t.Fatal(err)
@@ -735,7 +778,10 @@ func TestContractLifecycle(t *testing.T) {
 		}
 		defer node.Close()
 
-		am := alerts.NewManager()
+		am, err := alerts.NewManager(node.Store(), log.Named("alerts"))
+		if err != nil {
+			t.Fatal(err)
+		}
 		s, err := storage.NewVolumeManager(node.Store(), am, node.ChainManager(), log.Named("storage"), sectorCacheSize)
 		if err != nil {
 			t.Fatal(err)
@@ -957,7 +1003,10 @@ func TestSectorRoots(t *testing.T) {
 	}
 	defer node.Close()
 
-	am := alerts.NewManager()
+	am, err := alerts.NewManager(db, log.Named("alerts"))
+	if err != nil {
+		t.Fatal(err)
+	}
 	s, err := storage.NewVolumeManager(db, am, node.ChainManager(), log.Named("storage"), sectorCacheSize)
 	if err != nil {
 		t.Fatal(err)
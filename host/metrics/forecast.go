@@ -0,0 +1,205 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"go.sia.tech/core/types"
+)
+
+const (
+	// blockInterval is the expected time between blocks. It is used to
+	// estimate when a contract's proof window ends.
+	blockInterval = 10 * time.Minute
+
+	// maxForecastPeriods is the maximum number of periods in a forecast.
+	maxForecastPeriods = 1000
+)
+
+type (
+	// A ForecastContract is a pending or active contract's potential revenue
+	// and collateral.
+	ForecastContract struct {
+		WindowEnd        uint64
+		LockedCollateral types.Currency
+		RiskedCollateral types.Currency
+		Revenue          Revenue
+	}
+
+	// A ForecastPeriod is the revenue expected to be earned and the
+	// collateral expected to be released by contracts whose proof window
+	// ends within the period.
+	ForecastPeriod struct {
+		Contracts uint64  `json:"contracts"`
+		Revenue   Revenue `json:"revenue"`
+		// LockedCollateral is the collateral unlocked when the contracts
+		// resolve. If the forecast is discounted, the collateral expected
+		// to be lost by failed contracts is subtracted.
+		LockedCollateral types.Currency `json:"lockedCollateral"`
+		RiskedCollateral types.Currency `json:"riskedCollateral"`
+		Timestamp        time.Time      `json:"timestamp"`
+	}
+
+	// A Forecast projects when the potential revenue of active contracts will
+	// be earned and when their collateral will be released.
+	Forecast struct {
+		// FailureRate is the fraction of resolved contracts that failed. It
+		// is only applied if the forecast is discounted.
+		FailureRate float64          `json:"failureRate"`
+		Discounted  bool             `json:"discounted"`
+		Periods     []ForecastPeriod `json:"periods"`
+	}
+)
+
+// add adds the revenue of b to a.
+func (a Revenue) add(b Revenue) Revenue {
+	return Revenue{
+		RPC:           a.RPC.Add(b.RPC),
+		Storage:       a.Storage.Add(b.Storage),
+		Ingress:       a.Ingress.Add(b.Ingress),
+		Egress:        a.Egress.Add(b.Egress),
+		RegistryRead:  a.RegistryRead.Add(b.RegistryRead),
+		RegistryWrite: a.RegistryWrite.Add(b.RegistryWrite),
+		AccountExpiry: a.AccountExpiry.Add(b.AccountExpiry),
+	}
+}
+
+// scale multiplies each field of the revenue by f.
+func (a Revenue) scale(f *big.Rat) Revenue {
+	return Revenue{
+		RPC:           scaleCurrency(a.RPC, f),
+		Storage:       scaleCurrency(a.Storage, f),
+		Ingress:       scaleCurrency(a.Ingress, f),
+		Egress:        scaleCurrency(a.Egress, f),
+		RegistryRead:  scaleCurrency(a.RegistryRead, f),
+		RegistryWrite: scaleCurrency(a.RegistryWrite, f),
+		AccountExpiry: scaleCurrency(a.AccountExpiry, f),
+	}
+}
+
+// scaleCurrency multiplies c by f, rounding down. f must be between 0 and 1.
+func scaleCurrency(c types.Currency, f *big.Rat) types.Currency {
+	n := new(big.Int).Mul(c.Big(), f.Num())
+	n.Quo(n, f.Denom())
+	lo := new(big.Int).And(n, new(big.Int).SetUint64(^uint64(0))).Uint64()
+	hi := new(big.Int).Rsh(n, 64).Uint64()
+	return types.NewCurrency(lo, hi)
+}
+
+// nextPeriod returns the start of the period after start.
+func nextPeriod(start time.Time, interval Interval) time.Time {
+	switch interval {
+	case IntervalDaily:
+		return start.AddDate(0, 0, 1)
+	case IntervalWeekly:
+		return start.AddDate(0, 0, 7)
+	case IntervalMonthly:
+		return start.AddDate(0, 1, 0)
+	default:
+		panic(fmt.Errorf("unsupported forecast interval %v", interval)) // developer error
+	}
+}
+
+// Forecast projects when the potential revenue of pending and active contracts
+// will be earned and their collateral released. Each contract is assigned to the
+// period containing the estimated time of its proof window end, assuming one
+// block every 10 minutes after the current height. Contracts whose window
+// has already ended are assigned to the first period. Only daily, weekly,
+// and monthly intervals are supported. If periods is 0, enough periods are
+// returned to include every contract.
+//
+// If discount is true, revenue is reduced and collateral is expected to be
+// lost in proportion to the host's historical contract failure rate.
+func (mm *MetricManager) Forecast(height uint64, now time.Time, interval Interval, periods int, discount bool) (Forecast, error) {
+	switch interval {
+	case IntervalDaily, IntervalWeekly, IntervalMonthly:
+	default:
+		return Forecast{}, fmt.Errorf("unsupported forecast interval %q", interval)
+	}
+	if periods < 0 {
+		return Forecast{}, errors.New("periods must be positive")
+	} else if periods > maxForecastPeriods {
+		return Forecast{}, fmt.Errorf("periods must be at most %d", maxForecastPeriods)
+	}
+
+	active, err := mm.store.ForecastContracts()
+	if err != nil {
+		return Forecast{}, fmt.Errorf("failed to get active contracts: %w", err)
+	}
+
+	m, err := mm.store.Metrics(now)
+	if err != nil {
+		return Forecast{}, fmt.Errorf("failed to get metrics: %w", err)
+	}
+	forecast := Forecast{Discounted: discount}
+	keep := big.NewRat(1, 1)
+	lose := new(big.Rat)
+	if resolved := m.Contracts.Successful + m.Contracts.Failed; resolved > 0 {
+		forecast.FailureRate = float64(m.Contracts.Failed) / float64(resolved)
+		if discount {
+			lose.SetFrac64(int64(m.Contracts.Failed), int64(resolved))
+			keep.Sub(keep, lose)
+		}
+	}
+
+	// estimate the time each contract resolves
+	resolveTimes := make([]time.Time, len(active))
+	var last time.Time
+	for i, c := range active {
+		if c.WindowEnd > height {
+			resolveTimes[i] = now.Add(time.Duration(c.WindowEnd-height) * blockInterval)
+		} else {
+			resolveTimes[i] = now
+		}
+		if resolveTimes[i].After(last) {
+			last = resolveTimes[i]
+		}
+	}
+
+	start, err := Normalize(now, interval)
+	if err != nil {
+		return Forecast{}, err
+	}
+	var boundaries []time.Time // the end of each period
+	for end := nextPeriod(start, interval); len(boundaries) < maxForecastPeriods; end = nextPeriod(end, interval) {
+		boundaries = append(boundaries, end)
+		if periods > 0 && len(boundaries) == periods {
+			break
+		} else if periods == 0 && end.After(last) {
+			break
+		}
+	}
+
+	forecast.Periods = make([]ForecastPeriod, len(boundaries))
+	for i := range forecast.Periods {
+		if i == 0 {
+			forecast.Periods[i].Timestamp = start
+		} else {
+			forecast.Periods[i].Timestamp = boundaries[i-1]
+		}
+	}
+
+	for i, c := range active {
+		// find the period containing the resolve time
+		j := sort.Search(len(boundaries), func(j int) bool {
+			return resolveTimes[i].Before(boundaries[j])
+		})
+		if j == len(boundaries) {
+			continue // outside of the forecast
+		}
+
+		p := &forecast.Periods[j]
+		p.Contracts++
+		p.Revenue = p.Revenue.add(c.Revenue.scale(keep))
+		lost := scaleCurrency(c.RiskedCollateral, lose)
+		if lost.Cmp(c.LockedCollateral) > 0 {
+			lost = c.LockedCollateral
+		}
+		p.LockedCollateral = p.LockedCollateral.Add(c.LockedCollateral.Sub(lost))
+		p.RiskedCollateral = p.RiskedCollateral.Add(c.RiskedCollateral)
+	}
+	return forecast, nil
+}
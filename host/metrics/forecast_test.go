@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"go.sia.tech/core/types"
+	"go.uber.org/zap/zaptest"
+)
+
+type forecastStore struct {
+	Store
+
+	contracts []ForecastContract
+	metrics   Metrics
+}
+
+func (fs *forecastStore) Metrics(time.Time) (Metrics, error) {
+	return fs.metrics, nil
+}
+
+func (fs *forecastStore) ForecastContracts() ([]ForecastContract, error) {
+	return fs.contracts, nil
+}
+
+func TestForecast(t *testing.T) {
+	const blocksPerDay = 144
+	contract := func(windowEnd uint64, revenue uint32) ForecastContract {
+		return ForecastContract{
+			WindowEnd:        windowEnd,
+			LockedCollateral: types.Siacoins(100),
+			RiskedCollateral: types.Siacoins(40),
+			Revenue:          Revenue{Storage: types.Siacoins(revenue)},
+		}
+	}
+
+	store := &forecastStore{
+		contracts: []ForecastContract{
+			contract(900, 1),                 // window already ended
+			contract(1000+blocksPerDay/2, 2), // today
+			contract(1000+blocksPerDay*3, 4), // in three days
+			contract(1000+blocksPerDay*40, 8),
+		},
+		metrics: Metrics{
+			Contracts: Contracts{Successful: 3, Failed: 1},
+		},
+	}
+	mm := &MetricManager{store: store, log: zaptest.NewLogger(t)}
+
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := mm.Forecast(1000, now, IntervalHourly, 0, false); err == nil {
+		t.Fatal("expected error for unsupported interval")
+	}
+
+	forecast, err := mm.Forecast(1000, now, IntervalDaily, 7, false)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(forecast.Periods) != 7 {
+		t.Fatalf("expected 7 periods, got %v", len(forecast.Periods))
+	} else if forecast.FailureRate != 0.25 {
+		t.Fatalf("expected failure rate 0.25, got %v", forecast.FailureRate)
+	}
+
+	expected := map[int]uint32{0: 3, 3: 4}
+	for i, p := range forecast.Periods {
+		if exp := now.AddDate(0, 0, i); !p.Timestamp.Equal(exp) {
+			t.Fatalf("period %d: expected timestamp %v, got %v", i, exp, p.Timestamp)
+		} else if !p.Revenue.Storage.Equals(types.Siacoins(expected[i])) {
+			t.Fatalf("period %d: expected revenue %v, got %v", i, types.Siacoins(expected[i]), p.Revenue.Storage)
+		}
+	}
+	if p := forecast.Periods[0]; p.Contracts != 2 || !p.LockedCollateral.Equals(types.Siacoins(200)) || !p.RiskedCollateral.Equals(types.Siacoins(80)) {
+		t.Fatalf("unexpected first period %+v", p)
+	}
+
+	// without a period count, the forecast should include every contract
+	forecast, err = mm.Forecast(1000, now, IntervalWeekly, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(forecast.Periods) != 6 {
+		t.Fatalf("expected 6 periods, got %v", len(forecast.Periods))
+	} else if p := forecast.Periods[5]; p.Contracts != 1 || !p.Revenue.Storage.Equals(types.Siacoins(8)) {
+		t.Fatalf("unexpected last period %+v", p)
+	}
+
+	// discounting should reduce revenue and released collateral by the
+	// failure rate
+	forecast, err = mm.Forecast(1000, now, IntervalMonthly, 1, true)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(forecast.Periods) != 1 {
+		t.Fatalf("expected 1 period, got %v", len(forecast.Periods))
+	} else if p := forecast.Periods[0]; p.Contracts != 3 {
+		t.Fatalf("expected 3 contracts, got %v", p.Contracts)
+	} else if exp := types.Siacoins(7).Mul64(3).Div64(4); !p.Revenue.Storage.Equals(exp) {
+		t.Fatalf("expected revenue %v, got %v", exp, p.Revenue.Storage)
+	} else if exp := types.Siacoins(300).Sub(types.Siacoins(30)); !p.LockedCollateral.Equals(exp) {
+		t.Fatalf("expected collateral %v, got %v", exp, p.LockedCollateral)
+	}
+}
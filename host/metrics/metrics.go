@@ -3,6 +3,9 @@ package metrics
 import (
 	"fmt"
 	"time"
+
+	"go.sia.tech/hostd/internal/threadgroup"
+	"go.uber.org/zap"
 )
 
 type (
@@ -12,11 +15,28 @@ type (
 		PeriodMetrics(start time.Time, n int, interval Interval) (period []Metrics, err error)
 		// Metrics returns aggregated metrics for the host as of the timestamp.
 		Metrics(time.Time) (m Metrics, err error)
+
+		// DownsampleMetrics removes all but the last value of each stat in
+		// each resolution bucket before the given time.
+		DownsampleMetrics(before time.Time, resolution time.Duration) (int, error)
+		// PruneMetrics removes stats recorded before the given time, keeping
+		// the most recent value of each stat.
+		PruneMetrics(before time.Time) (int, error)
+		// Vacuum reclaims space freed by removed stats.
+		Vacuum() error
+
+		// ForecastContracts returns the potential revenue and collateral of
+		// the host's pending and active contracts.
+		ForecastContracts() ([]ForecastContract, error)
 	}
 
-	// A MetricManager retrieves metrics from a store
+	// A MetricManager retrieves metrics from a store and enforces the
+	// retention policy.
 	MetricManager struct {
-		store Store // note: this is currently a thin wrapper around the store, but may be expanded in the future
+		store     Store
+		retention RetentionConfig
+		log       *zap.Logger
+		tg        *threadgroup.ThreadGroup
 	}
 )
 
@@ -59,9 +79,20 @@ func Normalize(timestamp time.Time, interval Interval) (time.Time, error) {
 	}
 }
 
+// Close stops the retention loop.
+func (mm *MetricManager) Close() error {
+	mm.tg.Stop()
+	return nil
+}
+
 // NewManager returns a new MetricManager
-func NewManager(store Store) *MetricManager {
-	return &MetricManager{
-		store: store,
+func NewManager(store Store, retention RetentionConfig, log *zap.Logger) *MetricManager {
+	mm := &MetricManager{
+		store:     store,
+		retention: retention,
+		log:       log,
+		tg:        threadgroup.New(),
 	}
+	go mm.run()
+	return mm
 }
@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// compactionInterval is how often old metrics are rolled up and
+	// removed.
+	compactionInterval = time.Hour
+
+	hourlyResolution = time.Hour
+	dailyResolution  = 24 * time.Hour
+)
+
+// RetentionConfig configures how long metrics are kept at each resolution. A
+// retention of 0 keeps the data at that resolution forever.
+//
+// 5-minute data older than RawRetention is rolled up to hourly data and
+// hourly data older than HourlyRetention is rolled up to daily data. Rolling
+// up keeps the last value of each stat in each bucket, so PeriodMetrics
+// returns identical results for every interval at least as coarse as the
+// remaining resolution.
+//
+// Buckets are aligned to UTC. Once data has been rolled up to daily
+// resolution, PeriodMetrics queries in a time zone with a non-zero UTC
+// offset return the value at the end of the UTC day rather than the local
+// day.
+type RetentionConfig struct {
+	RawRetention    time.Duration
+	HourlyRetention time.Duration
+	DailyRetention  time.Duration
+	// VacuumInterval is how often the database is vacuumed. A value of 0
+	// disables vacuuming.
+	VacuumInterval time.Duration
+}
+
+// Compact rolls up and removes metrics older than the configured retention
+// periods relative to now.
+func (mm *MetricManager) Compact(now time.Time) error {
+	log := mm.log.Named("compact")
+	if mm.retention.RawRetention > 0 {
+		n, err := mm.store.DownsampleMetrics(now.Add(-mm.retention.RawRetention), hourlyResolution)
+		if err != nil {
+			return fmt.Errorf("failed to downsample metrics to hourly: %w", err)
+		}
+		log.Debug("downsampled metrics to hourly", zap.Int("removed", n))
+	}
+	if mm.retention.HourlyRetention > 0 {
+		n, err := mm.store.DownsampleMetrics(now.Add(-mm.retention.HourlyRetention), dailyResolution)
+		if err != nil {
+			return fmt.Errorf("failed to downsample metrics to daily: %w", err)
+		}
+		log.Debug("downsampled metrics to daily", zap.Int("removed", n))
+	}
+	if mm.retention.DailyRetention > 0 {
+		n, err := mm.store.PruneMetrics(now.Add(-mm.retention.DailyRetention))
+		if err != nil {
+			return fmt.Errorf("failed to prune metrics: %w", err)
+		}
+		log.Debug("pruned metrics", zap.Int("removed", n))
+	}
+	return nil
+}
+
+func (mm *MetricManager) run() {
+	done, err := mm.tg.Add()
+	if err != nil {
+		return
+	}
+	defer done()
+
+	compact := time.NewTimer(0)
+	defer compact.Stop()
+
+	// a nil channel blocks forever, disabling vacuuming
+	var vacuum <-chan time.Time
+	if mm.retention.VacuumInterval > 0 {
+		t := time.NewTicker(mm.retention.VacuumInterval)
+		defer t.Stop()
+		vacuum = t.C
+	}
+
+	for {
+		select {
+		case <-mm.tg.Done():
+			return
+		case <-compact.C:
+			if err := mm.Compact(time.Now()); err != nil {
+				mm.log.Error("failed to compact metrics", zap.Error(err))
+			}
+			compact.Reset(compactionInterval)
+		case <-vacuum:
+			start := time.Now()
+			if err := mm.store.Vacuum(); err != nil {
+				mm.log.Error("failed to vacuum database", zap.Error(err))
+				continue
+			}
+			mm.log.Debug("vacuumed database", zap.Duration("elapsed", time.Since(start)))
+		}
+	}
+}
@@ -27,6 +27,9 @@ type (
 		Egress        types.Currency `json:"egress"`
 		RegistryRead  types.Currency `json:"registryRead"`
 		RegistryWrite types.Currency `json:"registryWrite"`
+		// AccountExpiry is the balance of ephemeral accounts that expired
+		// before it was spent.
+		AccountExpiry types.Currency `json:"accountExpiry"`
 	}
 
 	// Data is a collection of metrics related to data usage.
@@ -73,6 +76,8 @@ type (
 
 		Reads  uint64 `json:"reads"`
 		Writes uint64 `json:"writes"`
+		// Pruned is the number of entries removed after expiring.
+		Pruned uint64 `json:"pruned"`
 	}
 
 	// Storage is a collection of metrics related to storage.
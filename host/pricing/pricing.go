@@ -0,0 +1,475 @@
+package pricing
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/host/contracts"
+	"go.sia.tech/hostd/host/settings"
+	"go.sia.tech/hostd/internal/threadgroup"
+	"go.uber.org/zap"
+)
+
+const (
+	// factorPrecision is the precision used when scaling prices by a
+	// utilization factor.
+	factorPrecision = 1e6
+
+	// minUpdateInterval is the minimum amount of time between price
+	// adjustments.
+	minUpdateInterval = time.Minute
+)
+
+type (
+	// Bounds are the minimum and maximum values a price can be adjusted to.
+	// If both values are zero, the price is not adjusted.
+	Bounds struct {
+		Min types.Currency `json:"min"`
+		Max types.Currency `json:"max"`
+	}
+
+	// Config contains the configuration of the dynamic pricing engine.
+	Config struct {
+		Enabled        bool          `json:"enabled"`
+		UpdateInterval time.Duration `json:"updateInterval"`
+
+		StoragePrice  Bounds `json:"storagePrice"`
+		EgressPrice   Bounds `json:"egressPrice"`
+		IngressPrice  Bounds `json:"ingressPrice"`
+		ContractPrice Bounds `json:"contractPrice"`
+
+		// TargetIngressRate and TargetEgressRate are the bandwidth rates, in
+		// bytes per second, at which the bandwidth prices reach their
+		// maximum. If zero, the host's bandwidth limits are used instead.
+		TargetIngressRate uint64 `json:"targetIngressRate"`
+		TargetEgressRate  uint64 `json:"targetEgressRate"`
+		// TargetContracts is the number of active contracts at which the
+		// contract price reaches its maximum. If zero, the number of
+		// active contracts does not affect the contract price.
+		TargetContracts uint64 `json:"targetContracts"`
+	}
+
+	// Inputs are the observations used to calculate the host's prices.
+	Inputs struct {
+		UsedSectors     uint64 `json:"usedSectors"`
+		TotalSectors    uint64 `json:"totalSectors"`
+		IngressRate     uint64 `json:"ingressRate"`
+		EgressRate      uint64 `json:"egressRate"`
+		ActiveContracts uint64 `json:"activeContracts"`
+	}
+
+	// Prices are the prices calculated by the pricing engine.
+	Prices struct {
+		StoragePrice  types.Currency `json:"storagePrice"`
+		EgressPrice   types.Currency `json:"egressPrice"`
+		IngressPrice  types.Currency `json:"ingressPrice"`
+		ContractPrice types.Currency `json:"contractPrice"`
+	}
+
+	// A PriceChange records a price adjustment made by the pricing engine
+	// and the inputs that caused it.
+	PriceChange struct {
+		Inputs    Inputs    `json:"inputs"`
+		Prices    Prices    `json:"prices"`
+		Timestamp time.Time `json:"timestamp"`
+	}
+
+	// A Store persists the pricing engine's configuration and price
+	// changes.
+	Store interface {
+		// PricingConfig returns the pricing engine's configuration. If the
+		// engine has not been configured, ErrNoConfig must be returned.
+		PricingConfig() (Config, error)
+		// UpdatePricingConfig updates the pricing engine's configuration.
+		UpdatePricingConfig(Config) error
+
+		// AddPriceChange records a price adjustment.
+		AddPriceChange(PriceChange) error
+		// PriceChanges returns the recorded price adjustments, ordered
+		// from newest to oldest.
+		PriceChanges(limit, offset int) ([]PriceChange, error)
+	}
+
+	// Settings returns the host's current settings.
+	Settings interface {
+		Settings() settings.Settings
+	}
+
+	// A VolumeManager reports the host's storage utilization.
+	VolumeManager interface {
+		Usage() (usedSectors uint64, totalSectors uint64, err error)
+	}
+
+	// A ContractManager reports the host's contracts.
+	ContractManager interface {
+		Contracts(filter contracts.ContractFilter) ([]contracts.Contract, int, error)
+	}
+
+	// A DataRecorder reports the total amount of data read and written
+	// across connections.
+	DataRecorder interface {
+		Total() (read, written uint64)
+	}
+
+	// A Manager adjusts the host's prices within the configured bounds
+	// based on storage utilization, bandwidth load and the number of
+	// active contracts.
+	Manager struct {
+		store     Store
+		settings  Settings
+		volumes   VolumeManager
+		contracts ContractManager
+		recorders []DataRecorder
+		log       *zap.Logger
+		tg        *threadgroup.ThreadGroup
+
+		trigger chan struct{}
+
+		// lastRead and lastWritten are the recorders' totals at the time
+		// of the previous update. They are used to calculate the
+		// bandwidth rates. They are only accessed by the update goroutine.
+		lastRead, lastWritten uint64
+		lastSample            time.Time
+
+		mu     sync.Mutex // guards the fields below
+		config Config
+		// current is the most recently calculated set of prices. It is
+		// nil if the engine is disabled or has not run yet.
+		current *Prices
+	}
+)
+
+var (
+	// ErrNoConfig must be returned by the store if the pricing engine has
+	// not been configured.
+	ErrNoConfig = errors.New("no pricing config found")
+
+	// DefaultConfig is the default configuration of the pricing engine.
+	DefaultConfig = Config{
+		Enabled:        false,
+		UpdateInterval: 10 * time.Minute,
+	}
+)
+
+// IsZero returns true if neither bound is set.
+func (b Bounds) IsZero() bool {
+	return b.Min.IsZero() && b.Max.IsZero()
+}
+
+// interpolate returns the price between the minimum and maximum bounds
+// scaled by factor. factor is clamped to [0, 1].
+func (b Bounds) interpolate(factor float64) types.Currency {
+	if factor <= 0 {
+		return b.Min
+	} else if factor >= 1 {
+		return b.Max
+	}
+	spread := b.Max.Sub(b.Min)
+	return b.Min.Add(spread.Mul64(uint64(factor * factorPrecision)).Div64(factorPrecision))
+}
+
+// Validate returns an error if the config is invalid.
+func (c Config) Validate() error {
+	check := func(name string, b Bounds) error {
+		if b.Min.Cmp(b.Max) > 0 {
+			return fmt.Errorf("%s minimum %v must not be greater than maximum %v", name, b.Min, b.Max)
+		}
+		return nil
+	}
+
+	if err := check("storage price", c.StoragePrice); err != nil {
+		return err
+	} else if err := check("egress price", c.EgressPrice); err != nil {
+		return err
+	} else if err := check("ingress price", c.IngressPrice); err != nil {
+		return err
+	} else if err := check("contract price", c.ContractPrice); err != nil {
+		return err
+	} else if c.Enabled && c.UpdateInterval < minUpdateInterval {
+		return fmt.Errorf("update interval must be at least %v", minUpdateInterval)
+	}
+	return nil
+}
+
+// ratio returns n/d clamped to [0, 1]. If d is zero, ratio returns 0.
+func ratio(n, d uint64) float64 {
+	if d == 0 {
+		return 0
+	}
+	r := float64(n) / float64(d)
+	if r > 1 {
+		return 1
+	}
+	return r
+}
+
+// calculatePrices calculates the host's prices from the config, the host's
+// current settings and the current inputs. Prices without bounds are copied
+// from the settings.
+func calculatePrices(config Config, s settings.Settings, in Inputs) Prices {
+	ingressTarget, egressTarget := config.TargetIngressRate, config.TargetEgressRate
+	if ingressTarget == 0 {
+		ingressTarget = s.IngressLimit
+	}
+	if egressTarget == 0 {
+		egressTarget = s.EgressLimit
+	}
+
+	utilization := ratio(in.UsedSectors, in.TotalSectors)
+	ingressLoad := ratio(in.IngressRate, ingressTarget)
+	egressLoad := ratio(in.EgressRate, egressTarget)
+	contractLoad := ratio(in.ActiveContracts, config.TargetContracts)
+
+	max := func(a, b float64) float64 {
+		if a > b {
+			return a
+		}
+		return b
+	}
+
+	prices := Prices{
+		StoragePrice:  s.StoragePrice,
+		EgressPrice:   s.EgressPrice,
+		IngressPrice:  s.IngressPrice,
+		ContractPrice: s.ContractPrice,
+	}
+	// storage becomes more expensive as the host fills up
+	if !config.StoragePrice.IsZero() {
+		prices.StoragePrice = config.StoragePrice.interpolate(utilization)
+	}
+	// egress becomes more expensive as the upload bandwidth saturates
+	if !config.EgressPrice.IsZero() {
+		prices.EgressPrice = config.EgressPrice.interpolate(egressLoad)
+	}
+	// ingress adds data to the host, so it becomes more expensive as either
+	// the download bandwidth saturates or the host fills up
+	if !config.IngressPrice.IsZero() {
+		prices.IngressPrice = config.IngressPrice.interpolate(max(ingressLoad, utilization))
+	}
+	// new contracts become more expensive as the host fills up or the
+	// number of active contracts approaches the target
+	if !config.ContractPrice.IsZero() {
+		prices.ContractPrice = config.ContractPrice.interpolate(max(utilization, contractLoad))
+	}
+	return prices
+}
+
+// inputs collects the current inputs for the pricing engine. It must only
+// be called by the update goroutine.
+func (m *Manager) inputs() (Inputs, error) {
+	used, total, err := m.volumes.Usage()
+	if err != nil {
+		return Inputs{}, fmt.Errorf("failed to get storage usage: %w", err)
+	}
+
+	_, active, err := m.contracts.Contracts(contracts.ContractFilter{
+		Statuses: []contracts.ContractStatus{contracts.ContractStatusActive},
+		Limit:    1,
+	})
+	if err != nil {
+		return Inputs{}, fmt.Errorf("failed to get active contracts: %w", err)
+	}
+
+	var read, written uint64
+	for _, r := range m.recorders {
+		r, w := r.Total()
+		read += r
+		written += w
+	}
+
+	in := Inputs{
+		UsedSectors:     used,
+		TotalSectors:    total,
+		ActiveContracts: uint64(active),
+	}
+	// the rates cannot be calculated until there are two samples
+	now := time.Now()
+	if elapsed := now.Sub(m.lastSample).Seconds(); !m.lastSample.IsZero() && elapsed > 0 {
+		in.IngressRate = uint64(float64(read-m.lastRead) / elapsed)
+		in.EgressRate = uint64(float64(written-m.lastWritten) / elapsed)
+	}
+	m.lastRead, m.lastWritten, m.lastSample = read, written, now
+	return in, nil
+}
+
+// updatePrices recalculates the host's prices and records any change. The
+// inputs are collected without holding the manager's lock so Settings is not
+// blocked by the store.
+func (m *Manager) updatePrices() error {
+	m.mu.Lock()
+	config := m.config
+	if !config.Enabled {
+		m.current = nil
+		m.mu.Unlock()
+		return nil
+	}
+	m.mu.Unlock()
+
+	in, err := m.inputs()
+	if err != nil {
+		return err
+	}
+	prices := calculatePrices(config, m.settings.Settings(), in)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.config != config {
+		// the config changed while the prices were calculated. The update
+		// was already triggered again, so discard the stale prices.
+		return nil
+	} else if m.current != nil && *m.current == prices {
+		return nil
+	}
+
+	change := PriceChange{
+		Inputs:    in,
+		Prices:    prices,
+		Timestamp: time.Now(),
+	}
+	if err := m.store.AddPriceChange(change); err != nil {
+		return fmt.Errorf("failed to record price change: %w", err)
+	}
+	m.current = &prices
+	m.log.Debug("adjusted prices", zap.Uint64("usedSectors", in.UsedSectors), zap.Uint64("totalSectors", in.TotalSectors),
+		zap.Uint64("ingressRate", in.IngressRate), zap.Uint64("egressRate", in.EgressRate), zap.Uint64("activeContracts", in.ActiveContracts),
+		zap.Stringer("storagePrice", prices.StoragePrice), zap.Stringer("egressPrice", prices.EgressPrice),
+		zap.Stringer("ingressPrice", prices.IngressPrice), zap.Stringer("contractPrice", prices.ContractPrice))
+	return nil
+}
+
+func (m *Manager) run() {
+	done, err := m.tg.Add()
+	if err != nil {
+		return
+	}
+	defer done()
+
+	interval := func() time.Duration {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if m.config.UpdateInterval < minUpdateInterval {
+			return minUpdateInterval
+		}
+		return m.config.UpdateInterval
+	}
+
+	t := time.NewTimer(0)
+	defer t.Stop()
+	for {
+		select {
+		case <-m.tg.Done():
+			return
+		case <-m.trigger:
+			if !t.Stop() {
+				select {
+				case <-t.C:
+				default:
+				}
+			}
+		case <-t.C:
+		}
+
+		if err := m.updatePrices(); err != nil {
+			m.log.Error("failed to update prices", zap.Error(err))
+		}
+		t.Reset(interval())
+	}
+}
+
+// Close stops the pricing engine.
+func (m *Manager) Close() error {
+	m.tg.Stop()
+	return nil
+}
+
+// Config returns the pricing engine's current configuration.
+func (m *Manager) Config() Config {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.config
+}
+
+// UpdateConfig updates the pricing engine's configuration. Prices are
+// recalculated immediately.
+func (m *Manager) UpdateConfig(c Config) error {
+	if err := c.Validate(); err != nil {
+		return fmt.Errorf("invalid pricing config: %w", err)
+	} else if err := m.store.UpdatePricingConfig(c); err != nil {
+		return fmt.Errorf("failed to update pricing config: %w", err)
+	}
+
+	m.mu.Lock()
+	m.config = c
+	// force the next update to record the new prices
+	m.current = nil
+	m.mu.Unlock()
+
+	// trigger a recalculation
+	select {
+	case m.trigger <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// PriceChanges returns the recorded price adjustments, ordered from newest
+// to oldest.
+func (m *Manager) PriceChanges(limit, offset int) ([]PriceChange, error) {
+	return m.store.PriceChanges(limit, offset)
+}
+
+// Settings returns the host's current settings with the static prices
+// replaced by the prices calculated by the engine. Prices without bounds are
+// not adjusted, so changes to them apply immediately.
+func (m *Manager) Settings() settings.Settings {
+	s := m.settings.Settings()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.config.Enabled || m.current == nil {
+		return s
+	}
+	if !m.config.StoragePrice.IsZero() {
+		s.StoragePrice = m.current.StoragePrice
+	}
+	if !m.config.EgressPrice.IsZero() {
+		s.EgressPrice = m.current.EgressPrice
+	}
+	if !m.config.IngressPrice.IsZero() {
+		s.IngressPrice = m.current.IngressPrice
+	}
+	if !m.config.ContractPrice.IsZero() {
+		s.ContractPrice = m.current.ContractPrice
+	}
+	return s
+}
+
+// NewManager initializes a new pricing engine. The engine periodically
+// recalculates the host's prices while it is enabled.
+func NewManager(store Store, s Settings, vm VolumeManager, cm ContractManager, recorders []DataRecorder, log *zap.Logger) (*Manager, error) {
+	config, err := store.PricingConfig()
+	if errors.Is(err, ErrNoConfig) {
+		config = DefaultConfig
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to load pricing config: %w", err)
+	}
+
+	m := &Manager{
+		store:     store,
+		settings:  s,
+		volumes:   vm,
+		contracts: cm,
+		recorders: recorders,
+		log:       log,
+		tg:        threadgroup.New(),
+
+		trigger: make(chan struct{}, 1),
+
+		config: config,
+	}
+	go m.run()
+	return m, nil
+}
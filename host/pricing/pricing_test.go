@@ -0,0 +1,100 @@
+package pricing
+
+import (
+	"testing"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/host/settings"
+)
+
+func TestCalculatePrices(t *testing.T) {
+	s := settings.Settings{
+		StoragePrice:  types.Siacoins(1),
+		EgressPrice:   types.Siacoins(2),
+		IngressPrice:  types.Siacoins(3),
+		ContractPrice: types.Siacoins(4),
+		IngressLimit:  1000,
+		EgressLimit:   1000,
+	}
+	config := Config{
+		Enabled:         true,
+		StoragePrice:    Bounds{Min: types.Siacoins(10), Max: types.Siacoins(20)},
+		EgressPrice:     Bounds{Min: types.Siacoins(100), Max: types.Siacoins(200)},
+		TargetContracts: 10,
+	}
+
+	// an empty host should use the minimum prices
+	prices := calculatePrices(config, s, Inputs{TotalSectors: 100})
+	if !prices.StoragePrice.Equals(types.Siacoins(10)) {
+		t.Fatalf("expected storage price %v, got %v", types.Siacoins(10), prices.StoragePrice)
+	} else if !prices.EgressPrice.Equals(types.Siacoins(100)) {
+		t.Fatalf("expected egress price %v, got %v", types.Siacoins(100), prices.EgressPrice)
+	} else if !prices.IngressPrice.Equals(s.IngressPrice) {
+		t.Fatalf("expected unbounded ingress price %v, got %v", s.IngressPrice, prices.IngressPrice)
+	} else if !prices.ContractPrice.Equals(s.ContractPrice) {
+		t.Fatalf("expected unbounded contract price %v, got %v", s.ContractPrice, prices.ContractPrice)
+	}
+
+	// a half-full host with saturated egress
+	prices = calculatePrices(config, s, Inputs{UsedSectors: 50, TotalSectors: 100, EgressRate: 5000})
+	if !prices.StoragePrice.Equals(types.Siacoins(15)) {
+		t.Fatalf("expected storage price %v, got %v", types.Siacoins(15), prices.StoragePrice)
+	} else if !prices.EgressPrice.Equals(types.Siacoins(200)) {
+		t.Fatalf("expected egress price %v, got %v", types.Siacoins(200), prices.EgressPrice)
+	}
+
+	// the contract price should follow the larger of utilization and
+	// contract load
+	config.ContractPrice = Bounds{Min: types.Siacoins(0), Max: types.Siacoins(1)}
+	prices = calculatePrices(config, s, Inputs{UsedSectors: 10, TotalSectors: 100, ActiveContracts: 5})
+	if !prices.ContractPrice.Equals(types.Siacoins(1).Div64(2)) {
+		t.Fatalf("expected contract price %v, got %v", types.Siacoins(1).Div64(2), prices.ContractPrice)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	config := DefaultConfig
+	if err := config.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	config.StoragePrice = Bounds{Min: types.Siacoins(2), Max: types.Siacoins(1)}
+	if err := config.Validate(); err == nil {
+		t.Fatal("expected error for inverted bounds")
+	}
+
+	config = DefaultConfig
+	config.Enabled = true
+	config.UpdateInterval = 0
+	if err := config.Validate(); err == nil {
+		t.Fatal("expected error for short update interval")
+	}
+}
+
+type stubSettings settings.Settings
+
+func (s *stubSettings) Settings() settings.Settings { return settings.Settings(*s) }
+
+func TestSettingsUnboundedPrices(t *testing.T) {
+	s := &stubSettings{
+		StoragePrice: types.Siacoins(1),
+		EgressPrice:  types.Siacoins(2),
+	}
+	m := &Manager{
+		settings: s,
+		config: Config{
+			Enabled:      true,
+			StoragePrice: Bounds{Min: types.Siacoins(10), Max: types.Siacoins(20)},
+		},
+		current: &Prices{StoragePrice: types.Siacoins(15), EgressPrice: types.Siacoins(2)},
+	}
+
+	// changes to an unbounded price should apply before the next update
+	s.EgressPrice = types.Siacoins(5)
+	current := m.Settings()
+	if !current.StoragePrice.Equals(types.Siacoins(15)) {
+		t.Fatalf("expected storage price %v, got %v", types.Siacoins(15), current.StoragePrice)
+	} else if !current.EgressPrice.Equals(types.Siacoins(5)) {
+		t.Fatalf("expected egress price %v, got %v", types.Siacoins(5), current.EgressPrice)
+	}
+}
@@ -0,0 +1,100 @@
+package registry
+
+import (
+	"fmt"
+
+	"go.sia.tech/core/types"
+)
+
+// EntrySortField is the field used to sort registry entries.
+const (
+	EntrySortExpiration = "expiration"
+	EntrySortRevision   = "revision"
+)
+
+type (
+	// An Entry is a registry entry and its metadata.
+	Entry struct {
+		// Key is the hash of the entry's public key and tweak. It uniquely
+		// identifies the entry.
+		Key types.Hash256 `json:"key"`
+		// PublicKey and Tweak are zero for entries stored before they were
+		// tracked.
+		PublicKey types.PublicKey `json:"publicKey"`
+		Tweak     types.Hash256   `json:"tweak"`
+
+		Revision         uint64          `json:"revision"`
+		Type             uint8           `json:"type"`
+		Data             []byte          `json:"data"`
+		Signature        types.Signature `json:"signature"`
+		ExpirationHeight uint64          `json:"expirationHeight"`
+	}
+
+	// An EntryFilter filters and sorts registry entries.
+	EntryFilter struct {
+		// PublicKey limits the results to entries owned by a public key.
+		PublicKey *types.PublicKey `json:"publicKey,omitempty"`
+
+		// pagination
+		Limit  int `json:"limit"`
+		Offset int `json:"offset"`
+
+		// sorting
+		SortField string `json:"sortField"`
+		SortDesc  bool   `json:"sortDesc"`
+	}
+
+	// KeyUsage is the number of registry entries owned by a public key.
+	KeyUsage struct {
+		// PublicKey is zero for entries stored before public keys were
+		// tracked.
+		PublicKey types.PublicKey `json:"publicKey"`
+		Entries   uint64          `json:"entries"`
+	}
+)
+
+// Validate returns an error if the filter is invalid.
+func (f EntryFilter) Validate() error {
+	switch f.SortField {
+	case "", EntrySortExpiration, EntrySortRevision:
+	default:
+		return fmt.Errorf("invalid sort field %q", f.SortField)
+	}
+	return nil
+}
+
+// ListEntries returns the registry entries matching the filter and the total
+// number of matching entries.
+func (r *Manager) ListEntries(filter EntryFilter) ([]Entry, int, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, 0, err
+	}
+	return r.store.RegistryEntryList(filter)
+}
+
+// Entry returns the registry entry with the given key. If the entry does not
+// exist, ErrEntryNotFound is returned.
+func (r *Manager) Entry(key types.Hash256) (Entry, error) {
+	return r.store.RegistryEntry(key)
+}
+
+// DeleteEntry removes the registry entry with the given key.
+func (r *Manager) DeleteEntry(key types.Hash256) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.store.DeleteRegistryEntry(key)
+}
+
+// DeleteEntries removes all registry entries owned by a public key. Returns
+// the number of entries removed.
+func (r *Manager) DeleteEntries(publicKey types.PublicKey) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.store.DeleteRegistryEntries(publicKey)
+}
+
+// KeyUsage returns the number of registry entries owned by each public key,
+// ordered by the number of entries descending.
+func (r *Manager) KeyUsage(limit, offset int) ([]KeyUsage, error) {
+	return r.store.RegistryKeyUsage(limit, offset)
+}
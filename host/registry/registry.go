@@ -4,10 +4,12 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
 
 	rhp3 "go.sia.tech/core/rhp/v3"
 	"go.sia.tech/core/types"
 	"go.sia.tech/hostd/internal/threadgroup"
+	"go.sia.tech/siad/modules"
 	"go.uber.org/zap"
 )
 
@@ -16,8 +18,9 @@ var (
 	// in the registry.
 	ErrEntryNotFound = errors.New("entry not found")
 
-	// ErrNotEnoughSpace should be returned when the registry is full and
-	// there is no more space to store a new entry.
+	// ErrNotEnoughSpace should be returned when the registry is full, or the
+	// entry's public key has reached its quota, and there is no more space to
+	// store a new entry.
 	ErrNotEnoughSpace = errors.New("not enough space")
 )
 
@@ -29,26 +32,66 @@ type (
 		// found should return ErrEntryNotFound.
 		GetRegistryValue(key rhp3.RegistryKey) (entry rhp3.RegistryValue, _ error)
 		// SetRegistryValue sets the registry value for the given key. If the
-		// value would exceed the maximum number of entries, should return
+		// value would exceed the maximum number of entries or the maximum
+		// number of entries for its public key, should return
 		// ErrNotEnoughSpace.
 		SetRegistryValue(entry rhp3.RegistryEntry, expiration uint64) error
 		// RegistryEntries returns the current number of entries as well as the
 		// maximum number of entries the registry can hold.
 		RegistryEntries() (count uint64, total uint64, err error)
 
+		// RegistryEntryList returns the registry entries matching the filter
+		// and the total number of matching entries.
+		RegistryEntryList(filter EntryFilter) ([]Entry, int, error)
+		// RegistryEntry returns the registry entry with the given key. If
+		// the key is not found should return ErrEntryNotFound.
+		RegistryEntry(key types.Hash256) (Entry, error)
+		// DeleteRegistryEntry removes the registry entry with the given key.
+		// If the key is not found should return ErrEntryNotFound.
+		DeleteRegistryEntry(key types.Hash256) error
+		// DeleteRegistryEntries removes all registry entries owned by a
+		// public key and returns the number of entries removed.
+		DeleteRegistryEntries(publicKey types.PublicKey) (int, error)
+		// RegistryKeyUsage returns the number of registry entries owned by
+		// each public key.
+		RegistryKeyUsage(limit, offset int) ([]KeyUsage, error)
+		// PruneRegistryEntries removes all registry entries that expire at or
+		// before the given height and returns the number of entries removed.
+		PruneRegistryEntries(height uint64) (int, error)
+
 		IncrementRegistryAccess(read, write uint64) error
 	}
 
+	// A Subscriber is notified when a registry entry it is subscribed to is
+	// created or updated. ReceiveRegistryUpdate is called while the registry
+	// is locked and must not block.
+	Subscriber interface {
+		ReceiveRegistryUpdate(rhp3.RegistryEntry)
+	}
+
+	// A ChainManager provides consensus changes to the registry.
+	ChainManager interface {
+		Subscribe(s modules.ConsensusSetSubscriber, ccID modules.ConsensusChangeID, cancel <-chan struct{}) error
+	}
+
 	// A Manager manages registry entries stored in a RegistryStore.
 	Manager struct {
 		hostID types.Hash256
 
+		// pruning is set while expired entries are being removed. It must
+		// be accessed atomically.
+		pruning int32
+
+		log      *zap.Logger
 		store    Store
 		tg       *threadgroup.ThreadGroup
 		recorder *registryAccessRecorder
 
 		// registry entries must be locked while they are being modified
 		mu sync.Mutex
+
+		subMu       sync.Mutex
+		subscribers map[types.Hash256]map[Subscriber]struct{}
 	}
 )
 
@@ -94,6 +137,7 @@ func (r *Manager) Put(entry rhp3.RegistryEntry, expirationHeight uint64) (rhp3.R
 		if err = r.store.SetRegistryValue(entry, expirationHeight); err != nil {
 			return entry.RegistryValue, fmt.Errorf("failed to create registry key: %w", err)
 		}
+		r.notify(entry)
 		return entry.RegistryValue, nil
 	} else if err != nil {
 		return old, fmt.Errorf("failed to get registry value: %w", err)
@@ -109,19 +153,100 @@ func (r *Manager) Put(entry rhp3.RegistryEntry, expirationHeight uint64) (rhp3.R
 		return old, fmt.Errorf("failed to update registry key: %w", err)
 	}
 	r.recorder.AddWrite()
+	r.notify(entry)
 	return entry.RegistryValue, nil
 }
 
+// Subscribe registers sub to receive updates to the registry entries with the
+// given keys. Keys are the hash of the entry's public key and tweak.
+func (r *Manager) Subscribe(sub Subscriber, keys ...types.Hash256) {
+	r.subMu.Lock()
+	defer r.subMu.Unlock()
+	for _, key := range keys {
+		if r.subscribers[key] == nil {
+			r.subscribers[key] = make(map[Subscriber]struct{})
+		}
+		r.subscribers[key][sub] = struct{}{}
+	}
+}
+
+// Unsubscribe stops sub from receiving updates to the registry entries with
+// the given keys.
+func (r *Manager) Unsubscribe(sub Subscriber, keys ...types.Hash256) {
+	r.subMu.Lock()
+	defer r.subMu.Unlock()
+	for _, key := range keys {
+		delete(r.subscribers[key], sub)
+		if len(r.subscribers[key]) == 0 {
+			delete(r.subscribers, key)
+		}
+	}
+}
+
+// notify sends an updated entry to its subscribers.
+func (r *Manager) notify(entry rhp3.RegistryEntry) {
+	r.subMu.Lock()
+	defer r.subMu.Unlock()
+	for sub := range r.subscribers[entry.RegistryKey.Hash()] {
+		sub.ReceiveRegistryUpdate(entry)
+	}
+}
+
+// PruneExpired removes all registry entries that expire at or before the
+// given height. Returns the number of entries removed.
+func (r *Manager) PruneExpired(height uint64) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.store.PruneRegistryEntries(height)
+}
+
+// ProcessConsensusChange prunes expired registry entries when the chain
+// advances. Pruning is skipped while the node is syncing.
+func (r *Manager) ProcessConsensusChange(cc modules.ConsensusChange) {
+	if !cc.Synced {
+		return
+	} else if !atomic.CompareAndSwapInt32(&r.pruning, 0, 1) {
+		// a prune is already in progress, the next block will catch any
+		// remaining entries
+		return
+	}
+
+	height := uint64(cc.BlockHeight)
+	go func() {
+		defer atomic.StoreInt32(&r.pruning, 0)
+
+		done, err := r.tg.Add()
+		if err != nil {
+			return
+		}
+		defer done()
+
+		pruned, err := r.PruneExpired(height)
+		if err != nil {
+			r.log.Error("failed to prune expired registry entries", zap.Uint64("height", height), zap.Error(err))
+		} else if pruned > 0 {
+			r.log.Debug("pruned expired registry entries", zap.Uint64("height", height), zap.Int("pruned", pruned))
+		}
+	}()
+}
+
 // NewManager returns a new registry manager.
-func NewManager(privkey types.PrivateKey, store Store, log *zap.Logger) *Manager {
+func NewManager(privkey types.PrivateKey, cm ChainManager, store Store, log *zap.Logger) (*Manager, error) {
 	m := &Manager{
 		hostID: rhp3.RegistryHostID(privkey.PublicKey()),
 		tg:     threadgroup.New(),
+		log:    log,
 		store:  store,
+
+		subscribers: make(map[types.Hash256]map[Subscriber]struct{}),
 		recorder: &registryAccessRecorder{
-			log: log.Named("recorder"),
+			store: store,
+			log:   log.Named("recorder"),
 		},
 	}
+	if err := cm.Subscribe(m, modules.ConsensusChangeRecent, m.tg.Done()); err != nil {
+		return nil, fmt.Errorf("failed to subscribe to consensus set: %w", err)
+	}
 	go m.recorder.Run(m.tg.Done())
-	return m
+	return m, nil
 }
@@ -1,6 +1,8 @@
 package registry_test
 
 import (
+	"bytes"
+	"errors"
 	"path/filepath"
 	"reflect"
 	"testing"
@@ -10,10 +12,19 @@ import (
 	"go.sia.tech/hostd/host/registry"
 	"go.sia.tech/hostd/host/settings"
 	"go.sia.tech/hostd/persist/sqlite"
+	"go.sia.tech/siad/modules"
 	"go.uber.org/zap/zaptest"
 	"lukechampine.com/frand"
 )
 
+// stubChain is a chain manager that never sends consensus changes. Tests
+// trigger pruning directly.
+type stubChain struct{}
+
+func (stubChain) Subscribe(modules.ConsensusSetSubscriber, modules.ConsensusChangeID, <-chan struct{}) error {
+	return nil
+}
+
 func randomValue(key types.PrivateKey) (value rhp3.RegistryEntry) {
 	value.Tweak = frand.Entropy256()
 	value.Data = frand.Bytes(32)
@@ -24,6 +35,10 @@ func randomValue(key types.PrivateKey) (value rhp3.RegistryEntry) {
 }
 
 func testRegistry(t *testing.T, privKey types.PrivateKey, limit uint64) *registry.Manager {
+	return testRegistryWithSettings(t, privKey, settings.Settings{MaxRegistryEntries: limit})
+}
+
+func testRegistryWithSettings(t *testing.T, privKey types.PrivateKey, s settings.Settings) *registry.Manager {
 	log := zaptest.NewLogger(t)
 	db, err := sqlite.OpenDatabase(filepath.Join(t.TempDir(), "hostdb.db"), log.Named("sqlite"))
 	if err != nil {
@@ -33,10 +48,17 @@ func testRegistry(t *testing.T, privKey types.PrivateKey, limit uint64) *registr
 		db.Close()
 	})
 
-	if err := db.UpdateSettings(settings.Settings{MaxRegistryEntries: limit}); err != nil {
+	if err := db.UpdateSettings(s); err != nil {
 		t.Fatal(err)
 	}
-	return registry.NewManager(privKey, db, log.Named("registry"))
+	reg, err := registry.NewManager(privKey, stubChain{}, db, log.Named("registry"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		reg.Close()
+	})
+	return reg
 }
 
 func TestRegistryPut(t *testing.T) {
@@ -147,3 +169,182 @@ func TestRegistryPut(t *testing.T) {
 		t.Fatalf("expected cap error")
 	}
 }
+
+func TestRegistryAdmin(t *testing.T) {
+	hostPriv := types.GeneratePrivateKey()
+	renterA := types.GeneratePrivateKey()
+	renterB := types.GeneratePrivateKey()
+	reg := testRegistry(t, hostPriv, 100)
+
+	// revisions are chosen so that ordering by the raw little-endian bytes
+	// differs from ordering by value
+	revisions := []uint64{256, 1, 65536, 2}
+	for i, rev := range revisions {
+		entry := randomValue(renterA)
+		entry.Revision = rev
+		entry.Signature = renterA.SignHash(entry.Hash())
+		if _, err := reg.Put(entry, uint64(100-i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	other := randomValue(renterB)
+	if _, err := reg.Put(other, 1000); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, count, err := reg.ListEntries(registry.EntryFilter{SortField: registry.EntrySortRevision})
+	if err != nil {
+		t.Fatal(err)
+	} else if count != 5 || len(entries) != 5 {
+		t.Fatalf("expected 5 entries, got %d (%d)", len(entries), count)
+	}
+	for i := 1; i < len(entries); i++ {
+		if entries[i].Revision < entries[i-1].Revision {
+			t.Fatalf("entries not sorted by revision: %d before %d", entries[i-1].Revision, entries[i].Revision)
+		}
+	}
+
+	entries, _, err = reg.ListEntries(registry.EntryFilter{SortField: registry.EntrySortExpiration, SortDesc: true, Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	} else if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	} else if entries[0].ExpirationHeight != 1000 || entries[1].ExpirationHeight != 100 {
+		t.Fatalf("unexpected expiration order: %d, %d", entries[0].ExpirationHeight, entries[1].ExpirationHeight)
+	}
+
+	pk := renterA.PublicKey()
+	entries, count, err = reg.ListEntries(registry.EntryFilter{PublicKey: &pk})
+	if err != nil {
+		t.Fatal(err)
+	} else if count != 4 {
+		t.Fatalf("expected 4 entries for public key, got %d", count)
+	}
+
+	if _, _, err := reg.ListEntries(registry.EntryFilter{SortField: "foo"}); err == nil {
+		t.Fatal("expected invalid sort field error")
+	}
+
+	// look up a single entry
+	key := other.RegistryKey.Hash()
+	entry, err := reg.Entry(key)
+	if err != nil {
+		t.Fatal(err)
+	} else if entry.PublicKey != renterB.PublicKey() || entry.Tweak != other.Tweak {
+		t.Fatal("expected entry to track public key and tweak")
+	} else if !bytes.Equal(entry.Data, other.Data) || entry.Signature != other.Signature {
+		t.Fatal("entry mismatch")
+	}
+
+	usage, err := reg.KeyUsage(10, 0)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(usage) != 2 {
+		t.Fatalf("expected 2 keys, got %d", len(usage))
+	} else if usage[0].PublicKey != renterA.PublicKey() || usage[0].Entries != 4 {
+		t.Fatalf("unexpected key usage %+v", usage[0])
+	}
+
+	// delete a single entry
+	if err := reg.DeleteEntry(key); err != nil {
+		t.Fatal(err)
+	} else if _, err := reg.Entry(key); !errors.Is(err, registry.ErrEntryNotFound) {
+		t.Fatalf("expected ErrEntryNotFound, got %v", err)
+	} else if err := reg.DeleteEntry(key); !errors.Is(err, registry.ErrEntryNotFound) {
+		t.Fatalf("expected ErrEntryNotFound, got %v", err)
+	}
+
+	// delete all entries for a public key
+	deleted, err := reg.DeleteEntries(renterA.PublicKey())
+	if err != nil {
+		t.Fatal(err)
+	} else if deleted != 4 {
+		t.Fatalf("expected 4 entries deleted, got %d", deleted)
+	}
+
+	if count, _, err := reg.Entries(); err != nil {
+		t.Fatal(err)
+	} else if count != 0 {
+		t.Fatalf("expected 0 entries, got %d", count)
+	}
+}
+
+func TestRegistryPrune(t *testing.T) {
+	hostPriv := types.GeneratePrivateKey()
+	renterPriv := types.GeneratePrivateKey()
+	reg := testRegistry(t, hostPriv, 100)
+
+	// heights are chosen so that comparing the raw little-endian bytes would
+	// prune the wrong entries
+	heights := []uint64{10, 256, 300, 1 << 16}
+	for _, height := range heights {
+		if _, err := reg.Put(randomValue(renterPriv), height); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pruned, err := reg.PruneExpired(256)
+	if err != nil {
+		t.Fatal(err)
+	} else if pruned != 2 {
+		t.Fatalf("expected 2 entries pruned, got %d", pruned)
+	}
+
+	entries, _, err := reg.ListEntries(registry.EntryFilter{SortField: registry.EntrySortExpiration})
+	if err != nil {
+		t.Fatal(err)
+	} else if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	} else if entries[0].ExpirationHeight != 300 || entries[1].ExpirationHeight != 1<<16 {
+		t.Fatalf("unexpected remaining entries: %d, %d", entries[0].ExpirationHeight, entries[1].ExpirationHeight)
+	}
+
+	if count, _, err := reg.Entries(); err != nil {
+		t.Fatal(err)
+	} else if count != 2 {
+		t.Fatalf("expected 2 entries, got %d", count)
+	}
+
+	// pruning again at the same height should not remove anything
+	if pruned, err := reg.PruneExpired(256); err != nil {
+		t.Fatal(err)
+	} else if pruned != 0 {
+		t.Fatalf("expected no entries pruned, got %d", pruned)
+	}
+}
+
+func TestRegistryKeyQuota(t *testing.T) {
+	const keyLimit = 3
+	hostPriv := types.GeneratePrivateKey()
+	renterA := types.GeneratePrivateKey()
+	renterB := types.GeneratePrivateKey()
+	reg := testRegistryWithSettings(t, hostPriv, settings.Settings{
+		MaxRegistryEntries:       100,
+		MaxRegistryEntriesPerKey: keyLimit,
+	})
+
+	var last rhp3.RegistryEntry
+	for i := 0; i < keyLimit; i++ {
+		last = randomValue(renterA)
+		if _, err := reg.Put(last, 100); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a new entry for the same key should be rejected
+	if _, err := reg.Put(randomValue(renterA), 100); !errors.Is(err, registry.ErrNotEnoughSpace) {
+		t.Fatalf("expected ErrNotEnoughSpace, got %v", err)
+	}
+
+	// updating an existing entry should still succeed
+	last.Revision++
+	last.Signature = renterA.SignHash(last.Hash())
+	if _, err := reg.Put(last, 100); err != nil {
+		t.Fatalf("expected update to succeed, got %v", err)
+	}
+
+	// other keys are unaffected
+	if _, err := reg.Put(randomValue(renterB), 100); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,218 @@
+package reports
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNoRate is returned by a RateSource when no exchange rate is available for
+// a timestamp.
+var ErrNoRate = errors.New("no exchange rate available")
+
+type (
+	// A RateSource returns the exchange rate between siacoins and a fiat
+	// currency.
+	RateSource interface {
+		// Currency returns the fiat currency of the rates.
+		Currency() string
+		// Rate returns the value of one siacoin in the fiat currency at
+		// the timestamp. If no rate is available, ErrNoRate is returned.
+		Rate(timestamp time.Time) (float64, error)
+	}
+
+	rateEntry struct {
+		timestamp time.Time
+		rate      float64
+	}
+
+	// FileRates reads exchange rates from a CSV file. Each row contains a
+	// date and the value of one siacoin on that date, for example
+	// "2023-01-31,0.0031". Dates may be formatted as YYYY-MM-DD, RFC 3339,
+	// or a unix timestamp. A header row is ignored. The file is read each
+	// time a rate is requested, so it can be updated without restarting
+	// the host.
+	FileRates struct {
+		path     string
+		currency string
+	}
+
+	// HTTPRates requests exchange rates from an HTTP endpoint. The endpoint
+	// is called with the "currency" and "timestamp" (unix seconds) query
+	// parameters and must respond with a JSON object containing the rate,
+	// for example {"rate": 0.0031}. A 404 response indicates that no rate
+	// is available.
+	HTTPRates struct {
+		url      string
+		currency string
+		client   *http.Client
+	}
+)
+
+func parseRateTimestamp(s string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	} else if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	} else if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(n, 0), nil
+	}
+	return time.Time{}, fmt.Errorf("unrecognized date %q", s)
+}
+
+func parseRate(s string) (float64, error) {
+	rate, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	} else if rate < 0 || math.IsInf(rate, 0) || math.IsNaN(rate) {
+		return 0, fmt.Errorf("invalid rate %v", s)
+	}
+	return rate, nil
+}
+
+// readRates reads and sorts the rates in a CSV file.
+func readRates(r io.Reader) ([]rateEntry, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = 2
+	cr.TrimLeadingSpace = true
+	cr.Comment = '#'
+
+	var entries []rateEntry
+	for i := 1; ; i++ {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		ts, err := parseRateTimestamp(strings.TrimSpace(record[0]))
+		if err != nil && i == 1 {
+			continue // header
+		} else if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i, err)
+		}
+		rate, err := parseRate(strings.TrimSpace(record[1]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i, err)
+		}
+		entries = append(entries, rateEntry{ts, rate})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].timestamp.Before(entries[j].timestamp)
+	})
+	return entries, nil
+}
+
+// Currency implements RateSource.
+func (fr *FileRates) Currency() string {
+	return fr.currency
+}
+
+// Rate implements RateSource. The most recent rate at or before the timestamp
+// is returned.
+func (fr *FileRates) Rate(timestamp time.Time) (float64, error) {
+	f, err := os.Open(fr.path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open rate file: %w", err)
+	}
+	defer f.Close()
+
+	entries, err := readRates(f)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read rate file: %w", err)
+	}
+	// find the first entry after the timestamp
+	i := sort.Search(len(entries), func(i int) bool {
+		return entries[i].timestamp.After(timestamp)
+	})
+	if i == 0 {
+		return 0, ErrNoRate
+	}
+	return entries[i-1].rate, nil
+}
+
+// Currency implements RateSource.
+func (hr *HTTPRates) Currency() string {
+	return hr.currency
+}
+
+// Rate implements RateSource.
+func (hr *HTTPRates) Rate(timestamp time.Time) (float64, error) {
+	u, err := url.Parse(hr.url)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse rate url: %w", err)
+	}
+	q := u.Query()
+	q.Set("currency", hr.currency)
+	q.Set("timestamp", strconv.FormatInt(timestamp.Unix(), 10))
+	u.RawQuery = q.Encode()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := hr.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to request rate: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return 0, ErrNoRate
+	case resp.StatusCode != http.StatusOK:
+		return 0, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Rate *float64 `json:"rate"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&body); err != nil {
+		return 0, fmt.Errorf("failed to decode response: %w", err)
+	} else if body.Rate == nil {
+		return 0, ErrNoRate
+	} else if *body.Rate < 0 || math.IsInf(*body.Rate, 0) || math.IsNaN(*body.Rate) {
+		return 0, fmt.Errorf("invalid rate %v", *body.Rate)
+	}
+	return *body.Rate, nil
+}
+
+// NewFileRates returns a RateSource that reads rates from a CSV file. The file
+// is validated when the source is created.
+func NewFileRates(path, currency string) (*FileRates, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open rate file: %w", err)
+	}
+	defer f.Close()
+	if _, err := readRates(f); err != nil {
+		return nil, fmt.Errorf("failed to read rate file: %w", err)
+	}
+	return &FileRates{path: path, currency: currency}, nil
+}
+
+// NewHTTPRates returns a RateSource that requests rates from an HTTP endpoint.
+func NewHTTPRates(endpoint, currency string) (*HTTPRates, error) {
+	if _, err := url.Parse(endpoint); err != nil {
+		return nil, fmt.Errorf("failed to parse rate url: %w", err)
+	}
+	return &HTTPRates{
+		url:      endpoint,
+		currency: currency,
+		client:   &http.Client{},
+	}, nil
+}
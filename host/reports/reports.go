@@ -0,0 +1,275 @@
+package reports
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/host/accounts"
+	"go.sia.tech/hostd/host/contracts"
+	"go.sia.tech/hostd/wallet"
+	"go.uber.org/zap"
+)
+
+// line types included in a revenue report
+const (
+	// LineContractRevenue is the revenue realized when a contract is
+	// successful.
+	LineContractRevenue LineType = "contractRevenue"
+	// LineCollateralLost is the collateral lost when a contract fails.
+	LineCollateralLost LineType = "collateralLost"
+	// LineMinerFee is a miner fee paid by the host's wallet.
+	LineMinerFee LineType = "minerFee"
+	// LineAccountExpiry is the remaining balance of an ephemeral account
+	// that expired before it was spent.
+	LineAccountExpiry LineType = "accountExpiry"
+)
+
+type (
+	// A LineType identifies the kind of entry in a report.
+	LineType string
+
+	// A Line is a single entry in a revenue report.
+	Line struct {
+		Timestamp time.Time `json:"timestamp"`
+		Type      LineType  `json:"type"`
+		// ID is the contract, transaction, or account the line refers to.
+		ID     string         `json:"id"`
+		Amount types.Currency `json:"amount"`
+		// Fiat is the value of the amount in the report's currency at the
+		// time of the line. It is nil if no rate is available.
+		Fiat *float64 `json:"fiat,omitempty"`
+	}
+
+	// A Report lists the host's realized revenue and expenses for a period.
+	Report struct {
+		From     time.Time `json:"from"`
+		To       time.Time `json:"to"`
+		Currency string    `json:"currency,omitempty"`
+		Lines    []Line    `json:"lines"`
+	}
+
+	// A ResolvedContract is a contract that was marked successful or
+	// failed.
+	ResolvedContract struct {
+		ID                types.FileContractID
+		Status            contracts.ContractStatus
+		RevisionConfirmed bool
+		Usage             contracts.Usage
+		// Timestamp is the time the contract's revenue was realized.
+		Timestamp time.Time
+		// LateRevenue is the portion of the contract's revenue that was
+		// spent from account funding after the contract resolved. It is
+		// reported when it was spent instead of with the contract.
+		LateRevenue types.Currency
+	}
+
+	// LateRevenue is revenue from a contract's account funding that was
+	// spent after the contract was marked successful.
+	LateRevenue struct {
+		ContractID types.FileContractID
+		Amount     types.Currency
+		Timestamp  time.Time
+	}
+
+	// A Store retrieves the records used to build reports. All methods
+	// return records with a timestamp in the range [from, to).
+	Store interface {
+		// ResolvedContracts returns contracts that were marked successful
+		// or failed.
+		ResolvedContracts(from, to time.Time) ([]ResolvedContract, error)
+		// WalletTransactions returns the wallet's confirmed transactions.
+		WalletTransactions(from, to time.Time) ([]wallet.Transaction, error)
+		// AccountExpirations returns the ledger entries of expired accounts.
+		AccountExpirations(from, to time.Time) ([]accounts.LedgerEntry, error)
+		// LateContractRevenue returns the revenue spent from resolved
+		// contracts' account funding.
+		LateContractRevenue(from, to time.Time) ([]LateRevenue, error)
+	}
+
+	// A Wallet provides the address of the host's wallet.
+	Wallet interface {
+		Address() types.Address
+	}
+
+	// A Manager generates accounting reports.
+	Manager struct {
+		store  Store
+		wallet Wallet
+		rates  RateSource // may be nil
+		log    *zap.Logger
+	}
+)
+
+// hastingsPerSiacoin is the number of hastings in one siacoin.
+var hastingsPerSiacoin = new(big.Int).Exp(big.NewInt(10), big.NewInt(24), nil)
+
+// Siacoins returns the amount in siacoins.
+func (l Line) Siacoins() *big.Rat {
+	return new(big.Rat).SetFrac(l.Amount.Big(), hastingsPerSiacoin)
+}
+
+// contractRevenue returns the revenue realized by a successful contract.
+// Expired account balances are excluded since they are reported when the
+// account expires.
+func contractRevenue(u contracts.Usage) types.Currency {
+	return u.RPCRevenue.
+		Add(u.StorageRevenue).
+		Add(u.IngressRevenue).
+		Add(u.EgressRevenue).
+		Add(u.RegistryRead).
+		Add(u.RegistryWrite)
+}
+
+// paidByWallet returns true if all of the transaction's siacoin inputs were
+// spent by the wallet.
+func paidByWallet(txn types.Transaction, addr types.Address) bool {
+	if len(txn.SiacoinInputs) == 0 {
+		return false
+	}
+	for _, sci := range txn.SiacoinInputs {
+		if sci.UnlockConditions.UnlockHash() != addr {
+			return false
+		}
+	}
+	return true
+}
+
+// Revenue returns a report of the revenue realized and the expenses incurred
+// by the host in the range [from, to). Lines are sorted by timestamp.
+//
+// Contract revenue is realized when the contract is marked successful. The
+// revenue of a contract whose final revision was not confirmed is not
+// included, matching the earned revenue metrics. Account funding spent after
+// a contract resolved is reported in a separate line when it is spent, so the
+// report for a closed period does not change.
+func (m *Manager) Revenue(from, to time.Time) (Report, error) {
+	if !from.Before(to) {
+		return Report{}, errors.New("from must be before to")
+	}
+
+	report := Report{
+		From: from,
+		To:   to,
+	}
+	if m.rates != nil {
+		report.Currency = m.rates.Currency()
+	}
+
+	resolved, err := m.store.ResolvedContracts(from, to)
+	if err != nil {
+		return Report{}, fmt.Errorf("failed to get resolved contracts: %w", err)
+	}
+	for _, c := range resolved {
+		switch {
+		case c.Status == contracts.ContractStatusSuccessful && c.RevisionConfirmed:
+			revenue, underflow := contractRevenue(c.Usage).SubWithUnderflow(c.LateRevenue)
+			if underflow {
+				m.log.Warn("late revenue exceeds contract revenue", zap.Stringer("contract", c.ID))
+				revenue = types.ZeroCurrency
+			}
+			if !revenue.IsZero() {
+				report.Lines = append(report.Lines, Line{Timestamp: c.Timestamp, Type: LineContractRevenue, ID: c.ID.String(), Amount: revenue})
+			}
+		case c.Status == contracts.ContractStatusFailed:
+			if !c.Usage.RiskedCollateral.IsZero() {
+				report.Lines = append(report.Lines, Line{Timestamp: c.Timestamp, Type: LineCollateralLost, ID: c.ID.String(), Amount: c.Usage.RiskedCollateral})
+			}
+		}
+	}
+
+	late, err := m.store.LateContractRevenue(from, to)
+	if err != nil {
+		return Report{}, fmt.Errorf("failed to get late contract revenue: %w", err)
+	}
+	for _, lr := range late {
+		if lr.Amount.IsZero() {
+			continue
+		}
+		report.Lines = append(report.Lines, Line{Timestamp: lr.Timestamp, Type: LineContractRevenue, ID: lr.ContractID.String(), Amount: lr.Amount})
+	}
+
+	txns, err := m.store.WalletTransactions(from, to)
+	if err != nil {
+		return Report{}, fmt.Errorf("failed to get wallet transactions: %w", err)
+	}
+	addr := m.wallet.Address()
+	for _, txn := range txns {
+		// fees are only attributed to the host if the wallet funded the
+		// whole transaction. Fees on transactions funded by renters, such
+		// as contract formations, are paid by the renter.
+		if !paidByWallet(txn.Transaction, addr) {
+			continue
+		}
+		var fees types.Currency
+		for _, fee := range txn.Transaction.MinerFees {
+			fees = fees.Add(fee)
+		}
+		if !fees.IsZero() {
+			report.Lines = append(report.Lines, Line{Timestamp: txn.Timestamp, Type: LineMinerFee, ID: txn.ID.String(), Amount: fees})
+		}
+	}
+
+	expired, err := m.store.AccountExpirations(from, to)
+	if err != nil {
+		return Report{}, fmt.Errorf("failed to get expired accounts: %w", err)
+	}
+	for _, entry := range expired {
+		if entry.Amount.IsZero() {
+			continue
+		}
+		report.Lines = append(report.Lines, Line{Timestamp: entry.Timestamp, Type: LineAccountExpiry, ID: entry.Account.String(), Amount: entry.Amount})
+	}
+
+	sort.SliceStable(report.Lines, func(i, j int) bool {
+		return report.Lines[i].Timestamp.Before(report.Lines[j].Timestamp)
+	})
+
+	if m.rates != nil {
+		if err := m.convert(report.Lines); err != nil {
+			return Report{}, fmt.Errorf("failed to convert to %v: %w", report.Currency, err)
+		}
+	}
+	return report, nil
+}
+
+// convert sets the fiat value of each line. Rates are looked up once per UTC
+// day.
+func (m *Manager) convert(lines []Line) error {
+	rates := make(map[time.Time]*big.Rat)
+	for i := range lines {
+		day := lines[i].Timestamp.UTC().Truncate(24 * time.Hour)
+		rate, ok := rates[day]
+		if !ok {
+			r, err := m.rates.Rate(day)
+			if errors.Is(err, ErrNoRate) {
+				m.log.Debug("no exchange rate available", zap.Time("day", day))
+			} else if err != nil {
+				return fmt.Errorf("failed to get rate for %v: %w", day.Format("2006-01-02"), err)
+			} else {
+				rate = new(big.Rat).SetFloat64(r)
+			}
+			rates[day] = rate
+		}
+		if rate == nil {
+			continue
+		}
+		fiat, _ := new(big.Rat).Mul(lines[i].Siacoins(), rate).Float64()
+		lines[i].Fiat = &fiat
+	}
+	return nil
+}
+
+// NewManager returns a new report manager. If rates is nil, reports do not
+// include fiat values.
+func NewManager(store Store, w Wallet, rates RateSource, log *zap.Logger) *Manager {
+	return &Manager{
+		store:  store,
+		wallet: w,
+		rates:  rates,
+		log:    log,
+	}
+}
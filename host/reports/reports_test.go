@@ -0,0 +1,355 @@
+package reports_test
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	rhp3 "go.sia.tech/core/rhp/v3"
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/host/accounts"
+	"go.sia.tech/hostd/host/contracts"
+	"go.sia.tech/hostd/host/metrics"
+	"go.sia.tech/hostd/host/reports"
+	"go.sia.tech/hostd/wallet"
+	"go.uber.org/zap/zaptest"
+	"lukechampine.com/frand"
+)
+
+type stubStore struct {
+	contracts []reports.ResolvedContract
+	txns      []wallet.Transaction
+	expired   []accounts.LedgerEntry
+	late      []reports.LateRevenue
+}
+
+func inRange(ts, from, to time.Time) bool {
+	return !ts.Before(from) && ts.Before(to)
+}
+
+func (s *stubStore) ResolvedContracts(from, to time.Time) (resolved []reports.ResolvedContract, _ error) {
+	for _, c := range s.contracts {
+		if inRange(c.Timestamp, from, to) {
+			resolved = append(resolved, c)
+		}
+	}
+	return
+}
+
+func (s *stubStore) WalletTransactions(from, to time.Time) (txns []wallet.Transaction, _ error) {
+	for _, txn := range s.txns {
+		if inRange(txn.Timestamp, from, to) {
+			txns = append(txns, txn)
+		}
+	}
+	return
+}
+
+func (s *stubStore) AccountExpirations(from, to time.Time) (entries []accounts.LedgerEntry, _ error) {
+	for _, entry := range s.expired {
+		if inRange(entry.Timestamp, from, to) {
+			entries = append(entries, entry)
+		}
+	}
+	return
+}
+
+func (s *stubStore) LateContractRevenue(from, to time.Time) (late []reports.LateRevenue, _ error) {
+	for _, lr := range s.late {
+		if inRange(lr.Timestamp, from, to) {
+			late = append(late, lr)
+		}
+	}
+	return
+}
+
+type stubWallet types.Address
+
+func (w stubWallet) Address() types.Address { return types.Address(w) }
+
+func TestRevenueReport(t *testing.T) {
+	hostKey := types.GeneratePrivateKey()
+	uc := types.StandardUnlockConditions(hostKey.PublicKey())
+	addr := uc.UnlockHash()
+	renterUC := types.StandardUnlockConditions(types.GeneratePrivateKey().PublicKey())
+
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	successful := reports.ResolvedContract{
+		ID:                frand.Entropy256(),
+		Status:            contracts.ContractStatusSuccessful,
+		RevisionConfirmed: true,
+		Usage: contracts.Usage{
+			RPCRevenue:       types.Siacoins(1),
+			StorageRevenue:   types.Siacoins(10),
+			AccountExpiry:    types.Siacoins(100), // reported when the account expires
+			RiskedCollateral: types.Siacoins(20),
+		},
+		Timestamp: start.Add(36 * time.Hour),
+	}
+	failed := reports.ResolvedContract{
+		ID:     frand.Entropy256(),
+		Status: contracts.ContractStatusFailed,
+		Usage: contracts.Usage{
+			StorageRevenue:   types.Siacoins(5),
+			RiskedCollateral: types.Siacoins(7),
+		},
+		Timestamp: start.Add(12 * time.Hour),
+	}
+	unconfirmed := reports.ResolvedContract{
+		ID:        frand.Entropy256(),
+		Status:    contracts.ContractStatusSuccessful,
+		Usage:     contracts.Usage{StorageRevenue: types.Siacoins(3)},
+		Timestamp: start.Add(12 * time.Hour),
+	}
+
+	// the wallet funded the whole transaction
+	walletTxn := wallet.Transaction{
+		ID: frand.Entropy256(),
+		Transaction: types.Transaction{
+			SiacoinInputs: []types.SiacoinInput{{UnlockConditions: uc}, {UnlockConditions: uc}},
+			MinerFees:     []types.Currency{types.Siacoins(1).Div64(10), types.Siacoins(1).Div64(10)},
+		},
+		Timestamp: start.Add(6 * time.Hour),
+	}
+	// a renter funded the fees of a contract formation
+	formationTxn := wallet.Transaction{
+		ID: frand.Entropy256(),
+		Transaction: types.Transaction{
+			SiacoinInputs: []types.SiacoinInput{{UnlockConditions: renterUC}, {UnlockConditions: uc}},
+			MinerFees:     []types.Currency{types.Siacoins(1)},
+		},
+		Timestamp: start.Add(6 * time.Hour),
+	}
+
+	expiry := accounts.LedgerEntry{
+		Account:   rhp3.Account(types.GeneratePrivateKey().PublicKey()),
+		Type:      accounts.LedgerEntryExpiry,
+		Amount:    types.Siacoins(2),
+		Timestamp: start.Add(48 * time.Hour),
+	}
+
+	store := &stubStore{
+		contracts: []reports.ResolvedContract{successful, failed, unconfirmed},
+		txns:      []wallet.Transaction{walletTxn, formationTxn},
+		expired:   []accounts.LedgerEntry{expiry},
+	}
+
+	dir := t.TempDir()
+	ratesPath := filepath.Join(dir, "rates.csv")
+	const ratesFile = `date,rate
+2023-01-02,0.5
+2023-01-01,0.25
+`
+	if err := os.WriteFile(ratesPath, []byte(ratesFile), 0600); err != nil {
+		t.Fatal(err)
+	}
+	rates, err := reports.NewFileRates(ratesPath, "usd")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := reports.NewManager(store, stubWallet(addr), rates, zaptest.NewLogger(t))
+	if _, err := m.Revenue(start, start); err == nil {
+		t.Fatal("expected error for empty range")
+	}
+
+	report, err := m.Revenue(start, start.Add(72*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	} else if report.Currency != "usd" {
+		t.Fatalf("expected currency usd, got %q", report.Currency)
+	}
+
+	expected := []struct {
+		typ    reports.LineType
+		id     string
+		amount types.Currency
+		fiat   float64
+	}{
+		{reports.LineMinerFee, walletTxn.ID.String(), types.Siacoins(1).Div64(5), 0.05},
+		{reports.LineCollateralLost, failed.ID.String(), types.Siacoins(7), 1.75},
+		{reports.LineContractRevenue, successful.ID.String(), types.Siacoins(11), 5.5},
+		{reports.LineAccountExpiry, expiry.Account.String(), types.Siacoins(2), 1},
+	}
+	if len(report.Lines) != len(expected) {
+		t.Fatalf("expected %v lines, got %+v", len(expected), report.Lines)
+	}
+	for i, exp := range expected {
+		line := report.Lines[i]
+		switch {
+		case line.Type != exp.typ:
+			t.Fatalf("line %d: expected type %v, got %v", i, exp.typ, line.Type)
+		case line.ID != exp.id:
+			t.Fatalf("line %d: expected id %v, got %v", i, exp.id, line.ID)
+		case !line.Amount.Equals(exp.amount):
+			t.Fatalf("line %d: expected amount %v, got %v", i, exp.amount, line.Amount)
+		case line.Fiat == nil:
+			t.Fatalf("line %d: expected fiat value", i)
+		case math.Abs(*line.Fiat-exp.fiat) > 1e-9:
+			t.Fatalf("line %d: expected fiat %v, got %v", i, exp.fiat, *line.Fiat)
+		}
+	}
+
+	// lines before the first rate should not have a fiat value
+	store.expired = append(store.expired, accounts.LedgerEntry{
+		Account:   expiry.Account,
+		Type:      accounts.LedgerEntryExpiry,
+		Amount:    types.Siacoins(1),
+		Timestamp: start.Add(-time.Hour),
+	})
+	report, err = m.Revenue(start.Add(-24*time.Hour), start)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(report.Lines) != 1 {
+		t.Fatalf("expected 1 line, got %+v", report.Lines)
+	} else if report.Lines[0].Fiat != nil {
+		t.Fatalf("expected no fiat value, got %v", *report.Lines[0].Fiat)
+	}
+
+	// reports without a rate source should not have fiat values
+	m = reports.NewManager(store, stubWallet(addr), nil, zaptest.NewLogger(t))
+	report, err = m.Revenue(start, start.Add(72*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	} else if report.Currency != "" {
+		t.Fatalf("expected no currency, got %q", report.Currency)
+	}
+	for _, line := range report.Lines {
+		if line.Fiat != nil {
+			t.Fatalf("expected no fiat value, got %v", *line.Fiat)
+		}
+	}
+}
+
+func TestLateContractRevenue(t *testing.T) {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	contract := reports.ResolvedContract{
+		ID:                frand.Entropy256(),
+		Status:            contracts.ContractStatusSuccessful,
+		RevisionConfirmed: true,
+		// includes the account funding spent after the contract resolved
+		Usage:       contracts.Usage{StorageRevenue: types.Siacoins(10)},
+		Timestamp:   start.Add(12 * time.Hour),
+		LateRevenue: types.Siacoins(3),
+	}
+	store := &stubStore{
+		contracts: []reports.ResolvedContract{contract},
+		late: []reports.LateRevenue{
+			{ContractID: contract.ID, Amount: types.Siacoins(1), Timestamp: start.Add(36 * time.Hour)},
+			{ContractID: contract.ID, Amount: types.Siacoins(2), Timestamp: start.Add(60 * time.Hour)},
+		},
+	}
+	m := reports.NewManager(store, stubWallet{}, nil, zaptest.NewLogger(t))
+
+	// revenue spent after a period closed should not change its report
+	for i, amount := range []types.Currency{types.Siacoins(7), types.Siacoins(1), types.Siacoins(2)} {
+		from := start.Add(time.Duration(i) * 24 * time.Hour)
+		report, err := m.Revenue(from, from.Add(24*time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		} else if len(report.Lines) != 1 {
+			t.Fatalf("day %d: expected 1 line, got %+v", i, report.Lines)
+		} else if line := report.Lines[0]; line.Type != reports.LineContractRevenue || line.ID != contract.ID.String() {
+			t.Fatalf("day %d: unexpected line %+v", i, line)
+		} else if !line.Amount.Equals(amount) {
+			t.Fatalf("day %d: expected amount %v, got %v", i, amount, line.Amount)
+		}
+	}
+}
+
+func TestWalletSummary(t *testing.T) {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := &stubStore{
+		txns: []wallet.Transaction{
+			{Source: wallet.TxnSourceMinerPayout, Inflow: types.Siacoins(10), Timestamp: start.Add(time.Hour)},
+			// send 4 SC, spending a 5 SC output and receiving 1 SC of change
+			{Source: wallet.TxnSourceTransaction, Inflow: types.Siacoins(1), Outflow: types.Siacoins(5), Timestamp: start.Add(2 * time.Hour)},
+			// a transaction that spends 2 SC of the wallet's outputs and pays it 5 SC
+			{Source: wallet.TxnSourceTransaction, Inflow: types.Siacoins(5), Outflow: types.Siacoins(2), Timestamp: start.Add(150 * time.Minute)},
+			{Source: wallet.TxnSourceMinerPayout, Inflow: types.Siacoins(10), Timestamp: start.Add(3 * time.Hour)},
+			{Source: wallet.TxnSourceContract, Inflow: types.Siacoins(20), Timestamp: start.Add(26 * time.Hour)},
+			// outside of the range
+			{Source: wallet.TxnSourceContract, Inflow: types.Siacoins(30), Timestamp: start.Add(72 * time.Hour)},
+		},
+	}
+	m := reports.NewManager(store, stubWallet{}, nil, zaptest.NewLogger(t))
+
+	if _, err := m.WalletSummary(start, start, metrics.IntervalDaily); err == nil {
+		t.Fatal("expected error for empty range")
+	}
+
+	summary, err := m.WalletSummary(start, start.Add(48*time.Hour), metrics.IntervalDaily)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []reports.WalletPeriod{
+		{Timestamp: start, Categories: []reports.CategorySummary{
+			{Source: wallet.TxnSourceMinerPayout, Count: 2, Inflow: types.Siacoins(20)},
+			{Source: wallet.TxnSourceTransaction, Count: 2, Inflow: types.Siacoins(3), Outflow: types.Siacoins(4)},
+		}},
+		{Timestamp: start.Add(24 * time.Hour), Categories: []reports.CategorySummary{
+			{Source: wallet.TxnSourceContract, Count: 1, Inflow: types.Siacoins(20)},
+		}},
+	}
+	if len(summary.Periods) != len(expected) {
+		t.Fatalf("expected %v periods, got %v", len(expected), len(summary.Periods))
+	}
+	for i, period := range summary.Periods {
+		if !period.Timestamp.Equal(expected[i].Timestamp) {
+			t.Fatalf("period %v: expected timestamp %v, got %v", i, expected[i].Timestamp, period.Timestamp)
+		} else if len(period.Categories) != len(expected[i].Categories) {
+			t.Fatalf("period %v: expected %v categories, got %v", i, len(expected[i].Categories), len(period.Categories))
+		}
+		for j, category := range period.Categories {
+			if category != expected[i].Categories[j] {
+				t.Fatalf("period %v: expected category %+v, got %+v", i, expected[i].Categories[j], category)
+			}
+		}
+	}
+}
+
+func TestFileRates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.csv")
+	if err := os.WriteFile(path, []byte("2023-01-01,abc\n"), 0600); err != nil {
+		t.Fatal(err)
+	} else if _, err := reports.NewFileRates(path, "usd"); err == nil {
+		t.Fatal("expected error for invalid rate")
+	}
+
+	const ratesFile = `# rates in usd
+2023-01-01,0.1
+2023-01-03T12:00:00Z,0.3
+1672617600,0.2
+`
+	if err := os.WriteFile(path, []byte(ratesFile), 0600); err != nil {
+		t.Fatal(err)
+	}
+	rates, err := reports.NewFileRates(path, "usd")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		timestamp time.Time
+		rate      float64
+		err       error
+	}{
+		{time.Date(2022, 12, 31, 0, 0, 0, 0, time.UTC), 0, reports.ErrNoRate},
+		{time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), 0.1, nil},
+		{time.Date(2023, 1, 2, 6, 0, 0, 0, time.UTC), 0.2, nil},
+		{time.Date(2023, 1, 3, 0, 0, 0, 0, time.UTC), 0.2, nil},
+		{time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC), 0.3, nil},
+	}
+	for _, test := range tests {
+		rate, err := rates.Rate(test.timestamp)
+		if !errors.Is(err, test.err) {
+			t.Fatalf("%v: expected error %v, got %v", test.timestamp, test.err, err)
+		} else if rate != test.rate {
+			t.Fatalf("%v: expected rate %v, got %v", test.timestamp, test.rate, rate)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package reports
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/host/metrics"
+	"go.sia.tech/hostd/wallet"
+)
+
+type (
+	// A CategorySummary totals the wallet transactions from a single source.
+	// Each transaction's inflow and outflow are netted, so a transaction only
+	// counts toward the total matching its direction.
+	CategorySummary struct {
+		Source  wallet.TransactionSource `json:"source"`
+		Count   int                      `json:"count"`
+		Inflow  types.Currency           `json:"inflow"`
+		Outflow types.Currency           `json:"outflow"`
+	}
+
+	// A WalletPeriod totals the wallet transactions confirmed during a
+	// period by source. Periods without transactions are omitted.
+	WalletPeriod struct {
+		Timestamp  time.Time         `json:"timestamp"`
+		Categories []CategorySummary `json:"categories"`
+	}
+
+	// A WalletSummary totals the wallet's inflows and outflows by source for
+	// each period in a range.
+	WalletSummary struct {
+		From     time.Time        `json:"from"`
+		To       time.Time        `json:"to"`
+		Interval metrics.Interval `json:"interval"`
+		Periods  []WalletPeriod   `json:"periods"`
+	}
+)
+
+// WalletSummary returns the wallet's inflows and outflows in the range
+// [from, to), grouped by period and transaction source. Periods start at
+// the beginning of the interval containing each transaction, in from's
+// location.
+func (m *Manager) WalletSummary(from, to time.Time, interval metrics.Interval) (WalletSummary, error) {
+	if !from.Before(to) {
+		return WalletSummary{}, errors.New("from must be before to")
+	} else if _, err := metrics.Normalize(from, interval); err != nil {
+		return WalletSummary{}, err
+	}
+
+	txns, err := m.store.WalletTransactions(from, to)
+	if err != nil {
+		return WalletSummary{}, fmt.Errorf("failed to get wallet transactions: %w", err)
+	}
+
+	summary := WalletSummary{
+		From:     from,
+		To:       to,
+		Interval: interval,
+	}
+	// transactions are ordered by timestamp, so periods are created in order
+	var categories map[wallet.TransactionSource]int // index of the category in the current period
+	for _, txn := range txns {
+		timestamp, _ := metrics.Normalize(txn.Timestamp.In(from.Location()), interval)
+		if len(summary.Periods) == 0 || !summary.Periods[len(summary.Periods)-1].Timestamp.Equal(timestamp) {
+			summary.Periods = append(summary.Periods, WalletPeriod{Timestamp: timestamp})
+			categories = make(map[wallet.TransactionSource]int)
+		}
+		period := &summary.Periods[len(summary.Periods)-1]
+
+		i, ok := categories[txn.Source]
+		if !ok {
+			i = len(period.Categories)
+			period.Categories = append(period.Categories, CategorySummary{Source: txn.Source})
+			categories[txn.Source] = i
+		}
+		category := &period.Categories[i]
+		category.Count++
+		// change returned to the wallet is part of the transaction's
+		// inflow, so only the net amount is counted
+		switch txn.Direction() {
+		case wallet.TxnDirectionIncoming:
+			category.Inflow = category.Inflow.Add(txn.Inflow.Sub(txn.Outflow))
+		case wallet.TxnDirectionOutgoing:
+			category.Outflow = category.Outflow.Add(txn.Outflow.Sub(txn.Inflow))
+		}
+	}
+
+	for _, period := range summary.Periods {
+		sort.Slice(period.Categories, func(i, j int) bool {
+			return period.Categories[i].Source < period.Categories[j].Source
+		})
+	}
+	return summary, nil
+}
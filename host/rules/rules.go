@@ -0,0 +1,657 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.sia.tech/core/consensus"
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/host/alerts"
+	"go.sia.tech/hostd/host/contracts"
+	"go.sia.tech/hostd/host/metrics"
+	"go.sia.tech/hostd/internal/threadgroup"
+	"go.sia.tech/hostd/rhp"
+	"go.uber.org/zap"
+)
+
+// metrics that can be used in a rule. Currency values are in Siacoins,
+// durations are in seconds and percentages are between 0 and 100.
+const (
+	// MetricWalletSpendable is the wallet's spendable balance.
+	MetricWalletSpendable Metric = "wallet.spendable"
+	// MetricWalletConfirmed is the wallet's confirmed balance.
+	MetricWalletConfirmed Metric = "wallet.confirmed"
+
+	// MetricStorageUsedSectors is the number of sectors stored by the host.
+	MetricStorageUsedSectors Metric = "storage.usedSectors"
+	// MetricStorageTotalSectors is the number of sectors the host's
+	// volumes can store.
+	MetricStorageTotalSectors Metric = "storage.totalSectors"
+	// MetricStorageUsedPercent is the percentage of the host's storage
+	// that is used.
+	MetricStorageUsedPercent Metric = "storage.usedPercent"
+
+	// MetricContractsPending is the number of pending contracts.
+	MetricContractsPending Metric = "contracts.pending"
+	// MetricContractsActive is the number of active contracts.
+	MetricContractsActive Metric = "contracts.active"
+	// MetricContractsFailed is the number of contracts that failed.
+	MetricContractsFailed Metric = "contracts.failed"
+	// MetricContractsLockedCollateral is the collateral locked in contracts.
+	MetricContractsLockedCollateral Metric = "contracts.lockedCollateral"
+	// MetricContractsRiskedCollateral is the collateral at risk of being
+	// lost.
+	MetricContractsRiskedCollateral Metric = "contracts.riskedCollateral"
+	// MetricContractsSinceLastFormed is the time since the most recent
+	// contract was negotiated, estimated from the block height. It is
+	// zero if the host has not formed any contracts.
+	MetricContractsSinceLastFormed Metric = "contracts.sinceLastFormed"
+
+	// MetricAccountsActive is the number of active ephemeral accounts.
+	MetricAccountsActive Metric = "accounts.active"
+	// MetricAccountsBalance is the total balance of ephemeral accounts.
+	MetricAccountsBalance Metric = "accounts.balance"
+
+	// MetricRegistryUsedPercent is the percentage of the host's registry
+	// that is used.
+	MetricRegistryUsedPercent Metric = "registry.usedPercent"
+
+	// MetricConsensusSynced is 1 if the host is synced with the network
+	// and 0 otherwise.
+	MetricConsensusSynced Metric = "consensus.synced"
+
+	// MetricSessionsActive is the number of active RHP sessions.
+	MetricSessionsActive Metric = "sessions.active"
+)
+
+// operators that compare a metric to a rule's threshold.
+const (
+	OperatorLess         Operator = "<"
+	OperatorLessEqual    Operator = "<="
+	OperatorGreater      Operator = ">"
+	OperatorGreaterEqual Operator = ">="
+	OperatorEqual        Operator = "=="
+	OperatorNotEqual     Operator = "!="
+)
+
+const (
+	// evaluateInterval is the time between rule evaluations.
+	evaluateInterval = time.Minute
+	// blockInterval is the expected time between blocks.
+	blockInterval = 10 * time.Minute
+	// maxNameLen is the maximum length of a rule's name.
+	maxNameLen = 256
+)
+
+type (
+	// A Metric is a value that rules are evaluated against.
+	Metric string
+
+	// An Operator compares a metric to a rule's threshold.
+	Operator string
+
+	// A Rule registers an alert while a metric meets its condition.
+	Rule struct {
+		ID int64 `json:"id"`
+		// Name is used as the message of the rule's alert.
+		Name      string   `json:"name"`
+		Metric    Metric   `json:"metric"`
+		Operator  Operator `json:"operator"`
+		Threshold float64  `json:"threshold"`
+		// For is how long the condition must hold before an alert is
+		// registered. If zero, the alert is registered as soon as the
+		// condition is met.
+		For      time.Duration   `json:"for"`
+		Severity alerts.Severity `json:"severity"`
+		Enabled  bool            `json:"enabled"`
+	}
+
+	// A Store persists alert rules.
+	Store interface {
+		// AlertRules returns all alert rules.
+		AlertRules() ([]Rule, error)
+		// AddAlertRule adds an alert rule and returns its ID.
+		AddAlertRule(Rule) (int64, error)
+		// UpdateAlertRule updates an alert rule. If the rule does not
+		// exist, ErrRuleNotFound must be returned.
+		UpdateAlertRule(Rule) error
+		// DeleteAlertRule removes an alert rule. If the rule does not
+		// exist, ErrRuleNotFound must be returned.
+		DeleteAlertRule(id int64) error
+	}
+
+	// Alerts registers and dismisses alerts.
+	Alerts interface {
+		Active() []alerts.Alert
+		Register(alerts.Alert)
+		DismissBy(by string, ids ...types.Hash256)
+	}
+
+	// Metrics returns the host's aggregated metrics.
+	Metrics interface {
+		Metrics(time.Time) (metrics.Metrics, error)
+	}
+
+	// A VolumeManager reports the host's storage utilization.
+	VolumeManager interface {
+		Usage() (usedSectors uint64, totalSectors uint64, err error)
+	}
+
+	// A Wallet reports the host's wallet balance.
+	Wallet interface {
+		Balance() (spendable, confirmed, unconfirmed types.Currency, err error)
+	}
+
+	// A ChainManager reports the state of the blockchain.
+	ChainManager interface {
+		Synced() bool
+		TipState() consensus.State
+	}
+
+	// A ContractManager reports the host's contracts.
+	ContractManager interface {
+		Contracts(filter contracts.ContractFilter) ([]contracts.Contract, int, error)
+	}
+
+	// A SessionReporter reports the host's active RHP sessions.
+	SessionReporter interface {
+		Active() []rhp.Session
+	}
+
+	// ruleState tracks the evaluation of a rule.
+	ruleState struct {
+		rule Rule
+		// pendingSince is the time the rule's condition was first met.
+		// It is zero if the condition is not met.
+		pendingSince time.Time
+		// firing is true if the rule's alert is registered.
+		firing bool
+	}
+
+	// A Manager periodically evaluates alert rules and registers an alert
+	// for each rule whose condition is met. The alert is dismissed when the
+	// condition resolves.
+	Manager struct {
+		store     Store
+		alerts    Alerts
+		metrics   Metrics
+		volumes   VolumeManager
+		wallet    Wallet
+		chain     ChainManager
+		contracts ContractManager
+		sessions  SessionReporter
+		log       *zap.Logger
+		tg        *threadgroup.ThreadGroup
+
+		trigger chan struct{}
+
+		mu    sync.Mutex // guards rules
+		rules map[int64]*ruleState
+	}
+
+	// sampler loads the values of metrics for a single evaluation. Each
+	// source is queried at most once.
+	sampler struct {
+		m   *Manager
+		now time.Time
+
+		values  map[Metric]float64
+		metrics *metrics.Metrics
+		usage   *[2]uint64
+	}
+)
+
+var (
+	// ErrRuleNotFound is returned when an alert rule does not exist.
+	ErrRuleNotFound = errors.New("alert rule not found")
+
+	// metricNames is the set of metrics that can be used in a rule.
+	metricNames = map[Metric]bool{
+		MetricWalletSpendable:           true,
+		MetricWalletConfirmed:           true,
+		MetricStorageUsedSectors:        true,
+		MetricStorageTotalSectors:       true,
+		MetricStorageUsedPercent:        true,
+		MetricContractsPending:          true,
+		MetricContractsActive:           true,
+		MetricContractsFailed:           true,
+		MetricContractsLockedCollateral: true,
+		MetricContractsRiskedCollateral: true,
+		MetricContractsSinceLastFormed:  true,
+		MetricAccountsActive:            true,
+		MetricAccountsBalance:           true,
+		MetricRegistryUsedPercent:       true,
+		MetricConsensusSynced:           true,
+		MetricSessionsActive:            true,
+	}
+)
+
+// Compare returns true if value compared to threshold satisfies the
+// operator.
+func (op Operator) Compare(value, threshold float64) bool {
+	switch op {
+	case OperatorLess:
+		return value < threshold
+	case OperatorLessEqual:
+		return value <= threshold
+	case OperatorGreater:
+		return value > threshold
+	case OperatorGreaterEqual:
+		return value >= threshold
+	case OperatorEqual:
+		return value == threshold
+	case OperatorNotEqual:
+		return value != threshold
+	default:
+		panic(fmt.Sprintf("unrecognized operator %q", op)) // should never happen
+	}
+}
+
+// Validate returns an error if the rule is invalid.
+func (r Rule) Validate() error {
+	switch {
+	case strings.TrimSpace(r.Name) == "":
+		return errors.New("name is required")
+	case len(r.Name) > maxNameLen:
+		return fmt.Errorf("name must be at most %d characters", maxNameLen)
+	case !metricNames[r.Metric]:
+		return fmt.Errorf("unrecognized metric %q", r.Metric)
+	case math.IsNaN(r.Threshold) || math.IsInf(r.Threshold, 0):
+		return errors.New("threshold must be a finite number")
+	case r.For < 0:
+		return errors.New("for must not be negative")
+	case r.Severity < alerts.SeverityInfo || r.Severity > alerts.SeverityCritical:
+		return fmt.Errorf("invalid severity %d", r.Severity)
+	}
+
+	switch r.Operator {
+	case OperatorLess, OperatorLessEqual, OperatorGreater, OperatorGreaterEqual, OperatorEqual, OperatorNotEqual:
+	default:
+		return fmt.Errorf("unrecognized operator %q", r.Operator)
+	}
+	return nil
+}
+
+// AlertID returns the ID of the alert registered by the rule.
+func (r Rule) AlertID() types.Hash256 {
+	return types.HashBytes([]byte(fmt.Sprintf("alertRule:%d", r.ID)))
+}
+
+// siacoins converts a currency value to Siacoins.
+func siacoins(c types.Currency) float64 {
+	sc, _ := new(big.Rat).SetFrac(c.Big(), types.Siacoins(1).Big()).Float64()
+	return sc
+}
+
+// percent returns n/d as a percentage. If d is zero, percent returns 0.
+func percent(n, d uint64) float64 {
+	if d == 0 {
+		return 0
+	}
+	return float64(n) / float64(d) * 100
+}
+
+// hostMetrics returns the host's aggregated metrics.
+func (s *sampler) hostMetrics() (metrics.Metrics, error) {
+	if s.metrics == nil {
+		m, err := s.m.metrics.Metrics(s.now)
+		if err != nil {
+			return metrics.Metrics{}, fmt.Errorf("failed to get metrics: %w", err)
+		}
+		s.metrics = &m
+	}
+	return *s.metrics, nil
+}
+
+// storageUsage returns the host's used and total sectors.
+func (s *sampler) storageUsage() (used, total uint64, err error) {
+	if s.usage == nil {
+		used, total, err := s.m.volumes.Usage()
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to get storage usage: %w", err)
+		}
+		s.usage = &[2]uint64{used, total}
+	}
+	return s.usage[0], s.usage[1], nil
+}
+
+// sinceLastFormed returns the estimated time since the most recent contract
+// was negotiated.
+func (s *sampler) sinceLastFormed() (time.Duration, error) {
+	latest, _, err := s.m.contracts.Contracts(contracts.ContractFilter{
+		SortField: contracts.ContractSortNegotiationHeight,
+		SortDesc:  true,
+		Limit:     1,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to get latest contract: %w", err)
+	} else if len(latest) == 0 {
+		return 0, nil
+	}
+
+	height := s.m.chain.TipState().Index.Height
+	if latest[0].NegotiationHeight >= height {
+		return 0, nil
+	}
+	return time.Duration(height-latest[0].NegotiationHeight) * blockInterval, nil
+}
+
+// load returns the current value of a metric.
+func (s *sampler) load(metric Metric) (float64, error) {
+	switch metric {
+	case MetricWalletSpendable, MetricWalletConfirmed:
+		spendable, confirmed, _, err := s.m.wallet.Balance()
+		if err != nil {
+			return 0, fmt.Errorf("failed to get wallet balance: %w", err)
+		}
+		// both balances are cached to avoid querying the wallet twice
+		s.values[MetricWalletSpendable] = siacoins(spendable)
+		s.values[MetricWalletConfirmed] = siacoins(confirmed)
+		return s.values[metric], nil
+	case MetricStorageUsedSectors, MetricStorageTotalSectors, MetricStorageUsedPercent:
+		used, total, err := s.storageUsage()
+		if err != nil {
+			return 0, err
+		}
+		switch metric {
+		case MetricStorageUsedSectors:
+			return float64(used), nil
+		case MetricStorageTotalSectors:
+			return float64(total), nil
+		default:
+			return percent(used, total), nil
+		}
+	case MetricContractsSinceLastFormed:
+		elapsed, err := s.sinceLastFormed()
+		if err != nil {
+			return 0, err
+		}
+		return elapsed.Seconds(), nil
+	case MetricConsensusSynced:
+		if s.m.chain.Synced() {
+			return 1, nil
+		}
+		return 0, nil
+	case MetricSessionsActive:
+		return float64(len(s.m.sessions.Active())), nil
+	}
+
+	m, err := s.hostMetrics()
+	if err != nil {
+		return 0, err
+	}
+	switch metric {
+	case MetricContractsPending:
+		return float64(m.Contracts.Pending), nil
+	case MetricContractsActive:
+		return float64(m.Contracts.Active), nil
+	case MetricContractsFailed:
+		return float64(m.Contracts.Failed), nil
+	case MetricContractsLockedCollateral:
+		return siacoins(m.Contracts.LockedCollateral), nil
+	case MetricContractsRiskedCollateral:
+		return siacoins(m.Contracts.RiskedCollateral), nil
+	case MetricAccountsActive:
+		return float64(m.Accounts.Active), nil
+	case MetricAccountsBalance:
+		return siacoins(m.Accounts.Balance), nil
+	case MetricRegistryUsedPercent:
+		return percent(m.Registry.Entries, m.Registry.MaxEntries), nil
+	default:
+		return 0, fmt.Errorf("unrecognized metric %q", metric)
+	}
+}
+
+// value returns the current value of a metric.
+func (s *sampler) value(metric Metric) (float64, error) {
+	if v, ok := s.values[metric]; ok {
+		return v, nil
+	}
+	v, err := s.load(metric)
+	if err != nil {
+		return 0, err
+	}
+	s.values[metric] = v
+	return v, nil
+}
+
+// dismiss dismisses the rule's alert if it is registered. The caller must
+// hold the manager's lock.
+func (m *Manager) dismiss(state *ruleState) {
+	state.pendingSince = time.Time{}
+	if !state.firing {
+		return
+	}
+	m.alerts.DismissBy(alerts.DismissedBySystem, state.rule.AlertID())
+	state.firing = false
+}
+
+// Evaluate evaluates the enabled rules. An alert is registered for each rule
+// whose condition has held for the rule's duration, and dismissed for each
+// rule whose condition no longer holds.
+func (m *Manager) Evaluate(now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s := &sampler{
+		m:      m,
+		now:    now,
+		values: make(map[Metric]float64),
+	}
+	var errs []error
+	for _, state := range m.rules {
+		rule := state.rule
+		if !rule.Enabled {
+			m.dismiss(state)
+			continue
+		}
+
+		value, err := s.value(rule.Metric)
+		if err != nil {
+			// leave the rule's alert in its current state
+			errs = append(errs, fmt.Errorf("rule %d: %w", rule.ID, err))
+			continue
+		}
+
+		if !rule.Operator.Compare(value, rule.Threshold) {
+			if state.firing {
+				m.log.Info("alert rule resolved", zap.Int64("id", rule.ID), zap.String("name", rule.Name), zap.Float64("value", value))
+			}
+			m.dismiss(state)
+			continue
+		}
+
+		if state.pendingSince.IsZero() {
+			state.pendingSince = now
+		}
+		if state.firing || now.Sub(state.pendingSince) < rule.For {
+			continue
+		}
+
+		m.log.Info("alert rule fired", zap.Int64("id", rule.ID), zap.String("name", rule.Name), zap.Float64("value", value))
+		m.alerts.Register(alerts.Alert{
+			ID:       rule.AlertID(),
+			Severity: rule.Severity,
+			Message:  rule.Name,
+			Data: map[string]any{
+				"ruleID":    rule.ID,
+				"metric":    rule.Metric,
+				"operator":  rule.Operator,
+				"threshold": rule.Threshold,
+				"value":     value,
+			},
+			Timestamp: now,
+		})
+		state.firing = true
+	}
+	return errors.Join(errs...)
+}
+
+func (m *Manager) run() {
+	done, err := m.tg.Add()
+	if err != nil {
+		return
+	}
+	defer done()
+
+	t := time.NewTicker(evaluateInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-m.tg.Done():
+			return
+		case <-m.trigger:
+		case <-t.C:
+		}
+
+		if err := m.Evaluate(time.Now()); err != nil {
+			m.log.Error("failed to evaluate alert rules", zap.Error(err))
+		}
+	}
+}
+
+// triggerEvaluation evaluates the rules in the background.
+func (m *Manager) triggerEvaluation() {
+	select {
+	case m.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Close stops evaluating rules.
+func (m *Manager) Close() error {
+	m.tg.Stop()
+	return nil
+}
+
+// Rules returns all alert rules ordered by ID.
+func (m *Manager) Rules() []Rule {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rules := make([]Rule, 0, len(m.rules))
+	for _, state := range m.rules {
+		rules = append(rules, state.rule)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+	return rules
+}
+
+// Rule returns the alert rule with the given ID.
+func (m *Manager) Rule(id int64) (Rule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, ok := m.rules[id]
+	if !ok {
+		return Rule{}, ErrRuleNotFound
+	}
+	return state.rule, nil
+}
+
+// AddRule adds an alert rule and returns it with its assigned ID. The rule
+// is evaluated immediately.
+func (m *Manager) AddRule(r Rule) (Rule, error) {
+	if err := r.Validate(); err != nil {
+		return Rule{}, fmt.Errorf("invalid alert rule: %w", err)
+	}
+
+	m.mu.Lock()
+	id, err := m.store.AddAlertRule(r)
+	if err != nil {
+		m.mu.Unlock()
+		return Rule{}, fmt.Errorf("failed to add alert rule: %w", err)
+	}
+	r.ID = id
+	m.rules[id] = &ruleState{rule: r}
+	m.mu.Unlock()
+
+	m.triggerEvaluation()
+	return r, nil
+}
+
+// UpdateRule replaces an alert rule. The rule's alert is dismissed and the
+// rule is evaluated again from scratch.
+func (m *Manager) UpdateRule(r Rule) error {
+	if err := r.Validate(); err != nil {
+		return fmt.Errorf("invalid alert rule: %w", err)
+	}
+
+	m.mu.Lock()
+	state, ok := m.rules[r.ID]
+	if !ok {
+		m.mu.Unlock()
+		return ErrRuleNotFound
+	} else if err := m.store.UpdateAlertRule(r); err != nil {
+		m.mu.Unlock()
+		return fmt.Errorf("failed to update alert rule: %w", err)
+	}
+	m.dismiss(state)
+	state.rule = r
+	m.mu.Unlock()
+
+	m.triggerEvaluation()
+	return nil
+}
+
+// DeleteRule removes an alert rule and dismisses its alert.
+func (m *Manager) DeleteRule(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, ok := m.rules[id]
+	if !ok {
+		return ErrRuleNotFound
+	} else if err := m.store.DeleteAlertRule(id); err != nil {
+		return fmt.Errorf("failed to delete alert rule: %w", err)
+	}
+	m.dismiss(state)
+	delete(m.rules, id)
+	return nil
+}
+
+// NewManager initializes a new alert rule manager. Rules are evaluated
+// periodically in the background.
+func NewManager(store Store, a Alerts, mm Metrics, vm VolumeManager, w Wallet, cm ChainManager, contracts ContractManager, sessions SessionReporter, log *zap.Logger) (*Manager, error) {
+	rules, err := store.AlertRules()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load alert rules: %w", err)
+	}
+
+	// alerts registered before the host was restarted are still active
+	active := make(map[types.Hash256]bool)
+	for _, alert := range a.Active() {
+		active[alert.ID] = true
+	}
+
+	m := &Manager{
+		store:     store,
+		alerts:    a,
+		metrics:   mm,
+		volumes:   vm,
+		wallet:    w,
+		chain:     cm,
+		contracts: contracts,
+		sessions:  sessions,
+		log:       log,
+		tg:        threadgroup.New(),
+
+		trigger: make(chan struct{}, 1),
+
+		rules: make(map[int64]*ruleState),
+	}
+	for _, r := range rules {
+		m.rules[r.ID] = &ruleState{
+			rule:   r,
+			firing: active[r.AlertID()],
+		}
+	}
+	go m.run()
+	return m, nil
+}
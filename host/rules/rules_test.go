@@ -0,0 +1,267 @@
+package rules_test
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"go.sia.tech/core/consensus"
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/host/alerts"
+	"go.sia.tech/hostd/host/contracts"
+	"go.sia.tech/hostd/host/metrics"
+	"go.sia.tech/hostd/host/rules"
+	"go.sia.tech/hostd/persist/sqlite"
+	"go.sia.tech/hostd/rhp"
+	"go.uber.org/zap/zaptest"
+)
+
+// host reports fixed values for each of the rule manager's dependencies
+type host struct {
+	mu               sync.Mutex
+	spendable        types.Currency
+	used, total      uint64
+	synced           bool
+	height           uint64
+	lastFormedHeight uint64
+	sessions         int
+	registryEntries  uint64
+}
+
+func (h *host) Metrics(time.Time) (metrics.Metrics, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return metrics.Metrics{
+		Registry: metrics.Registry{Entries: h.registryEntries, MaxEntries: 100},
+	}, nil
+}
+
+func (h *host) Usage() (uint64, uint64, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.used, h.total, nil
+}
+
+func (h *host) Balance() (types.Currency, types.Currency, types.Currency, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.spendable, h.spendable, types.ZeroCurrency, nil
+}
+
+func (h *host) Synced() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.synced
+}
+
+func (h *host) TipState() consensus.State {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return consensus.State{Index: types.ChainIndex{Height: h.height}}
+}
+
+func (h *host) Contracts(contracts.ContractFilter) ([]contracts.Contract, int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.lastFormedHeight == 0 {
+		return nil, 0, nil
+	}
+	return []contracts.Contract{{NegotiationHeight: h.lastFormedHeight}}, 1, nil
+}
+
+func (h *host) Active() []rhp.Session {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return make([]rhp.Session, h.sessions)
+}
+
+func (h *host) update(fn func(h *host)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fn(h)
+}
+
+func isActive(am *alerts.Manager, id types.Hash256) bool {
+	for _, a := range am.Active() {
+		if a.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRuleEvaluation(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	db, err := sqlite.OpenDatabase(filepath.Join(t.TempDir(), "hostd.db"), log.Named("sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	am, err := alerts.NewManager(db, log.Named("alerts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := &host{
+		spendable:        types.Siacoins(1000),
+		used:             50,
+		total:            100,
+		synced:           true,
+		height:           1000,
+		lastFormedHeight: 990,
+	}
+	rm, err := rules.NewManager(db, am, h, h, h, h, h, h, log.Named("rules"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rm.Close()
+
+	lowBalance, err := rm.AddRule(rules.Rule{
+		Name:      "spendable balance below 500 SC",
+		Metric:    rules.MetricWalletSpendable,
+		Operator:  rules.OperatorLess,
+		Threshold: 500,
+		Severity:  alerts.SeverityWarning,
+		Enabled:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	storageFull, err := rm.AddRule(rules.Rule{
+		Name:      "storage more than 90% full",
+		Metric:    rules.MetricStorageUsedPercent,
+		Operator:  rules.OperatorGreater,
+		Threshold: 90,
+		Severity:  alerts.SeverityError,
+		Enabled:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	noContracts, err := rm.AddRule(rules.Rule{
+		Name:      "no new contracts in 7 days",
+		Metric:    rules.MetricContractsSinceLastFormed,
+		Operator:  rules.OperatorGreater,
+		Threshold: (7 * 24 * time.Hour).Seconds(),
+		Severity:  alerts.SeverityWarning,
+		Enabled:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsynced, err := rm.AddRule(rules.Rule{
+		Name:      "consensus not synced for 30 minutes",
+		Metric:    rules.MetricConsensusSynced,
+		Operator:  rules.OperatorEqual,
+		Threshold: 0,
+		For:       30 * time.Minute,
+		Severity:  alerts.SeverityCritical,
+		Enabled:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	if err := rm.Evaluate(start); err != nil {
+		t.Fatal(err)
+	} else if active := am.Active(); len(active) != 0 {
+		t.Fatalf("expected no alerts, got %v", active)
+	}
+
+	h.update(func(h *host) {
+		h.spendable = types.Siacoins(100)
+		h.used = 95
+		h.height = h.lastFormedHeight + 7*144 + 1
+		h.synced = false
+	})
+	if err := rm.Evaluate(start.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range []rules.Rule{lowBalance, storageFull, noContracts} {
+		if !isActive(am, r.AlertID()) {
+			t.Fatalf("expected rule %q to fire", r.Name)
+		}
+	}
+	// the sync rule should wait for its duration
+	if isActive(am, unsynced.AlertID()) {
+		t.Fatal("expected sync rule to be pending")
+	} else if err := rm.Evaluate(start.Add(31 * time.Minute)); err != nil {
+		t.Fatal(err)
+	} else if !isActive(am, unsynced.AlertID()) {
+		t.Fatal("expected sync rule to fire")
+	}
+
+	// alerts should be dismissed when the condition resolves
+	h.update(func(h *host) {
+		h.spendable = types.Siacoins(1000)
+		h.synced = true
+	})
+	if err := rm.Evaluate(start.Add(32 * time.Minute)); err != nil {
+		t.Fatal(err)
+	} else if isActive(am, lowBalance.AlertID()) || isActive(am, unsynced.AlertID()) {
+		t.Fatal("expected resolved alerts to be dismissed")
+	} else if len(am.Active()) != 2 {
+		t.Fatalf("expected 2 active alerts, got %v", len(am.Active()))
+	}
+
+	// disabling a rule should dismiss its alert
+	storageFull.Enabled = false
+	if err := rm.UpdateRule(storageFull); err != nil {
+		t.Fatal(err)
+	} else if isActive(am, storageFull.AlertID()) {
+		t.Fatal("expected disabled rule's alert to be dismissed")
+	}
+
+	// deleting a rule should dismiss its alert
+	if err := rm.DeleteRule(noContracts.ID); err != nil {
+		t.Fatal(err)
+	} else if isActive(am, noContracts.AlertID()) {
+		t.Fatal("expected deleted rule's alert to be dismissed")
+	} else if err := rm.DeleteRule(noContracts.ID); err != rules.ErrRuleNotFound {
+		t.Fatalf("expected ErrRuleNotFound, got %v", err)
+	}
+
+	// rules should be persisted
+	rm.Close()
+	rm, err = rules.NewManager(db, am, h, h, h, h, h, h, log.Named("rules"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rm.Close()
+	if stored := rm.Rules(); len(stored) != 3 {
+		t.Fatalf("expected 3 rules, got %v", len(stored))
+	} else if stored[0] != lowBalance || stored[1] != storageFull || stored[2] != unsynced {
+		t.Fatalf("unexpected rules %+v", stored)
+	}
+}
+
+func TestRuleValidate(t *testing.T) {
+	valid := rules.Rule{
+		Name:      "registry almost full",
+		Metric:    rules.MetricRegistryUsedPercent,
+		Operator:  rules.OperatorGreaterEqual,
+		Threshold: 80,
+		Severity:  alerts.SeverityWarning,
+	}
+	if err := valid.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]func(r *rules.Rule){
+		"empty name":       func(r *rules.Rule) { r.Name = " " },
+		"unknown metric":   func(r *rules.Rule) { r.Metric = "disk.temperature" },
+		"unknown op":       func(r *rules.Rule) { r.Operator = "=~" },
+		"negative for":     func(r *rules.Rule) { r.For = -time.Second },
+		"missing severity": func(r *rules.Rule) { r.Severity = 0 },
+	}
+	for name, fn := range tests {
+		r := valid
+		fn(&r)
+		if err := r.Validate(); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
@@ -60,6 +60,9 @@ type (
 
 		// Registry settings
 		MaxRegistryEntries uint64 `json:"maxRegistryEntries"`
+		// MaxRegistryEntriesPerKey is the maximum number of registry entries
+		// a single public key can store. Zero means no limit.
+		MaxRegistryEntriesPerKey uint64 `json:"maxRegistryEntriesPerKey"`
 
 		// RHP3 settings
 		AccountExpiry     time.Duration  `json:"accountExpiry"`
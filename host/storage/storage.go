@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -26,6 +27,10 @@ const (
 	// MaxTempSectorBlocks is the maximum number of blocks that a temp sector
 	// can be stored for.
 	MaxTempSectorBlocks = 144 * 7 // 7 days
+
+	// SubtreeLeaves is the number of leaves covered by each of a sector's
+	// cached subtree roots.
+	SubtreeLeaves = 64 // 4 KiB
 )
 
 // VolumeStatus is the status of a volume.
@@ -85,6 +90,10 @@ type (
 		// changedVolumes tracks volumes that need to be fsynced
 		changedVolumes map[int64]bool
 		cache          *lru.Cache[types.Hash256, *[rhp2.SectorSize]byte] // Added cache
+		// subtrees caches the subtree roots of recently proven sectors. It
+		// is disabled if subtreeCacheSize is 0.
+		subtrees         *lru.Cache[types.Hash256, []types.Hash256]
+		subtreeCacheSize uint32
 	}
 )
 
@@ -822,8 +831,9 @@ func (vm *VolumeManager) RemoveSector(root types.Hash256) error {
 		return fmt.Errorf("failed to sync volume %v: %w", loc.Volume, err)
 	}
 
-	// eject the sector from the cache
+	// eject the sector from the caches
 	vm.cache.Remove(root)
+	vm.subtrees.Remove(root)
 	return nil
 }
 
@@ -887,6 +897,74 @@ func (vm *VolumeManager) Read(root types.Hash256) (*[rhp2.SectorSize]byte, error
 	return sector, nil
 }
 
+// ReadPartial reads length bytes starting at offset from the sector with the
+// given root. Unlike Read, only the requested range is read from disk.
+func (vm *VolumeManager) ReadPartial(root types.Hash256, offset, length uint64) ([]byte, error) {
+	if length == 0 || offset+length > rhp2.SectorSize {
+		return nil, fmt.Errorf("read range [%d, %d) is out of bounds", offset, offset+length)
+	}
+
+	done, err := vm.tg.Add()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	// if the full sector is cached, there's no need to read from disk
+	if sector, ok := vm.cache.Get(root); ok {
+		vm.recorder.AddCacheHit()
+		atomic.AddUint64(&vm.cacheHits, 1)
+		return sector[offset : offset+length], nil
+	}
+
+	loc, release, err := vm.vs.SectorLocation(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate sector %v: %w", root, err)
+	}
+	defer release()
+
+	v, err := vm.getVolume(loc.Volume)
+	if err != nil {
+		return nil, err
+	}
+	data, err := v.ReadSectorRange(loc.Index, offset, length)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sector %v: %w", root, err)
+	}
+	vm.recorder.AddCacheMiss()
+	atomic.AddUint64(&vm.cacheMisses, 1)
+	vm.recorder.AddRead()
+	return data, nil
+}
+
+// SubtreeRoots returns the Merkle roots of each SubtreeLeaves-leaf subtree of
+// the sector with the given root. The roots are cached so that proofs for
+// small ranges of the sector can be built without reading the full sector. If
+// the subtree cache is disabled, nil is returned.
+func (vm *VolumeManager) SubtreeRoots(root types.Hash256) ([]types.Hash256, error) {
+	if atomic.LoadUint32(&vm.subtreeCacheSize) == 0 {
+		return nil, nil
+	} else if roots, ok := vm.subtrees.Get(root); ok {
+		return roots, nil
+	}
+
+	sector, err := vm.Read(root)
+	if err != nil {
+		return nil, err
+	}
+
+	const subtreeSize = SubtreeLeaves * rhp2.LeafSize
+	roots := make([]types.Hash256, rhp2.SectorSize/subtreeSize)
+	for i := range roots {
+		roots[i], err = rhp2.ReaderRoot(bytes.NewReader(sector[i*subtreeSize : (i+1)*subtreeSize]))
+		if err != nil {
+			panic(err) // should never happen
+		}
+	}
+	vm.subtrees.Add(root, roots)
+	return roots, nil
+}
+
 // Sync syncs the data files of changed volumes.
 func (vm *VolumeManager) Sync() error {
 	done, err := vm.tg.Add()
@@ -964,6 +1042,14 @@ func (vm *VolumeManager) ResizeCache(size uint32) {
 	vm.cache.Resize(int(size))
 }
 
+// ResizeSubtreeCache resizes the subtree cache to hold the subtree roots of
+// size sectors. Each sector's subtree roots use 32 KiB of memory. A size of 0
+// disables the subtree cache.
+func (vm *VolumeManager) ResizeSubtreeCache(size uint32) {
+	vm.subtrees.Resize(int(size))
+	atomic.StoreUint32(&vm.subtreeCacheSize, size)
+}
+
 // ProcessConsensusChange is called when the consensus set changes.
 func (vm *VolumeManager) ProcessConsensusChange(cc modules.ConsensusChange) {
 	vm.mu.Lock()
@@ -993,6 +1079,13 @@ func NewVolumeManager(vs VolumeStore, a Alerts, cm ChainManager, log *zap.Logger
 	// cache to 0
 	cache.Resize(int(sectorCacheSize))
 
+	// the subtree cache is disabled until it is resized
+	subtrees, err := lru.New[types.Hash256, []types.Hash256](64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize subtree cache: %w", err)
+	}
+	subtrees.Resize(0)
+
 	vm := &VolumeManager{
 		vs:  vs,
 		a:   a,
@@ -1006,6 +1099,7 @@ func NewVolumeManager(vs VolumeStore, a Alerts, cm ChainManager, log *zap.Logger
 		volumes:        make(map[int64]*volume),
 		changedVolumes: make(map[int64]bool),
 		cache:          cache,
+		subtrees:       subtrees,
 		tg:             threadgroup.New(),
 	}
 	if err := vm.loadVolumes(); err != nil {
@@ -65,7 +65,10 @@ func TestVolumeLoad(t *testing.T) {
 	defer cm.Close()
 	defer cm.Close()
 
-	am := alerts.NewManager()
+	am, err := alerts.NewManager(db, log.Named("alerts"))
+	if err != nil {
+		t.Fatal(err)
+	}
 	vm, err := storage.NewVolumeManager(db, am, cm, log.Named("volumes"), sectorCacheSize)
 	if err != nil {
 		t.Fatal(err)
@@ -170,7 +173,10 @@ func TestAddVolume(t *testing.T) {
 	defer cm.Close()
 	defer cm.Close()
 
-	am := alerts.NewManager()
+	am, err := alerts.NewManager(db, log.Named("alerts"))
+	if err != nil {
+		t.Fatal(err)
+	}
 	vm, err := storage.NewVolumeManager(db, am, cm, log.Named("volumes"), sectorCacheSize)
 	if err != nil {
 		t.Fatal(err)
@@ -239,7 +245,10 @@ func TestRemoveVolume(t *testing.T) {
 	defer cm.Close()
 
 	// initialize the storage manager
-	am := alerts.NewManager()
+	am, err := alerts.NewManager(db, log.Named("alerts"))
+	if err != nil {
+		t.Fatal(err)
+	}
 	vm, err := storage.NewVolumeManager(db, am, cm, log.Named("volumes"), sectorCacheSize)
 	if err != nil {
 		t.Fatal(err)
@@ -327,7 +336,10 @@ func TestRemoveCorrupt(t *testing.T) {
 	defer cm.Close()
 
 	// initialize the storage manager
-	am := alerts.NewManager()
+	am, err := alerts.NewManager(db, log.Named("alerts"))
+	if err != nil {
+		t.Fatal(err)
+	}
 	vm, err := storage.NewVolumeManager(db, am, cm, log.Named("volumes"), 0)
 	if err != nil {
 		t.Fatal(err)
@@ -441,7 +453,10 @@ func TestRemoveMissing(t *testing.T) {
 	defer cm.Close()
 
 	// initialize the storage manager
-	am := alerts.NewManager()
+	am, err := alerts.NewManager(db, log.Named("alerts"))
+	if err != nil {
+		t.Fatal(err)
+	}
 	vm, err := storage.NewVolumeManager(db, am, cm, log.Named("volumes"), sectorCacheSize)
 	if err != nil {
 		t.Fatal(err)
@@ -560,7 +575,10 @@ func TestVolumeDistribution(t *testing.T) {
 	defer cm.Close()
 
 	// initialize the storage manager
-	am := alerts.NewManager()
+	am, err := alerts.NewManager(db, log.Named("alerts"))
+	if err != nil {
+		t.Fatal(err)
+	}
 	vm, err := storage.NewVolumeManager(db, am, cm, log.Named("volumes"), sectorCacheSize)
 	if err != nil {
 		t.Fatal(err)
@@ -695,7 +713,10 @@ func TestVolumeGrow(t *testing.T) {
 	defer cm.Close()
 
 	// initialize the storage manager
-	am := alerts.NewManager()
+	am, err := alerts.NewManager(db, log.Named("alerts"))
+	if err != nil {
+		t.Fatal(err)
+	}
 	vm, err := storage.NewVolumeManager(db, am, cm, log.Named("volumes"), sectorCacheSize)
 	if err != nil {
 		t.Fatal(err)
@@ -816,7 +837,10 @@ func TestVolumeShrink(t *testing.T) {
 	defer cm.Close()
 
 	// initialize the storage manager
-	am := alerts.NewManager()
+	am, err := alerts.NewManager(db, log.Named("alerts"))
+	if err != nil {
+		t.Fatal(err)
+	}
 	vm, err := storage.NewVolumeManager(db, am, cm, log.Named("volumes"), sectorCacheSize)
 	if err != nil {
 		t.Fatal(err)
@@ -987,7 +1011,10 @@ func TestVolumeManagerReadWrite(t *testing.T) {
 	defer cm.Close()
 
 	// initialize the storage manager
-	am := alerts.NewManager()
+	am, err := alerts.NewManager(db, log.Named("alerts"))
+	if err != nil {
+		t.Fatal(err)
+	}
 	vm, err := storage.NewVolumeManager(db, am, cm, log.Named("volumes"), sectorCacheSize)
 	if err != nil {
 		t.Fatal(err)
@@ -1092,7 +1119,10 @@ func TestSectorCache(t *testing.T) {
 	defer cm.Close()
 
 	// initialize the storage manager
-	am := alerts.NewManager()
+	am, err := alerts.NewManager(db, log.Named("alerts"))
+	if err != nil {
+		t.Fatal(err)
+	}
 	vm, err := storage.NewVolumeManager(db, am, cm, log.Named("volumes"), sectors/2) // cache half the sectors
 	if err != nil {
 		t.Fatal(err)
@@ -1229,7 +1259,10 @@ func BenchmarkVolumeManagerWrite(b *testing.B) {
 	defer cm.Close()
 
 	// initialize the storage manager
-	am := alerts.NewManager()
+	am, err := alerts.NewManager(db, log.Named("alerts"))
+	if err != nil {
+		b.Fatal(err)
+	}
 	vm, err := storage.NewVolumeManager(db, am, cm, log.Named("volumes"), sectorCacheSize)
 	if err != nil {
 		b.Fatal(err)
@@ -1299,7 +1332,10 @@ func BenchmarkNewVolume(b *testing.B) {
 	defer cm.Close()
 
 	// initialize the storage manager
-	am := alerts.NewManager()
+	am, err := alerts.NewManager(db, log.Named("alerts"))
+	if err != nil {
+		b.Fatal(err)
+	}
 	vm, err := storage.NewVolumeManager(db, am, cm, log.Named("volumes"), sectorCacheSize)
 	if err != nil {
 		b.Fatal(err)
@@ -1352,7 +1388,10 @@ func BenchmarkVolumeManagerRead(b *testing.B) {
 	defer cm.Close()
 
 	// initialize the storage manager
-	am := alerts.NewManager()
+	am, err := alerts.NewManager(db, log.Named("alerts"))
+	if err != nil {
+		b.Fatal(err)
+	}
 	vm, err := storage.NewVolumeManager(db, am, cm, log.Named("volumes"), sectorCacheSize)
 	if err != nil {
 		b.Fatal(err)
@@ -1424,7 +1463,10 @@ func BenchmarkVolumeRemove(b *testing.B) {
 	defer cm.Close()
 
 	// initialize the storage manager
-	am := alerts.NewManager()
+	am, err := alerts.NewManager(db, log.Named("alerts"))
+	if err != nil {
+		b.Fatal(err)
+	}
 	vm, err := storage.NewVolumeManager(db, am, cm, log.Named("volumes"), sectorCacheSize)
 	if err != nil {
 		b.Fatal(err)
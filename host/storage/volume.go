@@ -107,6 +107,27 @@ func (v *volume) ReadSector(index uint64) (*[rhp2.SectorSize]byte, error) {
 	return &sector, err
 }
 
+// ReadSectorRange reads length bytes starting at offset from the sector at
+// index.
+func (v *volume) ReadSectorRange(index, offset, length uint64) ([]byte, error) {
+	if v.data == nil {
+		return nil, ErrVolumeNotAvailable
+	} else if offset+length > rhp2.SectorSize {
+		panic("read out of bounds") // developer error
+	}
+	buf := make([]byte, length)
+	_, err := v.data.ReadAt(buf, int64(index*rhp2.SectorSize+offset))
+	v.mu.Lock()
+	if err != nil {
+		v.stats.FailedReads++
+		v.appendError(fmt.Errorf("failed to read sector range at index %v: %w", index, err))
+	} else {
+		v.stats.SuccessfulReads++
+	}
+	v.mu.Unlock()
+	return buf, err
+}
+
 // WriteSector writes a sector to the volume at index
 func (v *volume) WriteSector(data *[rhp2.SectorSize]byte, index uint64) error {
 	if v.data == nil {
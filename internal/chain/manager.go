@@ -12,11 +12,18 @@ import (
 	"go.sia.tech/core/consensus"
 	"go.sia.tech/core/types"
 	"go.sia.tech/hostd/build"
+	"go.sia.tech/siad/crypto"
 	"go.sia.tech/siad/modules"
 	stypes "go.sia.tech/siad/types"
 )
 
-const maxSyncTime = time.Hour
+const (
+	maxSyncTime = time.Hour
+
+	// maxChangeSearch is the number of blocks SubscribeAtHeight searches
+	// below the requested height for a change to resume from.
+	maxChangeSearch = 144
+)
 
 var (
 	// ErrBlockNotFound is returned when a block is not found.
@@ -46,6 +53,13 @@ func convertToCore(siad encoding.SiaMarshaler, core types.DecoderFrom) {
 	}
 }
 
+// changeEntry mirrors an entry in siad's consensus change log. The hash of
+// the entry is the ID of the consensus change.
+type changeEntry struct {
+	RevertedBlocks []stypes.BlockID
+	AppliedBlocks  []stypes.BlockID
+}
+
 // A Manager manages the current state of the blockchain.
 type Manager struct {
 	cs      modules.ConsensusSet
@@ -146,6 +160,32 @@ func (m *Manager) Subscribe(s modules.ConsensusSetSubscriber, ccID modules.Conse
 	return nil
 }
 
+// SubscribeAtHeight subscribes s to consensus changes beginning with the
+// block at the given height. The change log is indexed by change ID, so the
+// subscription resumes after the change that applied only the previous block.
+// If that change does not exist, for example because the block was applied
+// during a reorg, earlier blocks are tried. The subscriber may receive blocks
+// below height.
+func (m *Manager) SubscribeAtHeight(s modules.ConsensusSetSubscriber, height uint64, cancel <-chan struct{}) error {
+	for h := height; h > 0 && height-h < maxChangeSearch; h-- {
+		block, ok := m.cs.BlockAtHeight(stypes.BlockHeight(h - 1))
+		if !ok {
+			return fmt.Errorf("failed to get block at height %d: %w", h-1, ErrBlockNotFound)
+		}
+		ce := changeEntry{AppliedBlocks: []stypes.BlockID{block.ID()}}
+		err := m.Subscribe(s, modules.ConsensusChangeID(crypto.HashObject(ce)), cancel)
+		if !errors.Is(err, ErrInvalidChangeID) {
+			return err
+		}
+	}
+	return m.Subscribe(s, modules.ConsensusChangeBeginning, cancel)
+}
+
+// Unsubscribe removes a subscriber from the consensus set.
+func (m *Manager) Unsubscribe(s modules.ConsensusSetSubscriber) {
+	m.cs.Unsubscribe(s)
+}
+
 func synced(timestamp stypes.Timestamp) bool {
 	return time.Since(time.Unix(int64(timestamp), 0)) <= maxSyncTime
 }
@@ -82,6 +82,8 @@ func (h *Host) Close() error {
 	h.rhp3.Close()
 	h.settings.Close()
 	h.wallet.Close()
+	h.accounts.Close()
+	h.registry.Close()
 	h.contracts.Close()
 	h.storage.Close()
 	h.store.Close()
@@ -154,6 +156,11 @@ func (h *Host) Accounts() *accounts.AccountManager {
 	return h.accounts
 }
 
+// Registry returns the host's registry manager
+func (h *Host) Registry() *registry.Manager {
+	return h.registry
+}
+
 // Store returns the host's database
 func (h *Host) Store() *sqlite.Store {
 	return h.store
@@ -171,11 +178,15 @@ func NewHost(privKey types.PrivateKey, dir string, node *Node, log *zap.Logger)
 		return nil, fmt.Errorf("failed to create wallet: %w", err)
 	}
 
-	am := alerts.NewManager()
+	am, err := alerts.NewManager(db, log.Named("alerts"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create alerts manager: %w", err)
+	}
 	storage, err := storage.NewVolumeManager(db, am, node.cm, log.Named("storage"), DefaultSettings.SectorCacheSize)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create storage manager: %w", err)
 	}
+	storage.ResizeSubtreeCache(64)
 	result := make(chan error, 1)
 	if _, err := storage.AddVolume(context.Background(), filepath.Join(dir, "storage.dat"), 64, result); err != nil {
 		return nil, fmt.Errorf("failed to add storage volume: %w", err)
@@ -208,18 +219,26 @@ func NewHost(privKey types.PrivateKey, dir string, node *Node, log *zap.Logger)
 		return nil, fmt.Errorf("failed to update host settings: %w", err)
 	}
 
-	registry := registry.NewManager(privKey, db, log.Named("registry"))
-	accounts := accounts.NewManager(db, settings)
+	registry, err := registry.NewManager(privKey, node.cm, db, log.Named("registry"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create registry manager: %w", err)
+	}
+	accounts, err := accounts.NewManager(db, settings, log.Named("accounts"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create account manager: %w", err)
+	}
 
 	sessions := rhp.NewSessionReporter()
+	bans := rhp.NewBanManager(log.Named("bans"))
+	drain := rhp.NewDrainer(log.Named("drain"))
 
-	rhp2, err := rhp2.NewSessionHandler(rhp2Listener, privKey, rhp3Listener.Addr().String(), node.cm, node.tp, wallet, contracts, settings, storage, stubDataMonitor{}, sessions, log.Named("rhp2"))
+	rhp2, err := rhp2.NewSessionHandler(rhp2Listener, privKey, rhp3Listener.Addr().String(), node.cm, node.tp, wallet, contracts, settings, storage, stubDataMonitor{}, sessions, bans, drain, log.Named("rhp2"))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create rhp2 session handler: %w", err)
 	}
 	go rhp2.Serve()
 
-	rhp3, err := rhp3.NewSessionHandler(rhp3Listener, privKey, node.cm, node.tp, wallet, accounts, contracts, registry, storage, settings, stubDataMonitor{}, sessions, log.Named("rhp3"))
+	rhp3, err := rhp3.NewSessionHandler(rhp3Listener, privKey, node.cm, node.tp, wallet, accounts, contracts, registry, storage, settings, stubDataMonitor{}, sessions, bans, drain, log.Named("rhp3"))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create rhp3 session handler: %w", err)
 	}
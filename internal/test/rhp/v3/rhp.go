@@ -15,6 +15,7 @@ import (
 	rhp2 "go.sia.tech/core/rhp/v2"
 	rhp3 "go.sia.tech/core/rhp/v3"
 	"go.sia.tech/core/types"
+	hrhp3 "go.sia.tech/hostd/rhp/v3"
 )
 
 type (
@@ -524,6 +525,88 @@ func (s *Session) RenewContract(revision *rhp2.ContractRevision, hostAddr types.
 	}, append(renewalParents, renewTxn), nil
 }
 
+// A RegistrySubscription receives updates to registry entries from the host.
+type RegistrySubscription struct {
+	stream  *rhp3.Stream
+	updates []rhp3.RegistryEntry
+}
+
+// SubscribeToRegistry opens a registry subscription prepaid with budget.
+func (s *Session) SubscribeToRegistry(payment PaymentMethod, budget types.Currency) (*RegistrySubscription, error) {
+	stream := s.t.DialStream()
+	if err := stream.WriteRequest(hrhp3.RPCRegistrySubscribeID, &s.pt.UID); err != nil {
+		stream.Close()
+		return nil, fmt.Errorf("failed to write request: %w", err)
+	} else if err := s.processPayment(stream, payment, budget); err != nil {
+		stream.Close()
+		return nil, fmt.Errorf("failed to pay: %w", err)
+	}
+	return &RegistrySubscription{stream: stream}, nil
+}
+
+// call sends a subscription request and waits for the response of the
+// expected type. Updates received in the meantime are buffered.
+func (rs *RegistrySubscription) call(req *hrhp3.RPCRegistrySubscriptionRequest, expected types.Specifier) (hrhp3.RPCRegistrySubscriptionResponse, error) {
+	if err := rs.stream.WriteResponse(req); err != nil {
+		return hrhp3.RPCRegistrySubscriptionResponse{}, fmt.Errorf("failed to write request: %w", err)
+	}
+	for {
+		var resp hrhp3.RPCRegistrySubscriptionResponse
+		if err := rs.stream.ReadResponse(&resp, 1<<20); err != nil {
+			return hrhp3.RPCRegistrySubscriptionResponse{}, fmt.Errorf("failed to read response: %w", err)
+		} else if resp.Type == expected {
+			return resp, nil
+		} else if resp.Type != hrhp3.SubscriptionResponseUpdate {
+			return hrhp3.RPCRegistrySubscriptionResponse{}, fmt.Errorf("unexpected response type %q", resp.Type)
+		}
+		rs.updates = append(rs.updates, resp.Entries...)
+	}
+}
+
+// Subscribe adds keys to the subscription and returns the current value of
+// each key that exists.
+func (rs *RegistrySubscription) Subscribe(keys ...rhp3.RegistryKey) ([]rhp3.RegistryEntry, error) {
+	resp, err := rs.call(&hrhp3.RPCRegistrySubscriptionRequest{
+		Type: hrhp3.SubscriptionRequestSubscribe,
+		Keys: keys,
+	}, hrhp3.SubscriptionResponseValues)
+	return resp.Entries, err
+}
+
+// Unsubscribe removes keys from the subscription.
+func (rs *RegistrySubscription) Unsubscribe(keys ...rhp3.RegistryKey) error {
+	_, err := rs.call(&hrhp3.RPCRegistrySubscriptionRequest{
+		Type: hrhp3.SubscriptionRequestUnsubscribe,
+		Keys: keys,
+	}, hrhp3.SubscriptionResponseUnsubscribed)
+	return err
+}
+
+// Next blocks until the host sends updated entries.
+func (rs *RegistrySubscription) Next() ([]rhp3.RegistryEntry, error) {
+	if len(rs.updates) > 0 {
+		updates := rs.updates
+		rs.updates = nil
+		return updates, nil
+	}
+	var resp hrhp3.RPCRegistrySubscriptionResponse
+	if err := rs.stream.ReadResponse(&resp, 1<<20); err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	} else if resp.Type != hrhp3.SubscriptionResponseUpdate {
+		return nil, fmt.Errorf("unexpected response type %q", resp.Type)
+	}
+	return resp.Entries, nil
+}
+
+// Stop ends the subscription and returns the unspent budget.
+func (rs *RegistrySubscription) Stop() (types.Currency, error) {
+	defer rs.stream.Close()
+	resp, err := rs.call(&hrhp3.RPCRegistrySubscriptionRequest{
+		Type: hrhp3.SubscriptionRequestStop,
+	}, hrhp3.SubscriptionResponseStopped)
+	return resp.Remaining, err
+}
+
 // Close closes the underlying transport
 func (s *Session) Close() error {
 	return s.t.Close()
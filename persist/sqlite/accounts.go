@@ -2,8 +2,11 @@ package sqlite
 
 import (
 	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	rhp3 "go.sia.tech/core/rhp/v3"
@@ -13,6 +16,8 @@ import (
 	"go.uber.org/zap"
 )
 
+var errInsufficientBalance = errors.New("insufficient balance")
+
 // AccountBalance returns the balance of the account with the given ID.
 func (s *Store) AccountBalance(accountID rhp3.Account) (balance types.Currency, err error) {
 	_, balance, err = accountBalance(&dbTxn{s}, accountID)
@@ -58,6 +63,23 @@ func (s *Store) CreditAccountWithContract(fund accounts.FundAccountWithContract)
 			return fmt.Errorf("failed to increment balance metric: %w", err)
 		}
 
+		// record the deposit in the account's ledger
+		entryType := accounts.LedgerEntryDeposit
+		if fund.Refund {
+			entryType = accounts.LedgerEntryRefund
+		}
+		entry := accounts.LedgerEntry{
+			Account:      fund.Account,
+			Type:         entryType,
+			Amount:       fund.Amount,
+			Balance:      balance,
+			ContractID:   fund.Revision.Revision.ParentID,
+			LedgerSource: fund.Source,
+		}
+		if err := insertLedgerEntry(tx, entry); err != nil {
+			return fmt.Errorf("failed to record ledger entry: %w", err)
+		}
+
 		// update the number of active accounts
 		if !exists {
 			if err := incrementNumericStat(tx, metricActiveAccounts, 1, time.Now()); err != nil {
@@ -91,56 +113,287 @@ func (s *Store) CreditAccountWithContract(fund accounts.FundAccountWithContract)
 	return
 }
 
-// DebitAccount subtracts the specified amount from the account with the given
-// ID. Returns the remaining balance of the account.
-func (s *Store) DebitAccount(accountID rhp3.Account, usage accounts.Usage) (balance types.Currency, err error) {
-	amount := usage.Total()
-	err = s.transaction(func(tx txn) error {
-		dbID, balance, err := accountBalance(tx, accountID)
+// AppendAccountDebits records debits in the journal in a single transaction.
+// The debits are applied to the account balances by FlushAccountDebits.
+func (s *Store) AppendAccountDebits(debits []accounts.Debit) error {
+	const query = `INSERT INTO account_debit_journal (account_id, rpc, program, rpc_revenue, storage_revenue, ingress_revenue, egress_revenue, registry_read, registry_write, date_created)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+
+	return s.transaction(func(tx txn) error {
+		stmt, err := tx.Prepare(query)
 		if err != nil {
-			return fmt.Errorf("failed to query balance: %w", err)
-		} else if balance.Cmp(amount) < 0 {
-			return fmt.Errorf("insufficient balance")
+			return fmt.Errorf("failed to prepare statement: %w", err)
 		}
+		defer stmt.Close()
 
-		// update balance
-		balance = balance.Sub(amount)
-		const query = `UPDATE accounts SET balance=$1 WHERE id=$8 RETURNING id`
-		err = tx.QueryRow(query, sqlCurrency(balance), dbID).Scan(&dbID)
+		for _, debit := range debits {
+			var rpc string
+			if debit.Source.RPC != (types.Specifier{}) {
+				rpc = debit.Source.RPC.String()
+			}
+			_, err := stmt.Exec(sqlHash256(debit.Account), rpc, debit.Source.Program, sqlCurrency(debit.Usage.RPCRevenue), sqlCurrency(debit.Usage.StorageRevenue),
+				sqlCurrency(debit.Usage.IngressRevenue), sqlCurrency(debit.Usage.EgressRevenue), sqlCurrency(debit.Usage.RegistryRead), sqlCurrency(debit.Usage.RegistryWrite), sqlTime(debit.Timestamp))
+			if err != nil {
+				return fmt.Errorf("failed to journal debit: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// FlushAccountDebits applies the journaled debits to the account balances and
+// removes them from the journal. Debits for accounts that no longer exist or
+// do not have a sufficient balance can never be applied; they are removed from
+// the journal and recorded in the account's audit log. Returns the total
+// amount removed from the journal for each account.
+func (s *Store) FlushAccountDebits() (flushed map[rhp3.Account]types.Currency, err error) {
+	err = s.transaction(func(tx txn) error {
+		const query = `SELECT id, account_id, rpc, program, rpc_revenue, storage_revenue, ingress_revenue, egress_revenue, registry_read, registry_write, date_created
+FROM account_debit_journal
+ORDER BY id ASC`
+
+		rows, err := tx.Query(query)
 		if err != nil {
-			return fmt.Errorf("failed to update balance: %w", err)
-		} else if err := updateContractUsage(tx, dbID, usage, s.log); err != nil {
-			return fmt.Errorf("failed to update contract usage: %w", err)
+			return fmt.Errorf("failed to query journal: %w", err)
 		}
 
-		// update balance metric
-		if err := incrementCurrencyStat(tx, metricAccountBalance, amount, true, time.Now()); err != nil {
-			return fmt.Errorf("failed to increment balance metric: %w", err)
+		var lastID int64
+		var order []rhp3.Account
+		debits := make(map[rhp3.Account][]accountDebit)
+		for rows.Next() {
+			var accountID rhp3.Account
+			var debit accountDebit
+			var rpc string
+			if err := rows.Scan(&lastID, (*sqlHash256)(&accountID), &rpc, &debit.source.Program, (*sqlCurrency)(&debit.usage.RPCRevenue),
+				(*sqlCurrency)(&debit.usage.StorageRevenue), (*sqlCurrency)(&debit.usage.IngressRevenue), (*sqlCurrency)(&debit.usage.EgressRevenue),
+				(*sqlCurrency)(&debit.usage.RegistryRead), (*sqlCurrency)(&debit.usage.RegistryWrite), (*sqlTime)(&debit.timestamp)); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan journal entry: %w", err)
+			}
+			if rpc != "" {
+				debit.source.RPC = types.NewSpecifier(rpc)
+			}
+			if _, ok := debits[accountID]; !ok {
+				order = append(order, accountID)
+			}
+			debits[accountID] = append(debits[accountID], debit)
+		}
+		if err := rows.Close(); err != nil {
+			return fmt.Errorf("failed to close rows: %w", err)
+		} else if len(order) == 0 {
+			return nil
 		}
 
+		flushed = make(map[rhp3.Account]types.Currency, len(order))
+		for _, accountID := range order {
+			var total types.Currency
+			for _, debit := range debits[accountID] {
+				total = total.Add(debit.usage.Total())
+			}
+			flushed[accountID] = total
+
+			_, err := applyAccountDebits(tx, accountID, debits[accountID], s.log)
+			if errors.Is(err, sql.ErrNoRows) || errors.Is(err, errInsufficientBalance) {
+				// the account manager prevents overspending, so this should
+				// only happen if the account was removed while its debits
+				// were journaled. Retrying will never succeed, discard the
+				// debits and record them in the audit log.
+				s.log.Error("failed to apply journaled debits, discarding them", zap.Stringer("account", accountID), zap.String("amount", total.ExactString()), zap.Error(err))
+				reason := "insufficient balance"
+				if errors.Is(err, sql.ErrNoRows) {
+					reason = "account not found"
+				}
+				detail := fmt.Sprintf("discarded %d debits totaling %v", len(debits[accountID]), total.ExactString())
+				if err := insertAuditEntry(tx, accountID, accounts.AuditActionDiscardDebits, detail, reason); err != nil {
+					return fmt.Errorf("failed to record discarded debits: %w", err)
+				}
+			} else if err != nil {
+				return fmt.Errorf("failed to debit account %v: %w", accountID, err)
+			}
+
+			if _, err := tx.Exec(`DELETE FROM account_debit_journal WHERE account_id=$1 AND id <= $2`, sqlHash256(accountID), lastID); err != nil {
+				return fmt.Errorf("failed to clear journal: %w", err)
+			}
+		}
 		return nil
 	})
 	return
 }
 
-// Accounts returns all accounts in the database paginated.
-func (s *Store) Accounts(limit, offset int) (acc []accounts.Account, err error) {
-	rows, err := s.query(`SELECT account_id, balance, expiration_timestamp FROM accounts LIMIT $1 OFFSET $2`, limit, offset)
+// AccountHistory returns the ledger entries of the account with the given ID,
+// newest first.
+func (s *Store) AccountHistory(accountID rhp3.Account, limit, offset int) (entries []accounts.LedgerEntry, err error) {
+	const query = `SELECT id, account_id, entry_type, amount, balance, contract_id, rpc, program, rpc_revenue, storage_revenue, ingress_revenue, egress_revenue, registry_read, registry_write, date_created
+FROM account_ledger
+WHERE account_id=$1
+ORDER BY id DESC
+LIMIT $2 OFFSET $3`
+
+	rows, err := s.query(query, sqlHash256(accountID), limit, offset)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		var a accounts.Account
-		if err := rows.Scan((*sqlHash256)(&a.ID), (*sqlCurrency)(&a.Balance), (*sqlTime)(&a.Expiration)); err != nil {
-			return nil, fmt.Errorf("failed to scan row: %w", err)
+		var entry accounts.LedgerEntry
+		var rpc string
+		if err := rows.Scan(&entry.ID, (*sqlHash256)(&entry.Account), &entry.Type, (*sqlCurrency)(&entry.Amount), (*sqlCurrency)(&entry.Balance),
+			nullable((*sqlHash256)(&entry.ContractID)), &rpc, &entry.Program, (*sqlCurrency)(&entry.Usage.RPCRevenue), (*sqlCurrency)(&entry.Usage.StorageRevenue),
+			(*sqlCurrency)(&entry.Usage.IngressRevenue), (*sqlCurrency)(&entry.Usage.EgressRevenue), (*sqlCurrency)(&entry.Usage.RegistryRead),
+			(*sqlCurrency)(&entry.Usage.RegistryWrite), (*sqlTime)(&entry.Timestamp)); err != nil {
+			return nil, fmt.Errorf("failed to scan ledger entry: %w", err)
+		}
+		if rpc != "" {
+			entry.RPC = types.NewSpecifier(rpc)
+		}
+		entries = append(entries, entry)
+	}
+	return
+}
+
+// insertLedgerEntry appends an entry to the account ledger. The entry's ID is
+// ignored. If the entry's timestamp is zero, the current time is used.
+func insertLedgerEntry(tx txn, entry accounts.LedgerEntry) error {
+	const query = `INSERT INTO account_ledger (account_id, entry_type, amount, balance, contract_id, rpc, program, rpc_revenue, storage_revenue, ingress_revenue, egress_revenue, registry_read, registry_write, date_created)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)`
+
+	var contractID any
+	if entry.ContractID != (types.FileContractID{}) {
+		contractID = sqlHash256(entry.ContractID)
+	}
+	var rpc string
+	if entry.RPC != (types.Specifier{}) {
+		rpc = entry.RPC.String()
+	}
+	timestamp := entry.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	_, err := tx.Exec(query, sqlHash256(entry.Account), entry.Type, sqlCurrency(entry.Amount), sqlCurrency(entry.Balance), contractID, rpc, entry.Program,
+		sqlCurrency(entry.Usage.RPCRevenue), sqlCurrency(entry.Usage.StorageRevenue), sqlCurrency(entry.Usage.IngressRevenue), sqlCurrency(entry.Usage.EgressRevenue),
+		sqlCurrency(entry.Usage.RegistryRead), sqlCurrency(entry.Usage.RegistryWrite), sqlTime(timestamp))
+	return err
+}
+
+// Account returns the account with the given ID.
+func (s *Store) Account(accountID rhp3.Account) (acc accounts.Account, err error) {
+	const query = `SELECT account_id, balance, expiration_timestamp, frozen, max_balance FROM accounts WHERE account_id=$1`
+	acc, err = scanAccount(s.queryRow(query, sqlHash256(accountID)))
+	if errors.Is(err, sql.ErrNoRows) {
+		return accounts.Account{}, accounts.ErrNotFound
+	}
+	return
+}
+
+// Accounts returns the accounts matching the filter and the total number of
+// matching accounts.
+func (s *Store) Accounts(filter accounts.AccountFilter) (acc []accounts.Account, count int, err error) {
+	if filter.Limit <= 0 || filter.Limit > 500 {
+		filter.Limit = 500
+	}
+
+	whereClause, whereParams := buildAccountFilter(filter)
+	if err := s.queryRow(`SELECT COUNT(*) FROM accounts `+whereClause, whereParams...).Scan(&count); err != nil {
+		return nil, 0, fmt.Errorf("failed to query account count: %w", err)
+	}
+
+	query := fmt.Sprintf(`SELECT account_id, balance, expiration_timestamp, frozen, max_balance FROM accounts %s %s LIMIT ? OFFSET ?`, whereClause, buildAccountOrderBy(filter))
+	rows, err := s.query(query, append(whereParams, filter.Limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		a, err := scanAccount(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan row: %w", err)
 		}
 		acc = append(acc, a)
 	}
 	return
 }
 
+// SetAccountFrozen freezes or unfreezes the account with the given ID.
+func (s *Store) SetAccountFrozen(accountID rhp3.Account, frozen bool, reason string) error {
+	return s.transaction(func(tx txn) error {
+		var dbID int64
+		err := tx.QueryRow(`UPDATE accounts SET frozen=$1 WHERE account_id=$2 RETURNING id`, frozen, sqlHash256(accountID)).Scan(&dbID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return accounts.ErrNotFound
+		} else if err != nil {
+			return fmt.Errorf("failed to update account: %w", err)
+		}
+
+		action := accounts.AuditActionFreeze
+		if !frozen {
+			action = accounts.AuditActionUnfreeze
+		}
+		return insertAuditEntry(tx, accountID, action, "", reason)
+	})
+}
+
+// SetAccountMaxBalance overrides the maximum balance of the account with the
+// given ID. A zero maximum balance removes the override.
+func (s *Store) SetAccountMaxBalance(accountID rhp3.Account, maxBalance types.Currency, reason string) error {
+	return s.transaction(func(tx txn) error {
+		var value any
+		detail := "removed max balance override"
+		if !maxBalance.IsZero() {
+			value = sqlCurrency(maxBalance)
+			detail = "set max balance to " + maxBalance.ExactString()
+		}
+
+		var dbID int64
+		err := tx.QueryRow(`UPDATE accounts SET max_balance=$1 WHERE account_id=$2 RETURNING id`, value, sqlHash256(accountID)).Scan(&dbID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return accounts.ErrNotFound
+		} else if err != nil {
+			return fmt.Errorf("failed to update account: %w", err)
+		}
+		return insertAuditEntry(tx, accountID, accounts.AuditActionSetMaxBalance, detail, reason)
+	})
+}
+
+// ExpireAccount removes the account with the given ID. The removed balance is
+// recorded in the account's ledger and returned.
+func (s *Store) ExpireAccount(accountID rhp3.Account, reason string) (balance types.Currency, err error) {
+	err = s.transaction(func(tx txn) error {
+		var dbID int64
+		dbID, balance, err = accountBalance(tx, accountID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return accounts.ErrNotFound
+		} else if err != nil {
+			return fmt.Errorf("failed to query balance: %w", err)
+		} else if err := expireAccount(tx, dbID, accountID, balance, s.log); err != nil {
+			return fmt.Errorf("failed to expire account: %w", err)
+		}
+		return insertAuditEntry(tx, accountID, accounts.AuditActionExpire, "removed balance "+balance.ExactString(), reason)
+	})
+	return
+}
+
+// AccountAuditLog returns the admin actions performed on the account with the
+// given ID, newest first.
+func (s *Store) AccountAuditLog(accountID rhp3.Account, limit, offset int) (entries []accounts.AuditEntry, err error) {
+	const query = `SELECT id, account_id, action, detail, reason, date_created FROM account_audit_log WHERE account_id=$1 ORDER BY id DESC LIMIT $2 OFFSET $3`
+	rows, err := s.query(query, sqlHash256(accountID), limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry accounts.AuditEntry
+		if err := rows.Scan(&entry.ID, (*sqlHash256)(&entry.Account), &entry.Action, &entry.Detail, &entry.Reason, (*sqlTime)(&entry.Timestamp)); err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	return
+}
+
 // AccountFunding returns all contracts that were used to fund the account.
 func (s *Store) AccountFunding(account rhp3.Account) (srcs []accounts.FundingSource, err error) {
 	const query = `SELECT a.account_id, c.contract_id, caf.amount
@@ -165,17 +418,239 @@ WHERE a.account_id=$1`
 	return
 }
 
-// PruneAccounts removes all accounts that have expired
-func (s *Store) PruneAccounts(height uint64) error {
-	_, err := s.exec(`DELETE FROM accounts WHERE expiration_height<$1`, height)
+// PruneAccounts removes all accounts that expired before the given time,
+// except for the accounts in skip. The remaining balances are recognized as
+// revenue. Returns the number of accounts removed.
+func (s *Store) PruneAccounts(before time.Time, skip []rhp3.Account) (pruned int, err error) {
+	inUse := make(map[rhp3.Account]bool, len(skip))
+	for _, id := range skip {
+		inUse[id] = true
+	}
+
+	err = s.transaction(func(tx txn) error {
+		type expiredAccount struct {
+			dbID      int64
+			accountID rhp3.Account
+			balance   types.Currency
+		}
+
+		rows, err := tx.Query(`SELECT id, account_id, balance FROM accounts WHERE expiration_timestamp<$1`, sqlTime(before))
+		if err != nil {
+			return fmt.Errorf("failed to query expired accounts: %w", err)
+		}
+		var expired []expiredAccount
+		for rows.Next() {
+			var acc expiredAccount
+			if err := rows.Scan(&acc.dbID, (*sqlHash256)(&acc.accountID), (*sqlCurrency)(&acc.balance)); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan expired account: %w", err)
+			} else if inUse[acc.accountID] {
+				continue
+			}
+			expired = append(expired, acc)
+		}
+		if err := rows.Close(); err != nil {
+			return fmt.Errorf("failed to close rows: %w", err)
+		}
+
+		for _, acc := range expired {
+			if err := expireAccount(tx, acc.dbID, acc.accountID, acc.balance, s.log); err != nil {
+				return fmt.Errorf("failed to expire account %v: %w", acc.accountID, err)
+			}
+		}
+		pruned = len(expired)
+		return nil
+	})
+	return
+}
+
+func scanAccount(row scanner) (acc accounts.Account, err error) {
+	err = row.Scan((*sqlHash256)(&acc.ID), (*sqlCurrency)(&acc.Balance), (*sqlTime)(&acc.Expiration), &acc.Frozen, nullable((*sqlCurrency)(&acc.MaxBalance)))
+	return
+}
+
+// currencyOrderExpr returns an SQL expression that orders a currency column
+// numerically.
+func currencyOrderExpr(column string) string {
+	return littleEndianOrderExpr(column, 16)
+}
+
+// currencyOrderKey returns the value of currencyOrderExpr for a currency.
+func currencyOrderKey(c types.Currency) string {
+	var buf [16]byte
+	binary.BigEndian.PutUint64(buf[:8], c.Hi)
+	binary.BigEndian.PutUint64(buf[8:], c.Lo)
+	return strings.ToUpper(hex.EncodeToString(buf[:]))
+}
+
+func buildAccountFilter(filter accounts.AccountFilter) (string, []any) {
+	var whereClause []string
+	var queryParams []any
+
+	if filter.Frozen != nil {
+		whereClause = append(whereClause, `frozen=?`)
+		queryParams = append(queryParams, *filter.Frozen)
+	}
+	if !filter.MinBalance.IsZero() {
+		whereClause = append(whereClause, currencyOrderExpr("balance")+`>=?`)
+		queryParams = append(queryParams, currencyOrderKey(filter.MinBalance))
+	}
+	if !filter.MaxBalance.IsZero() {
+		whereClause = append(whereClause, currencyOrderExpr("balance")+`<=?`)
+		queryParams = append(queryParams, currencyOrderKey(filter.MaxBalance))
+	}
+	if !filter.ExpiresAfter.IsZero() {
+		whereClause = append(whereClause, `expiration_timestamp>=?`)
+		queryParams = append(queryParams, sqlTime(filter.ExpiresAfter))
+	}
+	if !filter.ExpiresBefore.IsZero() {
+		whereClause = append(whereClause, `expiration_timestamp<?`)
+		queryParams = append(queryParams, sqlTime(filter.ExpiresBefore))
+	}
+	if len(whereClause) == 0 {
+		return "", nil
+	}
+	return "WHERE " + strings.Join(whereClause, " AND "), queryParams
+}
+
+func buildAccountOrderBy(filter accounts.AccountFilter) string {
+	dir := "ASC"
+	if filter.SortDesc {
+		dir = "DESC"
+	}
+	switch filter.SortField {
+	case accounts.AccountSortBalance:
+		return `ORDER BY ` + currencyOrderExpr("balance") + ` ` + dir + `, id ASC`
+	case accounts.AccountSortExpiration:
+		return `ORDER BY expiration_timestamp ` + dir + `, id ASC`
+	default:
+		return `ORDER BY id ` + dir
+	}
+}
+
+// expireAccount removes an account and its funding sources. The remaining
+// balance is kept by the host: each funding contract earns its unspent
+// funding as account expiry revenue and the balance is recorded in the
+// account's ledger.
+func expireAccount(tx txn, dbID int64, accountID rhp3.Account, balance types.Currency, log *zap.Logger) error {
+	funding, err := contractFunding(tx, dbID)
+	if err != nil {
+		return fmt.Errorf("failed to get contract funding: %w", err)
+	}
+
+	remaining := balance
+	for _, f := range funding {
+		amount := f.Amount
+		if amount.Cmp(remaining) > 0 {
+			amount = remaining
+		}
+		remaining = remaining.Sub(amount)
+		if err := spendContractFunding(tx, f, contracts.Usage{AccountExpiry: amount}, types.ZeroCurrency); err != nil {
+			return err
+		}
+	}
+	if !remaining.IsZero() {
+		// note: any accounts funded before the v0.2.0 upgrade will have
+		// unallocated balances.
+		log.Debug("expired balance not fully distributed", zap.Int64("account", dbID), zap.String("remainder", remaining.ExactString()))
+	}
+
+	if _, err := tx.Exec(`DELETE FROM contract_account_funding WHERE account_id=$1`, dbID); err != nil {
+		return fmt.Errorf("failed to delete funding sources: %w", err)
+	} else if _, err := tx.Exec(`DELETE FROM accounts WHERE id=$1`, dbID); err != nil {
+		return fmt.Errorf("failed to delete account: %w", err)
+	}
+
+	now := time.Now()
+	if err := incrementCurrencyStat(tx, metricAccountBalance, balance, true, now); err != nil {
+		return fmt.Errorf("failed to decrement balance metric: %w", err)
+	} else if err := incrementNumericStat(tx, metricActiveAccounts, -1, now); err != nil {
+		return fmt.Errorf("failed to decrement active accounts metric: %w", err)
+	}
+
+	entry := accounts.LedgerEntry{
+		Account: accountID,
+		Type:    accounts.LedgerEntryExpiry,
+		Amount:  balance,
+		Balance: types.ZeroCurrency,
+	}
+	if err := insertLedgerEntry(tx, entry); err != nil {
+		return fmt.Errorf("failed to record ledger entry: %w", err)
+	}
+	return nil
+}
+
+func insertAuditEntry(tx txn, accountID rhp3.Account, action, detail, reason string) error {
+	_, err := tx.Exec(`INSERT INTO account_audit_log (account_id, action, detail, reason, date_created) VALUES ($1, $2, $3, $4, $5)`, sqlHash256(accountID), action, detail, reason, sqlTime(time.Now()))
 	return err
 }
 
+// applyAccountDebits subtracts the debits from the account's balance,
+// distributes the usage to the contracts that funded the account, and
+// records a withdrawal in the account's ledger for each debit. Returns the
+// remaining balance of the account.
+func applyAccountDebits(tx txn, accountID rhp3.Account, debits []accountDebit, log *zap.Logger) (types.Currency, error) {
+	var usage accounts.Usage
+	for _, debit := range debits {
+		usage = usage.Add(debit.usage)
+	}
+	amount := usage.Total()
+
+	dbID, balance, err := accountBalance(tx, accountID)
+	if err != nil {
+		return types.ZeroCurrency, fmt.Errorf("failed to query balance: %w", err)
+	} else if balance.Cmp(amount) < 0 {
+		return types.ZeroCurrency, errInsufficientBalance
+	}
+
+	// update balance
+	remaining := balance.Sub(amount)
+	err = tx.QueryRow(`UPDATE accounts SET balance=$1 WHERE id=$2 RETURNING id`, sqlCurrency(remaining), dbID).Scan(&dbID)
+	if err != nil {
+		return types.ZeroCurrency, fmt.Errorf("failed to update balance: %w", err)
+	} else if err := updateContractUsage(tx, dbID, usage, log); err != nil {
+		return types.ZeroCurrency, fmt.Errorf("failed to update contract usage: %w", err)
+	}
+
+	// update balance metric
+	if err := incrementCurrencyStat(tx, metricAccountBalance, amount, true, time.Now()); err != nil {
+		return types.ZeroCurrency, fmt.Errorf("failed to increment balance metric: %w", err)
+	}
+
+	// record each withdrawal in the account's ledger
+	for _, debit := range debits {
+		spent := debit.usage.Total()
+		if spent.IsZero() {
+			continue
+		}
+		balance = balance.Sub(spent)
+		entry := accounts.LedgerEntry{
+			Account:      accountID,
+			Type:         accounts.LedgerEntryWithdrawal,
+			Amount:       spent,
+			Balance:      balance,
+			Usage:        debit.usage,
+			Timestamp:    debit.timestamp,
+			LedgerSource: debit.source,
+		}
+		if err := insertLedgerEntry(tx, entry); err != nil {
+			return types.ZeroCurrency, fmt.Errorf("failed to record ledger entry: %w", err)
+		}
+	}
+	return remaining, nil
+}
+
 func accountBalance(tx txn, accountID rhp3.Account) (dbID int64, balance types.Currency, err error) {
 	err = tx.QueryRow(`SELECT id, balance FROM accounts WHERE account_id=$1`, sqlHash256(accountID)).Scan(&dbID, (*sqlCurrency)(&balance))
 	return
 }
 
+type accountDebit struct {
+	usage     accounts.Usage
+	source    accounts.LedgerSource
+	timestamp time.Time
+}
+
 type fundAmount struct {
 	ID         int64
 	ContractID int64
@@ -236,35 +711,8 @@ func updateContractUsage(tx txn, accountID int64, usage accounts.Usage, log *zap
 		distributeFunds(&usage.RegistryWrite, &additionalUsage.RegistryWrite, &remainder)
 		distributeFunds(&usage.RPCRevenue, &additionalUsage.RPCRevenue, &remainder)
 
-		// add the additional usage to the contract
-		if err := incrementContractUsage(tx, f.ContractID, additionalUsage); err != nil {
-			return fmt.Errorf("failed to increment contract usage: %w", err)
-		}
-		// update the remaining value for the funding source
-		if err := setContractAccountFunding(tx, f.ID, remainder); err != nil {
-			return fmt.Errorf("failed to set account funding: %w", err)
-		}
-
-		contract, err := getContract(tx, f.ContractID)
-		if err != nil {
-			return fmt.Errorf("failed to get contract: %w", err)
-		}
-		// subtract the spending from the contract's account funding
-		unspentContractFunds := contract.Usage.AccountFunding.Sub(f.Amount.Sub(remainder))
-		if err := setContractRemainingFunds(tx, f.ContractID, unspentContractFunds); err != nil {
-			return fmt.Errorf("failed to decrement account funding: %w", err)
-		}
-
-		if contract.Status == contracts.ContractStatusActive || contract.Status == contracts.ContractStatusPending {
-			// increment potential revenue
-			if err := incrementPotentialRevenueMetrics(tx, additionalUsage, false); err != nil {
-				return fmt.Errorf("failed to increment contract potential revenue: %w", err)
-			}
-		} else if contract.Status == contracts.ContractStatusSuccessful && contract.RevisionConfirmed {
-			// increment earned revenue
-			if err := incrementEarnedRevenueMetrics(tx, additionalUsage, false); err != nil {
-				return fmt.Errorf("failed to increment contract earned revenue: %w", err)
-			}
+		if err := spendContractFunding(tx, f, additionalUsage, remainder); err != nil {
+			return err
 		}
 	}
 
@@ -276,6 +724,57 @@ func updateContractUsage(tx txn, accountID int64, usage accounts.Usage, log *zap
 	return nil
 }
 
+// spendContractFunding attributes usage paid for by an account to the
+// contract that funded it. The funding source's remaining amount is set to
+// remainder and the revenue metrics are updated based on the contract's
+// status.
+func spendContractFunding(tx txn, f fundAmount, additionalUsage contracts.Usage, remainder types.Currency) error {
+	// add the additional usage to the contract
+	if err := incrementContractUsage(tx, f.ContractID, additionalUsage); err != nil {
+		return fmt.Errorf("failed to increment contract usage: %w", err)
+	}
+	// update the remaining value for the funding source
+	if err := setContractAccountFunding(tx, f.ID, remainder); err != nil {
+		return fmt.Errorf("failed to set account funding: %w", err)
+	}
+
+	contract, err := getContract(tx, f.ContractID)
+	if err != nil {
+		return fmt.Errorf("failed to get contract: %w", err)
+	}
+	// subtract the spending from the contract's account funding
+	unspentContractFunds := contract.Usage.AccountFunding.Sub(f.Amount.Sub(remainder))
+	if err := setContractRemainingFunds(tx, f.ContractID, unspentContractFunds); err != nil {
+		return fmt.Errorf("failed to decrement account funding: %w", err)
+	}
+
+	if contract.Status == contracts.ContractStatusActive || contract.Status == contracts.ContractStatusPending {
+		// increment potential revenue
+		if err := incrementPotentialRevenueMetrics(tx, additionalUsage, false); err != nil {
+			return fmt.Errorf("failed to increment contract potential revenue: %w", err)
+		}
+	} else if contract.Status == contracts.ContractStatusSuccessful && contract.RevisionConfirmed {
+		// increment earned revenue
+		if err := incrementEarnedRevenueMetrics(tx, additionalUsage, false); err != nil {
+			return fmt.Errorf("failed to increment contract earned revenue: %w", err)
+		}
+		// the contract's revenue has already been reported, record the
+		// spending separately so it is reported when it was spent
+		revenue := additionalUsage.RPCRevenue.
+			Add(additionalUsage.StorageRevenue).
+			Add(additionalUsage.IngressRevenue).
+			Add(additionalUsage.EgressRevenue).
+			Add(additionalUsage.RegistryRead).
+			Add(additionalUsage.RegistryWrite)
+		if !revenue.IsZero() {
+			if _, err := tx.Exec(`INSERT INTO contract_late_revenue (contract_id, amount, date_created) VALUES ($1, $2, $3)`, f.ContractID, sqlCurrency(revenue), sqlTime(time.Now())); err != nil {
+				return fmt.Errorf("failed to record late revenue: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
 func setContractRemainingFunds(tx txn, contractID int64, amount types.Currency) error {
 	return tx.QueryRow(`UPDATE contracts SET account_funding=$1 WHERE id=$2 RETURNING id`, sqlCurrency(amount), contractID).Scan(&contractID)
 }
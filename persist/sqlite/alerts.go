@@ -0,0 +1,149 @@
+package sqlite
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/host/alerts"
+)
+
+// ActiveAlerts returns the alerts that have not been dismissed.
+func (s *Store) ActiveAlerts() (active []alerts.Alert, err error) {
+	const query = `SELECT alert_id, severity, message, data, last_seen FROM host_alerts WHERE dismissed_at IS NULL ORDER BY last_seen DESC, id DESC`
+
+	rows, err := s.query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query alerts: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		a, err := scanAlert(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan alert: %w", err)
+		}
+		active = append(active, a)
+	}
+	return active, rows.Err()
+}
+
+// RegisterAlert adds an alert or, if the alert is already active, updates it
+// and increments its occurrence count.
+func (s *Store) RegisterAlert(a alerts.Alert) error {
+	const query = `INSERT INTO host_alerts (alert_id, severity, message, data, first_seen, last_seen, occurrences) VALUES ($1, $2, $3, $4, $5, $5, 1)
+ON CONFLICT (alert_id) WHERE dismissed_at IS NULL DO UPDATE SET severity=EXCLUDED.severity, message=EXCLUDED.message, data=EXCLUDED.data, last_seen=EXCLUDED.last_seen, occurrences=occurrences+1`
+
+	var data *string
+	if len(a.Data) > 0 {
+		buf, err := json.Marshal(a.Data)
+		if err != nil {
+			return fmt.Errorf("failed to encode alert data: %w", err)
+		}
+		str := string(buf)
+		data = &str
+	}
+	_, err := s.exec(query, sqlHash256(a.ID), a.Severity, a.Message, data, sqlTime(a.Timestamp))
+	return err
+}
+
+// DismissAlerts marks the active alerts with the given IDs as dismissed.
+func (s *Store) DismissAlerts(by string, timestamp time.Time, ids ...types.Hash256) error {
+	const query = `UPDATE host_alerts SET dismissed_by=$1, dismissed_at=$2 WHERE alert_id=$3 AND dismissed_at IS NULL`
+
+	return s.transaction(func(tx txn) error {
+		stmt, err := tx.Prepare(query)
+		if err != nil {
+			return fmt.Errorf("failed to prepare statement: %w", err)
+		}
+		defer stmt.Close()
+
+		for _, id := range ids {
+			if _, err := stmt.Exec(by, sqlTime(timestamp), sqlHash256(id)); err != nil {
+				return fmt.Errorf("failed to dismiss alert %v: %w", id, err)
+			}
+		}
+		return nil
+	})
+}
+
+// AlertHistory returns the alerts matching the filter, most recently seen
+// first, and the total number of matching alerts.
+func (s *Store) AlertHistory(filter alerts.HistoryFilter) (history []alerts.HistoryAlert, count int, err error) {
+	var where []string
+	var params []any
+	if filter.Severity != 0 {
+		where = append(where, "severity=$1")
+		params = append(params, filter.Severity)
+	}
+	if !filter.From.IsZero() {
+		where = append(where, fmt.Sprintf("COALESCE(dismissed_at, last_seen) >= $%d", len(params)+1))
+		params = append(params, sqlTime(filter.From))
+	}
+	if !filter.To.IsZero() {
+		where = append(where, fmt.Sprintf("first_seen < $%d", len(params)+1))
+		params = append(params, sqlTime(filter.To))
+	}
+	var whereClause string
+	if len(where) > 0 {
+		whereClause = "WHERE " + strings.Join(where, " AND ")
+	}
+
+	err = s.transaction(func(tx txn) error {
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM host_alerts `+whereClause, params...).Scan(&count); err != nil {
+			return fmt.Errorf("failed to count alerts: %w", err)
+		}
+
+		query := fmt.Sprintf(`SELECT alert_id, severity, message, data, last_seen, first_seen, occurrences, dismissed_by, dismissed_at FROM host_alerts %s ORDER BY last_seen DESC, id DESC LIMIT $%d OFFSET $%d`, whereClause, len(params)+1, len(params)+2)
+		rows, err := tx.Query(query, append(params, filter.Limit, filter.Offset)...)
+		if err != nil {
+			return fmt.Errorf("failed to query alerts: %w", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			h, err := scanHistoryAlert(rows)
+			if err != nil {
+				return fmt.Errorf("failed to scan alert: %w", err)
+			}
+			history = append(history, h)
+		}
+		return rows.Err()
+	})
+	return
+}
+
+// decodeAlertData decodes the JSON encoded data of an alert.
+func decodeAlertData(data *string, a *alerts.Alert) error {
+	if data == nil {
+		return nil
+	}
+	return json.Unmarshal([]byte(*data), &a.Data)
+}
+
+func scanAlert(s scanner) (a alerts.Alert, err error) {
+	var data *string
+	if err = s.Scan((*sqlHash256)(&a.ID), &a.Severity, &a.Message, &data, (*sqlTime)(&a.Timestamp)); err != nil {
+		return
+	}
+	err = decodeAlertData(data, &a)
+	return
+}
+
+func scanHistoryAlert(s scanner) (h alerts.HistoryAlert, err error) {
+	var data, dismissedBy *string
+	var dismissedAt time.Time
+	nullDismissedAt := nullable((*sqlTime)(&dismissedAt))
+	if err = s.Scan((*sqlHash256)(&h.ID), &h.Severity, &h.Message, &data, (*sqlTime)(&h.LastSeen), (*sqlTime)(&h.FirstSeen), &h.Occurrences, &dismissedBy, nullDismissedAt); err != nil {
+		return
+	}
+	h.Timestamp = h.LastSeen
+	if dismissedBy != nil {
+		h.DismissedBy = *dismissedBy
+	}
+	if nullDismissedAt.Valid {
+		h.DismissedAt = &dismissedAt
+	}
+	err = decodeAlertData(data, &h.Alert)
+	return
+}
@@ -0,0 +1,111 @@
+package sqlite
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.sia.tech/hostd/host/alerts"
+	"go.uber.org/zap/zaptest"
+	"lukechampine.com/frand"
+)
+
+func TestAlertHistory(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	db, err := OpenDatabase(filepath.Join(t.TempDir(), "hostdb.db"), log.Named("sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	am, err := alerts.NewManager(db, log.Named("alerts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	warning := alerts.Alert{
+		ID:        frand.Entropy256(),
+		Severity:  alerts.SeverityWarning,
+		Message:   "low balance",
+		Data:      map[string]any{"balance": "1 SC"},
+		Timestamp: start,
+	}
+	critical := alerts.Alert{
+		ID:        frand.Entropy256(),
+		Severity:  alerts.SeverityCritical,
+		Message:   "volume unavailable",
+		Timestamp: start.Add(time.Hour),
+	}
+
+	// registering the same alert again should increment its occurrences
+	am.Register(warning)
+	warning.Timestamp = start.Add(2 * time.Hour)
+	am.Register(warning)
+	am.Register(critical)
+
+	// active alerts should be restored after a restart
+	am, err = alerts.NewManager(db, log.Named("alerts"))
+	if err != nil {
+		t.Fatal(err)
+	} else if active := am.Active(); len(active) != 2 {
+		t.Fatalf("expected 2 active alerts, got %v", len(active))
+	} else if active[0].ID != warning.ID || active[0].Data["balance"] != "1 SC" {
+		t.Fatalf("unexpected alert %+v", active[0])
+	}
+
+	history, count, err := am.History(alerts.HistoryFilter{Severity: alerts.SeverityWarning})
+	if err != nil {
+		t.Fatal(err)
+	} else if count != 1 || len(history) != 1 {
+		t.Fatalf("expected 1 warning, got %v", count)
+	} else if h := history[0]; h.Occurrences != 2 || !h.FirstSeen.Equal(start) || !h.LastSeen.Equal(warning.Timestamp) || h.DismissedAt != nil {
+		t.Fatalf("unexpected history %+v", h)
+	}
+
+	// dismissing an alert should keep it in the history
+	am.DismissBy("alice", warning.ID)
+	if active := am.Active(); len(active) != 1 {
+		t.Fatalf("expected 1 active alert, got %v", len(active))
+	}
+	history, _, err = am.History(alerts.HistoryFilter{Severity: alerts.SeverityWarning})
+	if err != nil {
+		t.Fatal(err)
+	} else if len(history) != 1 {
+		t.Fatalf("expected 1 warning, got %v", len(history))
+	} else if h := history[0]; h.DismissedBy != "alice" || h.DismissedAt == nil {
+		t.Fatalf("expected alert to be dismissed, got %+v", h)
+	}
+
+	// registering a dismissed alert should start a new occurrence
+	warning.Timestamp = start.Add(48 * time.Hour)
+	am.Register(warning)
+	history, count, err = am.History(alerts.HistoryFilter{})
+	if err != nil {
+		t.Fatal(err)
+	} else if count != 3 || len(history) != 3 {
+		t.Fatalf("expected 3 alerts, got %v", count)
+	} else if h := history[0]; h.ID != warning.ID || h.Occurrences != 1 || h.DismissedAt != nil {
+		t.Fatalf("unexpected history %+v", h)
+	}
+
+	// only alerts active during the range should be returned
+	history, count, err = am.History(alerts.HistoryFilter{To: start.Add(30 * time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	} else if count != 1 || history[0].ID != warning.ID || history[0].Occurrences != 2 {
+		t.Fatalf("expected the first warning, got %+v", history)
+	}
+
+	// pagination
+	history, count, err = am.History(alerts.HistoryFilter{Limit: 1, Offset: 1})
+	if err != nil {
+		t.Fatal(err)
+	} else if count != 3 || len(history) != 1 {
+		t.Fatalf("expected 1 of 3 alerts, got %v of %v", len(history), count)
+	}
+
+	if _, _, err := am.History(alerts.HistoryFilter{From: start, To: start}); err == nil {
+		t.Fatal("expected error for empty range")
+	}
+}
@@ -78,15 +78,32 @@ func (u *updateContractsTxn) ConfirmFormation(id types.FileContractID) error {
 	return nil
 }
 
-// ConfirmRevision sets the confirmed revision number.
+// ConfirmRevision sets the confirmed revision number. Revisions are applied
+// again when the chain is rescanned, so a revision older than the confirmed
+// revision is ignored and the status of resolved contracts is not changed.
 func (u *updateContractsTxn) ConfirmRevision(revision types.FileContractRevision) error {
-	const query = `UPDATE contracts SET confirmed_revision_number=$1 WHERE contract_id=$2 RETURNING id;`
 	var dbID int64
-	err := u.tx.QueryRow(query, sqlUint64(revision.RevisionNumber), sqlHash256(revision.ParentID)).Scan(&dbID)
+	var status contracts.ContractStatus
+	var confirmed uint64
+	confirmedNull := nullable((*sqlUint64)(&confirmed))
+	err := u.tx.QueryRow(`SELECT id, contract_status, confirmed_revision_number FROM contracts WHERE contract_id=$1`, sqlHash256(revision.ParentID)).Scan(&dbID, &status, confirmedNull)
 	if err != nil {
-		return fmt.Errorf("failed to confirm revision: %w", err)
-	} else if err := setContractStatus(u.tx, revision.ParentID, contracts.ContractStatusActive); err != nil {
-		return fmt.Errorf("failed to set contract status to active: %w", err)
+		return fmt.Errorf("failed to get contract: %w", err)
+	}
+
+	if !confirmedNull.Valid || revision.RevisionNumber >= confirmed {
+		const query = `UPDATE contracts SET confirmed_revision_number=$1 WHERE id=$2 RETURNING id;`
+		if err := u.tx.QueryRow(query, sqlUint64(revision.RevisionNumber), dbID).Scan(&dbID); err != nil {
+			return fmt.Errorf("failed to confirm revision: %w", err)
+		}
+	}
+
+	// skip updating the status for contracts that are already marked as
+	// successful or failed
+	if status != contracts.ContractStatusSuccessful && status != contracts.ContractStatusFailed {
+		if err := setContractStatus(u.tx, revision.ParentID, contracts.ContractStatusActive); err != nil {
+			return fmt.Errorf("failed to set contract status to active: %w", err)
+		}
 	}
 	return nil
 }
@@ -173,7 +190,7 @@ func (s *Store) Contracts(filter contracts.ContractFilter) (contracts []contract
 
 	contractQuery := fmt.Sprintf(`SELECT c.contract_id, rt.contract_id AS renewed_to, rf.contract_id AS renewed_from, c.contract_status, c.negotiation_height, c.formation_confirmed, 
 	c.revision_number=c.confirmed_revision_number AS revision_confirmed, c.resolution_height, c.locked_collateral, c.rpc_revenue,
-	c.storage_revenue, c.ingress_revenue, c.egress_revenue, c.account_funding, c.risked_collateral, c.raw_revision, c.host_sig, c.renter_sig 
+	c.storage_revenue, c.ingress_revenue, c.egress_revenue, c.account_expiry_revenue, c.account_funding, c.risked_collateral, c.raw_revision, c.host_sig, c.renter_sig 
 FROM contracts c
 INNER JOIN contract_renters r ON (c.renter_id=r.id)
 LEFT JOIN contracts rt ON (c.renewed_to=rt.id)
@@ -462,6 +479,12 @@ func (s *Store) ExpireContract(id types.FileContractID, status contracts.Contrac
 		if err := setContractStatus(tx, id, status); err != nil {
 			return fmt.Errorf("failed to set contract status: %w", err)
 		}
+		// record when the contract's revenue or lost collateral was realized
+		if status == contracts.ContractStatusSuccessful || status == contracts.ContractStatusFailed {
+			if _, err := tx.Exec(`UPDATE contracts SET resolution_timestamp=$1 WHERE id=$2`, sqlTime(time.Now()), contractID); err != nil {
+				return fmt.Errorf("failed to set resolution timestamp: %w", err)
+			}
+		}
 		return nil
 	})
 }
@@ -523,7 +546,7 @@ func (s *Store) ExpireContractSectors(height uint64) error {
 func getContract(tx txn, contractID int64) (contracts.Contract, error) {
 	const query = `SELECT c.contract_id, rt.contract_id AS renewed_to, rf.contract_id AS renewed_from, c.contract_status, c.negotiation_height, c.formation_confirmed, 
 	c.revision_number=c.confirmed_revision_number AS revision_confirmed, c.resolution_height, c.locked_collateral, c.rpc_revenue,
-	c.storage_revenue, c.ingress_revenue, c.egress_revenue, c.account_funding, c.risked_collateral, c.raw_revision, c.host_sig, c.renter_sig 
+	c.storage_revenue, c.ingress_revenue, c.egress_revenue, c.account_expiry_revenue, c.account_funding, c.risked_collateral, c.raw_revision, c.host_sig, c.renter_sig 
 	FROM contracts c
 	LEFT JOIN contracts rt ON (c.renewed_to = rt.id)
 	LEFT JOIN contracts rf ON (c.renewed_from = rf.id)
@@ -692,12 +715,13 @@ func trimSectors(tx txn, contractID int64, n uint64, log *zap.Logger) error {
 func clearContract(tx txn, revision contracts.SignedRevision, renewedDBID int64, usage contracts.Usage) (dbID int64, err error) {
 	// get the existing contract's current usage
 	var total contracts.Usage
-	err = tx.QueryRow(`SELECT id, rpc_revenue, storage_revenue, ingress_revenue, egress_revenue, account_funding, risked_collateral FROM contracts WHERE contract_id=$1`, sqlHash256(revision.Revision.ParentID)).Scan(
+	err = tx.QueryRow(`SELECT id, rpc_revenue, storage_revenue, ingress_revenue, egress_revenue, account_expiry_revenue, account_funding, risked_collateral FROM contracts WHERE contract_id=$1`, sqlHash256(revision.Revision.ParentID)).Scan(
 		&dbID,
 		(*sqlCurrency)(&total.RPCRevenue),
 		(*sqlCurrency)(&total.StorageRevenue),
 		(*sqlCurrency)(&total.IngressRevenue),
 		(*sqlCurrency)(&total.EgressRevenue),
+		(*sqlCurrency)(&total.AccountExpiry),
 		(*sqlCurrency)(&total.AccountFunding),
 		(*sqlCurrency)(&total.RiskedCollateral))
 	if err != nil {
@@ -706,7 +730,7 @@ func clearContract(tx txn, revision contracts.SignedRevision, renewedDBID int64,
 	total = total.Add(usage)
 
 	// update the existing contract
-	const clearQuery = `UPDATE contracts SET (renewed_to, revision_number, host_sig, renter_sig, raw_revision, rpc_revenue, storage_revenue, ingress_revenue, egress_revenue, account_funding, risked_collateral) = ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) WHERE id=$12 RETURNING id;`
+	const clearQuery = `UPDATE contracts SET (renewed_to, revision_number, host_sig, renter_sig, raw_revision, rpc_revenue, storage_revenue, ingress_revenue, egress_revenue, account_expiry_revenue, account_funding, risked_collateral) = ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) WHERE id=$13 RETURNING id;`
 	err = tx.QueryRow(clearQuery,
 		renewedDBID,
 		sqlUint64(revision.Revision.RevisionNumber),
@@ -717,6 +741,7 @@ func clearContract(tx txn, revision contracts.SignedRevision, renewedDBID int64,
 		sqlCurrency(total.StorageRevenue),
 		sqlCurrency(total.IngressRevenue),
 		sqlCurrency(total.EgressRevenue),
+		sqlCurrency(total.AccountExpiry),
 		sqlCurrency(total.AccountFunding),
 		sqlCurrency(total.RiskedCollateral),
 		dbID,
@@ -739,13 +764,14 @@ func reviseContract(tx txn, revision contracts.SignedRevision) (dbID int64, err
 }
 
 func incrementContractUsage(tx txn, dbID int64, usage contracts.Usage) error {
-	const query = `SELECT rpc_revenue, storage_revenue, ingress_revenue, egress_revenue, account_funding, risked_collateral FROM contracts WHERE id=$1;`
+	const query = `SELECT rpc_revenue, storage_revenue, ingress_revenue, egress_revenue, account_expiry_revenue, account_funding, risked_collateral FROM contracts WHERE id=$1;`
 	var total contracts.Usage
 	err := tx.QueryRow(query, dbID).Scan(
 		(*sqlCurrency)(&total.RPCRevenue),
 		(*sqlCurrency)(&total.StorageRevenue),
 		(*sqlCurrency)(&total.IngressRevenue),
 		(*sqlCurrency)(&total.EgressRevenue),
+		(*sqlCurrency)(&total.AccountExpiry),
 		(*sqlCurrency)(&total.AccountFunding),
 		(*sqlCurrency)(&total.RiskedCollateral))
 	if err != nil {
@@ -753,11 +779,12 @@ func incrementContractUsage(tx txn, dbID int64, usage contracts.Usage) error {
 	}
 	total = total.Add(usage)
 	var updatedID int64
-	err = tx.QueryRow(`UPDATE contracts SET (rpc_revenue, storage_revenue, ingress_revenue, egress_revenue, account_funding, risked_collateral) = ($1, $2, $3, $4, $5, $6) WHERE id=$7 RETURNING id;`,
+	err = tx.QueryRow(`UPDATE contracts SET (rpc_revenue, storage_revenue, ingress_revenue, egress_revenue, account_expiry_revenue, account_funding, risked_collateral) = ($1, $2, $3, $4, $5, $6, $7) WHERE id=$8 RETURNING id;`,
 		sqlCurrency(total.RPCRevenue),
 		sqlCurrency(total.StorageRevenue),
 		sqlCurrency(total.IngressRevenue),
 		sqlCurrency(total.EgressRevenue),
+		sqlCurrency(total.AccountExpiry),
 		sqlCurrency(total.AccountFunding),
 		sqlCurrency(total.RiskedCollateral),
 		dbID).Scan(&updatedID)
@@ -898,9 +925,9 @@ func renterDBID(tx txn, renterKey types.PublicKey) (int64, error) {
 
 func insertContract(tx txn, revision contracts.SignedRevision, formationSet []types.Transaction, lockedCollateral types.Currency, initialUsage contracts.Usage, negotationHeight uint64) (dbID int64, err error) {
 	const query = `INSERT INTO contracts (contract_id, renter_id, locked_collateral, rpc_revenue, storage_revenue, ingress_revenue, 
-egress_revenue, registry_read, registry_write, account_funding, risked_collateral, revision_number, negotiation_height, window_start, window_end, formation_txn_set, 
+egress_revenue, registry_read, registry_write, account_expiry_revenue, account_funding, risked_collateral, revision_number, negotiation_height, window_start, window_end, formation_txn_set, 
 raw_revision, host_sig, renter_sig, confirmed_revision_number, formation_confirmed, contract_status) VALUES
- ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22) RETURNING id;`
+ ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23) RETURNING id;`
 	renterID, err := renterDBID(tx, revision.RenterKey())
 	if err != nil {
 		return 0, fmt.Errorf("failed to get renter id: %w", err)
@@ -915,6 +942,7 @@ raw_revision, host_sig, renter_sig, confirmed_revision_number, formation_confirm
 		sqlCurrency(initialUsage.EgressRevenue),
 		sqlCurrency(initialUsage.RegistryRead),
 		sqlCurrency(initialUsage.RegistryWrite),
+		sqlCurrency(initialUsage.AccountExpiry),
 		sqlCurrency(initialUsage.AccountFunding),
 		sqlCurrency(initialUsage.RiskedCollateral),
 		sqlUint64(revision.Revision.RevisionNumber),
@@ -1085,6 +1113,7 @@ func scanContract(row scanner) (c contracts.Contract, err error) {
 		(*sqlCurrency)(&c.Usage.StorageRevenue),
 		(*sqlCurrency)(&c.Usage.IngressRevenue),
 		(*sqlCurrency)(&c.Usage.EgressRevenue),
+		(*sqlCurrency)(&c.Usage.AccountExpiry),
 		(*sqlCurrency)(&c.Usage.AccountFunding),
 		(*sqlCurrency)(&c.Usage.RiskedCollateral),
 		&revisionBuf,
@@ -1194,6 +1223,8 @@ func incrementPotentialRevenueMetrics(tx txn, usage contracts.Usage, negative bo
 		return fmt.Errorf("failed to increment registry read revenue stat: %w", err)
 	} else if err := incrementCurrencyStat(tx, metricPotentialRegistryWriteRevenue, usage.RegistryWrite, negative, time.Now()); err != nil {
 		return fmt.Errorf("failed to increment registry write revenue stat: %w", err)
+	} else if err := incrementCurrencyStat(tx, metricPotentialAccountExpiryRevenue, usage.AccountExpiry, negative, time.Now()); err != nil {
+		return fmt.Errorf("failed to increment account expiry revenue stat: %w", err)
 	}
 	return nil
 }
@@ -1211,6 +1242,8 @@ func incrementEarnedRevenueMetrics(tx txn, usage contracts.Usage, negative bool)
 		return fmt.Errorf("failed to increment registry read revenue stat: %w", err)
 	} else if err := incrementCurrencyStat(tx, metricEarnedRegistryWriteRevenue, usage.RegistryWrite, negative, time.Now()); err != nil {
 		return fmt.Errorf("failed to increment registry write revenue stat: %w", err)
+	} else if err := incrementCurrencyStat(tx, metricEarnedAccountExpiryRevenue, usage.AccountExpiry, negative, time.Now()); err != nil {
+		return fmt.Errorf("failed to increment account expiry revenue stat: %w", err)
 	}
 	return nil
 }
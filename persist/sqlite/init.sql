@@ -9,6 +9,12 @@ CREATE TABLE wallet_utxos (
 	unlock_hash BLOB NOT NULL
 );
 
+CREATE TABLE wallet_locked_utxos (
+	id BLOB PRIMARY KEY REFERENCES wallet_utxos(id) ON DELETE CASCADE,
+	reason TEXT NOT NULL,
+	expiration_timestamp INTEGER NOT NULL
+);
+
 CREATE TABLE wallet_transactions (
 	id INTEGER PRIMARY KEY,
 	transaction_id BLOB NOT NULL,
@@ -17,6 +23,7 @@ CREATE TABLE wallet_transactions (
 	outflow BLOB NOT NULL,
 	raw_transaction BLOB NOT NULL, -- binary serialized transaction
 	source TEXT NOT NULL,
+	direction TEXT NOT NULL,
 	block_height INTEGER NOT NULL,
 	date_created INTEGER NOT NULL
 );
@@ -24,6 +31,12 @@ CREATE INDEX wallet_transactions_date_created_index ON wallet_transactions(date_
 CREATE INDEX wallet_transactions_block_id ON wallet_transactions(block_id);
 CREATE INDEX wallet_transactions_date_created ON wallet_transactions(date_created);
 CREATE INDEX wallet_transactions_block_height_id ON wallet_transactions(block_height DESC, id);
+CREATE INDEX wallet_transactions_direction_block_height_id ON wallet_transactions(direction, block_height DESC, id);
+
+CREATE TABLE wallet_transaction_labels (
+	transaction_id BLOB PRIMARY KEY, -- not a foreign key, unconfirmed transactions can be labeled
+	label TEXT NOT NULL
+);
 
 CREATE TABLE stored_sectors (
 	id INTEGER PRIMARY KEY,
@@ -91,6 +104,7 @@ CREATE TABLE contracts (
 	account_funding BLOB NOT NULL,
 	registry_read BLOB NOT NULL,
 	registry_write BLOB NOT NULL,
+	account_expiry_revenue BLOB NOT NULL,
 	risked_collateral BLOB NOT NULL,
 	confirmed_revision_number BLOB, -- stored as BLOB to support uint64_max on clearing revisions
 	host_sig BLOB NOT NULL,
@@ -98,6 +112,7 @@ CREATE TABLE contracts (
 	raw_revision BLOB NOT NULL, -- binary serialized contract revision
 	formation_confirmed BOOLEAN NOT NULL, -- true if the contract has been confirmed on the blockchain
 	resolution_height INTEGER, -- null if the storage proof/resolution has not been confirmed on the blockchain, otherwise the height of the block containing the storage proof/resolution
+	resolution_timestamp INTEGER, -- null until the contract is marked successful or failed, otherwise the time its revenue was realized
 	negotiation_height INTEGER NOT NULL, -- determines if the formation txn should be rebroadcast or if the contract should be deleted
 	window_start INTEGER NOT NULL,
 	window_end INTEGER NOT NULL,
@@ -111,6 +126,7 @@ CREATE INDEX contracts_negotiation_height ON contracts(negotiation_height);
 CREATE INDEX contracts_window_start ON contracts(window_start);
 CREATE INDEX contracts_window_end ON contracts(window_end);
 CREATE INDEX contracts_contract_status ON contracts(contract_status);
+CREATE INDEX contracts_resolution_timestamp ON contracts(resolution_timestamp);
 CREATE INDEX contracts_formation_confirmed_resolution_height_window_start ON contracts(formation_confirmed, resolution_height, window_start);
 CREATE INDEX contracts_formation_confirmed_resolution_height_window_end ON contracts(formation_confirmed, resolution_height, window_end);
 CREATE INDEX contracts_formation_confirmed_window_start ON contracts(formation_confirmed, window_start);
@@ -136,6 +152,8 @@ CREATE INDEX temp_storage_sector_roots_expiration_height ON temp_storage_sector_
 
 CREATE TABLE registry_entries (
 	registry_key BLOB PRIMARY KEY,
+	public_key BLOB, -- null for entries stored before public keys were tracked
+	tweak BLOB,
 	revision_number BLOB NOT NULL, -- stored as BLOB to support uint64_max
 	entry_data BLOB NOT NULL,
 	entry_signature BLOB NOT NULL,
@@ -143,15 +161,71 @@ CREATE TABLE registry_entries (
 	expiration_height INTEGER NOT NULL
 );
 CREATE INDEX registry_entries_expiration_height ON registry_entries(expiration_height);
+CREATE INDEX registry_entries_public_key ON registry_entries(public_key);
 
 CREATE TABLE accounts (
 	id INTEGER PRIMARY KEY,
 	account_id BLOB UNIQUE NOT NULL,
 	balance BLOB NOT NULL,
-	expiration_timestamp INTEGER NOT NULL
+	expiration_timestamp INTEGER NOT NULL,
+	frozen BOOLEAN NOT NULL DEFAULT false,
+	max_balance BLOB -- overrides the host's max account balance if not null
 );
 CREATE INDEX accounts_expiration_timestamp ON accounts(expiration_timestamp);
 
+CREATE TABLE account_debit_journal (
+	id INTEGER PRIMARY KEY,
+	account_id BLOB NOT NULL,
+	rpc TEXT NOT NULL,
+	program TEXT NOT NULL,
+	rpc_revenue BLOB NOT NULL,
+	storage_revenue BLOB NOT NULL,
+	ingress_revenue BLOB NOT NULL,
+	egress_revenue BLOB NOT NULL,
+	registry_read BLOB NOT NULL,
+	registry_write BLOB NOT NULL,
+	date_created INTEGER NOT NULL
+);
+
+CREATE TABLE account_audit_log (
+	id INTEGER PRIMARY KEY,
+	account_id BLOB NOT NULL, -- not a foreign key so entries are kept after the account is removed
+	action TEXT NOT NULL,
+	detail TEXT NOT NULL,
+	reason TEXT NOT NULL,
+	date_created INTEGER NOT NULL
+);
+CREATE INDEX account_audit_log_account_id ON account_audit_log(account_id);
+
+CREATE TABLE account_ledger (
+	id INTEGER PRIMARY KEY,
+	account_id BLOB NOT NULL, -- not a foreign key so entries are kept after the account is pruned
+	entry_type TEXT NOT NULL,
+	amount BLOB NOT NULL,
+	balance BLOB NOT NULL,
+	contract_id BLOB,
+	rpc TEXT NOT NULL,
+	program TEXT NOT NULL,
+	rpc_revenue BLOB NOT NULL,
+	storage_revenue BLOB NOT NULL,
+	ingress_revenue BLOB NOT NULL,
+	egress_revenue BLOB NOT NULL,
+	registry_read BLOB NOT NULL,
+	registry_write BLOB NOT NULL,
+	date_created INTEGER NOT NULL
+);
+CREATE INDEX account_ledger_account_id ON account_ledger(account_id);
+CREATE INDEX account_ledger_entry_type_date_created ON account_ledger(entry_type, date_created);
+
+CREATE TABLE contract_late_revenue (
+	id INTEGER PRIMARY KEY,
+	contract_id INTEGER NOT NULL REFERENCES contracts(id),
+	amount BLOB NOT NULL,
+	date_created INTEGER NOT NULL
+);
+CREATE INDEX contract_late_revenue_contract_id ON contract_late_revenue(contract_id);
+CREATE INDEX contract_late_revenue_date_created ON contract_late_revenue(date_created);
+
 CREATE TABLE contract_account_funding (
 	id INTEGER PRIMARY KEY,
 	contract_id INTEGER NOT NULL REFERENCES contracts(id),
@@ -193,9 +267,69 @@ CREATE TABLE host_settings (
 	ddns_update_v6 BOOLEAN NOT NULL,
 	ddns_opts BLOB,
 	registry_limit INTEGER NOT NULL,
+	registry_key_limit INTEGER NOT NULL DEFAULT 0,
 	sector_cache_size INTEGER NOT NULL DEFAULT 0
 );
 
+CREATE TABLE dynamic_pricing_settings (
+	id INTEGER PRIMARY KEY NOT NULL DEFAULT 0 CHECK (id = 0), -- enforce a single row
+	enabled BOOLEAN NOT NULL,
+	update_interval INTEGER NOT NULL,
+	storage_price_min BLOB NOT NULL,
+	storage_price_max BLOB NOT NULL,
+	egress_price_min BLOB NOT NULL,
+	egress_price_max BLOB NOT NULL,
+	ingress_price_min BLOB NOT NULL,
+	ingress_price_max BLOB NOT NULL,
+	contract_price_min BLOB NOT NULL,
+	contract_price_max BLOB NOT NULL,
+	target_ingress_rate INTEGER NOT NULL,
+	target_egress_rate INTEGER NOT NULL,
+	target_contracts INTEGER NOT NULL
+);
+
+CREATE TABLE dynamic_price_changes (
+	id INTEGER PRIMARY KEY,
+	storage_price BLOB NOT NULL,
+	egress_price BLOB NOT NULL,
+	ingress_price BLOB NOT NULL,
+	contract_price BLOB NOT NULL,
+	used_sectors INTEGER NOT NULL,
+	total_sectors INTEGER NOT NULL,
+	ingress_rate INTEGER NOT NULL,
+	egress_rate INTEGER NOT NULL,
+	active_contracts INTEGER NOT NULL,
+	date_created INTEGER NOT NULL
+);
+CREATE INDEX dynamic_price_changes_date_created ON dynamic_price_changes(date_created DESC);
+
+CREATE TABLE host_alerts (
+	id INTEGER PRIMARY KEY,
+	alert_id BLOB NOT NULL,
+	severity INTEGER NOT NULL,
+	message TEXT NOT NULL,
+	data TEXT, -- JSON encoded
+	first_seen INTEGER NOT NULL,
+	last_seen INTEGER NOT NULL,
+	occurrences INTEGER NOT NULL,
+	dismissed_by TEXT,
+	dismissed_at INTEGER
+);
+CREATE UNIQUE INDEX host_alerts_active_alert_id ON host_alerts(alert_id) WHERE dismissed_at IS NULL; -- only one active occurrence per alert
+CREATE INDEX host_alerts_last_seen ON host_alerts(last_seen DESC);
+CREATE INDEX host_alerts_severity_last_seen ON host_alerts(severity, last_seen DESC);
+
+CREATE TABLE alert_rules (
+	id INTEGER PRIMARY KEY,
+	rule_name TEXT NOT NULL,
+	metric TEXT NOT NULL,
+	operator TEXT NOT NULL,
+	threshold REAL NOT NULL,
+	duration INTEGER NOT NULL,
+	severity INTEGER NOT NULL,
+	enabled BOOLEAN NOT NULL
+);
+
 CREATE TABLE global_settings (
 	id INTEGER PRIMARY KEY NOT NULL DEFAULT 0 CHECK (id = 0), -- enforce a single row
 	db_version INTEGER NOT NULL, -- used for migrations
@@ -204,6 +338,7 @@ CREATE TABLE global_settings (
 	wallet_last_processed_change BLOB, -- last processed consensus change for the wallet
 	contracts_last_processed_change BLOB, -- last processed consensus change for the contract manager
 	wallet_height INTEGER, -- height of the wallet as of the last processed change
+	wallet_birth_height INTEGER, -- height of the first block relevant to the wallet
 	contracts_height INTEGER -- height of the contract manager as of the last processed change
 );
 
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/host/contracts"
 	"go.sia.tech/hostd/host/metrics"
 )
 
@@ -40,6 +41,7 @@ const (
 	metricRegistryEntries    = "registryEntries"
 	metricRegistryReads      = "registryReads"
 	metricRegistryWrites     = "registryWrites"
+	metricRegistryPruned     = "registryPruned"
 
 	// bandwidth
 	metricRHP2Ingress = "rhp2Ingress"
@@ -66,6 +68,7 @@ const (
 	metricPotentialEgressRevenue        = "potentialEgressRevenue"
 	metricPotentialRegistryReadRevenue  = "potentialRegistryReadRevenue"
 	metricPotentialRegistryWriteRevenue = "potentialRegistryWriteRevenue"
+	metricPotentialAccountExpiryRevenue = "potentialAccountExpiryRevenue"
 
 	// earned revenue
 	metricEarnedRPCRevenue           = "earnedRPCRevenue"
@@ -74,6 +77,7 @@ const (
 	metricEarnedEgressRevenue        = "earnedEgressRevenue"
 	metricEarnedRegistryReadRevenue  = "earnedRegistryReadRevenue"
 	metricEarnedRegistryWriteRevenue = "earnedRegistryWriteRevenue"
+	metricEarnedAccountExpiryRevenue = "earnedAccountExpiryRevenue"
 
 	statInterval = 5 * time.Minute
 )
@@ -227,6 +231,93 @@ JOIN (
 	return
 }
 
+// ForecastContracts returns the potential revenue and collateral of the host's
+// pending and active contracts.
+func (s *Store) ForecastContracts() (forecast []metrics.ForecastContract, err error) {
+	const query = `SELECT window_end, locked_collateral, risked_collateral, rpc_revenue, storage_revenue, ingress_revenue, egress_revenue, registry_read, registry_write, account_expiry_revenue
+FROM contracts
+WHERE contract_status IN ($1, $2)
+ORDER BY window_end ASC`
+
+	rows, err := s.query(query, contracts.ContractStatusPending, contracts.ContractStatusActive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query contracts: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var c metrics.ForecastContract
+		if err := rows.Scan(&c.WindowEnd, (*sqlCurrency)(&c.LockedCollateral), (*sqlCurrency)(&c.RiskedCollateral), (*sqlCurrency)(&c.Revenue.RPC),
+			(*sqlCurrency)(&c.Revenue.Storage), (*sqlCurrency)(&c.Revenue.Ingress), (*sqlCurrency)(&c.Revenue.Egress), (*sqlCurrency)(&c.Revenue.RegistryRead),
+			(*sqlCurrency)(&c.Revenue.RegistryWrite), (*sqlCurrency)(&c.Revenue.AccountExpiry)); err != nil {
+			return nil, fmt.Errorf("failed to scan contract: %w", err)
+		}
+		forecast = append(forecast, c)
+	}
+	return
+}
+
+// DownsampleMetrics removes stats recorded before the given time that are
+// superseded by a later value for the same stat within the same resolution
+// bucket. Only the last value of each stat in each bucket is kept, so
+// aggregates at the resolution or coarser are unchanged. Buckets are aligned
+// to UTC.
+func (s *Store) DownsampleMetrics(before time.Time, resolution time.Duration) (removed int, err error) {
+	bucket := int64(resolution / time.Second)
+	if bucket <= 0 {
+		return 0, errors.New("resolution must be at least one second")
+	}
+	// stats are stored as the value at the time of the change. Removing all
+	// but the last row in a bucket does not change the value at the end of
+	// the bucket.
+	const query = `DELETE FROM host_stats WHERE date_created < $1 AND (stat, date_created) NOT IN (
+	SELECT stat, MAX(date_created) FROM host_stats WHERE date_created < $1 GROUP BY stat, date_created / $2
+)`
+	before = time.Unix(before.Unix()-before.Unix()%bucket, 0)
+	err = s.transaction(func(tx txn) error {
+		res, err := tx.Exec(query, sqlTime(before), bucket)
+		if err != nil {
+			return fmt.Errorf("failed to downsample stats: %w", err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to get rows affected: %w", err)
+		}
+		removed = int(n)
+		return nil
+	})
+	return
+}
+
+// PruneMetrics removes stats recorded before the given time. The most recent
+// value of each stat is kept so that the current metrics are unchanged.
+func (s *Store) PruneMetrics(before time.Time) (removed int, err error) {
+	const query = `DELETE FROM host_stats WHERE date_created < $1 AND (stat, date_created) NOT IN (
+	SELECT stat, MAX(date_created) FROM host_stats WHERE date_created < $1 GROUP BY stat
+)`
+	err = s.transaction(func(tx txn) error {
+		res, err := tx.Exec(query, sqlTime(before))
+		if err != nil {
+			return fmt.Errorf("failed to prune stats: %w", err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to get rows affected: %w", err)
+		}
+		removed = int(n)
+		return nil
+	})
+	return
+}
+
+// Vacuum rebuilds the database file to reclaim space freed by removed rows.
+func (s *Store) Vacuum() error {
+	// VACUUM cannot be run inside a transaction
+	if _, err := s.exec(`VACUUM`); err != nil {
+		return fmt.Errorf("failed to vacuum database: %w", err)
+	}
+	return nil
+}
+
 // IncrementRHP2DataUsage increments the RHP2 ingress and egress metrics.
 func (s *Store) IncrementRHP2DataUsage(ingress, egress uint64) error {
 	return s.transaction(func(tx txn) error {
@@ -389,6 +480,8 @@ func mustParseMetricValue(stat string, buf []byte, m *metrics.Metrics) {
 		m.Registry.Reads = mustScanUint64(buf)
 	case metricRegistryWrites:
 		m.Registry.Writes = mustScanUint64(buf)
+	case metricRegistryPruned:
+		m.Registry.Pruned = mustScanUint64(buf)
 	// bandwidth
 	case metricRHP2Ingress:
 		m.Data.RHP2.Ingress = mustScanUint64(buf)
@@ -411,6 +504,8 @@ func mustParseMetricValue(stat string, buf []byte, m *metrics.Metrics) {
 		m.Revenue.Potential.RegistryRead = mustScanCurrency(buf)
 	case metricPotentialRegistryWriteRevenue:
 		m.Revenue.Potential.RegistryWrite = mustScanCurrency(buf)
+	case metricPotentialAccountExpiryRevenue:
+		m.Revenue.Potential.AccountExpiry = mustScanCurrency(buf)
 	// earnedRevenue
 	case metricEarnedRPCRevenue:
 		m.Revenue.Earned.RPC = mustScanCurrency(buf)
@@ -424,6 +519,8 @@ func mustParseMetricValue(stat string, buf []byte, m *metrics.Metrics) {
 		m.Revenue.Earned.RegistryRead = mustScanCurrency(buf)
 	case metricEarnedRegistryWriteRevenue:
 		m.Revenue.Earned.RegistryWrite = mustScanCurrency(buf)
+	case metricEarnedAccountExpiryRevenue:
+		m.Revenue.Earned.AccountExpiry = mustScanCurrency(buf)
 	// wallet
 	case metricWalletBalance:
 		m.Balance = mustScanCurrency(buf)
@@ -0,0 +1,87 @@
+package sqlite
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/host/metrics"
+	"go.uber.org/zap/zaptest"
+	"lukechampine.com/frand"
+)
+
+func TestDownsampleMetrics(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	db, err := OpenDatabase(filepath.Join(t.TempDir(), "hostdb.db"), log.Named("sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// record three days of stats at the raw resolution
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(72 * time.Hour)
+	for ts := start; ts.Before(end); ts = ts.Add(statInterval) {
+		err := db.transaction(func(tx txn) error {
+			if err := incrementNumericStat(tx, metricRegistryReads, 1+frand.Intn(10), ts); err != nil {
+				return err
+			}
+			return incrementCurrencyStat(tx, metricPotentialRPCRevenue, types.NewCurrency64(1+frand.Uint64n(1000)), false, ts)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	periodMetrics := func(interval metrics.Interval, n int) []metrics.Metrics {
+		t.Helper()
+		m, err := db.PeriodMetrics(start, n, interval)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+
+	hourly, daily := periodMetrics(metrics.IntervalHourly, 72), periodMetrics(metrics.IntervalDaily, 3)
+	current, err := db.Metrics(end)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// roll up the first two days to hourly data
+	removed, err := db.DownsampleMetrics(start.Add(48*time.Hour), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	} else if exp := 2 * 48 * 11; removed != exp {
+		t.Fatalf("expected %v rows removed, got %v", exp, removed)
+	} else if m := periodMetrics(metrics.IntervalHourly, 72); !reflect.DeepEqual(m, hourly) {
+		t.Fatal("hourly metrics changed after downsampling")
+	} else if m := periodMetrics(metrics.IntervalDaily, 3); !reflect.DeepEqual(m, daily) {
+		t.Fatal("daily metrics changed after downsampling")
+	}
+
+	// roll up the first day to daily data
+	removed, err = db.DownsampleMetrics(start.Add(24*time.Hour), 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	} else if exp := 2 * 23; removed != exp {
+		t.Fatalf("expected %v rows removed, got %v", exp, removed)
+	} else if m := periodMetrics(metrics.IntervalDaily, 3); !reflect.DeepEqual(m, daily) {
+		t.Fatal("daily metrics changed after downsampling")
+	}
+
+	// prune everything; the latest value of each stat must be kept
+	if _, err := db.PruneMetrics(end.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	} else if m, err := db.Metrics(end); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(m, current) {
+		t.Fatal("current metrics changed after pruning")
+	}
+
+	if err := db.Vacuum(); err != nil {
+		t.Fatal(err)
+	}
+}
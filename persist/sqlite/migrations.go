@@ -2,13 +2,377 @@ package sqlite
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
 	"go.sia.tech/core/types"
 	"go.sia.tech/hostd/host/contracts"
+	"go.sia.tech/hostd/wallet"
 )
 
+// migrateVersion36 adds a wallet_birth_height column to the global_settings
+// table and initializes it from the wallet's existing transactions.
+func migrateVersion36(tx txn) error {
+	if _, err := tx.Exec(`ALTER TABLE global_settings ADD COLUMN wallet_birth_height INTEGER`); err != nil {
+		return fmt.Errorf("failed to add wallet birth height column: %w", err)
+	} else if _, err := tx.Exec(`UPDATE global_settings SET wallet_birth_height=(SELECT MIN(block_height) FROM wallet_transactions)`); err != nil {
+		return fmt.Errorf("failed to initialize wallet birth height: %w", err)
+	}
+	return nil
+}
+
+// migrateVersion35 adds a direction column to the wallet_transactions table so
+// transactions can be filtered by direction without decoding their amounts.
+func migrateVersion35(tx txn) error {
+	const query = `ALTER TABLE wallet_transactions ADD COLUMN direction TEXT NOT NULL DEFAULT '';
+CREATE INDEX wallet_transactions_direction_block_height_id ON wallet_transactions(direction, block_height DESC, id);`
+	if _, err := tx.Exec(query); err != nil {
+		return fmt.Errorf("failed to add direction column: %w", err)
+	}
+
+	rows, err := tx.Query(`SELECT id, inflow, outflow FROM wallet_transactions`)
+	if err != nil {
+		return fmt.Errorf("failed to query wallet transactions: %w", err)
+	}
+	defer rows.Close()
+
+	directions := make(map[int64]wallet.TransactionDirection)
+	for rows.Next() {
+		var id int64
+		var txn wallet.Transaction
+		if err := rows.Scan(&id, (*sqlCurrency)(&txn.Inflow), (*sqlCurrency)(&txn.Outflow)); err != nil {
+			return fmt.Errorf("failed to scan wallet transaction: %w", err)
+		}
+		directions[id] = txn.Direction()
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate wallet transactions: %w", err)
+	} else if err := rows.Close(); err != nil {
+		return fmt.Errorf("failed to close rows: %w", err)
+	}
+
+	stmt, err := tx.Prepare(`UPDATE wallet_transactions SET direction=$1 WHERE id=$2`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for id, direction := range directions {
+		if _, err := stmt.Exec(direction, id); err != nil {
+			return fmt.Errorf("failed to update wallet transaction %d: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// migrateVersion34 adds the contract_late_revenue table to report account
+// funding spent after a contract resolved. It also estimates the resolution
+// timestamp of contracts that resolved before the timestamp was recorded from
+// their resolution height so they are included in revenue reports.
+func migrateVersion34(tx txn) error {
+	const query = `CREATE TABLE contract_late_revenue (
+	id INTEGER PRIMARY KEY,
+	contract_id INTEGER NOT NULL REFERENCES contracts(id),
+	amount BLOB NOT NULL,
+	date_created INTEGER NOT NULL
+);
+CREATE INDEX contract_late_revenue_contract_id ON contract_late_revenue(contract_id);
+CREATE INDEX contract_late_revenue_date_created ON contract_late_revenue(date_created);`
+	if _, err := tx.Exec(query); err != nil {
+		return fmt.Errorf("failed to create late revenue table: %w", err)
+	}
+
+	// the wallet's most recent transaction is used to estimate the time of
+	// each height. If the wallet has no transactions, the contract
+	// manager's height is assumed to be the current time.
+	const blockInterval = 10 * time.Minute
+	var anchorHeight uint64
+	var anchorTime time.Time
+	err := tx.QueryRow(`SELECT block_height, date_created FROM wallet_transactions ORDER BY block_height DESC LIMIT 1`).Scan(&anchorHeight, (*sqlTime)(&anchorTime))
+	if errors.Is(err, sql.ErrNoRows) {
+		anchorTime = time.Now()
+		err = tx.QueryRow(`SELECT COALESCE(contracts_height, 0) FROM global_settings`).Scan(&anchorHeight)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get anchor height: %w", err)
+	}
+
+	// failed contracts may not have a resolution height, use the end of
+	// the proof window instead
+	rows, err := tx.Query(`SELECT id, COALESCE(resolution_height, window_end) FROM contracts WHERE resolution_timestamp IS NULL AND contract_status IN ($1, $2)`, contracts.ContractStatusSuccessful, contracts.ContractStatusFailed)
+	if err != nil {
+		return fmt.Errorf("failed to query resolved contracts: %w", err)
+	}
+	defer rows.Close()
+
+	heights := make(map[int64]uint64)
+	for rows.Next() {
+		var id int64
+		var height uint64
+		if err := rows.Scan(&id, &height); err != nil {
+			return fmt.Errorf("failed to scan contract: %w", err)
+		}
+		heights[id] = height
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate contracts: %w", err)
+	} else if err := rows.Close(); err != nil {
+		return fmt.Errorf("failed to close rows: %w", err)
+	}
+
+	stmt, err := tx.Prepare(`UPDATE contracts SET resolution_timestamp=$1 WHERE id=$2`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for id, height := range heights {
+		timestamp := anchorTime.Add(time.Duration(int64(height)-int64(anchorHeight)) * blockInterval)
+		if _, err := stmt.Exec(sqlTime(timestamp), id); err != nil {
+			return fmt.Errorf("failed to set resolution timestamp of contract %d: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// migrateVersion33 converts registry expiration heights from little-endian
+// blobs to integers so the expiration index can be used when pruning.
+func migrateVersion33(tx txn) error {
+	rows, err := tx.Query(`SELECT registry_key, expiration_height FROM registry_entries WHERE typeof(expiration_height)='blob'`)
+	if err != nil {
+		return fmt.Errorf("failed to query registry entries: %w", err)
+	}
+	defer rows.Close()
+
+	heights := make(map[types.Hash256]uint64)
+	for rows.Next() {
+		var key types.Hash256
+		var height uint64
+		if err := rows.Scan((*sqlHash256)(&key), (*sqlUint64)(&height)); err != nil {
+			return fmt.Errorf("failed to scan registry entry: %w", err)
+		}
+		heights[key] = height
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate registry entries: %w", err)
+	} else if err := rows.Close(); err != nil {
+		return fmt.Errorf("failed to close rows: %w", err)
+	}
+
+	stmt, err := tx.Prepare(`UPDATE registry_entries SET expiration_height=$1 WHERE registry_key=$2`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for key, height := range heights {
+		if _, err := stmt.Exec(height, sqlHash256(key)); err != nil {
+			return fmt.Errorf("failed to update registry entry %v: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// migrateVersion32 adds the wallet_transaction_labels table to store
+// user-defined transaction labels.
+func migrateVersion32(tx txn) error {
+	const query = `CREATE TABLE wallet_transaction_labels (
+	transaction_id BLOB PRIMARY KEY,
+	label TEXT NOT NULL
+);`
+	_, err := tx.Exec(query)
+	return err
+}
+
+// migrateVersion31 adds the wallet_locked_utxos table to persist the
+// wallet's output locks across restarts.
+func migrateVersion31(tx txn) error {
+	const query = `CREATE TABLE wallet_locked_utxos (
+	id BLOB PRIMARY KEY REFERENCES wallet_utxos(id) ON DELETE CASCADE,
+	reason TEXT NOT NULL,
+	expiration_timestamp INTEGER NOT NULL
+);`
+	_, err := tx.Exec(query)
+	return err
+}
+
+// migrateVersion30 adds the alert_rules table to persist user-defined alert
+// rules.
+func migrateVersion30(tx txn) error {
+	const query = `CREATE TABLE alert_rules (
+	id INTEGER PRIMARY KEY,
+	rule_name TEXT NOT NULL,
+	metric TEXT NOT NULL,
+	operator TEXT NOT NULL,
+	threshold REAL NOT NULL,
+	duration INTEGER NOT NULL,
+	severity INTEGER NOT NULL,
+	enabled BOOLEAN NOT NULL
+);`
+	_, err := tx.Exec(query)
+	return err
+}
+
+// migrateVersion29 adds the host_alerts table to persist alerts and their
+// history.
+func migrateVersion29(tx txn) error {
+	const query = `CREATE TABLE host_alerts (
+	id INTEGER PRIMARY KEY,
+	alert_id BLOB NOT NULL,
+	severity INTEGER NOT NULL,
+	message TEXT NOT NULL,
+	data TEXT, -- JSON encoded
+	first_seen INTEGER NOT NULL,
+	last_seen INTEGER NOT NULL,
+	occurrences INTEGER NOT NULL,
+	dismissed_by TEXT,
+	dismissed_at INTEGER
+);
+CREATE UNIQUE INDEX host_alerts_active_alert_id ON host_alerts(alert_id) WHERE dismissed_at IS NULL; -- only one active occurrence per alert
+CREATE INDEX host_alerts_last_seen ON host_alerts(last_seen DESC);
+CREATE INDEX host_alerts_severity_last_seen ON host_alerts(severity, last_seen DESC);`
+	_, err := tx.Exec(query)
+	return err
+}
+
+// migrateVersion28 adds the time a contract's revenue was realized and an
+// index for querying ledger entries by type. The column is null for contracts
+// that resolved before the migration.
+func migrateVersion28(tx txn) error {
+	const query = `ALTER TABLE contracts ADD COLUMN resolution_timestamp INTEGER;
+CREATE INDEX contracts_resolution_timestamp ON contracts(resolution_timestamp);
+CREATE INDEX account_ledger_entry_type_date_created ON account_ledger(entry_type, date_created);`
+	_, err := tx.Exec(query)
+	return err
+}
+
+// migrateVersion27 adds the per-key registry entry limit to the host settings.
+func migrateVersion27(tx txn) error {
+	const query = `ALTER TABLE host_settings ADD COLUMN registry_key_limit INTEGER NOT NULL DEFAULT 0;`
+	_, err := tx.Exec(query)
+	return err
+}
+
+// migrateVersion26 adds the public key and tweak to registry entries. The
+// columns are null for existing entries since registry keys are hashed.
+func migrateVersion26(tx txn) error {
+	const query = `ALTER TABLE registry_entries ADD COLUMN public_key BLOB;
+ALTER TABLE registry_entries ADD COLUMN tweak BLOB;
+CREATE INDEX registry_entries_public_key ON registry_entries(public_key);`
+	_, err := tx.Exec(query)
+	return err
+}
+
+// migrateVersion25 adds the account debit journal table
+func migrateVersion25(tx txn) error {
+	const query = `CREATE TABLE account_debit_journal (
+	id INTEGER PRIMARY KEY,
+	account_id BLOB NOT NULL,
+	rpc TEXT NOT NULL,
+	program TEXT NOT NULL,
+	rpc_revenue BLOB NOT NULL,
+	storage_revenue BLOB NOT NULL,
+	ingress_revenue BLOB NOT NULL,
+	egress_revenue BLOB NOT NULL,
+	registry_read BLOB NOT NULL,
+	registry_write BLOB NOT NULL,
+	date_created INTEGER NOT NULL
+);`
+	_, err := tx.Exec(query)
+	return err
+}
+
+// migrateVersion24 adds the account expiry revenue column to the contracts
+// table
+func migrateVersion24(tx txn) error {
+	const query = `ALTER TABLE contracts ADD COLUMN account_expiry_revenue BLOB NOT NULL DEFAULT X'00000000000000000000000000000000';`
+	_, err := tx.Exec(query)
+	return err
+}
+
+// migrateVersion23 adds the account admin columns and the audit log table
+func migrateVersion23(tx txn) error {
+	const query = `
+ALTER TABLE accounts ADD COLUMN frozen BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE accounts ADD COLUMN max_balance BLOB;
+
+CREATE TABLE account_audit_log (
+	id INTEGER PRIMARY KEY,
+	account_id BLOB NOT NULL,
+	action TEXT NOT NULL,
+	detail TEXT NOT NULL,
+	reason TEXT NOT NULL,
+	date_created INTEGER NOT NULL
+);
+CREATE INDEX account_audit_log_account_id ON account_audit_log(account_id);`
+	_, err := tx.Exec(query)
+	return err
+}
+
+// migrateVersion22 adds the account ledger table
+func migrateVersion22(tx txn) error {
+	const query = `
+CREATE TABLE account_ledger (
+	id INTEGER PRIMARY KEY,
+	account_id BLOB NOT NULL,
+	entry_type TEXT NOT NULL,
+	amount BLOB NOT NULL,
+	balance BLOB NOT NULL,
+	contract_id BLOB,
+	rpc TEXT NOT NULL,
+	program TEXT NOT NULL,
+	rpc_revenue BLOB NOT NULL,
+	storage_revenue BLOB NOT NULL,
+	ingress_revenue BLOB NOT NULL,
+	egress_revenue BLOB NOT NULL,
+	registry_read BLOB NOT NULL,
+	registry_write BLOB NOT NULL,
+	date_created INTEGER NOT NULL
+);
+CREATE INDEX account_ledger_account_id ON account_ledger(account_id);`
+	_, err := tx.Exec(query)
+	return err
+}
+
+// migrateVersion21 adds the dynamic pricing tables
+func migrateVersion21(tx txn) error {
+	const query = `
+CREATE TABLE dynamic_pricing_settings (
+	id INTEGER PRIMARY KEY NOT NULL DEFAULT 0 CHECK (id = 0), -- enforce a single row
+	enabled BOOLEAN NOT NULL,
+	update_interval INTEGER NOT NULL,
+	storage_price_min BLOB NOT NULL,
+	storage_price_max BLOB NOT NULL,
+	egress_price_min BLOB NOT NULL,
+	egress_price_max BLOB NOT NULL,
+	ingress_price_min BLOB NOT NULL,
+	ingress_price_max BLOB NOT NULL,
+	contract_price_min BLOB NOT NULL,
+	contract_price_max BLOB NOT NULL,
+	target_ingress_rate INTEGER NOT NULL,
+	target_egress_rate INTEGER NOT NULL,
+	target_contracts INTEGER NOT NULL
+);
+
+CREATE TABLE dynamic_price_changes (
+	id INTEGER PRIMARY KEY,
+	storage_price BLOB NOT NULL,
+	egress_price BLOB NOT NULL,
+	ingress_price BLOB NOT NULL,
+	contract_price BLOB NOT NULL,
+	used_sectors INTEGER NOT NULL,
+	total_sectors INTEGER NOT NULL,
+	ingress_rate INTEGER NOT NULL,
+	egress_rate INTEGER NOT NULL,
+	active_contracts INTEGER NOT NULL,
+	date_created INTEGER NOT NULL
+);
+CREATE INDEX dynamic_price_changes_date_created ON dynamic_price_changes(date_created DESC);`
+	_, err := tx.Exec(query)
+	return err
+}
+
 // migrateVersion20 adds a compound index to the volume_sectors table
 func migrateVersion20(tx txn) error {
 	_, err := tx.Exec(`CREATE INDEX volume_sectors_volume_id_sector_id_volume_index_set_compound ON volume_sectors (volume_id, sector_id, volume_index) WHERE sector_id IS NOT NULL;`)
@@ -512,4 +876,20 @@ var migrations = []func(tx txn) error{
 	migrateVersion18,
 	migrateVersion19,
 	migrateVersion20,
+	migrateVersion21,
+	migrateVersion22,
+	migrateVersion23,
+	migrateVersion24,
+	migrateVersion25,
+	migrateVersion26,
+	migrateVersion27,
+	migrateVersion28,
+	migrateVersion29,
+	migrateVersion30,
+	migrateVersion31,
+	migrateVersion32,
+	migrateVersion33,
+	migrateVersion34,
+	migrateVersion35,
+	migrateVersion36,
 }
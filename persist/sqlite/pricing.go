@@ -0,0 +1,90 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"go.sia.tech/hostd/host/pricing"
+)
+
+// PricingConfig returns the pricing engine's configuration.
+func (s *Store) PricingConfig() (config pricing.Config, err error) {
+	const query = `SELECT enabled, update_interval, storage_price_min, storage_price_max,
+	egress_price_min, egress_price_max, ingress_price_min, ingress_price_max,
+	contract_price_min, contract_price_max, target_ingress_rate, target_egress_rate, target_contracts
+FROM dynamic_pricing_settings;`
+	err = s.queryRow(query).Scan(&config.Enabled, &config.UpdateInterval,
+		(*sqlCurrency)(&config.StoragePrice.Min), (*sqlCurrency)(&config.StoragePrice.Max),
+		(*sqlCurrency)(&config.EgressPrice.Min), (*sqlCurrency)(&config.EgressPrice.Max),
+		(*sqlCurrency)(&config.IngressPrice.Min), (*sqlCurrency)(&config.IngressPrice.Max),
+		(*sqlCurrency)(&config.ContractPrice.Min), (*sqlCurrency)(&config.ContractPrice.Max),
+		&config.TargetIngressRate, &config.TargetEgressRate, &config.TargetContracts)
+	if errors.Is(err, sql.ErrNoRows) {
+		return pricing.Config{}, pricing.ErrNoConfig
+	}
+	return
+}
+
+// UpdatePricingConfig updates the pricing engine's configuration.
+func (s *Store) UpdatePricingConfig(config pricing.Config) error {
+	const query = `INSERT INTO dynamic_pricing_settings (id, enabled, update_interval, storage_price_min, storage_price_max,
+	egress_price_min, egress_price_max, ingress_price_min, ingress_price_max,
+	contract_price_min, contract_price_max, target_ingress_rate, target_egress_rate, target_contracts)
+	VALUES (0, $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+ON CONFLICT (id) DO UPDATE SET (enabled, update_interval, storage_price_min, storage_price_max,
+	egress_price_min, egress_price_max, ingress_price_min, ingress_price_max,
+	contract_price_min, contract_price_max, target_ingress_rate, target_egress_rate, target_contracts) = (
+	EXCLUDED.enabled, EXCLUDED.update_interval, EXCLUDED.storage_price_min, EXCLUDED.storage_price_max,
+	EXCLUDED.egress_price_min, EXCLUDED.egress_price_max, EXCLUDED.ingress_price_min, EXCLUDED.ingress_price_max,
+	EXCLUDED.contract_price_min, EXCLUDED.contract_price_max, EXCLUDED.target_ingress_rate, EXCLUDED.target_egress_rate,
+	EXCLUDED.target_contracts);`
+	_, err := s.exec(query, config.Enabled, config.UpdateInterval,
+		sqlCurrency(config.StoragePrice.Min), sqlCurrency(config.StoragePrice.Max),
+		sqlCurrency(config.EgressPrice.Min), sqlCurrency(config.EgressPrice.Max),
+		sqlCurrency(config.IngressPrice.Min), sqlCurrency(config.IngressPrice.Max),
+		sqlCurrency(config.ContractPrice.Min), sqlCurrency(config.ContractPrice.Max),
+		config.TargetIngressRate, config.TargetEgressRate, config.TargetContracts)
+	if err != nil {
+		return fmt.Errorf("failed to update pricing config: %w", err)
+	}
+	return nil
+}
+
+// AddPriceChange records a price adjustment made by the pricing engine.
+func (s *Store) AddPriceChange(change pricing.PriceChange) error {
+	const query = `INSERT INTO dynamic_price_changes (storage_price, egress_price, ingress_price, contract_price,
+	used_sectors, total_sectors, ingress_rate, egress_rate, active_contracts, date_created)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10);`
+	_, err := s.exec(query, sqlCurrency(change.Prices.StoragePrice), sqlCurrency(change.Prices.EgressPrice),
+		sqlCurrency(change.Prices.IngressPrice), sqlCurrency(change.Prices.ContractPrice),
+		change.Inputs.UsedSectors, change.Inputs.TotalSectors, change.Inputs.IngressRate,
+		change.Inputs.EgressRate, change.Inputs.ActiveContracts, sqlTime(change.Timestamp))
+	return err
+}
+
+// PriceChanges returns the price adjustments made by the pricing engine,
+// ordered from newest to oldest.
+func (s *Store) PriceChanges(limit, offset int) (changes []pricing.PriceChange, err error) {
+	const query = `SELECT storage_price, egress_price, ingress_price, contract_price,
+	used_sectors, total_sectors, ingress_rate, egress_rate, active_contracts, date_created
+FROM dynamic_price_changes ORDER BY date_created DESC, id DESC LIMIT $1 OFFSET $2;`
+	rows, err := s.query(query, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query price changes: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var change pricing.PriceChange
+		err := rows.Scan((*sqlCurrency)(&change.Prices.StoragePrice), (*sqlCurrency)(&change.Prices.EgressPrice),
+			(*sqlCurrency)(&change.Prices.IngressPrice), (*sqlCurrency)(&change.Prices.ContractPrice),
+			&change.Inputs.UsedSectors, &change.Inputs.TotalSectors, &change.Inputs.IngressRate,
+			&change.Inputs.EgressRate, &change.Inputs.ActiveContracts, (*sqlTime)(&change.Timestamp))
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan price change: %w", err)
+		}
+		changes = append(changes, change)
+	}
+	return
+}
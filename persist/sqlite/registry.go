@@ -7,6 +7,7 @@ import (
 	"time"
 
 	rhp3 "go.sia.tech/core/rhp/v3"
+	"go.sia.tech/core/types"
 	"go.sia.tech/hostd/host/registry"
 )
 
@@ -31,8 +32,8 @@ func (s *Store) GetRegistryValue(key rhp3.RegistryKey) (entry rhp3.RegistryValue
 func (s *Store) SetRegistryValue(entry rhp3.RegistryEntry, expiration uint64) error {
 	const (
 		selectQuery = `SELECT registry_key FROM registry_entries re WHERE re.registry_key=$1`
-		insertQuery = `INSERT INTO registry_entries (registry_key, revision_number, entry_type, entry_signature, entry_data, expiration_height) VALUES ($1, $2, $3, $4, $5, $6) RETURNING registry_key`
-		updateQuery = `UPDATE registry_entries SET (registry_key, revision_number, entry_type, entry_signature, entry_data, expiration_height) = ($1, $2, $3, $4, $5, $6) WHERE registry_key=$1 RETURNING registry_key`
+		insertQuery = `INSERT INTO registry_entries (registry_key, public_key, tweak, revision_number, entry_type, entry_signature, entry_data, expiration_height) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING registry_key`
+		updateQuery = `UPDATE registry_entries SET (registry_key, public_key, tweak, revision_number, entry_type, entry_signature, entry_data, expiration_height) = ($1, $2, $3, $4, $5, $6, $7, $8) WHERE registry_key=$1 RETURNING registry_key`
 	)
 	// note: need to error when the registry is full, so can't use upsert
 	registryKey := entry.RegistryKey.Hash()
@@ -46,7 +47,13 @@ func (s *Store) SetRegistryValue(entry rhp3.RegistryEntry, expiration uint64) er
 			} else if count >= max {
 				return registry.ErrNotEnoughSpace
 			}
-			err = tx.QueryRow(insertQuery, sqlHash256(registryKey), sqlUint64(entry.Revision), entry.Type, sqlHash512(entry.Signature), entry.Data, sqlUint64(expiration)).Scan((*sqlHash256)(&registryKey))
+			keyCount, keyMax, err := registryKeyLimits(tx, entry.PublicKey)
+			if err != nil {
+				return fmt.Errorf("failed to get registry key limits: %w", err)
+			} else if keyMax > 0 && keyCount >= keyMax {
+				return registry.ErrNotEnoughSpace
+			}
+			err = tx.QueryRow(insertQuery, sqlHash256(registryKey), sqlHash256(entry.PublicKey), sqlHash256(entry.Tweak), sqlUint64(entry.Revision), entry.Type, sqlHash512(entry.Signature), entry.Data, expiration).Scan((*sqlHash256)(&registryKey))
 			if err != nil {
 				return fmt.Errorf("failed to insert registry entry: %w", err)
 			} else if err := incrementNumericStat(tx, metricRegistryEntries, 1, time.Now()); err != nil {
@@ -56,7 +63,7 @@ func (s *Store) SetRegistryValue(entry rhp3.RegistryEntry, expiration uint64) er
 			return fmt.Errorf("failed to get registry entry: %w", err)
 		}
 		// key exists, update it
-		return tx.QueryRow(updateQuery, sqlHash256(registryKey), sqlUint64(entry.Revision), entry.Type, sqlHash512(entry.Signature), entry.Data, sqlUint64(expiration)).Scan((*sqlHash256)(&registryKey))
+		return tx.QueryRow(updateQuery, sqlHash256(registryKey), sqlHash256(entry.PublicKey), sqlHash256(entry.Tweak), sqlUint64(entry.Revision), entry.Type, sqlHash512(entry.Signature), entry.Data, expiration).Scan((*sqlHash256)(&registryKey))
 	})
 }
 
@@ -66,6 +73,178 @@ func (s *Store) RegistryEntries() (count, max uint64, err error) {
 	return registryLimits(&dbTxn{s})
 }
 
+// RegistryEntryList returns the registry entries matching the filter and the
+// total number of matching entries.
+func (s *Store) RegistryEntryList(filter registry.EntryFilter) (entries []registry.Entry, count int, err error) {
+	if filter.Limit <= 0 || filter.Limit > 1000 {
+		filter.Limit = 1000
+	}
+
+	var whereClause string
+	var whereParams []any
+	if filter.PublicKey != nil {
+		whereClause = `WHERE public_key=?`
+		whereParams = append(whereParams, sqlHash256(*filter.PublicKey))
+	}
+
+	if err := s.queryRow(`SELECT COUNT(*) FROM registry_entries `+whereClause, whereParams...).Scan(&count); err != nil {
+		return nil, 0, fmt.Errorf("failed to query entry count: %w", err)
+	}
+
+	query := fmt.Sprintf(`SELECT registry_key, public_key, tweak, revision_number, entry_type, entry_data, entry_signature, expiration_height FROM registry_entries %s %s LIMIT ? OFFSET ?`, whereClause, buildRegistryOrderBy(filter))
+	rows, err := s.query(query, append(whereParams, filter.Limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query entries: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		entry, err := scanRegistryEntry(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	return
+}
+
+// RegistryEntry returns the registry entry with the given key.
+func (s *Store) RegistryEntry(key types.Hash256) (registry.Entry, error) {
+	const query = `SELECT registry_key, public_key, tweak, revision_number, entry_type, entry_data, entry_signature, expiration_height FROM registry_entries WHERE registry_key=$1`
+	entry, err := scanRegistryEntry(s.queryRow(query, sqlHash256(key)))
+	if errors.Is(err, sql.ErrNoRows) {
+		return registry.Entry{}, registry.ErrEntryNotFound
+	} else if err != nil {
+		return registry.Entry{}, fmt.Errorf("failed to get registry entry: %w", err)
+	}
+	return entry, nil
+}
+
+// DeleteRegistryEntry removes the registry entry with the given key.
+func (s *Store) DeleteRegistryEntry(key types.Hash256) error {
+	return s.transaction(func(tx txn) error {
+		res, err := tx.Exec(`DELETE FROM registry_entries WHERE registry_key=$1`, sqlHash256(key))
+		if err != nil {
+			return fmt.Errorf("failed to delete registry entry: %w", err)
+		} else if n, err := res.RowsAffected(); err != nil {
+			return fmt.Errorf("failed to get rows affected: %w", err)
+		} else if n == 0 {
+			return registry.ErrEntryNotFound
+		} else if err := incrementNumericStat(tx, metricRegistryEntries, -1, time.Now()); err != nil {
+			return fmt.Errorf("failed to track registry entries: %w", err)
+		}
+		return nil
+	})
+}
+
+// DeleteRegistryEntries removes all registry entries owned by a public key
+// and returns the number of entries removed. Entries stored before the owner
+// was tracked have no public key and are reported by RegistryKeyUsage as
+// owned by the zero key, so deleting the zero key also removes them.
+func (s *Store) DeleteRegistryEntries(publicKey types.PublicKey) (deleted int, err error) {
+	query := `DELETE FROM registry_entries WHERE public_key=$1`
+	if publicKey == (types.PublicKey{}) {
+		query += ` OR public_key IS NULL`
+	}
+	err = s.transaction(func(tx txn) error {
+		res, err := tx.Exec(query, sqlHash256(publicKey))
+		if err != nil {
+			return fmt.Errorf("failed to delete registry entries: %w", err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to get rows affected: %w", err)
+		} else if err := incrementNumericStat(tx, metricRegistryEntries, -int(n), time.Now()); err != nil {
+			return fmt.Errorf("failed to track registry entries: %w", err)
+		}
+		deleted = int(n)
+		return nil
+	})
+	return
+}
+
+// RegistryKeyUsage returns the number of registry entries owned by each
+// public key, ordered by the number of entries descending.
+func (s *Store) RegistryKeyUsage(limit, offset int) (usage []registry.KeyUsage, err error) {
+	const query = `SELECT public_key, COUNT(*) AS entries FROM registry_entries GROUP BY public_key ORDER BY entries DESC, public_key ASC LIMIT $1 OFFSET $2`
+	rows, err := s.query(query, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query key usage: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var ku registry.KeyUsage
+		if err := rows.Scan(nullable((*sqlHash256)(&ku.PublicKey)), &ku.Entries); err != nil {
+			return nil, fmt.Errorf("failed to scan key usage: %w", err)
+		}
+		usage = append(usage, ku)
+	}
+	return
+}
+
+// PruneRegistryEntries removes all registry entries that expire at or before
+// the given height and returns the number of entries removed.
+func (s *Store) PruneRegistryEntries(height uint64) (pruned int, err error) {
+	err = s.transaction(func(tx txn) error {
+		res, err := tx.Exec(`DELETE FROM registry_entries WHERE expiration_height<=$1`, height)
+		if err != nil {
+			return fmt.Errorf("failed to delete expired registry entries: %w", err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to get rows affected: %w", err)
+		} else if n == 0 {
+			return nil
+		}
+
+		pruned = int(n)
+		now := time.Now()
+		if err := incrementNumericStat(tx, metricRegistryEntries, -pruned, now); err != nil {
+			return fmt.Errorf("failed to track registry entries: %w", err)
+		} else if err := incrementNumericStat(tx, metricRegistryPruned, pruned, now); err != nil {
+			return fmt.Errorf("failed to track pruned registry entries: %w", err)
+		}
+		return nil
+	})
+	return
+}
+
+func buildRegistryOrderBy(filter registry.EntryFilter) string {
+	dir := "ASC"
+	if filter.SortDesc {
+		dir = "DESC"
+	}
+	switch filter.SortField {
+	case registry.EntrySortExpiration:
+		return `ORDER BY expiration_height ` + dir + `, registry_key ASC`
+	case registry.EntrySortRevision:
+		return `ORDER BY ` + littleEndianOrderExpr("revision_number", 8) + ` ` + dir + `, registry_key ASC`
+	default:
+		return `ORDER BY registry_key ` + dir
+	}
+}
+
+func scanRegistryEntry(row scanner) (entry registry.Entry, err error) {
+	err = row.Scan((*sqlHash256)(&entry.Key), nullable((*sqlHash256)(&entry.PublicKey)), nullable((*sqlHash256)(&entry.Tweak)), (*sqlUint64)(&entry.Revision),
+		&entry.Type, &entry.Data, (*sqlHash512)(&entry.Signature), &entry.ExpirationHeight)
+	return
+}
+
+// registryKeyLimits returns the number of registry entries stored for a
+// public key and the maximum number of entries a single key may store. A
+// maximum of zero means the number of entries per key is not limited.
+func registryKeyLimits(tx txn, publicKey types.PublicKey) (count, max uint64, err error) {
+	err = tx.QueryRow(`SELECT COALESCE(registry_key_limit, 0) FROM host_settings`).Scan(&max)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, 0, nil
+	} else if err != nil || max == 0 {
+		return
+	}
+	err = tx.QueryRow(`SELECT COUNT(*) FROM registry_entries WHERE public_key=$1`, sqlHash256(publicKey)).Scan(&count)
+	return
+}
+
 func registryLimits(tx txn) (count, max uint64, err error) {
 	err = tx.QueryRow(`SELECT COALESCE(COUNT(re.registry_key), 0), COALESCE(hs.registry_limit, 0) FROM host_settings hs LEFT JOIN registry_entries re ON (true);`).Scan(&count, &max)
 	return
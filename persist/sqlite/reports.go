@@ -0,0 +1,139 @@
+package sqlite
+
+import (
+	"fmt"
+	"time"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/host/accounts"
+	"go.sia.tech/hostd/host/contracts"
+	"go.sia.tech/hostd/host/reports"
+	"go.sia.tech/hostd/wallet"
+)
+
+// ResolvedContracts returns the contracts that were marked successful or
+// failed in the range [from, to). The resolution timestamp of contracts that
+// resolved before it was recorded is estimated from the resolution height.
+func (s *Store) ResolvedContracts(from, to time.Time) (resolved []reports.ResolvedContract, err error) {
+	const query = `SELECT contract_id, contract_status, revision_number=confirmed_revision_number AS revision_confirmed, rpc_revenue, storage_revenue, ingress_revenue, egress_revenue, registry_read, registry_write, account_expiry_revenue, account_funding, risked_collateral, resolution_timestamp
+FROM contracts
+WHERE resolution_timestamp >= $1 AND resolution_timestamp < $2 AND contract_status IN ($3, $4)
+ORDER BY resolution_timestamp ASC, id ASC`
+
+	rows, err := s.query(query, sqlTime(from), sqlTime(to), contracts.ContractStatusSuccessful, contracts.ContractStatusFailed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query resolved contracts: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c reports.ResolvedContract
+		if err := rows.Scan((*sqlHash256)(&c.ID), &c.Status, &c.RevisionConfirmed, (*sqlCurrency)(&c.Usage.RPCRevenue), (*sqlCurrency)(&c.Usage.StorageRevenue),
+			(*sqlCurrency)(&c.Usage.IngressRevenue), (*sqlCurrency)(&c.Usage.EgressRevenue), (*sqlCurrency)(&c.Usage.RegistryRead), (*sqlCurrency)(&c.Usage.RegistryWrite),
+			(*sqlCurrency)(&c.Usage.AccountExpiry), (*sqlCurrency)(&c.Usage.AccountFunding), (*sqlCurrency)(&c.Usage.RiskedCollateral), (*sqlTime)(&c.Timestamp)); err != nil {
+			return nil, fmt.Errorf("failed to scan resolved contract: %w", err)
+		}
+		resolved = append(resolved, c)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close rows: %w", err)
+	}
+
+	// sum the revenue spent after each contract resolved
+	const lateQuery = `SELECT c.contract_id, lr.amount
+FROM contract_late_revenue lr
+INNER JOIN contracts c ON (lr.contract_id=c.id)
+WHERE c.resolution_timestamp >= $1 AND c.resolution_timestamp < $2`
+	lateRows, err := s.query(lateQuery, sqlTime(from), sqlTime(to))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query late revenue: %w", err)
+	}
+	defer lateRows.Close()
+
+	late := make(map[types.FileContractID]types.Currency)
+	for lateRows.Next() {
+		var id types.FileContractID
+		var amount types.Currency
+		if err := lateRows.Scan((*sqlHash256)(&id), (*sqlCurrency)(&amount)); err != nil {
+			return nil, fmt.Errorf("failed to scan late revenue: %w", err)
+		}
+		late[id] = late[id].Add(amount)
+	}
+	for i := range resolved {
+		resolved[i].LateRevenue = late[resolved[i].ID]
+	}
+	return
+}
+
+// LateContractRevenue returns the revenue spent from resolved contracts'
+// account funding in the range [from, to), oldest first.
+func (s *Store) LateContractRevenue(from, to time.Time) (late []reports.LateRevenue, err error) {
+	const query = `SELECT c.contract_id, lr.amount, lr.date_created
+FROM contract_late_revenue lr
+INNER JOIN contracts c ON (lr.contract_id=c.id)
+WHERE lr.date_created >= $1 AND lr.date_created < $2
+ORDER BY lr.date_created ASC, lr.id ASC`
+
+	rows, err := s.query(query, sqlTime(from), sqlTime(to))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query late revenue: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var lr reports.LateRevenue
+		if err := rows.Scan((*sqlHash256)(&lr.ContractID), (*sqlCurrency)(&lr.Amount), (*sqlTime)(&lr.Timestamp)); err != nil {
+			return nil, fmt.Errorf("failed to scan late revenue: %w", err)
+		}
+		late = append(late, lr)
+	}
+	return
+}
+
+// WalletTransactions returns the wallet's transactions confirmed in the range
+// [from, to), oldest first.
+func (s *Store) WalletTransactions(from, to time.Time) (txns []wallet.Transaction, err error) {
+	const query = `SELECT transaction_id, block_id, block_height, source, inflow, outflow, raw_transaction, date_created
+FROM wallet_transactions
+WHERE date_created >= $1 AND date_created < $2
+ORDER BY date_created ASC, id ASC`
+
+	rows, err := s.query(query, sqlTime(from), sqlTime(to))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transactions: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var txn wallet.Transaction
+		var buf []byte
+		if err := rows.Scan((*sqlHash256)(&txn.ID), (*sqlHash256)(&txn.Index.ID), &txn.Index.Height, &txn.Source, (*sqlCurrency)(&txn.Inflow), (*sqlCurrency)(&txn.Outflow), &buf, (*sqlTime)(&txn.Timestamp)); err != nil {
+			return nil, fmt.Errorf("failed to scan transaction: %w", err)
+		} else if err := decodeTransaction(buf, &txn); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal transaction data: %w", err)
+		}
+		txns = append(txns, txn)
+	}
+	return
+}
+
+// AccountExpirations returns the ledger entries recorded when an account
+// expired in the range [from, to), oldest first.
+func (s *Store) AccountExpirations(from, to time.Time) (entries []accounts.LedgerEntry, err error) {
+	const query = `SELECT id, account_id, amount, date_created
+FROM account_ledger
+WHERE entry_type=$1 AND date_created >= $2 AND date_created < $3
+ORDER BY date_created ASC, id ASC`
+
+	rows, err := s.query(query, accounts.LedgerEntryExpiry, sqlTime(from), sqlTime(to))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ledger entries: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		entry := accounts.LedgerEntry{Type: accounts.LedgerEntryExpiry}
+		if err := rows.Scan(&entry.ID, (*sqlHash256)(&entry.Account), (*sqlCurrency)(&entry.Amount), (*sqlTime)(&entry.Timestamp)); err != nil {
+			return nil, fmt.Errorf("failed to scan ledger entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	return
+}
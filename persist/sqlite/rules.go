@@ -0,0 +1,60 @@
+package sqlite
+
+import (
+	"fmt"
+
+	"go.sia.tech/hostd/host/rules"
+)
+
+// AlertRules returns all alert rules.
+func (s *Store) AlertRules() (alertRules []rules.Rule, err error) {
+	const query = `SELECT id, rule_name, metric, operator, threshold, duration, severity, enabled FROM alert_rules ORDER BY id ASC`
+
+	rows, err := s.query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query alert rules: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r rules.Rule
+		if err := rows.Scan(&r.ID, &r.Name, &r.Metric, &r.Operator, &r.Threshold, &r.For, &r.Severity, &r.Enabled); err != nil {
+			return nil, fmt.Errorf("failed to scan alert rule: %w", err)
+		}
+		alertRules = append(alertRules, r)
+	}
+	return alertRules, rows.Err()
+}
+
+// AddAlertRule adds an alert rule and returns its ID.
+func (s *Store) AddAlertRule(r rules.Rule) (id int64, err error) {
+	const query = `INSERT INTO alert_rules (rule_name, metric, operator, threshold, duration, severity, enabled) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
+	err = s.queryRow(query, r.Name, r.Metric, r.Operator, r.Threshold, r.For, r.Severity, r.Enabled).Scan(&id)
+	return
+}
+
+// UpdateAlertRule updates an alert rule.
+func (s *Store) UpdateAlertRule(r rules.Rule) error {
+	const query = `UPDATE alert_rules SET (rule_name, metric, operator, threshold, duration, severity, enabled) = ($1, $2, $3, $4, $5, $6, $7) WHERE id=$8`
+	res, err := s.exec(query, r.Name, r.Metric, r.Operator, r.Threshold, r.For, r.Severity, r.Enabled, r.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update alert rule: %w", err)
+	} else if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	} else if n == 0 {
+		return rules.ErrRuleNotFound
+	}
+	return nil
+}
+
+// DeleteAlertRule removes an alert rule.
+func (s *Store) DeleteAlertRule(id int64) error {
+	res, err := s.exec(`DELETE FROM alert_rules WHERE id=$1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete alert rule: %w", err)
+	} else if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	} else if n == 0 {
+		return rules.ErrRuleNotFound
+	}
+	return nil
+}
@@ -17,23 +17,24 @@ import (
 
 func randomSettings() settings.Settings {
 	return settings.Settings{
-		AcceptingContracts:   frand.Intn(1) == 1,
-		NetAddress:           hex.EncodeToString(frand.Bytes(64)),
-		MaxContractDuration:  uint64(frand.Intn(math.MaxInt)),
-		ContractPrice:        types.NewCurrency(frand.Uint64n(math.MaxUint64), frand.Uint64n(math.MaxUint64)),
-		BaseRPCPrice:         types.NewCurrency(frand.Uint64n(math.MaxUint64), frand.Uint64n(math.MaxUint64)),
-		SectorAccessPrice:    types.NewCurrency(frand.Uint64n(math.MaxUint64), frand.Uint64n(math.MaxUint64)),
-		CollateralMultiplier: frand.Float64(),
-		MaxCollateral:        types.NewCurrency(frand.Uint64n(math.MaxUint64), frand.Uint64n(math.MaxUint64)),
-		StoragePrice:         types.NewCurrency(frand.Uint64n(math.MaxUint64), frand.Uint64n(math.MaxUint64)),
-		EgressPrice:          types.NewCurrency(frand.Uint64n(math.MaxUint64), frand.Uint64n(math.MaxUint64)),
-		IngressPrice:         types.NewCurrency(frand.Uint64n(math.MaxUint64), frand.Uint64n(math.MaxUint64)),
-		IngressLimit:         uint64(frand.Intn(math.MaxInt)),
-		EgressLimit:          uint64(frand.Intn(math.MaxInt)),
-		MaxRegistryEntries:   uint64(frand.Intn(math.MaxInt)),
-		AccountExpiry:        time.Duration(frand.Intn(math.MaxInt)),
-		PriceTableValidity:   time.Duration(frand.Intn(math.MaxInt)),
-		MaxAccountBalance:    types.NewCurrency(frand.Uint64n(math.MaxUint64), frand.Uint64n(math.MaxUint64)),
+		AcceptingContracts:       frand.Intn(1) == 1,
+		NetAddress:               hex.EncodeToString(frand.Bytes(64)),
+		MaxContractDuration:      uint64(frand.Intn(math.MaxInt)),
+		ContractPrice:            types.NewCurrency(frand.Uint64n(math.MaxUint64), frand.Uint64n(math.MaxUint64)),
+		BaseRPCPrice:             types.NewCurrency(frand.Uint64n(math.MaxUint64), frand.Uint64n(math.MaxUint64)),
+		SectorAccessPrice:        types.NewCurrency(frand.Uint64n(math.MaxUint64), frand.Uint64n(math.MaxUint64)),
+		CollateralMultiplier:     frand.Float64(),
+		MaxCollateral:            types.NewCurrency(frand.Uint64n(math.MaxUint64), frand.Uint64n(math.MaxUint64)),
+		StoragePrice:             types.NewCurrency(frand.Uint64n(math.MaxUint64), frand.Uint64n(math.MaxUint64)),
+		EgressPrice:              types.NewCurrency(frand.Uint64n(math.MaxUint64), frand.Uint64n(math.MaxUint64)),
+		IngressPrice:             types.NewCurrency(frand.Uint64n(math.MaxUint64), frand.Uint64n(math.MaxUint64)),
+		IngressLimit:             uint64(frand.Intn(math.MaxInt)),
+		EgressLimit:              uint64(frand.Intn(math.MaxInt)),
+		MaxRegistryEntries:       uint64(frand.Intn(math.MaxInt)),
+		MaxRegistryEntriesPerKey: uint64(frand.Intn(math.MaxInt)),
+		AccountExpiry:            time.Duration(frand.Intn(math.MaxInt)),
+		PriceTableValidity:       time.Duration(frand.Intn(math.MaxInt)),
+		MaxAccountBalance:        types.NewCurrency(frand.Uint64n(math.MaxUint64), frand.Uint64n(math.MaxUint64)),
 	}
 }
 
@@ -6,6 +6,7 @@ import (
 	"encoding/binary"
 	"encoding/hex"
 	"fmt"
+	"strings"
 	"time"
 
 	"go.sia.tech/core/types"
@@ -122,3 +123,14 @@ func (st sqlTime) Value() (driver.Value, error) {
 func nullable[T sql.Scanner](v T) *sqlNullable[T] {
 	return &sqlNullable[T]{Value: v}
 }
+
+// littleEndianOrderExpr returns an SQL expression that orders a column of
+// little-endian encoded integers numerically. The bytes are reversed and
+// hex-encoded to produce a fixed-width string that sorts correctly.
+func littleEndianOrderExpr(column string, size int) string {
+	parts := make([]string, size)
+	for i := range parts {
+		parts[i] = fmt.Sprintf("hex(substr(%s, %d, 1))", column, size-i)
+	}
+	return strings.Join(parts, "||")
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"go.sia.tech/core/types"
@@ -73,17 +74,22 @@ func (tx updateWalletTxn) SubWalletDelta(value types.Currency, timestamp time.Ti
 
 // AddTransaction adds a transaction to the wallet.
 func (tx *updateWalletTxn) AddTransaction(txn wallet.Transaction) error {
-	const query = `INSERT INTO wallet_transactions (transaction_id, block_id, block_height, source, inflow, outflow, raw_transaction, date_created) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	const query = `INSERT INTO wallet_transactions (transaction_id, block_id, block_height, source, direction, inflow, outflow, raw_transaction, date_created) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
 	_, err := tx.tx.Exec(query,
 		sqlHash256(txn.ID),
 		sqlHash256(txn.Index.ID),
 		txn.Index.Height,
 		txn.Source,
+		txn.Direction(),
 		sqlCurrency(txn.Inflow),
 		sqlCurrency(txn.Outflow),
 		encodeTransaction(txn),
 		sqlTime(txn.Timestamp),
 	)
+	if err != nil {
+		return err
+	}
+	_, err = tx.tx.Exec(`UPDATE global_settings SET wallet_birth_height=MIN(COALESCE(wallet_birth_height, $1), $1)`, txn.Index.Height)
 	return err
 }
 
@@ -107,6 +113,16 @@ func (s *Store) LastWalletChange() (id modules.ConsensusChangeID, height uint64,
 	return
 }
 
+// WalletBirthHeight returns the height of the first block relevant to the
+// wallet. If the wallet has never received a transaction, ok is false.
+func (s *Store) WalletBirthHeight() (height uint64, ok bool, err error) {
+	var nullHeight sql.NullInt64
+	if err = s.queryRow(`SELECT wallet_birth_height FROM global_settings`).Scan(&nullHeight); err != nil {
+		return 0, false, fmt.Errorf("failed to query wallet birth height: %w", err)
+	}
+	return uint64(nullHeight.Int64), nullHeight.Valid, nil
+}
+
 // UnspentSiacoinElements returns the spendable siacoin outputs in the wallet.
 func (s *Store) UnspentSiacoinElements() (utxos []wallet.SiacoinElement, err error) {
 	rows, err := s.query(`SELECT id, amount, unlock_hash FROM wallet_utxos`)
@@ -124,10 +140,117 @@ func (s *Store) UnspentSiacoinElements() (utxos []wallet.SiacoinElement, err err
 	return utxos, nil
 }
 
+// LockedSiacoinElements returns the wallet's output locks, including expired
+// locks.
+func (s *Store) LockedSiacoinElements() (locks []wallet.OutputLock, err error) {
+	rows, err := s.query(`SELECT wl.id, wu.amount, wl.reason, wl.expiration_timestamp FROM wallet_locked_utxos wl
+INNER JOIN wallet_utxos wu ON (wl.id=wu.id)`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query locked siacoin elements: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var lock wallet.OutputLock
+		if err := rows.Scan((*sqlHash256)(&lock.ID), (*sqlCurrency)(&lock.Value), &lock.Reason, (*sqlTime)(&lock.Expiration)); err != nil {
+			return nil, fmt.Errorf("failed to scan locked siacoin element: %w", err)
+		}
+		locks = append(locks, lock)
+	}
+	return locks, nil
+}
+
+// LockSiacoinElements locks the outputs until their expiration. Existing locks
+// on the outputs are replaced.
+func (s *Store) LockSiacoinElements(locks ...wallet.OutputLock) error {
+	return s.transaction(func(tx txn) error {
+		stmt, err := tx.Prepare(`INSERT INTO wallet_locked_utxos (id, reason, expiration_timestamp) VALUES ($1, $2, $3) ON CONFLICT (id) DO UPDATE SET reason=EXCLUDED.reason, expiration_timestamp=EXCLUDED.expiration_timestamp`)
+		if err != nil {
+			return fmt.Errorf("failed to prepare statement: %w", err)
+		}
+		defer stmt.Close()
+
+		for _, lock := range locks {
+			if _, err := stmt.Exec(sqlHash256(lock.ID), lock.Reason, sqlTime(lock.Expiration)); err != nil {
+				return fmt.Errorf("failed to lock output %v: %w", lock.ID, err)
+			}
+		}
+		return nil
+	})
+}
+
+// ReleaseSiacoinElements removes the locks on the outputs.
+func (s *Store) ReleaseSiacoinElements(ids ...types.SiacoinOutputID) error {
+	return s.transaction(func(tx txn) error {
+		stmt, err := tx.Prepare(`DELETE FROM wallet_locked_utxos WHERE id=$1`)
+		if err != nil {
+			return fmt.Errorf("failed to prepare statement: %w", err)
+		}
+		defer stmt.Close()
+
+		for _, id := range ids {
+			if _, err := stmt.Exec(sqlHash256(id)); err != nil {
+				return fmt.Errorf("failed to release output %v: %w", id, err)
+			}
+		}
+		return nil
+	})
+}
+
 // Transactions returns a paginated list of transactions ordered by block height
 // descending. If no transactions are found, (nil, nil) is returned.
 func (s *Store) Transactions(limit, offset int) (txns []wallet.Transaction, err error) {
-	rows, err := s.query(`SELECT transaction_id, block_id, block_height, source, inflow, outflow, raw_transaction, date_created FROM wallet_transactions ORDER BY block_height DESC, id ASC LIMIT ? OFFSET ?`, limit, offset)
+	return s.SearchTransactions(wallet.TransactionFilter{Limit: limit, Offset: offset})
+}
+
+// SearchTransactions returns a paginated list of transactions matching the
+// filter ordered by block height descending. If no transactions are found,
+// (nil, nil) is returned.
+func (s *Store) SearchTransactions(filter wallet.TransactionFilter) (txns []wallet.Transaction, err error) {
+	var whereClause []string
+	var queryParams []any
+	if filter.Label != "" {
+		// instr is used instead of LIKE so wildcards in the label are
+		// matched literally
+		whereClause = append(whereClause, `instr(lower(wl.label), lower(?)) > 0`)
+		queryParams = append(queryParams, filter.Label)
+	}
+	if len(filter.Sources) > 0 {
+		whereClause = append(whereClause, `wt.source IN (`+queryPlaceHolders(len(filter.Sources))+`)`)
+		for _, source := range filter.Sources {
+			queryParams = append(queryParams, source)
+		}
+	}
+	if filter.Direction != "" {
+		whereClause = append(whereClause, `wt.direction = ?`)
+		queryParams = append(queryParams, filter.Direction)
+	}
+	if filter.MinHeight > 0 {
+		whereClause = append(whereClause, `wt.block_height >= ?`)
+		queryParams = append(queryParams, filter.MinHeight)
+	}
+	if filter.MaxHeight > 0 {
+		whereClause = append(whereClause, `wt.block_height <= ?`)
+		queryParams = append(queryParams, filter.MaxHeight)
+	}
+	if !filter.After.IsZero() {
+		whereClause = append(whereClause, `wt.date_created >= ?`)
+		queryParams = append(queryParams, sqlTime(filter.After))
+	}
+	if !filter.Before.IsZero() {
+		whereClause = append(whereClause, `wt.date_created < ?`)
+		queryParams = append(queryParams, sqlTime(filter.Before))
+	}
+
+	query := `SELECT wt.transaction_id, wt.block_id, wt.block_height, wt.source, wt.inflow, wt.outflow, wt.raw_transaction, wt.date_created, wl.label
+FROM wallet_transactions wt
+LEFT JOIN wallet_transaction_labels wl ON (wt.transaction_id=wl.transaction_id)`
+	if len(whereClause) > 0 {
+		query += ` WHERE ` + strings.Join(whereClause, " AND ")
+	}
+	query += ` ORDER BY wt.block_height DESC, wt.id ASC LIMIT ? OFFSET ?`
+	queryParams = append(queryParams, filter.Limit, filter.Offset)
+
+	rows, err := s.query(query, queryParams...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query transactions: %w", err)
 	}
@@ -135,16 +258,29 @@ func (s *Store) Transactions(limit, offset int) (txns []wallet.Transaction, err
 	for rows.Next() {
 		var txn wallet.Transaction
 		var buf []byte
-		if err := rows.Scan((*sqlHash256)(&txn.ID), (*sqlHash256)(&txn.Index.ID), &txn.Index.Height, &txn.Source, (*sqlCurrency)(&txn.Inflow), (*sqlCurrency)(&txn.Outflow), &buf, (*sqlTime)(&txn.Timestamp)); err != nil {
+		var label sql.NullString
+		if err := rows.Scan((*sqlHash256)(&txn.ID), (*sqlHash256)(&txn.Index.ID), &txn.Index.Height, &txn.Source, (*sqlCurrency)(&txn.Inflow), (*sqlCurrency)(&txn.Outflow), &buf, (*sqlTime)(&txn.Timestamp), &label); err != nil {
 			return nil, fmt.Errorf("failed to scan transaction: %w", err)
 		} else if err := decodeTransaction(buf, &txn); err != nil {
 			return nil, fmt.Errorf("failed to unmarshal transaction data: %w", err)
 		}
+		txn.Label = label.String
 		txns = append(txns, txn)
 	}
 	return
 }
 
+// SetTransactionLabel sets the label of a transaction. An empty label removes
+// the transaction's label.
+func (s *Store) SetTransactionLabel(id types.TransactionID, label string) error {
+	if label == "" {
+		_, err := s.exec(`DELETE FROM wallet_transaction_labels WHERE transaction_id=$1`, sqlHash256(id))
+		return err
+	}
+	_, err := s.exec(`INSERT INTO wallet_transaction_labels (transaction_id, label) VALUES ($1, $2) ON CONFLICT (transaction_id) DO UPDATE SET label=EXCLUDED.label`, sqlHash256(id), label)
+	return err
+}
+
 // TransactionCount returns the total number of transactions in the wallet.
 func (s *Store) TransactionCount() (count uint64, err error) {
 	err = s.queryRow(`SELECT COUNT(*) FROM wallet_transactions`).Scan(&count)
@@ -182,7 +318,8 @@ func (s *Store) VerifyWalletKey(seedHash types.Hash256) error {
 }
 
 // ResetWallet resets the wallet to its initial state. This is used when a
-// consensus subscription error occurs.
+// consensus subscription error occurs. The wallet's birth height is kept
+// unless the seed has changed.
 func (s *Store) ResetWallet(seedHash types.Hash256) error {
 	return s.transaction(func(tx txn) error {
 		if _, err := tx.Exec(`DELETE FROM wallet_utxos`); err != nil {
@@ -191,7 +328,7 @@ func (s *Store) ResetWallet(seedHash types.Hash256) error {
 			return fmt.Errorf("failed to delete wallet transactions: %w", err)
 		} else if _, err := tx.Exec(`DELETE FROM host_stats WHERE stat=$1`, metricWalletBalance); err != nil {
 			return fmt.Errorf("failed to delete wallet metrics: %w", err)
-		} else if _, err := tx.Exec(`UPDATE global_settings SET wallet_last_processed_change=NULL, wallet_height=NULL,  wallet_hash=?`, sqlHash256(seedHash)); err != nil {
+		} else if _, err := tx.Exec(`UPDATE global_settings SET wallet_last_processed_change=NULL, wallet_height=NULL, wallet_birth_height=CASE WHEN wallet_hash=$1 THEN wallet_birth_height ELSE NULL END, wallet_hash=$1`, sqlHash256(seedHash)); err != nil {
 			return fmt.Errorf("failed to reset wallet settings: %w", err)
 		}
 		return nil
@@ -0,0 +1,74 @@
+package sqlite
+
+import (
+	"path/filepath"
+	"testing"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/wallet"
+	"go.sia.tech/siad/modules"
+	"go.uber.org/zap/zaptest"
+	"lukechampine.com/frand"
+)
+
+func TestWalletBirthHeight(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	db, err := OpenDatabase(filepath.Join(t.TempDir(), "test.db"), log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	seedHash := frand.Entropy256()
+	if err := db.VerifyWalletKey(seedHash); err != nil {
+		t.Fatal(err)
+	}
+
+	checkBirthHeight := func(height uint64, ok bool) {
+		t.Helper()
+		h, exists, err := db.WalletBirthHeight()
+		if err != nil {
+			t.Fatal(err)
+		} else if exists != ok {
+			t.Fatalf("expected birth height exists %v, got %v", ok, exists)
+		} else if h != height {
+			t.Fatalf("expected birth height %d, got %d", height, h)
+		}
+	}
+	addTransaction := func(height uint64) {
+		t.Helper()
+		txn := wallet.Transaction{
+			ID:     frand.Entropy256(),
+			Index:  types.ChainIndex{ID: frand.Entropy256(), Height: height},
+			Inflow: types.Siacoins(1),
+			Source: wallet.TxnSourceTransaction,
+		}
+		err := db.UpdateWallet(modules.ConsensusChangeID(frand.Entropy256()), height, func(tx wallet.UpdateTransaction) error {
+			return tx.AddTransaction(txn)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	checkBirthHeight(0, false)
+
+	addTransaction(20)
+	checkBirthHeight(20, true)
+	addTransaction(10)
+	checkBirthHeight(10, true)
+	addTransaction(30)
+	checkBirthHeight(10, true)
+
+	// the birth height should survive a reset with the same seed
+	if err := db.ResetWallet(seedHash); err != nil {
+		t.Fatal(err)
+	}
+	checkBirthHeight(10, true)
+
+	// the birth height should be cleared when the seed changes
+	if err := db.ResetWallet(frand.Entropy256()); err != nil {
+		t.Fatal(err)
+	}
+	checkBirthHeight(0, false)
+}
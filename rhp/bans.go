@@ -0,0 +1,269 @@
+package rhp
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.sia.tech/core/types"
+	"go.uber.org/zap"
+)
+
+const (
+	// offenseThreshold is the number of offenses a peer can commit within
+	// offenseWindow before it is banned.
+	offenseThreshold = 5
+	// offenseWindow is the period in which offenses are counted.
+	offenseWindow = 10 * time.Minute
+
+	// initialBanDuration is the duration of a peer's first ban. Each
+	// subsequent ban doubles in duration up to maxBanDuration.
+	initialBanDuration = 10 * time.Minute
+	// maxBanDuration is the maximum duration of a ban.
+	maxBanDuration = 7 * 24 * time.Hour
+	// banResetPeriod is the period after a ban has expired after which the
+	// ban duration is reset to initialBanDuration.
+	banResetPeriod = 7 * 24 * time.Hour
+
+	// pruneInterval is the interval at which stale peers are removed.
+	pruneInterval = time.Hour
+)
+
+type (
+	// A Ban is a temporary ban of a peer. Peers are identified by either
+	// their IP address or their public key.
+	Ban struct {
+		Peer       string    `json:"peer"`
+		Reason     string    `json:"reason"`
+		Bans       int       `json:"bans"`
+		Expiration time.Time `json:"expiration"`
+		Timestamp  time.Time `json:"timestamp"`
+	}
+
+	// A RenterError is returned by RPC handlers when an RPC failed because
+	// of a renter's misbehavior. It identifies the renter's public key so
+	// that an offense can be recorded against it.
+	RenterError struct {
+		RenterKey types.PublicKey
+		Err       error
+	}
+
+	peerRecord struct {
+		offenses []time.Time
+		reason   string
+
+		bans       int
+		banned     time.Time
+		expiration time.Time
+	}
+
+	// A BanManager counts offenses committed by peers and temporarily bans
+	// peers that repeatedly misbehave. Ban durations escalate with each
+	// subsequent ban.
+	BanManager struct {
+		log *zap.Logger
+
+		mu        sync.Mutex // guards the fields below
+		peers     map[string]*peerRecord
+		lastPrune time.Time
+	}
+)
+
+var (
+	// ErrPeerBanned is returned when a banned peer attempts to interact
+	// with the host.
+	ErrPeerBanned = errors.New("peer is temporarily banned")
+	// ErrNotBanned is returned when attempting to lift a ban for a peer
+	// that is not banned.
+	ErrNotBanned = errors.New("peer is not banned")
+)
+
+// Error implements the error interface.
+func (re *RenterError) Error() string {
+	return re.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (re *RenterError) Unwrap() error {
+	return re.Err
+}
+
+// PeerIP returns the IP address of a peer without its port. It is used to
+// identify peers by their remote address.
+func PeerIP(addr net.Addr) string {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
+// IsDisconnect returns true if err was caused by the peer closing the
+// connection or timing out rather than by a protocol violation. Port checkers
+// and monitoring services regularly open connections and close them without
+// completing a handshake, so disconnects are not counted as offenses.
+func IsDisconnect(err error) bool {
+	return errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, os.ErrDeadlineExceeded) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, syscall.EPIPE)
+}
+
+// OffendingPeers returns the peers that should be held responsible for err in
+// addition to the peer's IP address.
+func OffendingPeers(ip string, err error) []string {
+	peers := []string{ip}
+	var re *RenterError
+	if errors.As(err, &re) {
+		peers = append(peers, re.RenterKey.String())
+	}
+	return peers
+}
+
+// prune removes peers with no active ban, no recent offenses and no recent
+// bans. The caller must hold the lock.
+func (bm *BanManager) prune(now time.Time) {
+	if now.Sub(bm.lastPrune) < pruneInterval {
+		return
+	}
+	bm.lastPrune = now
+	for peer, record := range bm.peers {
+		switch {
+		case now.Before(record.expiration):
+		case len(record.offenses) > 0 && now.Sub(record.offenses[len(record.offenses)-1]) < offenseWindow:
+		case record.bans > 0 && now.Sub(record.expiration) < banResetPeriod:
+		default:
+			delete(bm.peers, peer)
+		}
+	}
+}
+
+// ban bans a peer. The ban duration doubles with each ban unless the previous
+// ban expired more than banResetPeriod ago. The caller must hold the lock.
+func (bm *BanManager) ban(peer string, record *peerRecord, now time.Time) {
+	if record.bans > 0 && now.Sub(record.expiration) > banResetPeriod {
+		record.bans = 0
+	}
+	record.bans++
+
+	duration := initialBanDuration
+	for i := 1; i < record.bans && duration < maxBanDuration; i++ {
+		duration *= 2
+	}
+	if duration > maxBanDuration {
+		duration = maxBanDuration
+	}
+
+	record.banned = now
+	record.expiration = now.Add(duration)
+	record.offenses = record.offenses[:0]
+	bm.log.Warn("banned peer", zap.String("peer", peer), zap.String("reason", record.reason), zap.Int("bans", record.bans), zap.Duration("duration", duration))
+}
+
+// RecordOffense records an offense against each of the peers. Peers that
+// commit too many offenses in a short period are temporarily banned.
+func (bm *BanManager) RecordOffense(reason error, peers ...string) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+
+	now := time.Now()
+	bm.prune(now)
+
+	for _, peer := range peers {
+		if peer == "" {
+			continue
+		}
+
+		record, ok := bm.peers[peer]
+		if !ok {
+			record = new(peerRecord)
+			bm.peers[peer] = record
+		} else if now.Before(record.expiration) {
+			// the peer is already banned
+			continue
+		}
+
+		// remove offenses outside of the window
+		var i int
+		for i < len(record.offenses) && now.Sub(record.offenses[i]) > offenseWindow {
+			i++
+		}
+		record.offenses = append(record.offenses[i:], now)
+		record.reason = reason.Error()
+
+		bm.log.Debug("recorded offense", zap.String("peer", peer), zap.Error(reason), zap.Int("offenses", len(record.offenses)))
+		if len(record.offenses) >= offenseThreshold {
+			bm.ban(peer, record, now)
+		}
+	}
+}
+
+// Banned returns true if any of the peers are currently banned.
+func (bm *BanManager) Banned(peers ...string) bool {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+
+	now := time.Now()
+	for _, peer := range peers {
+		if record, ok := bm.peers[peer]; ok && now.Before(record.expiration) {
+			return true
+		}
+	}
+	return false
+}
+
+// Bans returns the currently active bans ordered by expiration.
+func (bm *BanManager) Bans() []Ban {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+
+	now := time.Now()
+	bans := make([]Ban, 0)
+	for peer, record := range bm.peers {
+		if !now.Before(record.expiration) {
+			continue
+		}
+		bans = append(bans, Ban{
+			Peer:       peer,
+			Reason:     record.reason,
+			Bans:       record.bans,
+			Expiration: record.expiration,
+			Timestamp:  record.banned,
+		})
+	}
+	sort.Slice(bans, func(i, j int) bool {
+		return bans[i].Expiration.Before(bans[j].Expiration)
+	})
+	return bans
+}
+
+// Unban lifts a peer's ban and clears its offense history.
+func (bm *BanManager) Unban(peer string) error {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+
+	record, ok := bm.peers[peer]
+	if !ok || !time.Now().Before(record.expiration) {
+		return ErrNotBanned
+	}
+	delete(bm.peers, peer)
+	bm.log.Info("lifted ban", zap.String("peer", peer))
+	return nil
+}
+
+// NewBanManager initializes a new BanManager.
+func NewBanManager(log *zap.Logger) *BanManager {
+	return &BanManager{
+		log:       log,
+		peers:     make(map[string]*peerRecord),
+		lastPrune: time.Now(),
+	}
+}
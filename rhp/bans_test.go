@@ -0,0 +1,85 @@
+package rhp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+)
+
+func TestBanEscalation(t *testing.T) {
+	bm := NewBanManager(zaptest.NewLogger(t))
+	const peer = "127.0.0.1"
+	reason := errors.New("test offense")
+
+	for i := 0; i < offenseThreshold-1; i++ {
+		bm.RecordOffense(reason, peer)
+	}
+	if bm.Banned(peer) {
+		t.Fatal("peer should not be banned")
+	}
+	bm.RecordOffense(reason, peer)
+	if !bm.Banned(peer) {
+		t.Fatal("peer should be banned")
+	}
+
+	bans := bm.Bans()
+	if len(bans) != 1 {
+		t.Fatalf("expected 1 ban, got %v", len(bans))
+	} else if bans[0].Peer != peer || bans[0].Reason != reason.Error() || bans[0].Bans != 1 {
+		t.Fatalf("unexpected ban %+v", bans[0])
+	} else if d := bans[0].Expiration.Sub(bans[0].Timestamp); d != initialBanDuration {
+		t.Fatalf("expected ban duration %v, got %v", initialBanDuration, d)
+	}
+
+	// expire the ban and offend again, the duration should double
+	bm.peers[peer].expiration = time.Now().Add(-time.Second)
+	if bm.Banned(peer) {
+		t.Fatal("ban should have expired")
+	}
+	for i := 0; i < offenseThreshold; i++ {
+		bm.RecordOffense(reason, peer)
+	}
+	bans = bm.Bans()
+	if len(bans) != 1 {
+		t.Fatalf("expected 1 ban, got %v", len(bans))
+	} else if bans[0].Bans != 2 {
+		t.Fatalf("expected 2 bans, got %v", bans[0].Bans)
+	} else if d := bans[0].Expiration.Sub(bans[0].Timestamp); d != 2*initialBanDuration {
+		t.Fatalf("expected ban duration %v, got %v", 2*initialBanDuration, d)
+	}
+
+	// lift the ban
+	if err := bm.Unban(peer); err != nil {
+		t.Fatal(err)
+	} else if bm.Banned(peer) {
+		t.Fatal("peer should not be banned")
+	} else if err := bm.Unban(peer); !errors.Is(err, ErrNotBanned) {
+		t.Fatalf("expected ErrNotBanned, got %v", err)
+	}
+}
+
+func TestIsDisconnect(t *testing.T) {
+	tests := []struct {
+		err        error
+		disconnect bool
+	}{
+		{fmt.Errorf("failed to complete handshake: %w", io.EOF), true},
+		{fmt.Errorf("could not read peer version: %w", io.ErrUnexpectedEOF), true},
+		{&net.OpError{Op: "read", Err: syscall.ECONNRESET}, true},
+		{os.ErrDeadlineExceeded, true},
+		{errors.New("peer sent invalid version"), false},
+		{&RenterError{Err: errors.New("invalid signature")}, false},
+	}
+	for _, tt := range tests {
+		if IsDisconnect(tt.err) != tt.disconnect {
+			t.Fatalf("expected IsDisconnect(%v) to be %v", tt.err, tt.disconnect)
+		}
+	}
+}
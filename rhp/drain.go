@@ -0,0 +1,169 @@
+package rhp
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type (
+	// DrainState is the current drain state of the host.
+	DrainState struct {
+		Draining       bool      `json:"draining"`
+		Drained        bool      `json:"drained"`
+		Started        time.Time `json:"started,omitempty"`
+		Deadline       time.Time `json:"deadline,omitempty"`
+		ActiveSessions int       `json:"activeSessions"`
+	}
+
+	// A Drainer stops the host from accepting new sessions, contract
+	// formations and renewals while allowing existing sessions to finish.
+	// Once all sessions have ended or the drain deadline has passed, the
+	// host is drained and the remaining sessions are rejected.
+	Drainer struct {
+		log *zap.Logger
+
+		mu       sync.Mutex // guards the fields below
+		sessions int
+		draining bool
+		started  time.Time
+		deadline time.Time
+		// idle is closed when the host is draining and all sessions have
+		// ended. It is replaced each time a drain is started.
+		idle chan struct{}
+	}
+)
+
+// ErrHostDraining is returned when a renter attempts to start a session or
+// form a contract while the host is draining.
+var ErrHostDraining = errors.New("host is draining sessions for maintenance, try again later")
+
+// checkIdle closes the idle channel if the host is draining and no sessions
+// remain. The caller must hold the lock.
+func (d *Drainer) checkIdle() {
+	if !d.draining || d.sessions > 0 {
+		return
+	}
+	select {
+	case <-d.idle:
+	default:
+		close(d.idle)
+	}
+}
+
+// StartSession registers a new session and returns a function that should be
+// called when the session ends. If the host is draining, ErrHostDraining is
+// returned.
+func (d *Drainer) StartSession() (end func(), err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.draining {
+		return nil, ErrHostDraining
+	}
+	d.sessions++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			d.mu.Lock()
+			defer d.mu.Unlock()
+			d.sessions--
+			d.checkIdle()
+		})
+	}, nil
+}
+
+// Draining returns true if the host is draining or drained. New sessions,
+// contract formations and renewals should be rejected.
+func (d *Drainer) Draining() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.draining
+}
+
+// Drained returns true if the host is draining and either all sessions have
+// ended or the drain deadline has passed. RPCs from the remaining sessions
+// should be rejected.
+func (d *Drainer) Drained() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.draining && (d.sessions == 0 || !time.Now().Before(d.deadline))
+}
+
+// Drain starts draining the host. Existing sessions have until timeout to
+// finish. If the host is already draining, the deadline is updated.
+func (d *Drainer) Drain(timeout time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.draining {
+		d.draining = true
+		d.started = time.Now()
+		d.idle = make(chan struct{})
+	}
+	d.deadline = time.Now().Add(timeout)
+	d.checkIdle()
+	d.log.Info("draining sessions", zap.Int("sessions", d.sessions), zap.Time("deadline", d.deadline))
+}
+
+// Resume stops draining and resumes accepting new sessions.
+func (d *Drainer) Resume() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.draining {
+		return
+	}
+	d.draining = false
+	d.started = time.Time{}
+	d.deadline = time.Time{}
+	d.log.Info("resumed accepting sessions")
+}
+
+// Wait blocks until the host is drained or ctx is cancelled. Wait returns
+// immediately if the host is not draining.
+func (d *Drainer) Wait(ctx context.Context) error {
+	d.mu.Lock()
+	if !d.draining {
+		d.mu.Unlock()
+		return nil
+	}
+	idle, deadline := d.idle, d.deadline
+	d.mu.Unlock()
+
+	t := time.NewTimer(time.Until(deadline))
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-idle:
+	case <-t.C:
+	}
+	return nil
+}
+
+// State returns the current drain state.
+func (d *Drainer) State() DrainState {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return DrainState{
+		Draining:       d.draining,
+		Drained:        d.draining && (d.sessions == 0 || !time.Now().Before(d.deadline)),
+		Started:        d.started,
+		Deadline:       d.deadline,
+		ActiveSessions: d.sessions,
+	}
+}
+
+// NewDrainer initializes a new Drainer.
+func NewDrainer(log *zap.Logger) *Drainer {
+	return &Drainer{
+		log:  log,
+		idle: make(chan struct{}),
+	}
+}
@@ -0,0 +1,66 @@
+package rhp
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestDrainer(t *testing.T) {
+	d := NewDrainer(zap.NewNop())
+
+	// wait should return immediately when the host is not draining
+	if err := d.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	end, err := d.StartSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d.Drain(time.Minute)
+	if !d.Draining() {
+		t.Fatal("expected host to be draining")
+	} else if d.Drained() {
+		t.Fatal("expected host to not be drained with an active session")
+	} else if _, err := d.StartSession(); !errors.Is(err, ErrHostDraining) {
+		t.Fatalf("expected ErrHostDraining, got %v", err)
+	}
+
+	// ending the last session should drain the host
+	time.AfterFunc(50*time.Millisecond, end)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := d.Wait(ctx); err != nil {
+		t.Fatal(err)
+	} else if state := d.State(); !state.Drained || state.ActiveSessions != 0 {
+		t.Fatalf("expected host to be drained, got %+v", state)
+	}
+
+	// resuming should accept new sessions
+	d.Resume()
+	end, err = d.StartSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the host should be drained once the deadline passes even with active
+	// sessions
+	d.Drain(50 * time.Millisecond)
+	if err := d.Wait(ctx); err != nil {
+		t.Fatal(err)
+	} else if !d.Drained() {
+		t.Fatal("expected host to be drained after the deadline")
+	} else if state := d.State(); state.ActiveSessions != 1 {
+		t.Fatalf("expected 1 active session, got %d", state.ActiveSessions)
+	}
+	end()
+	end() // calling end twice should not change the session count
+	if state := d.State(); state.ActiveSessions != 0 {
+		t.Fatalf("expected 0 active sessions, got %d", state.ActiveSessions)
+	}
+}
@@ -24,6 +24,10 @@ type (
 
 		mu   sync.Mutex // guards the following fields
 		r, w uint64
+		// totalR and totalW are the number of bytes read and written since
+		// the recorder was created. Unlike r and w, they are not reset when
+		// usage is persisted.
+		totalR, totalW uint64
 	}
 )
 
@@ -32,6 +36,7 @@ func (dr *DataRecorder) ReadBytes(n int) {
 	dr.mu.Lock()
 	defer dr.mu.Unlock()
 	dr.r += uint64(n)
+	dr.totalR += uint64(n)
 }
 
 // WriteBytes increments the number of bytes written by n.
@@ -39,6 +44,7 @@ func (dr *DataRecorder) WriteBytes(n int) {
 	dr.mu.Lock()
 	defer dr.mu.Unlock()
 	dr.w += uint64(n)
+	dr.totalW += uint64(n)
 }
 
 // Usage returns the number of bytes read and written
@@ -48,6 +54,14 @@ func (dr *DataRecorder) Usage() (read, written uint64) {
 	return dr.r, dr.w
 }
 
+// Total returns the number of bytes read and written since the recorder was
+// created.
+func (dr *DataRecorder) Total() (read, written uint64) {
+	dr.mu.Lock()
+	defer dr.mu.Unlock()
+	return dr.totalR, dr.totalW
+}
+
 func (dr *DataRecorder) persistUsage() {
 	dr.mu.Lock()
 	r, w := dr.r, dr.w
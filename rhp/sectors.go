@@ -0,0 +1,96 @@
+package rhp
+
+import (
+	"bytes"
+	"fmt"
+
+	rhp2 "go.sia.tech/core/rhp/v2"
+	"go.sia.tech/core/types"
+)
+
+// A SectorReader reads full and partial sectors from disk.
+type SectorReader interface {
+	// Read reads the full sector with the given root.
+	Read(root types.Hash256) (*[rhp2.SectorSize]byte, error)
+	// ReadPartial reads length bytes starting at offset from the sector
+	// with the given root.
+	ReadPartial(root types.Hash256, offset, length uint64) ([]byte, error)
+	// SubtreeRoots returns the roots of the sector's equal-sized subtrees.
+	// If the roots are not available, nil is returned.
+	SubtreeRoots(root types.Hash256) ([]types.Hash256, error)
+}
+
+// buildPartialProof builds a Merkle proof for the leaf range [start, end) of a
+// sector. data must contain the sector's leaves starting at leaf dataStart
+// and must cover every subtree that partially overlaps the proof range.
+// Subtrees outside of data are built from the sector's subtree roots.
+func buildPartialProof(data []byte, dataStart, start, end uint64, subtrees []types.Hash256) []types.Hash256 {
+	leavesPerSubtree := rhp2.LeavesPerSector / uint64(len(subtrees))
+
+	proof := make([]types.Hash256, 0, rhp2.RangeProofSize(rhp2.LeavesPerSector, start, end))
+	var rec func(i, j uint64)
+	rec = func(i, j uint64) {
+		switch {
+		case i >= start && j <= end:
+			// the subtree only contains requested leaves; skip it
+		case j <= start || i >= end:
+			// the subtree does not contain any requested leaves; add its
+			// root to the proof
+			if j-i >= leavesPerSubtree {
+				proof = append(proof, rhp2.MetaRoot(subtrees[i/leavesPerSubtree:j/leavesPerSubtree]))
+			} else {
+				leaves := data[(i-dataStart)*rhp2.LeafSize : (j-dataStart)*rhp2.LeafSize]
+				root, err := rhp2.ReaderRoot(bytes.NewReader(leaves))
+				if err != nil {
+					panic(err) // should never happen
+				}
+				proof = append(proof, root)
+			}
+		default:
+			// the subtree partially overlaps the requested leaves; split it
+			mid := (i + j) / 2
+			rec(i, mid)
+			rec(mid, j)
+		}
+	}
+	rec(0, rhp2.LeavesPerSector)
+	return proof
+}
+
+// ReadSectorRange reads length bytes starting at offset from the sector with
+// the given root. If proof is true, a Merkle proof for the range is also
+// returned and offset and length must be multiples of rhp2.LeafSize. When the
+// sector's subtree roots are available, only the subtrees overlapping the
+// range are read from disk; otherwise, the full sector is read.
+func ReadSectorRange(sr SectorReader, root types.Hash256, offset, length uint64, proof bool) ([]byte, []types.Hash256, error) {
+	if length == 0 || offset+length > rhp2.SectorSize {
+		return nil, nil, fmt.Errorf("read range [%d, %d) is out of bounds", offset, offset+length)
+	} else if !proof {
+		data, err := sr.ReadPartial(root, offset, length)
+		return data, nil, err
+	}
+
+	start, end := offset/rhp2.LeafSize, (offset+length)/rhp2.LeafSize
+	subtrees, err := sr.SubtreeRoots(root)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get subtree roots: %w", err)
+	} else if len(subtrees) == 0 {
+		// fall back to reading the full sector
+		sector, err := sr.Read(root)
+		if err != nil {
+			return nil, nil, err
+		}
+		return sector[offset : offset+length], rhp2.BuildProof(sector, start, end, nil), nil
+	}
+
+	// expand the range to the boundaries of the overlapping subtrees
+	subtreeSize := rhp2.SectorSize / uint64(len(subtrees))
+	alignedStart := offset / subtreeSize * subtreeSize
+	alignedEnd := (offset + length + subtreeSize - 1) / subtreeSize * subtreeSize
+	data, err := sr.ReadPartial(root, alignedStart, alignedEnd-alignedStart)
+	if err != nil {
+		return nil, nil, err
+	}
+	rel := offset - alignedStart
+	return data[rel : rel+length], buildPartialProof(data, alignedStart/rhp2.LeafSize, start, end, subtrees), nil
+}
@@ -0,0 +1,87 @@
+package rhp
+
+import (
+	"bytes"
+	"testing"
+
+	rhp2 "go.sia.tech/core/rhp/v2"
+	"go.sia.tech/core/types"
+	"lukechampine.com/frand"
+)
+
+type memSectorReader struct {
+	sector   *[rhp2.SectorSize]byte
+	subtrees []types.Hash256
+	read     uint64
+}
+
+func (mr *memSectorReader) Read(root types.Hash256) (*[rhp2.SectorSize]byte, error) {
+	mr.read += rhp2.SectorSize
+	return mr.sector, nil
+}
+
+func (mr *memSectorReader) ReadPartial(root types.Hash256, offset, length uint64) ([]byte, error) {
+	mr.read += length
+	return mr.sector[offset : offset+length], nil
+}
+
+func (mr *memSectorReader) SubtreeRoots(root types.Hash256) ([]types.Hash256, error) {
+	return mr.subtrees, nil
+}
+
+func TestReadSectorRange(t *testing.T) {
+	var sector [rhp2.SectorSize]byte
+	frand.Read(sector[:])
+	root := rhp2.SectorRoot(&sector)
+
+	const subtreeSize = 64 * rhp2.LeafSize
+	subtrees := make([]types.Hash256, rhp2.SectorSize/subtreeSize)
+	for i := range subtrees {
+		subtrees[i], _ = rhp2.ReaderRoot(bytes.NewReader(sector[i*subtreeSize : (i+1)*subtreeSize]))
+	}
+
+	ranges := [][2]uint64{
+		{0, rhp2.LeafSize},
+		{rhp2.LeafSize, rhp2.LeafSize},
+		{subtreeSize, subtreeSize},
+		{subtreeSize - rhp2.LeafSize, 3 * rhp2.LeafSize},
+		{rhp2.SectorSize - rhp2.LeafSize, rhp2.LeafSize},
+		{12345 * rhp2.LeafSize, 6789 * rhp2.LeafSize},
+		{0, rhp2.SectorSize},
+	}
+
+	for _, r := range ranges {
+		offset, length := r[0], r[1]
+		start, end := offset/rhp2.LeafSize, (offset+length)/rhp2.LeafSize
+		expected := rhp2.BuildProof(&sector, start, end, nil)
+
+		// with subtree roots, only the overlapping subtrees should be read
+		mr := &memSectorReader{sector: &sector, subtrees: subtrees}
+		data, proof, err := ReadSectorRange(mr, root, offset, length, true)
+		if err != nil {
+			t.Fatal(err)
+		} else if !bytes.Equal(data, sector[offset:offset+length]) {
+			t.Fatalf("range [%d, %d): data mismatch", offset, offset+length)
+		} else if len(proof) != len(expected) {
+			t.Fatalf("range [%d, %d): expected %d proof hashes, got %d", offset, offset+length, len(expected), len(proof))
+		} else if mr.read > length+2*subtreeSize {
+			t.Fatalf("range [%d, %d): read %d bytes", offset, offset+length, mr.read)
+		}
+		for i := range proof {
+			if proof[i] != expected[i] {
+				t.Fatalf("range [%d, %d): proof mismatch at %d", offset, offset+length, i)
+			}
+		}
+
+		// without subtree roots, the full sector should be read
+		mr = &memSectorReader{sector: &sector}
+		_, proof, err = ReadSectorRange(mr, root, offset, length, true)
+		if err != nil {
+			t.Fatal(err)
+		} else if mr.read != rhp2.SectorSize {
+			t.Fatalf("expected full sector read, got %d bytes", mr.read)
+		} else if len(proof) != len(expected) {
+			t.Fatalf("range [%d, %d): expected %d proof hashes, got %d", offset, offset+length, len(expected), len(proof))
+		}
+	}
+}
@@ -58,6 +58,12 @@ type (
 		Write(root types.Hash256, data *[rhp2.SectorSize]byte) (release func() error, _ error)
 		// Read reads the sector with the given root from the manager.
 		Read(root types.Hash256) (*[rhp2.SectorSize]byte, error)
+		// ReadPartial reads length bytes starting at offset from the sector
+		// with the given root.
+		ReadPartial(root types.Hash256, offset, length uint64) ([]byte, error)
+		// SubtreeRoots returns the cached subtree roots of the sector with
+		// the given root. If the roots are not cached, nil is returned.
+		SubtreeRoots(root types.Hash256) ([]types.Hash256, error)
 		// Sync syncs the data files of changed volumes.
 		Sync() error
 	}
@@ -93,6 +99,26 @@ type (
 		StartRPC(sessionID rhp.UID, rpc types.Specifier) (rpcID rhp.UID, end func(contracts.Usage, error))
 	}
 
+	// A BanManager records offenses committed by peers and temporarily bans
+	// repeat offenders.
+	BanManager interface {
+		Banned(peers ...string) bool
+		RecordOffense(reason error, peers ...string)
+	}
+
+	// A Drainer stops the host from accepting new sessions and contracts
+	// during planned maintenance.
+	Drainer interface {
+		// StartSession registers a new session. If the host is draining,
+		// rhp.ErrHostDraining is returned.
+		StartSession() (end func(), err error)
+		// Draining returns true if new contracts should be rejected.
+		Draining() bool
+		// Drained returns true if the remaining sessions should be
+		// rejected.
+		Drained() bool
+	}
+
 	// A SessionHandler handles the host side of the renter-host protocol and
 	// manages renter sessions
 	SessionHandler struct {
@@ -107,7 +133,9 @@ type (
 		tpool  TransactionPool
 		wallet Wallet
 
+		bans      BanManager
 		contracts ContractManager
+		drain     Drainer
 		sessions  SessionReporter
 		settings  SettingsReporter
 		storage   StorageManager
@@ -127,6 +155,26 @@ func (sh *SessionHandler) rpcLoop(sess *session, log *zap.Logger) error {
 		return fmt.Errorf("failed to read RPC ID: %w", err)
 	}
 
+	// check if the peer or the renter of the locked contract has been banned
+	// since the session started
+	peers := []string{rhp.PeerIP(sess.conn.RemoteAddr())}
+	if sess.contract.Revision.ParentID != (types.FileContractID{}) {
+		peers = append(peers, sess.contract.RenterKey().String())
+	}
+	if sh.bans.Banned(peers...) {
+		sess.t.WriteResponseErr(rhp.ErrPeerBanned)
+		return rhp.ErrPeerBanned
+	}
+
+	// reject all RPCs once the host has drained and new contracts while the
+	// host is draining
+	switch {
+	case sh.drain.Drained(),
+		sh.drain.Draining() && (id == rhp2.RPCFormContractID || id == rhp2.RPCRenewClearContractID):
+		sess.t.WriteResponseErr(rhp.ErrHostDraining)
+		return rhp.ErrHostDraining
+	}
+
 	rpcFn, ok := map[types.Specifier]func(*session, *zap.Logger) (contracts.Usage, error){
 		rhp2.RPCFormContractID:       sh.rpcFormContract,
 		rhp2.RPCRenewClearContractID: sh.rpcRenewAndClearContract,
@@ -140,6 +188,7 @@ func (sh *SessionHandler) rpcLoop(sess *session, log *zap.Logger) error {
 	if !ok {
 		err = fmt.Errorf("unknown RPC ID %q", id)
 		sess.t.WriteResponseErr(err)
+		sh.bans.RecordOffense(err, peers[0])
 		return err
 	}
 	start := time.Now()
@@ -149,6 +198,7 @@ func (sh *SessionHandler) rpcLoop(sess *session, log *zap.Logger) error {
 	usage, err := rpcFn(sess, log)
 	end(usage, err)
 	if err != nil {
+		sh.recordOffense(sess, err)
 		log.Warn("RPC error", zap.Error(err), zap.Duration("elapsed", time.Since(start)))
 		return fmt.Errorf("RPC %q error: %w", id, err)
 	}
@@ -156,6 +206,22 @@ func (sh *SessionHandler) rpcLoop(sess *session, log *zap.Logger) error {
 	return nil
 }
 
+// recordOffense records an offense against the session's peer if an RPC
+// failed due to the renter's misbehavior.
+func (sh *SessionHandler) recordOffense(sess *session, err error) {
+	if !errors.Is(err, ErrInvalidRenterSignature) && !errors.As(err, new(*rhp.RenterError)) {
+		return
+	}
+
+	peers := rhp.OffendingPeers(rhp.PeerIP(sess.conn.RemoteAddr()), err)
+	// if the error does not identify the renter, hold the renter of the
+	// locked contract responsible
+	if len(peers) == 1 && sess.contract.Revision.ParentID != (types.FileContractID{}) {
+		peers = append(peers, sess.contract.RenterKey().String())
+	}
+	sh.bans.RecordOffense(err, peers...)
+}
+
 // upgrade performs the RHP2 handshake and begins handling RPCs
 func (sh *SessionHandler) upgrade(conn net.Conn) error {
 	// wrap the conn with the bandwidth limiters
@@ -164,8 +230,26 @@ func (sh *SessionHandler) upgrade(conn net.Conn) error {
 
 	t, err := rhp2.NewHostTransport(rhpConn, sh.privateKey)
 	if err != nil {
+		err = fmt.Errorf("failed to complete handshake: %w", err)
+		if !rhp.IsDisconnect(err) {
+			sh.bans.RecordOffense(err, rhp.PeerIP(conn.RemoteAddr()))
+		}
+		return err
+	}
+
+	// reject new sessions while the host is draining
+	endDrain, err := sh.drain.StartSession()
+	if err != nil {
+		// respond to the renter's first RPC with the error so the renter
+		// receives a descriptive error instead of a closed connection
+		t.SetDeadline(time.Now().Add(30 * time.Second))
+		if _, readErr := t.ReadID(); readErr == nil {
+			t.WriteResponseErr(err)
+		}
+		t.Close()
 		return err
 	}
+	defer endDrain()
 
 	sessionID, end := sh.sessions.StartSession(rhpConn, rhp.SessionProtocolTCP, 2)
 	defer end()
@@ -194,8 +278,10 @@ func (sh *SessionHandler) upgrade(conn net.Conn) error {
 
 // Close closes the listener and stops accepting new connections
 func (sh *SessionHandler) Close() error {
+	// stop accepting new connections before waiting for in-flight RPCs
+	err := sh.listener.Close()
 	sh.tg.Stop()
-	return sh.listener.Close()
+	return err
 }
 
 // Settings returns the host's current settings
@@ -233,7 +319,7 @@ func (sh *SessionHandler) Settings() (rhp2.HostSettings, error) {
 		WindowSize:           settings.WindowSize,
 
 		// contract formation
-		AcceptingContracts: settings.AcceptingContracts,
+		AcceptingContracts: settings.AcceptingContracts && !sh.drain.Draining(),
 		MaxDuration:        settings.MaxContractDuration,
 		ContractPrice:      settings.ContractPrice,
 
@@ -265,6 +351,10 @@ func (sh *SessionHandler) Serve() error {
 		}
 		go func() {
 			defer conn.Close()
+			if sh.bans.Banned(rhp.PeerIP(conn.RemoteAddr())) {
+				sh.log.Debug("rejected connection from banned peer", zap.String("remoteAddr", conn.RemoteAddr().String()))
+				return
+			}
 			if err := sh.upgrade(conn); err != nil {
 				if errors.Is(err, rhp2.ErrRenterClosed) || errors.Is(err, io.EOF) {
 					// skip logging graceful close and EOF errors
@@ -282,7 +372,7 @@ func (sh *SessionHandler) LocalAddr() string {
 }
 
 // NewSessionHandler creates a new RHP2 SessionHandler
-func NewSessionHandler(l net.Listener, hostKey types.PrivateKey, rhp3Addr string, cm ChainManager, tpool TransactionPool, wallet Wallet, contracts ContractManager, settings SettingsReporter, storage StorageManager, monitor rhp.DataMonitor, sessions SessionReporter, log *zap.Logger) (*SessionHandler, error) {
+func NewSessionHandler(l net.Listener, hostKey types.PrivateKey, rhp3Addr string, cm ChainManager, tpool TransactionPool, wallet Wallet, contracts ContractManager, settings SettingsReporter, storage StorageManager, monitor rhp.DataMonitor, sessions SessionReporter, bans BanManager, drain Drainer, log *zap.Logger) (*SessionHandler, error) {
 	_, rhp3Port, err := net.SplitHostPort(rhp3Addr)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse rhp3 addr: %w", err)
@@ -299,7 +389,9 @@ func NewSessionHandler(l net.Listener, hostKey types.PrivateKey, rhp3Addr string
 		tpool:    tpool,
 		wallet:   wallet,
 
+		bans:      bans,
 		contracts: contracts,
+		drain:     drain,
 		sessions:  sessions,
 		settings:  settings,
 		storage:   storage,
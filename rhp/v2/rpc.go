@@ -190,7 +190,7 @@ func (sh *SessionHandler) rpcFormContract(s *session, log *zap.Logger) (contract
 	} else if err := validateRenterRevisionSignature(renterSignaturesResp.RevisionSignature, initialRevision.ParentID, sigHash, renterPub); err != nil {
 		err := fmt.Errorf("contract rejected: validation failed: %w", err)
 		s.t.WriteResponseErr(err)
-		return contracts.Usage{}, err
+		return contracts.Usage{}, &rhp.RenterError{RenterKey: renterPub, Err: err}
 	}
 	// add the renter's signatures to the transaction and contract revision
 	renterTxnSigs := len(renterSignaturesResp.ContractSignatures)
@@ -802,7 +802,7 @@ func (sh *SessionHandler) rpcRead(s *session, log *zap.Logger) (contracts.Usage,
 
 	// enter response loop
 	for i, sec := range req.Sections {
-		sector, err := sh.storage.Read(sec.MerkleRoot)
+		data, proof, err := rhp.ReadSectorRange(sh.storage, sec.MerkleRoot, sec.Offset, sec.Length, req.MerkleProof)
 		if err != nil {
 			err := fmt.Errorf("failed to get sector: %w", err)
 			s.t.WriteResponseErr(err)
@@ -810,12 +810,8 @@ func (sh *SessionHandler) rpcRead(s *session, log *zap.Logger) (contracts.Usage,
 		}
 
 		resp := &rhp2.RPCReadResponse{
-			Data: sector[sec.Offset : sec.Offset+sec.Length],
-		}
-		if req.MerkleProof {
-			start := sec.Offset / rhp2.LeafSize
-			end := (sec.Offset + sec.Length) / rhp2.LeafSize
-			resp.MerkleProof = rhp2.BuildProof(sector, start, end, nil)
+			Data:        data,
+			MerkleProof: proof,
 		}
 
 		// check for the stop signal and send the response
@@ -53,6 +53,11 @@ type (
 		storage   StorageManager
 		registry  RegistryManager
 
+		// prefetcher reads sectors ahead of the executing read
+		// instructions. It is nil if the program does not read multiple
+		// sectors.
+		prefetcher *sectorPrefetcher
+
 		committed bool
 	}
 )
@@ -225,22 +230,7 @@ func (pe *programExecutor) executeReadOffset(instr *rhp3.InstrReadOffset, log *z
 		return nil, nil, fmt.Errorf("failed to get root: %w", err)
 	}
 
-	sector, err := pe.storage.Read(root)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to read sector: %w", err)
-	}
-
-	// if no proof was requested, return the data
-	if !instr.ProofRequired {
-		return sector[relOffset : relOffset+length], nil, nil
-	}
-
-	proofStartTime := time.Now()
-	proofStart := relOffset / rhp2.LeafSize
-	proofEnd := (relOffset + length) / rhp2.LeafSize
-	proof := rhp2.BuildProof(sector, proofStart, proofEnd, nil)
-	log.Debug("built proof", zap.Duration("duration", time.Since(proofStartTime)))
-	return sector[relOffset : relOffset+length], proof, nil
+	return pe.readSectorRange(root, relOffset, length, instr.ProofRequired, log)
 }
 
 func (pe *programExecutor) executeReadSector(instr *rhp3.InstrReadSector, log *zap.Logger) ([]byte, []types.Hash256, error) {
@@ -273,13 +263,32 @@ func (pe *programExecutor) executeReadSector(instr *rhp3.InstrReadSector, log *z
 		return nil, nil, fmt.Errorf("failed to pay for instruction: %w", err)
 	}
 
-	sector, err := pe.storage.Read(root)
+	return pe.readSectorRange(root, offset, length, instr.ProofRequired, log)
+}
+
+// readSectorRange reads length bytes starting at offset from the sector with
+// the given root. Full sector reads go through the prefetcher. Partial reads
+// only read the required range from disk, expanded to the sector's cached
+// subtree boundaries if a proof is required.
+func (pe *programExecutor) readSectorRange(root types.Hash256, offset, length uint64, proofRequired bool, log *zap.Logger) ([]byte, []types.Hash256, error) {
+	if length > 0 && length < rhp2.SectorSize {
+		proofStartTime := time.Now()
+		data, proof, err := rhp.ReadSectorRange(pe.storage, root, offset, length, proofRequired)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read sector: %w", err)
+		} else if proofRequired {
+			log.Debug("built partial proof", zap.Duration("duration", time.Since(proofStartTime)))
+		}
+		return data, proof, nil
+	}
+
+	sector, err := pe.readSector(root)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to read sector: %w", err)
 	}
 
 	// if no proof was requested, return the data
-	if !instr.ProofRequired {
+	if !proofRequired {
 		return sector[offset : offset+length], nil, nil
 	}
 
@@ -561,6 +570,9 @@ func (pe *programExecutor) executeUpdateRegistry(instr *rhp3.InstrUpdateRegistry
 
 func (pe *programExecutor) executeProgram(ctx context.Context) <-chan rhp3.RPCExecuteProgramResponse {
 	outputs := make(chan rhp3.RPCExecuteProgramResponse, len(pe.instructions))
+	// start reading the program's sectors ahead of the instructions. The
+	// prefetcher is stopped when the context is cancelled.
+	pe.prefetcher = pe.startPrefetch(ctx)
 	go func() {
 		defer close(outputs)
 
@@ -722,9 +734,8 @@ func (pe *programExecutor) commit(s *rhp3.Stream) error {
 		// verify the renter signature
 		sigHash := rhp.HashRevision(revision)
 		if !pe.revision.RenterKey().VerifyHash(sigHash, req.Signature) {
-			err = ErrInvalidRenterSignature
-			s.WriteResponseErr(err)
-			return err
+			s.WriteResponseErr(ErrInvalidRenterSignature)
+			return &rhp.RenterError{RenterKey: pe.revision.RenterKey(), Err: ErrInvalidRenterSignature}
 		}
 
 		// sign and commit the revision
@@ -875,6 +886,10 @@ func (sh *SessionHandler) newExecutor(instructions []rhp3.Instruction, data []by
 		storage:   sh.storage,
 		registry:  sh.registry,
 	}
+	budget.SetSource(accounts.LedgerSource{
+		RPC:     rhp3.RPCExecuteProgramID,
+		Program: programLabel(instructions),
+	})
 
 	if revision != nil {
 		ex.remainingDuration = revision.Revision.WindowEnd - pt.HostBlockHeight
@@ -887,6 +902,29 @@ func (sh *SessionHandler) newExecutor(instructions []rhp3.Instruction, data []by
 	return ex, nil
 }
 
+// programLabel returns a compact description of a program's instructions for
+// the account ledger. Consecutive instructions of the same type are grouped,
+// e.g. "ReadSector(3),AppendSector".
+func programLabel(instructions []rhp3.Instruction) string {
+	var sb strings.Builder
+	for i := 0; i < len(instructions); {
+		label := instrLabel(instructions[i])
+		n := 1
+		for i+n < len(instructions) && instrLabel(instructions[i+n]) == label {
+			n++
+		}
+		if sb.Len() > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(label)
+		if n > 1 {
+			fmt.Fprintf(&sb, "(%d)", n)
+		}
+		i += n
+	}
+	return sb.String()
+}
+
 func instrLabel(instr rhp3.Instruction) string {
 	switch instr.(type) {
 	case *rhp3.InstrAppendSector:
@@ -1,9 +1,14 @@
 package rhp
 
 import (
+	"context"
+	"errors"
+	"sync"
 	"testing"
+	"time"
 
 	rhp2 "go.sia.tech/core/rhp/v2"
+	"go.sia.tech/core/types"
 	"lukechampine.com/frand"
 )
 
@@ -21,3 +26,156 @@ func BenchmarkReadSector(b *testing.B) {
 		}
 	}
 }
+
+type prefetchStorage struct {
+	StorageManager
+
+	mu       sync.Mutex
+	reads    int
+	active   int
+	maxReads int
+	sectors  map[types.Hash256]*[rhp2.SectorSize]byte
+}
+
+func (ps *prefetchStorage) Read(root types.Hash256) (*[rhp2.SectorSize]byte, error) {
+	ps.mu.Lock()
+	ps.reads++
+	ps.active++
+	if ps.active > ps.maxReads {
+		ps.maxReads = ps.active
+	}
+	sector, ok := ps.sectors[root]
+	ps.mu.Unlock()
+
+	time.Sleep(time.Millisecond)
+
+	ps.mu.Lock()
+	ps.active--
+	ps.mu.Unlock()
+	if !ok {
+		return nil, errors.New("sector not found")
+	}
+	return sector, nil
+}
+
+func TestSectorPrefetcher(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	storage := &prefetchStorage{
+		sectors: make(map[types.Hash256]*[rhp2.SectorSize]byte),
+	}
+	var roots []types.Hash256
+	for i := 0; i < 3*maxPrefetchSectors; i++ {
+		var sector [rhp2.SectorSize]byte
+		frand.Read(sector[:256])
+		root := rhp2.SectorRoot(&sector)
+		storage.sectors[root] = &sector
+		roots = append(roots, root)
+	}
+
+	sp := &sectorPrefetcher{
+		ctx:     ctx,
+		storage: storage,
+		slots:   make(chan struct{}, maxPrefetchSectors),
+	}
+	for _, root := range roots {
+		sp.sectors = append(sp.sectors, &prefetchedSector{root: root, done: make(chan struct{})})
+	}
+	go sp.run()
+
+	// read every other sector to check that skipped sectors free their slots
+	for i := 0; i < len(roots); i += 2 {
+		sector, err := sp.Read(roots[i])
+		if err != nil {
+			t.Fatal(err)
+		} else if rhp2.SectorRoot(sector) != roots[i] {
+			t.Fatalf("sector %d has wrong root", i)
+		}
+	}
+
+	// sectors that were not prefetched should be read directly
+	if _, err := sp.Read(frand.Entropy256()); err == nil {
+		t.Fatal("expected error reading missing sector")
+	}
+
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+	if storage.maxReads > maxPrefetchParallelism {
+		t.Fatalf("expected at most %d concurrent reads, got %d", maxPrefetchParallelism, storage.maxReads)
+	}
+}
+
+func TestSectorPrefetcherRelease(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	storage := &prefetchStorage{
+		sectors: make(map[types.Hash256]*[rhp2.SectorSize]byte),
+	}
+	var roots []types.Hash256
+	for i := 0; i < 3*maxPrefetchSectors; i++ {
+		var sector [rhp2.SectorSize]byte
+		frand.Read(sector[:256])
+		root := rhp2.SectorRoot(&sector)
+		storage.sectors[root] = &sector
+		roots = append(roots, root)
+	}
+
+	sp := &sectorPrefetcher{
+		ctx:     ctx,
+		storage: storage,
+		slots:   make(chan struct{}, maxPrefetchSectors),
+	}
+	entries := make([]*prefetchedSector, 0, len(roots))
+	for _, root := range roots {
+		entries = append(entries, &prefetchedSector{root: root, done: make(chan struct{})})
+	}
+	sp.sectors = append(sp.sectors, entries...)
+	go sp.run()
+
+	// read two of every three sectors so that both consumed and skipped
+	// sectors are released
+	for i := 0; i < len(roots); i++ {
+		if i%3 == 1 {
+			continue
+		}
+		sector, err := sp.Read(roots[i])
+		if err != nil {
+			t.Fatal(err)
+		} else if rhp2.SectorRoot(sector) != roots[i] {
+			t.Fatalf("sector %d has wrong root", i)
+		}
+
+		// every sector up to the one just read should be released
+		sp.mu.Lock()
+		for j := 0; j <= i; j++ {
+			if sp.sectors[j] != nil {
+				sp.mu.Unlock()
+				t.Fatalf("expected sector %d to be removed from the prefetcher", j)
+			} else if entries[j].sector != nil {
+				sp.mu.Unlock()
+				t.Fatalf("expected released sector %d to hold no data", j)
+			}
+		}
+		sp.mu.Unlock()
+	}
+
+	// skipped sectors that were still being read must not hold data once the
+	// read completes
+	for i, ps := range entries {
+		sp.mu.Lock()
+		started := ps.started
+		sp.mu.Unlock()
+		if !started {
+			continue
+		}
+		<-ps.done
+		sp.mu.Lock()
+		sector := ps.sector
+		sp.mu.Unlock()
+		if sector != nil {
+			t.Fatalf("expected released sector %d to hold no data", i)
+		}
+	}
+}
@@ -14,7 +14,7 @@ import (
 )
 
 // processContractPayment initializes an RPC budget using funds from a contract.
-func (sh *SessionHandler) processContractPayment(s *rhp3.Stream, height uint64) (rhp3.Account, types.Currency, error) {
+func (sh *SessionHandler) processContractPayment(s *rhp3.Stream, height uint64, rpc types.Specifier) (rhp3.Account, types.Currency, error) {
 	var req rhp3.PayByContractRequest
 	if err := s.ReadRequest(&req, maxRequestSize); err != nil {
 		return rhp3.ZeroAccount, types.ZeroCurrency, fmt.Errorf("failed to read contract payment request: %w", err)
@@ -30,6 +30,11 @@ func (sh *SessionHandler) processContractPayment(s *rhp3.Stream, height uint64)
 	}
 	defer sh.contracts.Unlock(req.ContractID)
 
+	if sh.bans.Banned(contract.RenterKey().String()) {
+		s.WriteResponseErr(rhp.ErrPeerBanned)
+		return rhp3.ZeroAccount, types.ZeroCurrency, rhp.ErrPeerBanned
+	}
+
 	current := contract.Revision
 	revision, err := rhp.Revise(current, req.RevisionNumber, req.ValidProofValues, req.MissedProofValues)
 	if err != nil {
@@ -56,7 +61,7 @@ func (sh *SessionHandler) processContractPayment(s *rhp3.Stream, height uint64)
 	// verify the renter's signature
 	sigHash := rhp.HashRevision(revision)
 	if !contract.RenterKey().VerifyHash(sigHash, req.Signature) {
-		return rhp3.ZeroAccount, types.ZeroCurrency, ErrInvalidRenterSignature
+		return rhp3.ZeroAccount, types.ZeroCurrency, &rhp.RenterError{RenterKey: contract.RenterKey(), Err: ErrInvalidRenterSignature}
 	}
 
 	settings := sh.settings.Settings()
@@ -75,12 +80,15 @@ func (sh *SessionHandler) processContractPayment(s *rhp3.Stream, height uint64)
 		},
 		Amount:     fundAmount,
 		Expiration: time.Now().Add(settings.AccountExpiry),
+		Source:     accounts.LedgerSource{RPC: rpc},
 	}
 	// credit the account with the deposit
 	_, err = sh.accounts.Credit(fundReq, true)
 	if err != nil {
 		if errors.Is(err, accounts.ErrBalanceExceeded) {
 			s.WriteResponseErr(accounts.ErrBalanceExceeded)
+		} else if errors.Is(err, accounts.ErrAccountFrozen) {
+			s.WriteResponseErr(accounts.ErrAccountFrozen)
 		} else {
 			s.WriteResponseErr(ErrHostInternalError)
 		}
@@ -115,14 +123,16 @@ func (sh *SessionHandler) processAccountPayment(s *rhp3.Stream, height uint64) (
 	case req.Account == rhp3.ZeroAccount:
 		return rhp3.ZeroAccount, types.ZeroCurrency, errors.New("cannot withdraw from zero account")
 	case !types.PublicKey(req.Account).VerifyHash(req.SigHash(), req.Signature):
-		return rhp3.ZeroAccount, types.ZeroCurrency, ErrInvalidRenterSignature
+		return rhp3.ZeroAccount, types.ZeroCurrency, &rhp.RenterError{RenterKey: types.PublicKey(req.Account), Err: ErrInvalidRenterSignature}
+	case sh.bans.Banned(types.PublicKey(req.Account).String()):
+		return rhp3.ZeroAccount, types.ZeroCurrency, rhp.ErrPeerBanned
 	}
 	return req.Account, req.Amount, nil
 }
 
 // processPayment initializes an RPC budget using funds from a contract or an
-// ephemeral account.
-func (sh *SessionHandler) processPayment(s *rhp3.Stream, pt *rhp3.HostPriceTable) (*accounts.Budget, error) {
+// ephemeral account. rpc is recorded in the account's ledger.
+func (sh *SessionHandler) processPayment(s *rhp3.Stream, pt *rhp3.HostPriceTable, rpc types.Specifier) (*accounts.Budget, error) {
 	var paymentType types.Specifier
 	if err := s.ReadRequest(&paymentType, 16); err != nil {
 		return nil, fmt.Errorf("failed to read payment type: %w", err)
@@ -133,7 +143,7 @@ func (sh *SessionHandler) processPayment(s *rhp3.Stream, pt *rhp3.HostPriceTable
 	currentHeight := pt.HostBlockHeight
 	switch paymentType {
 	case rhp3.PaymentTypeContract:
-		account, amount, err = sh.processContractPayment(s, currentHeight)
+		account, amount, err = sh.processContractPayment(s, currentHeight, rpc)
 		if err != nil {
 			return nil, fmt.Errorf("failed to process contract payment: %w", err)
 		}
@@ -147,7 +157,12 @@ func (sh *SessionHandler) processPayment(s *rhp3.Stream, pt *rhp3.HostPriceTable
 	}
 
 	// create a budget for the payment
-	return sh.accounts.Budget(account, amount)
+	budget, err := sh.accounts.Budget(account, amount)
+	if err != nil {
+		return nil, err
+	}
+	budget.SetSource(accounts.LedgerSource{RPC: rpc})
+	return budget, nil
 }
 
 // processFundAccountPayment processes a contract payment to fund an account for
@@ -175,6 +190,11 @@ func (sh *SessionHandler) processFundAccountPayment(pt rhp3.HostPriceTable, s *r
 	}
 	defer sh.contracts.Unlock(req.ContractID)
 
+	if sh.bans.Banned(contract.RenterKey().String()) {
+		s.WriteResponseErr(rhp.ErrPeerBanned)
+		return types.ZeroCurrency, types.ZeroCurrency, rhp.ErrPeerBanned
+	}
+
 	current := contract.Revision
 	revision, err := rhp.Revise(current, req.RevisionNumber, req.ValidProofValues, req.MissedProofValues)
 	if err != nil {
@@ -201,7 +221,7 @@ func (sh *SessionHandler) processFundAccountPayment(pt rhp3.HostPriceTable, s *r
 	// verify the renter's signature
 	sigHash := rhp.HashRevision(revision)
 	if !contract.RenterKey().VerifyHash(sigHash, req.Signature) {
-		return types.ZeroCurrency, types.ZeroCurrency, ErrInvalidRenterSignature
+		return types.ZeroCurrency, types.ZeroCurrency, &rhp.RenterError{RenterKey: contract.RenterKey(), Err: ErrInvalidRenterSignature}
 	}
 
 	settings := sh.settings.Settings()
@@ -222,12 +242,15 @@ func (sh *SessionHandler) processFundAccountPayment(pt rhp3.HostPriceTable, s *r
 		Cost:       pt.FundAccountCost,
 		Amount:     totalAmount.Sub(pt.FundAccountCost),
 		Expiration: time.Now().Add(settings.AccountExpiry),
+		Source:     accounts.LedgerSource{RPC: rhp3.RPCFundAccountID},
 	}
 	// credit the account with the deposit
 	balance, err = sh.accounts.Credit(fundReq, false)
 	if err != nil {
 		if errors.Is(err, accounts.ErrBalanceExceeded) {
 			s.WriteResponseErr(accounts.ErrBalanceExceeded)
+		} else if errors.Is(err, accounts.ErrAccountFrozen) {
+			s.WriteResponseErr(accounts.ErrAccountFrozen)
 		} else {
 			s.WriteResponseErr(ErrHostInternalError)
 		}
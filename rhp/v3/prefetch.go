@@ -0,0 +1,273 @@
+package rhp
+
+import (
+	"context"
+	"sync"
+
+	rhp2 "go.sia.tech/core/rhp/v2"
+	rhp3 "go.sia.tech/core/rhp/v3"
+	"go.sia.tech/core/types"
+)
+
+const (
+	// maxPrefetchSectors is the maximum number of sectors that can be read
+	// ahead of the executing instruction. It bounds the memory used by the
+	// prefetcher to maxPrefetchSectors * SectorSize.
+	maxPrefetchSectors = 8
+	// maxPrefetchParallelism is the maximum number of sectors read from disk
+	// concurrently.
+	maxPrefetchParallelism = 4
+)
+
+type (
+	// A prefetchedSector is a sector read ahead of the instruction that
+	// requires it.
+	prefetchedSector struct {
+		root types.Hash256
+		done chan struct{}
+
+		// the fields below are guarded by the prefetcher's mutex
+		started   bool
+		cancelled bool
+		released  bool
+
+		// sector and err are set before done is closed. sector is cleared
+		// when the sector is consumed or skipped so that the prefetcher
+		// does not keep it in memory.
+		sector *[rhp2.SectorSize]byte
+		err    error
+	}
+
+	// A sectorPrefetcher concurrently reads the sectors required by a
+	// program's read instructions before they are executed. Sectors are
+	// read in program order. Instructions that do not find their sector in
+	// the prefetcher fall back to reading it directly.
+	sectorPrefetcher struct {
+		ctx     context.Context
+		storage StorageManager
+
+		// slots bounds the number of sectors that have been read, or are
+		// being read, but have not been consumed.
+		slots chan struct{}
+
+		mu sync.Mutex
+		// sectors is the list of sectors to prefetch in program order.
+		// Consumed and skipped sectors are set to nil.
+		sectors []*prefetchedSector
+		next    int // index of the next sector to be consumed
+	}
+)
+
+// release frees the slot and the sector held by a prefetched sector. The
+// caller must hold the prefetcher's mutex.
+func (sp *sectorPrefetcher) release(ps *prefetchedSector) {
+	ps.sector = nil
+	if ps.started && !ps.released {
+		ps.released = true
+		<-sp.slots
+	}
+}
+
+// run reads the pending sectors in order until all sectors have been read or
+// the context is cancelled.
+func (sp *sectorPrefetcher) run() {
+	inflight := make(chan struct{}, maxPrefetchParallelism)
+	for i := 0; ; i++ {
+		sp.mu.Lock()
+		if i >= len(sp.sectors) {
+			sp.mu.Unlock()
+			return
+		}
+		ps := sp.sectors[i]
+		cancelled := ps == nil || ps.cancelled
+		sp.mu.Unlock()
+		if cancelled {
+			continue
+		}
+
+		// wait for a free slot and a free reader
+		select {
+		case <-sp.ctx.Done():
+			return
+		case sp.slots <- struct{}{}:
+		}
+		select {
+		case <-sp.ctx.Done():
+			<-sp.slots
+			return
+		case inflight <- struct{}{}:
+		}
+
+		sp.mu.Lock()
+		if ps.cancelled {
+			// the sector was skipped while waiting for a slot
+			sp.mu.Unlock()
+			<-inflight
+			<-sp.slots
+			continue
+		}
+		ps.started = true
+		sp.mu.Unlock()
+
+		go func(ps *prefetchedSector) {
+			defer func() { <-inflight }()
+			sector, err := sp.storage.Read(ps.root)
+
+			sp.mu.Lock()
+			defer sp.mu.Unlock()
+			if !ps.cancelled {
+				// skipped sectors are discarded immediately
+				ps.sector, ps.err = sector, err
+			}
+			close(ps.done)
+		}(ps)
+	}
+}
+
+// Read returns the sector with the given root. If the sector was not
+// prefetched, it is read directly from the storage manager. Any sectors
+// prefetched before the requested sector are discarded since instructions
+// are executed in order.
+func (sp *sectorPrefetcher) Read(root types.Hash256) (*[rhp2.SectorSize]byte, error) {
+	sp.mu.Lock()
+	var ps *prefetchedSector
+	var index int
+	for i := sp.next; i < len(sp.sectors); i++ {
+		if sp.sectors[i].root != root {
+			continue
+		}
+		ps = sp.sectors[i]
+		// discard the skipped sectors
+		for j := sp.next; j < i; j++ {
+			skipped := sp.sectors[j]
+			skipped.cancelled = true
+			sp.release(skipped)
+			sp.sectors[j] = nil
+		}
+		index = i
+		sp.next = i + 1
+		break
+	}
+	sp.mu.Unlock()
+
+	if ps == nil {
+		return sp.storage.Read(root)
+	}
+
+	select {
+	case <-sp.ctx.Done():
+		return nil, sp.ctx.Err()
+	case <-ps.done:
+	}
+
+	sp.mu.Lock()
+	sector, err := ps.sector, ps.err
+	sp.release(ps)
+	// the sector has been read, drop it from the prefetcher
+	sp.sectors[index] = nil
+	sp.mu.Unlock()
+
+	if err != nil {
+		// retry the read directly so that the instruction's error is
+		// unchanged
+		return sp.storage.Read(root)
+	}
+	return sector, nil
+}
+
+// prefetchRoots returns the roots of the full sectors read by the program's
+// instructions in execution order. Reads are only included while the
+// cumulative cost of the reads can be paid by the budget. ReadOffset
+// instructions are only resolved until the first instruction that modifies
+// the contract's sector roots.
+func (pe *programExecutor) prefetchRoots() (roots []types.Hash256) {
+	remaining := pe.budget.Remaining()
+	pay := func(cost rhp3.ResourceCost) bool {
+		total := cost.Base.Add(cost.Storage).Add(cost.Egress).Add(cost.Ingress)
+		if total.Cmp(remaining) > 0 {
+			return false
+		}
+		remaining = remaining.Sub(total)
+		return true
+	}
+
+	modified := false
+	for _, instruction := range pe.instructions {
+		switch instr := instruction.(type) {
+		case *rhp3.InstrReadSector:
+			root, err := pe.programData.Hash(instr.MerkleRootOffset)
+			if err != nil {
+				return
+			}
+			length, err := pe.programData.Uint64(instr.LengthOffset)
+			if err != nil || length == 0 || length > rhp2.SectorSize {
+				return
+			} else if !pay(pe.priceTable.ReadSectorCost(length)) {
+				return
+			} else if length != rhp2.SectorSize {
+				// partial reads do not read the full sector
+				continue
+			}
+			roots = append(roots, root)
+		case *rhp3.InstrReadOffset:
+			if modified || pe.updater == nil {
+				continue
+			}
+			offset, err := pe.programData.Uint64(instr.OffsetOffset)
+			if err != nil {
+				return
+			}
+			length, err := pe.programData.Uint64(instr.LengthOffset)
+			if err != nil || length > rhp2.SectorSize {
+				return
+			} else if !pay(pe.priceTable.ReadOffsetCost(length)) {
+				return
+			} else if length != rhp2.SectorSize {
+				continue
+			}
+			root, err := pe.updater.SectorRoot(offset / rhp2.SectorSize)
+			if err != nil {
+				continue
+			}
+			roots = append(roots, root)
+		case *rhp3.InstrAppendSector, *rhp3.InstrAppendSectorRoot, *rhp3.InstrDropSectors,
+			*rhp3.InstrSwapSector, *rhp3.InstrUpdateSector:
+			modified = true
+		}
+	}
+	return
+}
+
+// startPrefetch starts reading the sectors required by the program's read
+// instructions. The prefetcher stops when ctx is cancelled. If the program
+// does not read more than one sector, nil is returned.
+func (pe *programExecutor) startPrefetch(ctx context.Context) *sectorPrefetcher {
+	roots := pe.prefetchRoots()
+	if len(roots) <= 1 {
+		// there's nothing to gain from prefetching a single sector
+		return nil
+	}
+
+	sp := &sectorPrefetcher{
+		ctx:     ctx,
+		storage: pe.storage,
+		slots:   make(chan struct{}, maxPrefetchSectors),
+		sectors: make([]*prefetchedSector, 0, len(roots)),
+	}
+	for _, root := range roots {
+		sp.sectors = append(sp.sectors, &prefetchedSector{
+			root: root,
+			done: make(chan struct{}),
+		})
+	}
+	go sp.run()
+	return sp
+}
+
+// readSector reads a sector using the prefetcher if one is running.
+func (pe *programExecutor) readSector(root types.Hash256) (*[rhp2.SectorSize]byte, error) {
+	if pe.prefetcher == nil {
+		return pe.storage.Read(root)
+	}
+	return pe.prefetcher.Read(root)
+}
@@ -13,6 +13,7 @@ import (
 	"go.sia.tech/core/types"
 	"go.sia.tech/hostd/host/accounts"
 	"go.sia.tech/hostd/host/contracts"
+	"go.sia.tech/hostd/host/registry"
 	"go.sia.tech/hostd/host/settings"
 	"go.sia.tech/hostd/host/storage"
 	"go.sia.tech/hostd/internal/threadgroup"
@@ -67,6 +68,12 @@ type (
 		Write(root types.Hash256, data *[rhp2.SectorSize]byte) (release func() error, _ error)
 		// Read reads the sector with the given root from the manager.
 		Read(root types.Hash256) (*[rhp2.SectorSize]byte, error)
+		// ReadPartial reads length bytes starting at offset from the sector
+		// with the given root.
+		ReadPartial(root types.Hash256, offset, length uint64) ([]byte, error)
+		// SubtreeRoots returns the cached subtree roots of the sector with
+		// the given root. If the roots are not cached, nil is returned.
+		SubtreeRoots(root types.Hash256) ([]types.Hash256, error)
 		// Sync syncs the data files of changed volumes.
 		Sync() error
 
@@ -81,6 +88,11 @@ type (
 		Get(key rhp3.RegistryKey) (rhp3.RegistryValue, error)
 		Put(value rhp3.RegistryEntry, expirationHeight uint64) (rhp3.RegistryValue, error)
 		Entries() (count uint64, max uint64, err error)
+
+		// Subscribe and Unsubscribe manage notifications of registry
+		// updates for the subscription RPC.
+		Subscribe(sub registry.Subscriber, keys ...types.Hash256)
+		Unsubscribe(sub registry.Subscriber, keys ...types.Hash256)
 	}
 
 	// A ChainManager provides access to the current state of the blockchain.
@@ -113,6 +125,26 @@ type (
 		StartRPC(sessionID rhp.UID, rpc types.Specifier) (rpcID rhp.UID, end func(contracts.Usage, error))
 	}
 
+	// A BanManager records offenses committed by peers and temporarily bans
+	// repeat offenders.
+	BanManager interface {
+		Banned(peers ...string) bool
+		RecordOffense(reason error, peers ...string)
+	}
+
+	// A Drainer stops the host from accepting new sessions and contracts
+	// during planned maintenance.
+	Drainer interface {
+		// StartSession registers a new session. If the host is draining,
+		// rhp.ErrHostDraining is returned.
+		StartSession() (end func(), err error)
+		// Draining returns true if new contracts should be rejected.
+		Draining() bool
+		// Drained returns true if the remaining sessions should be
+		// rejected.
+		Drained() bool
+	}
+
 	// A SessionHandler handles the host side of the renter-host protocol and
 	// manages renter sessions
 	SessionHandler struct {
@@ -123,7 +155,9 @@ type (
 		tg       *threadgroup.ThreadGroup
 
 		accounts  AccountManager
+		bans      BanManager
 		contracts ContractManager
+		drain     Drainer
 		sessions  SessionReporter
 		registry  RegistryManager
 		storage   StorageManager
@@ -171,7 +205,7 @@ var (
 )
 
 // handleHostStream handles streams routed to the "host" subscriber
-func (sh *SessionHandler) handleHostStream(s *rhp3.Stream, sessionID rhp.UID, log *zap.Logger) {
+func (sh *SessionHandler) handleHostStream(s *rhp3.Stream, sessionID rhp.UID, peerIP string, log *zap.Logger) {
 	defer s.Close() // close the stream when the RPC has completed
 
 	done, err := sh.tg.Add() // add the RPC to the threadgroup
@@ -193,10 +227,21 @@ func (sh *SessionHandler) handleHostStream(s *rhp3.Stream, sessionID rhp.UID, lo
 		rhp3.RPCFundAccountID:      sh.handleRPCFundAccount,
 		rhp3.RPCLatestRevisionID:   sh.handleRPCLatestRevision,
 		rhp3.RPCRenewContractID:    sh.handleRPCRenew,
+
+		RPCRegistrySubscribeID: sh.handleRPCRegistrySubscription,
 	}
 	rpcFn, ok := rpcs[rpc]
 	if !ok {
 		log.Debug("unrecognized RPC ID", zap.String("rpc", rpc.String()))
+		sh.bans.RecordOffense(fmt.Errorf("unrecognized RPC ID %q", rpc), peerIP)
+		return
+	} else if sh.bans.Banned(peerIP) {
+		// the peer may have been banned since the session started
+		s.WriteResponseErr(rhp.ErrPeerBanned)
+		return
+	} else if sh.drain.Drained() {
+		// the drain deadline has passed, reject the remaining sessions
+		s.WriteResponseErr(rhp.ErrHostDraining)
 		return
 	}
 
@@ -208,12 +253,29 @@ func (sh *SessionHandler) handleHostStream(s *rhp3.Stream, sessionID rhp.UID, lo
 	usage, err := rpcFn(s, log)
 	end(usage, err)
 	if err != nil {
+		if errors.Is(err, ErrInvalidRenterSignature) || errors.As(err, new(*rhp.RenterError)) {
+			sh.bans.RecordOffense(err, rhp.OffendingPeers(peerIP, err)...)
+		}
 		log.Warn("RPC failed", zap.Error(err), zap.Duration("elapsed", time.Since(rpcStart)))
 		return
 	}
 	log.Info("RPC success", zap.Duration("elapsed", time.Since(rpcStart)))
 }
 
+// rejectSession responds to the renter's first RPC with err so that the
+// renter receives a descriptive error instead of a closed connection.
+func rejectSession(t *rhp3.Transport, err error) {
+	stream, acceptErr := t.AcceptStream()
+	if acceptErr != nil {
+		return
+	}
+	defer stream.Close()
+	stream.SetDeadline(time.Now().Add(30 * time.Second))
+	if _, readErr := stream.ReadID(); readErr == nil {
+		stream.WriteResponseErr(err)
+	}
+}
+
 // HostKey returns the host's ed25519 public key
 func (sh *SessionHandler) HostKey() types.UnlockKey {
 	return sh.privateKey.PublicKey().UnlockKey()
@@ -221,8 +283,10 @@ func (sh *SessionHandler) HostKey() types.UnlockKey {
 
 // Close closes the session handler and stops accepting new connections.
 func (sh *SessionHandler) Close() error {
+	// stop accepting new connections before waiting for in-flight RPCs
+	err := sh.listener.Close()
 	sh.tg.Stop()
-	return sh.listener.Close()
+	return err
 }
 
 // Serve starts the host RPC server.
@@ -238,6 +302,12 @@ func (sh *SessionHandler) Serve() error {
 		go func() {
 			defer conn.Close()
 
+			peerIP := rhp.PeerIP(conn.RemoteAddr())
+			if sh.bans.Banned(peerIP) {
+				sh.log.Debug("rejected connection from banned peer", zap.String("peerAddress", conn.RemoteAddr().String()))
+				return
+			}
+
 			// wrap the conn with the bandwidth limiters
 			ingress, egress := sh.settings.BandwidthLimiters()
 			rhpConn := rhp.NewConn(conn, sh.monitor, ingress, egress)
@@ -252,11 +322,22 @@ func (sh *SessionHandler) Serve() error {
 			// upgrade the connection to RHP3
 			t, err := rhp3.NewHostTransport(rhpConn, sh.privateKey)
 			if err != nil {
+				if !rhp.IsDisconnect(err) {
+					sh.bans.RecordOffense(fmt.Errorf("failed to complete handshake: %w", err), peerIP)
+				}
 				log.Debug("failed to upgrade conn", zap.Error(err))
 				return
 			}
 			defer t.Close()
 
+			// reject new sessions while the host is draining
+			endDrain, err := sh.drain.StartSession()
+			if err != nil {
+				rejectSession(t, err)
+				return
+			}
+			defer endDrain()
+
 			for {
 				stream, err := t.AcceptStream()
 				if err != nil {
@@ -266,7 +347,7 @@ func (sh *SessionHandler) Serve() error {
 					return
 				}
 
-				go sh.handleHostStream(stream, sessionID, log)
+				go sh.handleHostStream(stream, sessionID, peerIP, log)
 			}
 		}()
 	}
@@ -278,7 +359,7 @@ func (sh *SessionHandler) LocalAddr() string {
 }
 
 // NewSessionHandler creates a new SessionHandler
-func NewSessionHandler(l net.Listener, hostKey types.PrivateKey, chain ChainManager, tpool TransactionPool, wallet Wallet, accounts AccountManager, contracts ContractManager, registry RegistryManager, storage StorageManager, settings SettingsReporter, monitor rhp.DataMonitor, sessions SessionReporter, log *zap.Logger) (*SessionHandler, error) {
+func NewSessionHandler(l net.Listener, hostKey types.PrivateKey, chain ChainManager, tpool TransactionPool, wallet Wallet, accounts AccountManager, contracts ContractManager, registry RegistryManager, storage StorageManager, settings SettingsReporter, monitor rhp.DataMonitor, sessions SessionReporter, bans BanManager, drain Drainer, log *zap.Logger) (*SessionHandler, error) {
 	sh := &SessionHandler{
 		privateKey: hostKey,
 
@@ -291,7 +372,9 @@ func NewSessionHandler(l net.Listener, hostKey types.PrivateKey, chain ChainMana
 		wallet: wallet,
 
 		accounts:  accounts,
+		bans:      bans,
 		contracts: contracts,
+		drain:     drain,
 		sessions:  sessions,
 		registry:  registry,
 		settings:  settings,
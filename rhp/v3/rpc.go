@@ -64,7 +64,7 @@ func (sh *SessionHandler) handleRPCPriceTable(s *rhp3.Stream, log *zap.Logger) (
 
 	// process the payment, catch connection closed errors since the renter
 	// likely did not intend to pay
-	budget, err := sh.processPayment(s, &pt)
+	budget, err := sh.processPayment(s, &pt, rhp3.RPCUpdatePriceTableID)
 	if isNonPaymentErr(err) {
 		return contracts.Usage{}, nil
 	} else if err != nil {
@@ -145,7 +145,7 @@ func (sh *SessionHandler) handleRPCAccountBalance(s *rhp3.Stream, log *zap.Logge
 	}
 
 	// read the payment from the stream
-	budget, err := sh.processPayment(s, &pt)
+	budget, err := sh.processPayment(s, &pt, rhp3.RPCAccountBalanceID)
 	if err != nil {
 		err = fmt.Errorf("failed to process payment: %w", err)
 		s.WriteResponseErr(err)
@@ -215,7 +215,7 @@ func (sh *SessionHandler) handleRPCLatestRevision(s *rhp3.Stream, log *zap.Logge
 		return contracts.Usage{}, err
 	}
 
-	budget, err := sh.processPayment(s, &pt)
+	budget, err := sh.processPayment(s, &pt, rhp3.RPCLatestRevisionID)
 	if isNonPaymentErr(err) {
 		return contracts.Usage{}, nil
 	} else if err != nil {
@@ -243,6 +243,9 @@ func (sh *SessionHandler) handleRPCRenew(s *rhp3.Stream, log *zap.Logger) (contr
 	if !sh.settings.Settings().AcceptingContracts {
 		s.WriteResponseErr(ErrNotAcceptingContracts)
 		return contracts.Usage{}, ErrNotAcceptingContracts
+	} else if sh.drain.Draining() {
+		s.WriteResponseErr(rhp.ErrHostDraining)
+		return contracts.Usage{}, rhp.ErrHostDraining
 	}
 	pt, err := sh.readPriceTable(s)
 	if errors.Is(err, ErrNoPriceTable) {
@@ -310,7 +313,7 @@ func (sh *SessionHandler) handleRPCRenew(s *rhp3.Stream, log *zap.Logger) (contr
 	if !existing.RenterKey().VerifyHash(finalRevisionSigHash, req.FinalRevisionSignature) { // important to verify using the existing contract's renter key
 		err := fmt.Errorf("failed to verify final revision signature: %w", ErrInvalidRenterSignature)
 		s.WriteResponseErr(err)
-		return contracts.Usage{}, err
+		return contracts.Usage{}, &rhp.RenterError{RenterKey: existing.RenterKey(), Err: err}
 	}
 	// sign the clearing revision
 	signedClearingRevision := contracts.SignedRevision{
@@ -368,7 +371,7 @@ func (sh *SessionHandler) handleRPCRenew(s *rhp3.Stream, log *zap.Logger) (contr
 	if err := validateRenterRevisionSignature(renterSigsResp.RevisionSignature, renewalRevision.ParentID, renewalSigHash, renterKey); err != nil {
 		err := fmt.Errorf("failed to verify renter revision signature: %w", ErrInvalidRenterSignature)
 		s.WriteResponseErr(err)
-		return contracts.Usage{}, err
+		return contracts.Usage{}, &rhp.RenterError{RenterKey: renterKey, Err: err}
 	}
 	signedRenewal := contracts.SignedRevision{
 		Revision:        renewalRevision,
@@ -453,7 +456,7 @@ func (sh *SessionHandler) handleRPCExecute(s *rhp3.Stream, log *zap.Logger) (con
 	}
 
 	// create the program budget
-	budget, err := sh.processPayment(s, &pt)
+	budget, err := sh.processPayment(s, &pt, rhp3.RPCExecuteProgramID)
 	if err != nil {
 		err = fmt.Errorf("failed to process payment: %w", err)
 		s.WriteResponseErr(err)
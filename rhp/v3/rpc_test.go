@@ -345,6 +345,11 @@ func TestRenew(t *testing.T) {
 		}
 		time.Sleep(100 * time.Millisecond)
 
+		// flush the account debit journal so the contract usage is up to date
+		if err := host.Accounts().Flush(); err != nil {
+			t.Fatal(err)
+		}
+
 		expectedRevenue := pt.ContractPrice.Add(pt.UpdatePriceTableCost)
 		old, err := host.Contracts().Contract(origin.ID())
 		if err != nil {
@@ -452,6 +457,11 @@ func TestRenew(t *testing.T) {
 		baseStorageRevenue := pt.RenewContractCost.Add(pt.WriteStoreCost.Mul64(origin.Revision.Filesize).Mul64(extension)) // renew contract cost is included because it is burned on failure
 		baseRiskedCollateral := settings.Collateral.Mul64(extension).Mul64(origin.Revision.Filesize)
 
+		// flush the account debit journal so the contract usage is up to date
+		if err := host.Accounts().Flush(); err != nil {
+			t.Fatal(err)
+		}
+
 		expectedExchange := pt.ContractPrice.Add(pt.FundAccountCost).Add(pt.UpdatePriceTableCost).Add(usage.Base)
 		old, err := host.Contracts().Contract(origin.ID())
 		if err != nil {
@@ -626,3 +636,158 @@ func BenchmarkReadSector(b *testing.B) {
 		}
 	}
 }
+
+func TestRegistrySubscription(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	renter, host, err := test.NewTestingPair(t.TempDir(), log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer renter.Close()
+	defer host.Close()
+
+	s := test.DefaultSettings
+	s.MaxRegistryEntries = 100
+	if err := host.UpdateSettings(s); err != nil {
+		t.Fatal(err)
+	}
+
+	session, err := renter.NewRHP3Session(context.Background(), host.RHP3Addr(), host.PublicKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+
+	revision, err := renter.FormContract(context.Background(), host.RHP2Addr(), host.PublicKey(), types.Siacoins(50), types.Siacoins(100), 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	account := rhp3.Account(renter.PublicKey())
+	payment := proto3.ContractPayment(&revision, renter.PrivateKey(), account)
+	if _, err := session.RegisterPriceTable(payment); err != nil {
+		t.Fatal(err)
+	} else if _, err := session.FundAccount(account, payment, types.Siacoins(10)); err != nil {
+		t.Fatal(err)
+	}
+
+	// store an initial value in the registry
+	entryKey := types.GeneratePrivateKey()
+	signEntry := func(entry rhp3.RegistryEntry) rhp3.RegistryEntry {
+		entry.Signature = entryKey.SignHash(entry.Hash())
+		return entry
+	}
+	entry := signEntry(rhp3.RegistryEntry{
+		RegistryKey: rhp3.RegistryKey{
+			PublicKey: entryKey.PublicKey(),
+			Tweak:     frand.Entropy256(),
+		},
+		RegistryValue: rhp3.RegistryValue{
+			Data: frand.Bytes(32),
+			Type: rhp3.EntryTypeArbitrary,
+		},
+	})
+	if _, err := host.Registry().Put(entry, 100); err != nil {
+		t.Fatal(err)
+	}
+
+	budget := types.Siacoins(1)
+	sub, err := session.SubscribeToRegistry(proto3.AccountPayment(account, renter.PrivateKey()), budget)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// subscribe to the existing key and a key that does not exist yet
+	missing := rhp3.RegistryKey{
+		PublicKey: entryKey.PublicKey(),
+		Tweak:     frand.Entropy256(),
+	}
+	values, err := sub.Subscribe(entry.RegistryKey, missing)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(values) != 1 {
+		t.Fatalf("expected 1 value, got %d", len(values))
+	} else if !reflect.DeepEqual(values[0], entry) {
+		t.Fatal("initial value mismatch")
+	}
+
+	// update the existing entry
+	entry.Revision++
+	entry = signEntry(entry)
+	if _, err := host.Registry().Put(entry, 100); err != nil {
+		t.Fatal(err)
+	}
+	updates, err := sub.Next()
+	if err != nil {
+		t.Fatal(err)
+	} else if len(updates) != 1 || !reflect.DeepEqual(updates[0], entry) {
+		t.Fatal("expected update to match new entry")
+	}
+
+	// create the missing entry
+	created := signEntry(rhp3.RegistryEntry{
+		RegistryKey: missing,
+		RegistryValue: rhp3.RegistryValue{
+			Data: frand.Bytes(32),
+			Type: rhp3.EntryTypeArbitrary,
+		},
+	})
+	if _, err := host.Registry().Put(created, 100); err != nil {
+		t.Fatal(err)
+	}
+	updates, err = sub.Next()
+	if err != nil {
+		t.Fatal(err)
+	} else if len(updates) != 1 || !reflect.DeepEqual(updates[0], created) {
+		t.Fatal("expected update to match created entry")
+	}
+
+	// unsubscribed keys should not be sent
+	if err := sub.Unsubscribe(missing); err != nil {
+		t.Fatal(err)
+	}
+	created.Revision++
+	created = signEntry(created)
+	if _, err := host.Registry().Put(created, 100); err != nil {
+		t.Fatal(err)
+	}
+
+	remaining, err := sub.Stop()
+	if err != nil {
+		t.Fatal(err)
+	} else if remaining.IsZero() || remaining.Cmp(budget) >= 0 {
+		t.Fatalf("expected part of the budget to be spent, %v remaining", remaining)
+	}
+
+	// the unspent budget should be returned to the account
+	host.Accounts().Flush()
+	balance, err := host.Accounts().Balance(account)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := types.Siacoins(10).Sub(budget.Sub(remaining))
+	if !balance.Equals(expected) {
+		t.Fatalf("expected balance %v, got %v", expected, balance)
+	}
+
+	// values delivered before a failed request should still be paid for
+	sub, err = session.SubscribeToRegistry(proto3.AccountPayment(account, renter.PrivateKey()), budget)
+	if err != nil {
+		t.Fatal(err)
+	} else if _, err := sub.Subscribe(entry.RegistryKey); err != nil {
+		t.Fatal(err)
+	}
+	// a request larger than the maximum request size fails to decode
+	oversized := make([]rhp3.RegistryKey, 1100)
+	if _, err := sub.Subscribe(oversized...); err == nil {
+		t.Fatal("expected oversized request to fail")
+	}
+
+	time.Sleep(time.Second) // wait for the host to commit the budget
+	host.Accounts().Flush()
+	if balance, err := host.Accounts().Balance(account); err != nil {
+		t.Fatal(err)
+	} else if balance.Cmp(expected) >= 0 {
+		t.Fatalf("expected delivered values to be paid for, balance %v", balance)
+	}
+}
@@ -0,0 +1,420 @@
+package rhp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	rhp3 "go.sia.tech/core/rhp/v3"
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/host/accounts"
+	"go.sia.tech/hostd/host/contracts"
+	"go.sia.tech/hostd/host/registry"
+	"go.uber.org/zap"
+)
+
+const (
+	// subscriptionPeriod is the interval at which the host charges for the
+	// memory used by a registry subscription.
+	subscriptionPeriod = time.Minute
+	// subscriptionKeyMemory is the number of bytes of memory charged for
+	// each subscribed key.
+	subscriptionKeyMemory = 32 + 32 + rhp3.MaxValueDataSize + 8 + 1 + 64
+	// maxSubscriptionKeys is the maximum number of keys a single
+	// subscription can watch.
+	maxSubscriptionKeys = 1000
+	// maxSubscriptionRequestSize is the maximum size of a subscription
+	// request.
+	maxSubscriptionRequestSize = 64*maxSubscriptionKeys + 1024
+)
+
+var (
+	// RPCRegistrySubscribeID is the ID of the registry subscription RPC. The
+	// protocol differs from siad's, so it does not use
+	// rhp3.RPCRegistrySubscriptionID.
+	RPCRegistrySubscribeID = types.NewSpecifier("RegistrySub")
+
+	// SubscriptionRequestSubscribe adds keys to a registry subscription.
+	SubscriptionRequestSubscribe = types.NewSpecifier("Subscribe")
+	// SubscriptionRequestUnsubscribe removes keys from a registry
+	// subscription.
+	SubscriptionRequestUnsubscribe = types.NewSpecifier("Unsubscribe")
+	// SubscriptionRequestStop ends a registry subscription.
+	SubscriptionRequestStop = types.NewSpecifier("Stop")
+
+	// SubscriptionResponseValues is sent in response to a subscribe request
+	// with the current value of each subscribed key that exists.
+	SubscriptionResponseValues = types.NewSpecifier("Values")
+	// SubscriptionResponseUnsubscribed is sent in response to an
+	// unsubscribe request.
+	SubscriptionResponseUnsubscribed = types.NewSpecifier("Unsubscribed")
+	// SubscriptionResponseUpdate is pushed to the renter when a subscribed
+	// entry is updated.
+	SubscriptionResponseUpdate = types.NewSpecifier("Update")
+	// SubscriptionResponseStopped is sent in response to a stop request.
+	SubscriptionResponseStopped = types.NewSpecifier("Stopped")
+
+	// ErrSubscriptionBudgetExhausted is returned when a registry
+	// subscription's prepaid budget can no longer cover its costs.
+	ErrSubscriptionBudgetExhausted = errors.New("subscription budget exhausted")
+	// ErrSubscriptionPriceTableExpired is returned when the price table a
+	// registry subscription was opened with expires. The renter must
+	// resubscribe with a new price table.
+	ErrSubscriptionPriceTableExpired = errors.New("subscription price table expired")
+)
+
+type (
+	// RPCRegistrySubscriptionRequest is sent by the renter to change or end
+	// a registry subscription.
+	RPCRegistrySubscriptionRequest struct {
+		Type types.Specifier
+		Keys []rhp3.RegistryKey
+	}
+
+	// RPCRegistrySubscriptionResponse is sent by the host in response to a
+	// subscription request or when a subscribed entry is updated. Remaining
+	// is the unspent subscription budget.
+	RPCRegistrySubscriptionResponse struct {
+		Type      types.Specifier
+		Entries   []rhp3.RegistryEntry
+		Remaining types.Currency
+	}
+
+	// registrySubscriber collects registry updates for a subscription
+	// session. Updates to the same key are coalesced so that only the
+	// latest value is sent.
+	registrySubscriber struct {
+		notify chan struct{}
+
+		mu      sync.Mutex
+		pending map[types.Hash256]rhp3.RegistryEntry
+	}
+)
+
+func encodeRegistryEntry(e *types.Encoder, entry rhp3.RegistryEntry) {
+	entry.PublicKey.EncodeTo(e)
+	entry.Tweak.EncodeTo(e)
+	e.WriteBytes(entry.Data)
+	e.WriteUint64(entry.Revision)
+	e.WriteUint8(entry.Type)
+	entry.Signature.EncodeTo(e)
+}
+
+func decodeRegistryEntry(d *types.Decoder) (entry rhp3.RegistryEntry) {
+	entry.PublicKey.DecodeFrom(d)
+	entry.Tweak.DecodeFrom(d)
+	entry.Data = d.ReadBytes()
+	entry.Revision = d.ReadUint64()
+	entry.Type = d.ReadUint8()
+	entry.Signature.DecodeFrom(d)
+	return
+}
+
+// EncodeTo implements rhp3.ProtocolObject.
+func (r *RPCRegistrySubscriptionRequest) EncodeTo(e *types.Encoder) {
+	r.Type.EncodeTo(e)
+	e.WritePrefix(len(r.Keys))
+	for _, key := range r.Keys {
+		key.PublicKey.EncodeTo(e)
+		key.Tweak.EncodeTo(e)
+	}
+}
+
+// DecodeFrom implements rhp3.ProtocolObject.
+func (r *RPCRegistrySubscriptionRequest) DecodeFrom(d *types.Decoder) {
+	r.Type.DecodeFrom(d)
+	r.Keys = make([]rhp3.RegistryKey, d.ReadPrefix())
+	for i := range r.Keys {
+		r.Keys[i].PublicKey.DecodeFrom(d)
+		r.Keys[i].Tweak.DecodeFrom(d)
+	}
+}
+
+// EncodeTo implements rhp3.ProtocolObject.
+func (r *RPCRegistrySubscriptionResponse) EncodeTo(e *types.Encoder) {
+	r.Type.EncodeTo(e)
+	e.WritePrefix(len(r.Entries))
+	for _, entry := range r.Entries {
+		encodeRegistryEntry(e, entry)
+	}
+	r.Remaining.EncodeTo(e)
+}
+
+// DecodeFrom implements rhp3.ProtocolObject.
+func (r *RPCRegistrySubscriptionResponse) DecodeFrom(d *types.Decoder) {
+	r.Type.DecodeFrom(d)
+	r.Entries = make([]rhp3.RegistryEntry, d.ReadPrefix())
+	for i := range r.Entries {
+		r.Entries[i] = decodeRegistryEntry(d)
+	}
+	r.Remaining.DecodeFrom(d)
+}
+
+// ReceiveRegistryUpdate implements registry.Subscriber.
+func (rs *registrySubscriber) ReceiveRegistryUpdate(entry rhp3.RegistryEntry) {
+	rs.mu.Lock()
+	rs.pending[entry.RegistryKey.Hash()] = entry
+	rs.mu.Unlock()
+
+	select {
+	case rs.notify <- struct{}{}:
+	default:
+	}
+}
+
+// drain returns and clears the pending updates.
+func (rs *registrySubscriber) drain() []rhp3.RegistryEntry {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	entries := make([]rhp3.RegistryEntry, 0, len(rs.pending))
+	for key, entry := range rs.pending {
+		entries = append(entries, entry)
+		delete(rs.pending, key)
+	}
+	return entries
+}
+
+// subscriptionMemoryCost returns the cost of holding n keys for one
+// subscription period. A subscription is always charged for at least one key
+// so that idle subscriptions are not free.
+func subscriptionMemoryCost(pt rhp3.HostPriceTable, n int) types.Currency {
+	if n < 1 {
+		n = 1
+	}
+	return pt.SubscriptionMemoryCost.Mul64(uint64(n) * subscriptionKeyMemory)
+}
+
+// subscriptionNotificationCost returns the cost of sending a response
+// containing entries to the renter.
+func subscriptionNotificationCost(pt rhp3.HostPriceTable, resp *RPCRegistrySubscriptionResponse) accounts.Usage {
+	var buf bytes.Buffer
+	e := types.NewEncoder(&buf)
+	resp.EncodeTo(e)
+	e.Flush()
+	return accounts.Usage{
+		RegistryRead:  pt.SubscriptionNotificationCost.Mul64(uint64(len(resp.Entries))),
+		EgressRevenue: pt.DownloadBandwidthCost.Mul64(uint64(buf.Len())),
+	}
+}
+
+// isSubscriptionEnded returns true if err ends a subscription without being a
+// failure of the host.
+func isSubscriptionEnded(err error) bool {
+	return errors.Is(err, ErrSubscriptionBudgetExhausted) || errors.Is(err, ErrSubscriptionPriceTableExpired)
+}
+
+// handleRPCRegistrySubscription streams registry updates to the renter. The
+// subscription is prepaid from an ephemeral account and ends when the renter
+// stops it, the budget is exhausted, its price table expires, or the host
+// shuts down. Updates that were
+// paid for are committed on every exit path, including errors.
+func (sh *SessionHandler) handleRPCRegistrySubscription(s *rhp3.Stream, log *zap.Logger) (contracts.Usage, error) {
+	s.SetDeadline(time.Now().Add(time.Minute))
+	pt, err := sh.readPriceTable(s)
+	if err != nil {
+		err = fmt.Errorf("failed to read price table: %w", err)
+		s.WriteResponseErr(err)
+		return contracts.Usage{}, err
+	}
+
+	budget, err := sh.processPayment(s, &pt, RPCRegistrySubscribeID)
+	if err != nil {
+		err = fmt.Errorf("failed to process payment: %w", err)
+		s.WriteResponseErr(err)
+		return contracts.Usage{}, err
+	}
+	defer budget.Rollback()
+
+	sub := &registrySubscriber{
+		notify:  make(chan struct{}, 1),
+		pending: make(map[types.Hash256]rhp3.RegistryEntry),
+	}
+	keys := make(map[types.Hash256]bool)
+	defer func() {
+		for key := range keys {
+			sh.registry.Unsubscribe(sub, key)
+		}
+	}()
+
+	var usage accounts.Usage
+	spend := func(u accounts.Usage) error {
+		// the subscription's costs are calculated with the price table it
+		// was opened with, which must still be valid
+		if _, err := sh.priceTables.Get(pt.UID); err != nil {
+			return ErrSubscriptionPriceTableExpired
+		} else if err := budget.Spend(u); err != nil {
+			return ErrSubscriptionBudgetExhausted
+		}
+		usage = usage.Add(u)
+		return nil
+	}
+	// send writes a response to the renter after paying for it
+	send := func(resp *RPCRegistrySubscriptionResponse) error {
+		if err := spend(subscriptionNotificationCost(pt, resp)); err != nil {
+			return err
+		}
+		resp.Remaining = budget.Remaining()
+		return s.WriteResponse(resp)
+	}
+	commit := func() (contracts.Usage, error) {
+		if err := budget.Commit(); err != nil {
+			return contracts.Usage{}, fmt.Errorf("failed to commit payment: %w", err)
+		}
+		return contracts.Usage{
+			RPCRevenue:    usage.RPCRevenue,
+			RegistryRead:  usage.RegistryRead,
+			EgressRevenue: usage.EgressRevenue,
+		}, nil
+	}
+	// fail commits the spent usage and returns err. The renter has already
+	// received the responses it paid for, so the budget is not rolled back.
+	fail := func(err error) (contracts.Usage, error) {
+		usage, commitErr := commit()
+		if commitErr != nil {
+			return contracts.Usage{}, errors.Join(err, commitErr)
+		}
+		return usage, err
+	}
+
+	// pay for the first period up front
+	if err := spend(accounts.Usage{RPCRevenue: subscriptionMemoryCost(pt, 0)}); err != nil {
+		s.WriteResponseErr(err)
+		return fail(err)
+	}
+
+	// the subscription is long-lived, remove the deadline. Reads are
+	// handled in a separate goroutine so that updates can be pushed while
+	// waiting for the next request.
+	s.SetDeadline(time.Time{})
+	done := make(chan struct{})
+	defer close(done)
+	reqs := make(chan RPCRegistrySubscriptionRequest)
+	readErr := make(chan error, 1)
+	go func() {
+		for {
+			var req RPCRegistrySubscriptionRequest
+			if err := s.ReadRequest(&req, maxSubscriptionRequestSize); err != nil {
+				readErr <- err
+				return
+			}
+			select {
+			case reqs <- req:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	period := time.NewTicker(subscriptionPeriod)
+	defer period.Stop()
+
+	for {
+		select {
+		case <-sh.tg.Done():
+			s.WriteResponseErr(errors.New("host is shutting down"))
+			return commit()
+		case err := <-readErr:
+			if isNonPaymentErr(err) {
+				// the renter closed the stream without stopping the
+				// subscription
+				return commit()
+			}
+			return fail(fmt.Errorf("failed to read subscription request: %w", err))
+		case <-period.C:
+			if err := spend(accounts.Usage{RPCRevenue: subscriptionMemoryCost(pt, len(keys))}); err != nil {
+				s.WriteResponseErr(err)
+				return commit()
+			}
+		case <-sub.notify:
+			entries := sub.drain()
+			// the renter may have unsubscribed since the update was queued
+			filtered := entries[:0]
+			for _, entry := range entries {
+				if keys[entry.RegistryKey.Hash()] {
+					filtered = append(filtered, entry)
+				}
+			}
+			if len(filtered) == 0 {
+				continue
+			}
+			err := send(&RPCRegistrySubscriptionResponse{
+				Type:    SubscriptionResponseUpdate,
+				Entries: filtered,
+			})
+			if isSubscriptionEnded(err) {
+				s.WriteResponseErr(err)
+				return commit()
+			} else if err != nil {
+				return fail(fmt.Errorf("failed to send registry update: %w", err))
+			}
+		case req := <-reqs:
+			switch req.Type {
+			case SubscriptionRequestSubscribe:
+				if len(keys)+len(req.Keys) > maxSubscriptionKeys {
+					err := fmt.Errorf("subscription cannot exceed %d keys", maxSubscriptionKeys)
+					s.WriteResponseErr(err)
+					return commit()
+				}
+
+				resp := &RPCRegistrySubscriptionResponse{Type: SubscriptionResponseValues}
+				for _, key := range req.Keys {
+					hash := key.Hash()
+					if !keys[hash] {
+						keys[hash] = true
+						sh.registry.Subscribe(sub, hash)
+					}
+
+					value, err := sh.registry.Get(key)
+					if errors.Is(err, registry.ErrEntryNotFound) {
+						continue
+					} else if err != nil {
+						s.WriteResponseErr(ErrHostInternalError)
+						return fail(fmt.Errorf("failed to get registry value: %w", err))
+					}
+					resp.Entries = append(resp.Entries, rhp3.RegistryEntry{RegistryKey: key, RegistryValue: value})
+				}
+				err := send(resp)
+				if isSubscriptionEnded(err) {
+					s.WriteResponseErr(err)
+					return commit()
+				} else if err != nil {
+					return fail(fmt.Errorf("failed to send registry values: %w", err))
+				}
+			case SubscriptionRequestUnsubscribe:
+				for _, key := range req.Keys {
+					hash := key.Hash()
+					if keys[hash] {
+						delete(keys, hash)
+						sh.registry.Unsubscribe(sub, hash)
+					}
+				}
+				err := s.WriteResponse(&RPCRegistrySubscriptionResponse{
+					Type:      SubscriptionResponseUnsubscribed,
+					Remaining: budget.Remaining(),
+				})
+				if err != nil {
+					return fail(fmt.Errorf("failed to write unsubscribe response: %w", err))
+				}
+			case SubscriptionRequestStop:
+				// the unspent budget is returned to the account when the
+				// budget is committed
+				remaining := budget.Remaining()
+				usage, err := commit()
+				if err != nil {
+					s.WriteResponseErr(ErrHostInternalError)
+					return contracts.Usage{}, err
+				}
+				return usage, s.WriteResponse(&RPCRegistrySubscriptionResponse{
+					Type:      SubscriptionResponseStopped,
+					Remaining: remaining,
+				})
+			default:
+				err := fmt.Errorf("unknown subscription request type %q", req.Type)
+				s.WriteResponseErr(err)
+				return commit()
+			}
+		}
+	}
+}
@@ -2,6 +2,8 @@ package rhp
 
 import (
 	"context"
+	"fmt"
+	"net"
 	"net/http"
 
 	rhp3 "go.sia.tech/core/rhp/v3"
@@ -13,6 +15,24 @@ import (
 // handleWebSockets handles websocket connections to the host.
 func (sh *SessionHandler) handleWebSockets(w http.ResponseWriter, r *http.Request) {
 	log := sh.log.Named("websockets").With(zap.String("peerAddr", r.RemoteAddr))
+	peerIP, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		peerIP = r.RemoteAddr
+	}
+	if sh.bans.Banned(peerIP) {
+		log.Debug("rejected connection from banned peer")
+		http.Error(w, rhp.ErrPeerBanned.Error(), http.StatusForbidden)
+		return
+	}
+
+	// reject new sessions while the host is draining
+	endDrain, err := sh.drain.StartSession()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	defer endDrain()
+
 	wsConn, err := websocket.Accept(w, r, &websocket.AcceptOptions{
 		OriginPatterns: []string{"*"},
 	})
@@ -40,6 +60,9 @@ func (sh *SessionHandler) handleWebSockets(w http.ResponseWriter, r *http.Reques
 	// upgrade the connection
 	t, err := rhp3.NewHostTransport(rhpConn, sh.privateKey)
 	if err != nil {
+		if !rhp.IsDisconnect(err) {
+			sh.bans.RecordOffense(fmt.Errorf("failed to complete handshake: %w", err), peerIP)
+		}
 		sh.log.Debug("failed to upgrade conn", zap.Error(err), zap.String("remoteAddress", conn.RemoteAddr().String()))
 		return
 	}
@@ -52,7 +75,7 @@ func (sh *SessionHandler) handleWebSockets(w http.ResponseWriter, r *http.Reques
 			return
 		}
 
-		go sh.handleHostStream(stream, sessionID, log)
+		go sh.handleHostStream(stream, sessionID, peerIP, log)
 	}
 }
 
@@ -0,0 +1,163 @@
+package wallet
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"go.sia.tech/core/types"
+	"go.uber.org/zap"
+)
+
+// outputLockDuration is how long outputs are locked by FundTransaction. Locks
+// are normally released when the transaction is confirmed or discarded, the
+// expiration only releases outputs that were leaked or locked by a crashed
+// process.
+const outputLockDuration = 3 * time.Hour
+
+// lock reasons
+const (
+	LockReasonFormation      = "contract formation"
+	LockReasonRenewal        = "contract renewal"
+	LockReasonRevision       = "contract revision"
+	LockReasonStorageProof   = "storage proof"
+	LockReasonAnnouncement   = "announcement"
+	LockReasonRedistribution = "redistribution"
+	LockReasonTransaction    = "transaction"
+)
+
+// An OutputLock prevents a siacoin output from being used to fund another
+// transaction until it expires.
+type OutputLock struct {
+	ID         types.SiacoinOutputID `json:"id"`
+	Value      types.Currency        `json:"value"`
+	Reason     string                `json:"reason"`
+	Expiration time.Time             `json:"expiration"`
+}
+
+// lockReason returns the reason outputs are being locked to fund txn.
+func lockReason(txn types.Transaction) string {
+	switch {
+	case len(txn.FileContracts) > 0 && len(txn.FileContractRevisions) > 0:
+		return LockReasonRenewal
+	case len(txn.FileContracts) > 0:
+		return LockReasonFormation
+	case len(txn.FileContractRevisions) > 0:
+		return LockReasonRevision
+	case len(txn.StorageProofs) > 0:
+		return LockReasonStorageProof
+	case len(txn.ArbitraryData) > 0:
+		return LockReasonAnnouncement
+	default:
+		return LockReasonTransaction
+	}
+}
+
+// isLocked returns true if the output has an unexpired lock. The caller must
+// hold the wallet's lock.
+func (sw *SingleAddressWallet) isLocked(id types.SiacoinOutputID, now time.Time) bool {
+	lock, ok := sw.locked[id]
+	return ok && now.Before(lock.Expiration)
+}
+
+// lockOutputs locks the outputs in memory and in the store. Expired locks are
+// removed. The caller must hold the wallet's lock. The returned function
+// releases the locks and must be called without holding the wallet's lock.
+func (sw *SingleAddressWallet) lockOutputs(reason string, elements []SiacoinElement) (func(), error) {
+	now := time.Now()
+	var expired []types.SiacoinOutputID
+	for id, lock := range sw.locked {
+		if !now.Before(lock.Expiration) {
+			expired = append(expired, id)
+		}
+	}
+	if len(expired) > 0 {
+		if err := sw.store.ReleaseSiacoinElements(expired...); err != nil {
+			return nil, fmt.Errorf("failed to release expired locks: %w", err)
+		}
+		for _, id := range expired {
+			delete(sw.locked, id)
+		}
+	}
+
+	locks := make([]OutputLock, 0, len(elements))
+	ids := make([]types.SiacoinOutputID, 0, len(elements))
+	for _, sce := range elements {
+		locks = append(locks, OutputLock{
+			ID:         sce.ID,
+			Value:      sce.Value,
+			Reason:     reason,
+			Expiration: now.Add(outputLockDuration),
+		})
+		ids = append(ids, sce.ID)
+	}
+	if err := sw.store.LockSiacoinElements(locks...); err != nil {
+		return nil, fmt.Errorf("failed to lock outputs: %w", err)
+	}
+	for _, lock := range locks {
+		sw.locked[lock.ID] = lock
+	}
+
+	return func() {
+		sw.mu.Lock()
+		defer sw.mu.Unlock()
+		for _, id := range ids {
+			delete(sw.locked, id)
+		}
+		if err := sw.store.ReleaseSiacoinElements(ids...); err != nil {
+			sw.log.Error("failed to release outputs", zap.Error(err))
+		}
+	}, nil
+}
+
+// ReleaseOutputs releases the locks on the outputs so they can fund other
+// transactions. It is used to release the inputs of a prepared transaction
+// that will not be broadcast.
+func (sw *SingleAddressWallet) ReleaseOutputs(ids ...types.SiacoinOutputID) error {
+	done, err := sw.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	now := time.Now()
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	for _, id := range ids {
+		if !sw.isLocked(id, now) {
+			return fmt.Errorf("output %v is not locked", id)
+		}
+	}
+	if err := sw.store.ReleaseSiacoinElements(ids...); err != nil {
+		return fmt.Errorf("failed to release outputs: %w", err)
+	}
+	for _, id := range ids {
+		delete(sw.locked, id)
+	}
+	return nil
+}
+
+// LockedOutputs returns the wallet's unexpired output locks ordered by
+// expiration.
+func (sw *SingleAddressWallet) LockedOutputs() ([]OutputLock, error) {
+	done, err := sw.tg.Add()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	now := time.Now()
+	sw.mu.Lock()
+	locks := make([]OutputLock, 0, len(sw.locked))
+	for id, lock := range sw.locked {
+		if sw.isLocked(id, now) {
+			locks = append(locks, lock)
+		}
+	}
+	sw.mu.Unlock()
+
+	sort.Slice(locks, func(i, j int) bool {
+		return locks[i].Expiration.Before(locks[j].Expiration)
+	})
+	return locks, nil
+}
@@ -0,0 +1,90 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+
+	"go.sia.tech/core/consensus"
+	"go.sia.tech/core/types"
+)
+
+// An UnsignedTransaction is a funded transaction that has not been signed. It
+// contains everything needed to sign the transaction on a machine without
+// access to the network.
+type UnsignedTransaction struct {
+	// Network is the name of the network the transaction was created on.
+	Network string `json:"network"`
+	// Index is the chain index the transaction was created at. It determines
+	// the replay protection used when signing.
+	Index       types.ChainIndex  `json:"index"`
+	Transaction types.Transaction `json:"transaction"`
+	// ToSign is the IDs of the inputs that must be signed by the wallet's
+	// key.
+	ToSign []types.Hash256 `json:"toSign"`
+	// Change is the indices of the siacoin outputs that return change to
+	// the wallet.
+	Change []int `json:"change"`
+}
+
+// NewUnsignedTransaction wraps a funded transaction for offline signing.
+func NewUnsignedTransaction(cs consensus.State, txn types.Transaction, toSign []types.Hash256, change []int) UnsignedTransaction {
+	return UnsignedTransaction{
+		Network:     cs.Network.Name,
+		Index:       cs.Index,
+		Transaction: txn,
+		ToSign:      toSign,
+		Change:      change,
+	}
+}
+
+// Validate returns an error if the transaction can not be signed by the
+// wallet with the given address on network n. Every input in ToSign must be
+// controlled by the address and every change output must be sent to it.
+func (ut UnsignedTransaction) Validate(addr types.Address, n *consensus.Network) error {
+	if ut.Network != n.Name {
+		return fmt.Errorf("transaction was created on network %q, not %q", ut.Network, n.Name)
+	} else if len(ut.ToSign) == 0 {
+		return errors.New("transaction has no inputs to sign")
+	}
+
+	// check that the key controls every input to be signed
+	inputs := make(map[types.Hash256]types.Address)
+	for _, sci := range ut.Transaction.SiacoinInputs {
+		inputs[types.Hash256(sci.ParentID)] = sci.UnlockConditions.UnlockHash()
+	}
+	for _, id := range ut.ToSign {
+		if a, ok := inputs[id]; !ok {
+			return fmt.Errorf("transaction does not contain input %v", id)
+		} else if a != addr {
+			return fmt.Errorf("input %v is not controlled by %v", id, addr)
+		}
+	}
+
+	// check that change is returned to the wallet
+	for _, i := range ut.Change {
+		if i < 0 || i >= len(ut.Transaction.SiacoinOutputs) {
+			return fmt.Errorf("change output %d does not exist", i)
+		} else if a := ut.Transaction.SiacoinOutputs[i].Address; a != addr {
+			return fmt.Errorf("change output %d is sent to %v, not %v", i, a, addr)
+		}
+	}
+	return nil
+}
+
+// Sign signs each of the transaction's inputs in ToSign with priv and returns
+// the signed transaction. The transaction must pass Validate for the key's
+// address.
+func (ut UnsignedTransaction) Sign(priv types.PrivateKey, n *consensus.Network) (types.Transaction, error) {
+	if err := ut.Validate(types.StandardUnlockHash(priv.PublicKey()), n); err != nil {
+		return types.Transaction{}, err
+	}
+
+	txn := ut.Transaction
+	txn.Signatures = append([]types.TransactionSignature(nil), ut.Transaction.Signatures...)
+	cs := consensus.State{
+		Network: n,
+		Index:   ut.Index,
+	}
+	signTransaction(priv, cs, &txn, ut.ToSign, types.CoveredFields{WholeTransaction: true})
+	return txn, nil
+}
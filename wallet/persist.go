@@ -25,18 +25,38 @@ type (
 		// LastWalletChange returns the consensus change ID and block height of
 		// the last wallet change.
 		LastWalletChange() (id modules.ConsensusChangeID, height uint64, err error)
+		// WalletBirthHeight returns the height of the first block relevant
+		// to the wallet. If the wallet has never received a transaction, ok
+		// is false.
+		WalletBirthHeight() (height uint64, ok bool, err error)
 		// UnspentSiacoinElements returns a list of all unspent siacoin outputs
 		UnspentSiacoinElements() ([]SiacoinElement, error)
+		// LockedSiacoinElements returns the wallet's output locks, including
+		// expired locks.
+		LockedSiacoinElements() ([]OutputLock, error)
+		// LockSiacoinElements locks the outputs until their expiration.
+		// Existing locks on the outputs are replaced. Locks should be
+		// removed when their output is removed.
+		LockSiacoinElements(...OutputLock) error
+		// ReleaseSiacoinElements removes the locks on the outputs.
+		ReleaseSiacoinElements(...types.SiacoinOutputID) error
 		// Transactions returns a paginated list of transactions ordered by
 		// block height, descending. If no more transactions are available,
 		// (nil, nil) should be returned.
 		Transactions(limit, offset int) ([]Transaction, error)
+		// SearchTransactions returns a paginated list of transactions
+		// matching the filter ordered by block height, descending.
+		SearchTransactions(TransactionFilter) ([]Transaction, error)
+		// SetTransactionLabel sets the label of a transaction. An empty
+		// label removes the transaction's label.
+		SetTransactionLabel(id types.TransactionID, label string) error
 		// TransactionCount returns the total number of transactions in the
 		// wallet.
 		TransactionCount() (uint64, error)
 		UpdateWallet(ccID modules.ConsensusChangeID, height uint64, fn func(UpdateTransaction) error) error
 		// ResetWallet resets the wallet to its initial state. This is used when a
-		// consensus subscription error occurs.
+		// consensus subscription error occurs. The wallet's birth height
+		// should be kept unless the seed hash has changed.
 		ResetWallet(seedHash types.Hash256) error
 		// VerifyWalletKey checks that the wallet seed matches the existing seed
 		// hash. This detects if the user's recovery phrase has changed and the
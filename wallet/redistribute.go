@@ -0,0 +1,268 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/internal/threadgroup"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxRedistributeOutputs is the maximum number of outputs created by a
+	// single redistribution transaction. It keeps the transaction well
+	// below the size limit.
+	maxRedistributeOutputs = 250
+
+	// estimated sizes, in bytes, of a redistribution transaction. Inputs
+	// include their signature.
+	redistributeTxnOverhead = 200
+	redistributeInputSize   = 300
+	redistributeOutputSize  = 64
+)
+
+// ErrAlreadyRedistributed is returned by Redistribute when the wallet already
+// has the requested number of outputs.
+var ErrAlreadyRedistributed = errors.New("wallet already has the requested outputs")
+
+type (
+	// A TransactionBroadcaster broadcasts transactions to the network.
+	TransactionBroadcaster interface {
+		RecommendedFee() types.Currency
+		AcceptTransactionSet([]types.Transaction) error
+	}
+
+	// MaintenanceConfig configures the automatic redistribution of the
+	// wallet's outputs.
+	MaintenanceConfig struct {
+		// MinOutputs is the minimum number of spendable outputs worth at
+		// least OutputValue the wallet should have.
+		MinOutputs int
+		// OutputValue is the value of each output created by a
+		// redistribution.
+		OutputValue types.Currency
+		// Interval is how often the wallet's outputs are checked.
+		Interval time.Duration
+	}
+
+	// An OutputMaintainer periodically redistributes the wallet's balance to
+	// keep a minimum number of spendable outputs available for concurrent
+	// contract formations.
+	OutputMaintainer struct {
+		w   *SingleAddressWallet
+		tp  TransactionBroadcaster
+		cfg MaintenanceConfig
+		log *zap.Logger
+		tg  *threadgroup.ThreadGroup
+	}
+)
+
+func redistributeFee(feePerByte types.Currency, inputs, outputs int) types.Currency {
+	return feePerByte.Mul64(uint64(redistributeTxnOverhead + inputs*redistributeInputSize + outputs*redistributeOutputSize))
+}
+
+// Redistribute funds a transaction that splits the wallet's balance into
+// outputs worth amount until the wallet has at least the requested number of
+// spendable outputs in the range [amount, 2*amount). Existing outputs in that
+// range are counted towards the total and are not spent. If the balance is
+// not large enough to create every missing output, as many outputs as
+// possible are created.
+//
+// The inputs will not be available to future calls to FundTransaction until
+// release is called. If no outputs need to be created, ErrAlreadyRedistributed
+// is returned.
+func (sw *SingleAddressWallet) Redistribute(outputs int, amount, feePerByte types.Currency) (types.Transaction, []types.Hash256, func(), error) {
+	done, err := sw.tg.Add()
+	if err != nil {
+		return types.Transaction{}, nil, nil, err
+	}
+	defer done()
+
+	if outputs <= 0 {
+		return types.Transaction{}, nil, nil, errors.New("outputs must be greater than zero")
+	} else if amount.IsZero() {
+		return types.Transaction{}, nil, nil, errors.New("amount must be greater than zero")
+	}
+
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	utxos, err := sw.store.UnspentSiacoinElements()
+	if err != nil {
+		return types.Transaction{}, nil, nil, fmt.Errorf("failed to get unspent outputs: %w", err)
+	}
+
+	// outputs already in the target range are kept, everything else can be
+	// used to fund the new outputs
+	now := time.Now()
+	var existing int
+	var candidates []SiacoinElement
+	upper := amount.Mul64(2)
+	for _, sce := range utxos {
+		if sw.isLocked(sce.ID, now) || sw.tpoolSpent[sce.ID] || sw.consensusLocked[sce.ID] {
+			continue
+		} else if sce.Value.Cmp(amount) >= 0 && sce.Value.Cmp(upper) < 0 {
+			existing++
+			continue
+		}
+		candidates = append(candidates, sce)
+	}
+
+	missing := outputs - existing
+	if missing <= 0 {
+		return types.Transaction{}, nil, nil, ErrAlreadyRedistributed
+	} else if missing > maxRedistributeOutputs {
+		missing = maxRedistributeOutputs
+	}
+
+	// spend the largest outputs first to minimize the transaction size
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].Value.Cmp(candidates[j].Value) > 0
+	})
+
+	// the fee includes a change output
+	required := func(inputs, outputs int) types.Currency {
+		return amount.Mul64(uint64(outputs)).Add(redistributeFee(feePerByte, inputs, outputs+1))
+	}
+
+	var inputSum types.Currency
+	var fundingElements []SiacoinElement
+	for _, sce := range candidates {
+		fundingElements = append(fundingElements, sce)
+		inputSum = inputSum.Add(sce.Value)
+		if inputSum.Cmp(required(len(fundingElements), missing)) >= 0 {
+			break
+		}
+	}
+	// create fewer outputs if the balance is not large enough
+	for missing > 0 && inputSum.Cmp(required(len(fundingElements), missing)) < 0 {
+		missing--
+	}
+	if missing == 0 {
+		return types.Transaction{}, nil, nil, ErrNotEnoughFunds
+	}
+
+	release, err := sw.lockOutputs(LockReasonRedistribution, fundingElements)
+	if err != nil {
+		return types.Transaction{}, nil, nil, err
+	}
+
+	fee := redistributeFee(feePerByte, len(fundingElements), missing+1)
+	txn := types.Transaction{
+		MinerFees: []types.Currency{fee},
+	}
+	for i := 0; i < missing; i++ {
+		txn.SiacoinOutputs = append(txn.SiacoinOutputs, types.SiacoinOutput{
+			Value:   amount,
+			Address: sw.addr,
+		})
+	}
+	if change := inputSum.Sub(required(len(fundingElements), missing)); !change.IsZero() {
+		txn.SiacoinOutputs = append(txn.SiacoinOutputs, types.SiacoinOutput{
+			Value:   change,
+			Address: sw.addr,
+		})
+	}
+
+	toSign := make([]types.Hash256, len(fundingElements))
+	for i, sce := range fundingElements {
+		txn.SiacoinInputs = append(txn.SiacoinInputs, types.SiacoinInput{
+			ParentID:         sce.ID,
+			UnlockConditions: types.StandardUnlockConditions(sw.priv.PublicKey()),
+		})
+		toSign[i] = types.Hash256(sce.ID)
+	}
+	return txn, toSign, release, nil
+}
+
+// Maintain redistributes the wallet's balance if it has fewer than the
+// configured minimum number of outputs. Redistribution is skipped while the
+// wallet has unconfirmed transactions, since their outputs are not yet
+// spendable.
+func (om *OutputMaintainer) Maintain() error {
+	done, err := om.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if pending, err := om.w.UnconfirmedTransactions(); err != nil {
+		return fmt.Errorf("failed to get unconfirmed transactions: %w", err)
+	} else if len(pending) > 0 {
+		om.log.Debug("skipping redistribution, wallet has unconfirmed transactions", zap.Int("pending", len(pending)))
+		return nil
+	}
+
+	txn, toSign, release, err := om.w.Redistribute(om.cfg.MinOutputs, om.cfg.OutputValue, om.tp.RecommendedFee())
+	if errors.Is(err, ErrAlreadyRedistributed) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to fund redistribution: %w", err)
+	}
+	defer release()
+
+	if err := om.w.SignTransaction(om.w.cm.TipState(), &txn, toSign, types.CoveredFields{WholeTransaction: true}); err != nil {
+		return fmt.Errorf("failed to sign redistribution: %w", err)
+	} else if err := om.tp.AcceptTransactionSet([]types.Transaction{txn}); err != nil {
+		return fmt.Errorf("failed to broadcast redistribution: %w", err)
+	}
+	om.log.Info("redistributed wallet outputs", zap.Stringer("txnID", txn.ID()), zap.Int("outputs", len(txn.SiacoinOutputs)))
+	return nil
+}
+
+func (om *OutputMaintainer) run(ctx context.Context) {
+	t := time.NewTicker(om.cfg.Interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		if err := om.Maintain(); err != nil {
+			om.log.Error("failed to maintain wallet outputs", zap.Error(err))
+		}
+	}
+}
+
+// Close stops the output maintainer.
+func (om *OutputMaintainer) Close() error {
+	om.tg.Stop()
+	return nil
+}
+
+// NewOutputMaintainer initializes a new output maintainer. The wallet's
+// outputs are checked every cfg.Interval.
+func NewOutputMaintainer(w *SingleAddressWallet, tp TransactionBroadcaster, cfg MaintenanceConfig, log *zap.Logger) (*OutputMaintainer, error) {
+	switch {
+	case cfg.MinOutputs <= 0:
+		return nil, errors.New("minimum outputs must be greater than zero")
+	case cfg.OutputValue.IsZero():
+		return nil, errors.New("output value must be greater than zero")
+	case cfg.Interval <= 0:
+		return nil, errors.New("interval must be greater than zero")
+	}
+
+	om := &OutputMaintainer{
+		w:   w,
+		tp:  tp,
+		cfg: cfg,
+		log: log,
+		tg:  threadgroup.New(),
+	}
+	ctx, cancel, err := om.tg.AddContext(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		defer cancel()
+		om.run(ctx)
+	}()
+	return om, nil
+}
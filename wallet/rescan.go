@@ -0,0 +1,78 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"go.sia.tech/core/types"
+	"go.uber.org/zap"
+)
+
+// subscribe subscribes the wallet to consensus changes in a separate
+// goroutine. The subscription's initial sync is cancelled when the wallet is
+// closed or the wallet is rescanned. The caller must hold subMu.
+func (sw *SingleAddressWallet) subscribe(fn func(cancel <-chan struct{}) error) {
+	ctx, cancel := sw.tg.WithContext(context.Background())
+	done := make(chan struct{})
+	sw.subCancel, sw.subDone = cancel, done
+
+	go func() {
+		defer close(done)
+		if err := fn(ctx.Done()); err != nil && ctx.Err() == nil {
+			sw.log.Error("failed to subscribe to consensus changes", zap.Error(err))
+		}
+	}()
+}
+
+// ErrRescanAboveBirthHeight is returned by Rescan when the rescan height is
+// above the first block relevant to the wallet.
+var ErrRescanAboveBirthHeight = errors.New("rescan height is above the wallet's birth height")
+
+// Rescan resets the wallet and rescans the blockchain beginning with the
+// block at height. Since outputs and transactions confirmed below height
+// would not be restored, height must be at or below the wallet's birth
+// height. The rescan runs in the background, its progress is reported by
+// ScanHeight.
+func (sw *SingleAddressWallet) Rescan(height uint64) error {
+	done, err := sw.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if tip := sw.cm.TipState().Index.Height; height > tip {
+		return fmt.Errorf("rescan height %d is above the current height %d", height, tip)
+	} else if birth, ok, err := sw.store.WalletBirthHeight(); err != nil {
+		return fmt.Errorf("failed to get wallet birth height: %w", err)
+	} else if ok && height > birth {
+		return fmt.Errorf("rescan height %d is above birth height %d: %w", height, birth, ErrRescanAboveBirthHeight)
+	}
+
+	sw.subMu.Lock()
+	defer sw.subMu.Unlock()
+
+	// stop the current subscription. If the initial sync is still running,
+	// wait for it to return before unsubscribing.
+	sw.subCancel()
+	<-sw.subDone
+	sw.cm.Unsubscribe(sw)
+
+	// removing the wallet's outputs also removes their locks
+	if err := sw.store.ResetWallet(types.HashBytes(sw.priv[:])); err != nil {
+		return fmt.Errorf("failed to reset wallet: %w", err)
+	}
+	sw.mu.Lock()
+	for id := range sw.locked {
+		delete(sw.locked, id)
+	}
+	sw.mu.Unlock()
+	atomic.StoreUint64(&sw.scanHeight, 0)
+
+	sw.log.Info("rescanning wallet", zap.Uint64("height", height))
+	sw.subscribe(func(cancel <-chan struct{}) error {
+		return sw.cm.SubscribeAtHeight(sw, height, cancel)
+	})
+	return nil
+}
@@ -2,6 +2,7 @@ package wallet
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"sync"
@@ -29,6 +30,13 @@ const (
 	TxnSourceFoundationPayout TransactionSource = "foundation"
 )
 
+// transaction directions indicate whether a transaction increased or
+// decreased the wallet's balance.
+const (
+	TxnDirectionIncoming TransactionDirection = "incoming"
+	TxnDirectionOutgoing TransactionDirection = "outgoing"
+)
+
 var (
 	// ErrNotEnoughFunds is returned when there are not enough unspent outputs
 	// to fund a transaction.
@@ -39,11 +47,17 @@ type (
 	// A TransactionSource is a string indicating the source of a transaction.
 	TransactionSource string
 
+	// A TransactionDirection is a string indicating whether a transaction
+	// increased or decreased the wallet's balance.
+	TransactionDirection string
+
 	// A ChainManager manages the current state of the blockchain.
 	ChainManager interface {
 		TipState() consensus.State
 		BlockAtHeight(height uint64) (types.Block, bool)
 		Subscribe(subscriber modules.ConsensusSetSubscriber, ccID modules.ConsensusChangeID, cancel <-chan struct{}) error
+		SubscribeAtHeight(subscriber modules.ConsensusSetSubscriber, height uint64, cancel <-chan struct{}) error
+		Unsubscribe(subscriber modules.ConsensusSetSubscriber)
 	}
 
 	// A TransactionPool manages unconfirmed transactions.
@@ -67,6 +81,36 @@ type (
 		Outflow     types.Currency      `json:"outflow"`
 		Source      TransactionSource   `json:"source"`
 		Timestamp   time.Time           `json:"timestamp"`
+		// Label is a user-defined label for the transaction. It is stored
+		// separately and is not part of the transaction's encoding.
+		Label string `json:"label,omitempty"`
+	}
+
+	// A TransactionFilter filters the wallet's transactions.
+	TransactionFilter struct {
+		// Label limits the results to transactions whose label contains
+		// the string. The match is case-insensitive.
+		Label string `json:"label,omitempty"`
+		// Sources limits the results to transactions from any of the
+		// sources.
+		Sources []TransactionSource `json:"sources,omitempty"`
+		// Direction limits the results to incoming or outgoing
+		// transactions.
+		Direction TransactionDirection `json:"direction,omitempty"`
+
+		// MinHeight and MaxHeight limit the results to transactions
+		// confirmed in the range [MinHeight, MaxHeight]. A zero MaxHeight
+		// is ignored.
+		MinHeight uint64 `json:"minHeight,omitempty"`
+		MaxHeight uint64 `json:"maxHeight,omitempty"`
+		// After and Before limit the results to transactions confirmed in
+		// the range [After, Before). Zero values are ignored.
+		After  time.Time `json:"after,omitempty"`
+		Before time.Time `json:"before,omitempty"`
+
+		// pagination
+		Limit  int `json:"limit"`
+		Offset int `json:"offset"`
 	}
 
 	// A SingleAddressWallet is a hot wallet that manages the outputs controlled by
@@ -82,6 +126,14 @@ type (
 		log   *zap.Logger
 		tg    *threadgroup.ThreadGroup
 
+		subMu sync.Mutex // protects the consensus subscription
+		// subCancel cancels the initial sync of the current consensus
+		// subscription
+		subCancel context.CancelFunc
+		// subDone is closed when the initial sync of the current consensus
+		// subscription returns
+		subDone chan struct{}
+
 		mu sync.Mutex // protects the following fields
 		// tpoolTxns maps a transaction set ID to the transactions in that set
 		tpoolTxns map[modules.TransactionSetID][]Transaction
@@ -96,10 +148,11 @@ type (
 		// the process of removal. Reduces a race-condition with UnspentUtxos
 		// causing "siacoin output does not exist errors."
 		consensusLocked map[types.SiacoinOutputID]bool
-		// locked is a set of siacoin output IDs locked by FundTransaction. They
-		// will be released either by calling Release for unused transactions or
-		// being confirmed in a block.
-		locked map[types.SiacoinOutputID]bool
+		// locked is a map of siacoin output IDs locked by FundTransaction.
+		// They will be released either by calling Release for unused
+		// transactions, being confirmed in a block, or expiring. Locks are
+		// persisted so they survive a restart.
+		locked map[types.SiacoinOutputID]OutputLock
 	}
 )
 
@@ -129,6 +182,15 @@ func (txn *Transaction) DecodeFrom(d *types.Decoder) {
 	txn.Timestamp = d.ReadTime()
 }
 
+// Direction returns TxnDirectionIncoming if the transaction increased the
+// wallet's balance and TxnDirectionOutgoing otherwise.
+func (txn Transaction) Direction() TransactionDirection {
+	if txn.Inflow.Cmp(txn.Outflow) > 0 {
+		return TxnDirectionIncoming
+	}
+	return TxnDirectionOutgoing
+}
+
 func transactionIsRelevant(txn types.Transaction, addr types.Address) bool {
 	for i := range txn.SiacoinInputs {
 		if txn.SiacoinInputs[i].UnlockConditions.UnlockHash() == addr {
@@ -208,11 +270,12 @@ func (sw *SingleAddressWallet) Balance() (spendable, confirmed, unconfirmed type
 	if err != nil {
 		return types.ZeroCurrency, types.ZeroCurrency, types.ZeroCurrency, fmt.Errorf("failed to get unspent outputs: %w", err)
 	}
+	now := time.Now()
 	sw.mu.Lock()
 	defer sw.mu.Unlock()
 	for _, sco := range outputs {
 		confirmed = confirmed.Add(sco.Value)
-		if !sw.locked[sco.ID] && !sw.tpoolSpent[sco.ID] {
+		if !sw.isLocked(sco.ID, now) && !sw.tpoolSpent[sco.ID] {
 			spendable = spendable.Add(sco.Value)
 		}
 	}
@@ -235,6 +298,28 @@ func (sw *SingleAddressWallet) Transactions(limit, offset int) ([]Transaction, e
 	return sw.store.Transactions(limit, offset)
 }
 
+// SearchTransactions returns a paginated list of transactions matching the
+// filter, ordered by block height descending.
+func (sw *SingleAddressWallet) SearchTransactions(filter TransactionFilter) ([]Transaction, error) {
+	done, err := sw.tg.Add()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	return sw.store.SearchTransactions(filter)
+}
+
+// SetTransactionLabel sets the label of a transaction. The transaction does
+// not need to be confirmed. An empty label removes the transaction's label.
+func (sw *SingleAddressWallet) SetTransactionLabel(id types.TransactionID, label string) error {
+	done, err := sw.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer done()
+	return sw.store.SetTransactionLabel(id, label)
+}
+
 // TransactionCount returns the total number of transactions in the wallet.
 func (sw *SingleAddressWallet) TransactionCount() (uint64, error) {
 	done, err := sw.tg.Add()
@@ -267,10 +352,11 @@ func (sw *SingleAddressWallet) FundTransaction(txn *types.Transaction, amount ty
 	if err != nil {
 		return nil, nil, err
 	}
+	now := time.Now()
 	var inputSum types.Currency
 	var fundingElements []SiacoinElement
 	for _, sce := range utxos {
-		if sw.locked[sce.ID] || sw.tpoolSpent[sce.ID] || sw.consensusLocked[sce.ID] {
+		if sw.isLocked(sce.ID, now) || sw.tpoolSpent[sce.ID] || sw.consensusLocked[sce.ID] {
 			continue
 		}
 		fundingElements = append(fundingElements, sce)
@@ -281,7 +367,71 @@ func (sw *SingleAddressWallet) FundTransaction(txn *types.Transaction, amount ty
 	}
 	if inputSum.Cmp(amount) < 0 {
 		return nil, nil, ErrNotEnoughFunds
-	} else if inputSum.Cmp(amount) > 0 {
+	}
+	return sw.addFundingInputs(txn, amount, fundingElements, inputSum)
+}
+
+// FundTransactionWithInputs adds the specified siacoin inputs to the provided
+// transaction. The inputs must be worth at least amount. If necessary, a
+// change output will also be added. The inputs will not be available to
+// future calls to FundTransaction unless release is called.
+func (sw *SingleAddressWallet) FundTransactionWithInputs(txn *types.Transaction, amount types.Currency, inputs []types.SiacoinOutputID) ([]types.Hash256, func(), error) {
+	done, err := sw.tg.Add()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer done()
+
+	if len(inputs) == 0 {
+		return nil, nil, errors.New("no inputs specified")
+	}
+
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	utxos, err := sw.store.UnspentSiacoinElements()
+	if err != nil {
+		return nil, nil, err
+	}
+	elements := make(map[types.SiacoinOutputID]SiacoinElement, len(utxos))
+	for _, sce := range utxos {
+		elements[sce.ID] = sce
+	}
+
+	now := time.Now()
+	seen := make(map[types.SiacoinOutputID]bool)
+	var inputSum types.Currency
+	var fundingElements []SiacoinElement
+	for _, id := range inputs {
+		sce, ok := elements[id]
+		switch {
+		case !ok:
+			return nil, nil, fmt.Errorf("output %v is not spendable by the wallet", id)
+		case seen[id]:
+			return nil, nil, fmt.Errorf("output %v specified more than once", id)
+		case sw.isLocked(id, now) || sw.tpoolSpent[id] || sw.consensusLocked[id]:
+			return nil, nil, fmt.Errorf("output %v is already in use", id)
+		}
+		seen[id] = true
+		fundingElements = append(fundingElements, sce)
+		inputSum = inputSum.Add(sce.Value)
+	}
+	if inputSum.Cmp(amount) < 0 {
+		return nil, nil, ErrNotEnoughFunds
+	}
+	return sw.addFundingInputs(txn, amount, fundingElements, inputSum)
+}
+
+// addFundingInputs locks the funding elements and adds them to the
+// transaction along with a change output, if necessary. The caller must hold
+// the wallet's lock.
+func (sw *SingleAddressWallet) addFundingInputs(txn *types.Transaction, amount types.Currency, fundingElements []SiacoinElement, inputSum types.Currency) ([]types.Hash256, func(), error) {
+	release, err := sw.lockOutputs(lockReason(*txn), fundingElements)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if inputSum.Cmp(amount) > 0 {
 		txn.SiacoinOutputs = append(txn.SiacoinOutputs, types.SiacoinOutput{
 			Value:   inputSum.Sub(amount),
 			Address: sw.addr,
@@ -295,15 +445,6 @@ func (sw *SingleAddressWallet) FundTransaction(txn *types.Transaction, amount ty
 			UnlockConditions: types.StandardUnlockConditions(sw.priv.PublicKey()),
 		})
 		toSign[i] = types.Hash256(sce.ID)
-		sw.locked[sce.ID] = true
-	}
-
-	release := func() {
-		sw.mu.Lock()
-		defer sw.mu.Unlock()
-		for _, id := range toSign {
-			delete(sw.locked, types.SiacoinOutputID(id))
-		}
 	}
 	return toSign, release, nil
 }
@@ -316,21 +457,7 @@ func (sw *SingleAddressWallet) SignTransaction(cs consensus.State, txn *types.Tr
 	}
 	defer done()
 
-	for _, id := range toSign {
-		var h types.Hash256
-		if cf.WholeTransaction {
-			h = cs.WholeSigHash(*txn, id, 0, 0, cf.Signatures)
-		} else {
-			h = cs.PartialSigHash(*txn, cf)
-		}
-		sig := sw.priv.SignHash(h)
-		txn.Signatures = append(txn.Signatures, types.TransactionSignature{
-			ParentID:       id,
-			CoveredFields:  cf,
-			PublicKeyIndex: 0,
-			Signature:      sig[:],
-		})
-	}
+	signTransaction(sw.priv, cs, txn, toSign, cf)
 	return nil
 }
 
@@ -699,6 +826,9 @@ func (sw *SingleAddressWallet) ProcessConsensusChange(cc modules.ConsensusChange
 	sw.mu.Lock()
 	for _, id := range locked {
 		delete(sw.consensusLocked, id)
+		// the output was spent or reverted, its lock was removed by the
+		// store
+		delete(sw.locked, id)
 	}
 	sw.mu.Unlock()
 
@@ -706,6 +836,25 @@ func (sw *SingleAddressWallet) ProcessConsensusChange(cc modules.ConsensusChange
 	sw.log.Debug("applied consensus change", zap.String("changeID", cc.ID.String()), zap.Int("applied", len(cc.AppliedBlocks)), zap.Int("reverted", len(cc.RevertedBlocks)), zap.Uint64("height", uint64(cc.BlockHeight)), zap.Duration("elapsed", time.Since(start)), zap.String("address", sw.addr.String()))
 }
 
+// signTransaction adds a signature from priv to each of the specified inputs.
+func signTransaction(priv types.PrivateKey, cs consensus.State, txn *types.Transaction, toSign []types.Hash256, cf types.CoveredFields) {
+	for _, id := range toSign {
+		var h types.Hash256
+		if cf.WholeTransaction {
+			h = cs.WholeSigHash(*txn, id, 0, 0, cf.Signatures)
+		} else {
+			h = cs.PartialSigHash(*txn, cf)
+		}
+		sig := priv.SignHash(h)
+		txn.Signatures = append(txn.Signatures, types.TransactionSignature{
+			ParentID:       id,
+			CoveredFields:  cf,
+			PublicKeyIndex: 0,
+			Signature:      sig[:],
+		})
+	}
+}
+
 // payoutTransaction wraps a delayed siacoin output in a transaction for display
 // in the wallet.
 func payoutTransaction(output SiacoinElement, index types.ChainIndex, source TransactionSource, timestamp time.Time) Transaction {
@@ -762,7 +911,7 @@ func NewSingleAddressWallet(priv types.PrivateKey, cm ChainManager, tp Transacti
 
 		addr: types.StandardUnlockHash(priv.PublicKey()),
 
-		locked:          make(map[types.SiacoinOutputID]bool),
+		locked:          make(map[types.SiacoinOutputID]OutputLock),
 		consensusLocked: make(map[types.SiacoinOutputID]bool),
 		tpoolSpent:      make(map[types.SiacoinOutputID]bool),
 
@@ -770,20 +919,32 @@ func NewSingleAddressWallet(priv types.PrivateKey, cm ChainManager, tp Transacti
 		tpoolTxns:  make(map[modules.TransactionSetID][]Transaction),
 	}
 
-	go func() {
-		// note: start in goroutine to avoid blocking startup
-		if err := cm.Subscribe(sw, changeID, sw.tg.Done()); err != nil {
+	// restore the output locks from a previous run
+	locks, err := store.LockedSiacoinElements()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load locked outputs: %w", err)
+	}
+	for _, lock := range locks {
+		sw.locked[lock.ID] = lock
+	}
+
+	// note: subscribe in a goroutine to avoid blocking startup
+	sw.subMu.Lock()
+	sw.subscribe(func(cancel <-chan struct{}) error {
+		err := cm.Subscribe(sw, changeID, cancel)
+		if errors.Is(err, chain.ErrInvalidChangeID) {
 			sw.log.Error("failed to subscribe to consensus changes", zap.Error(err))
-			if errors.Is(err, chain.ErrInvalidChangeID) {
-				// reset change ID and subscribe again
-				if err := store.ResetWallet(seedHash); err != nil {
-					sw.log.Fatal("failed to reset wallet", zap.Error(err))
-				} else if err = cm.Subscribe(sw, modules.ConsensusChangeBeginning, sw.tg.Done()); err != nil {
-					sw.log.Fatal("failed to reset consensus change subscription", zap.Error(err))
-				}
+			// reset change ID and subscribe again
+			if err := store.ResetWallet(seedHash); err != nil {
+				sw.log.Fatal("failed to reset wallet", zap.Error(err))
+			} else if err = cm.Subscribe(sw, modules.ConsensusChangeBeginning, cancel); err != nil {
+				sw.log.Fatal("failed to reset consensus change subscription", zap.Error(err))
 			}
+			return nil
 		}
-	}()
+		return err
+	})
+	sw.subMu.Unlock()
 	tp.Subscribe(sw)
 	return sw, nil
 }
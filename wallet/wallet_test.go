@@ -1,14 +1,18 @@
 package wallet_test
 
 import (
+	"encoding/json"
+	"errors"
 	"testing"
 	"time"
 
+	"go.sia.tech/core/chain"
 	"go.sia.tech/core/types"
 	"go.sia.tech/hostd/internal/test"
 	"go.sia.tech/hostd/wallet"
 	stypes "go.sia.tech/siad/types"
 	"go.uber.org/zap/zaptest"
+	"lukechampine.com/frand"
 )
 
 func TestWallet(t *testing.T) {
@@ -325,3 +329,525 @@ func TestWalletReset(t *testing.T) {
 		t.Fatal("expected zero balance")
 	}
 }
+
+func TestRescan(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	w, err := test.NewWallet(types.GeneratePrivateKey(), t.TempDir(), log.Named("wallet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// mine some blocks before the wallet receives funds
+	if err := w.MineBlocks(types.VoidAddress, 10); err != nil {
+		t.Fatal(err)
+	} else if err := w.MineBlocks(w.Address(), int(stypes.MaturityDelay)*2); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Second) // sleep for sync
+
+	height := w.ScanHeight()
+	_, balance, _, err := w.Balance()
+	if err != nil {
+		t.Fatal(err)
+	} else if balance.IsZero() {
+		t.Fatal("expected non-zero balance")
+	}
+	txns, err := w.Transactions(100, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Rescan(height + 1); err == nil {
+		t.Fatal("expected error rescanning above the tip")
+	} else if err := w.Rescan(height); !errors.Is(err, wallet.ErrRescanAboveBirthHeight) {
+		t.Fatalf("expected error rescanning above the birth height, got %v", err)
+	}
+
+	// rescan from before the first block paying the wallet
+	if err := w.Rescan(5); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		if current := w.ScanHeight(); current == height {
+			break
+		}
+		time.Sleep(100 * time.Millisecond) // sleep for sync
+	}
+	if current := w.ScanHeight(); current != height {
+		t.Fatalf("expected scan height %v, got %v", height, current)
+	}
+
+	// check that the wallet's outputs and transactions were restored
+	if _, rescanned, _, err := w.Balance(); err != nil {
+		t.Fatal(err)
+	} else if !rescanned.Equals(balance) {
+		t.Fatalf("expected balance %v, got %v", balance, rescanned)
+	} else if rescannedTxns, err := w.Transactions(100, 0); err != nil {
+		t.Fatal(err)
+	} else if len(rescannedTxns) != len(txns) {
+		t.Fatalf("expected %v transactions, got %v", len(txns), len(rescannedTxns))
+	}
+}
+
+func TestRedistribute(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	w, err := test.NewWallet(types.GeneratePrivateKey(), t.TempDir(), log.Named("wallet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// fund the wallet with a single output
+	reward := w.TipState().BlockReward()
+	if err := w.MineBlocks(w.Address(), 1); err != nil {
+		t.Fatal(err)
+	} else if err := w.MineBlocks(types.VoidAddress, int(stypes.MaturityDelay)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(500 * time.Millisecond) // sleep for consensus sync
+
+	amount := reward.Div64(20)
+	feePerByte := w.TPool().RecommendedFee()
+	redistribute := func(outputs int) error {
+		txn, toSign, release, err := w.Redistribute(outputs, amount, feePerByte)
+		if err != nil {
+			return err
+		}
+		defer release()
+		if err := w.SignTransaction(w.TipState(), &txn, toSign, types.CoveredFields{WholeTransaction: true}); err != nil {
+			t.Fatal(err)
+		} else if err := w.TPool().AcceptTransactionSet([]types.Transaction{txn}); err != nil {
+			t.Fatal(err)
+		} else if err := w.MineBlocks(types.VoidAddress, 1); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(500 * time.Millisecond) // sleep for consensus sync
+		return nil
+	}
+	// countOutputs returns the number of outputs in the redistribution
+	// range
+	countOutputs := func() (n int) {
+		utxos, err := w.Store().UnspentSiacoinElements()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, sce := range utxos {
+			if sce.Value.Cmp(amount) >= 0 && sce.Value.Cmp(amount.Mul64(2)) < 0 {
+				n++
+			}
+		}
+		return
+	}
+
+	if err := redistribute(10); err != nil {
+		t.Fatal(err)
+	} else if n := countOutputs(); n != 10 {
+		t.Fatalf("expected 10 outputs, got %v", n)
+	}
+
+	// the wallet already has enough outputs
+	if err := redistribute(10); err != wallet.ErrAlreadyRedistributed {
+		t.Fatalf("expected ErrAlreadyRedistributed, got %v", err)
+	}
+
+	// the remaining balance is not enough for 20 more outputs, only the
+	// change output should be split
+	if err := redistribute(30); err != nil {
+		t.Fatal(err)
+	} else if n := countOutputs(); n != 19 {
+		t.Fatalf("expected 19 outputs, got %v", n)
+	}
+
+	// the redistributed outputs should never be spent
+	if err := redistribute(40); err != wallet.ErrNotEnoughFunds {
+		t.Fatalf("expected ErrNotEnoughFunds, got %v", err)
+	}
+}
+
+func TestOutputLocks(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	privKey := types.GeneratePrivateKey()
+	w, err := test.NewWallet(privKey, t.TempDir(), log.Named("wallet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// fund the wallet with a single output
+	if err := w.MineBlocks(w.Address(), 1); err != nil {
+		t.Fatal(err)
+	} else if err := w.MineBlocks(types.VoidAddress, int(stypes.MaturityDelay)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(500 * time.Millisecond) // sleep for consensus sync
+
+	// lock the output without releasing it
+	txn := types.Transaction{
+		FileContracts: []types.FileContract{{}},
+	}
+	if _, _, err := w.FundTransaction(&txn, types.Siacoins(1)); err != nil {
+		t.Fatal(err)
+	}
+	locks, err := w.LockedOutputs()
+	if err != nil {
+		t.Fatal(err)
+	} else if len(locks) != 1 {
+		t.Fatalf("expected 1 lock, got %v", len(locks))
+	} else if locks[0].ID != txn.SiacoinInputs[0].ParentID {
+		t.Fatalf("expected lock on %v, got %v", txn.SiacoinInputs[0].ParentID, locks[0].ID)
+	} else if locks[0].Reason != wallet.LockReasonFormation {
+		t.Fatalf("expected reason %q, got %q", wallet.LockReasonFormation, locks[0].Reason)
+	}
+
+	// simulate a restart, the lock should be restored
+	w.SingleAddressWallet.Close()
+	w2, err := wallet.NewSingleAddressWallet(privKey, w.ChainManager(), w.TPool(), w.Store(), log.Named("wallet2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w2.Close()
+	time.Sleep(500 * time.Millisecond) // sleep for consensus sync
+
+	if locks, err := w2.LockedOutputs(); err != nil {
+		t.Fatal(err)
+	} else if len(locks) != 1 {
+		t.Fatalf("expected 1 lock, got %v", len(locks))
+	} else if _, _, err := w2.FundTransaction(&types.Transaction{}, types.Siacoins(1)); err != wallet.ErrNotEnoughFunds {
+		t.Fatalf("expected ErrNotEnoughFunds, got %v", err)
+	}
+
+	// expire the lock and restart again, the output should be spendable
+	locks[0].Expiration = time.Now().Add(-time.Minute)
+	if err := w.Store().LockSiacoinElements(locks[0]); err != nil {
+		t.Fatal(err)
+	}
+	w2.Close()
+	w3, err := wallet.NewSingleAddressWallet(privKey, w.ChainManager(), w.TPool(), w.Store(), log.Named("wallet3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w3.Close()
+	time.Sleep(500 * time.Millisecond) // sleep for consensus sync
+
+	if locks, err := w3.LockedOutputs(); err != nil {
+		t.Fatal(err)
+	} else if len(locks) != 0 {
+		t.Fatalf("expected no locks, got %v", len(locks))
+	}
+	_, release, err := w3.FundTransaction(&types.Transaction{}, types.Siacoins(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+	if locks, err := w3.LockedOutputs(); err != nil {
+		t.Fatal(err)
+	} else if len(locks) != 0 {
+		t.Fatalf("expected no locks after release, got %v", len(locks))
+	}
+
+	// outputs of a prepared transaction can be released by ID
+	prepared := types.Transaction{}
+	if _, _, err := w3.FundTransaction(&prepared, types.Siacoins(1)); err != nil {
+		t.Fatal(err)
+	} else if err := w3.ReleaseOutputs(prepared.SiacoinInputs[0].ParentID); err != nil {
+		t.Fatal(err)
+	} else if locks, err := w3.LockedOutputs(); err != nil {
+		t.Fatal(err)
+	} else if len(locks) != 0 {
+		t.Fatalf("expected no locks after release, got %v", len(locks))
+	} else if err := w3.ReleaseOutputs(prepared.SiacoinInputs[0].ParentID); err == nil {
+		t.Fatal("expected error releasing an unlocked output")
+	}
+}
+
+func TestOfflineSigning(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	privKey := types.GeneratePrivateKey()
+	w, err := test.NewWallet(privKey, t.TempDir(), log.Named("wallet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// fund the wallet
+	if err := w.MineBlocks(w.Address(), 1); err != nil {
+		t.Fatal(err)
+	} else if err := w.MineBlocks(types.VoidAddress, int(stypes.MaturityDelay)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(500 * time.Millisecond) // sleep for consensus sync
+
+	txn := types.Transaction{
+		SiacoinOutputs: []types.SiacoinOutput{{Value: types.Siacoins(100)}},
+	}
+	toSign, _, err := w.FundTransaction(&txn, types.Siacoins(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var change []int
+	for i := 1; i < len(txn.SiacoinOutputs); i++ {
+		change = append(change, i)
+	}
+	cs := w.TipState()
+	ut := wallet.NewUnsignedTransaction(cs, txn, toSign, change)
+
+	// round trip through the portable format
+	buf, err := json.Marshal(ut)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded wallet.UnsignedTransaction
+	if err := json.Unmarshal(buf, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	// signing with the wrong key or network should fail
+	if _, err := decoded.Sign(types.GeneratePrivateKey(), cs.Network); err == nil {
+		t.Fatal("expected error signing with the wrong key")
+	}
+	wrongNetwork := *cs.Network
+	wrongNetwork.Name = "other"
+	if _, err := decoded.Sign(privKey, &wrongNetwork); err == nil {
+		t.Fatal("expected error signing for the wrong network")
+	}
+
+	signed, err := decoded.Sign(privKey, cs.Network)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(decoded.Transaction.Signatures) != 0 {
+		t.Fatal("expected unsigned transaction to be unmodified")
+	} else if err := w.TPool().AcceptTransactionSet([]types.Transaction{signed}); err != nil {
+		t.Fatal(err)
+	} else if err := w.MineBlocks(types.VoidAddress, 1); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(500 * time.Millisecond) // sleep for consensus sync
+
+	// the inputs should be spent and their locks removed
+	if locks, err := w.LockedOutputs(); err != nil {
+		t.Fatal(err)
+	} else if len(locks) != 0 {
+		t.Fatalf("expected no locks, got %v", len(locks))
+	}
+	txns, err := w.Transactions(1, 0)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(txns) != 1 || txns[0].ID != signed.ID() {
+		t.Fatalf("expected transaction %v, got %+v", signed.ID(), txns)
+	}
+}
+
+func TestUnsignedTransactionValidate(t *testing.T) {
+	privKey := types.GeneratePrivateKey()
+	uc := types.StandardUnlockConditions(privKey.PublicKey())
+	addr := uc.UnlockHash()
+	n, _ := chain.TestnetZen()
+
+	parentID := types.SiacoinOutputID(frand.Entropy256())
+	ut := wallet.UnsignedTransaction{
+		Network: n.Name,
+		Transaction: types.Transaction{
+			SiacoinInputs: []types.SiacoinInput{{ParentID: parentID, UnlockConditions: uc}},
+			SiacoinOutputs: []types.SiacoinOutput{
+				{Address: types.Address(frand.Entropy256()), Value: types.Siacoins(10)},
+				{Address: addr, Value: types.Siacoins(90)},
+			},
+			MinerFees: []types.Currency{types.Siacoins(1)},
+		},
+		ToSign: []types.Hash256{types.Hash256(parentID)},
+		Change: []int{1},
+	}
+	if err := ut.Validate(addr, n); err != nil {
+		t.Fatal(err)
+	} else if _, err := ut.Sign(privKey, n); err != nil {
+		t.Fatal(err)
+	}
+
+	// change sent to another address should be rejected
+	redirected := ut
+	redirected.Transaction.SiacoinOutputs = append([]types.SiacoinOutput(nil), ut.Transaction.SiacoinOutputs...)
+	redirected.Transaction.SiacoinOutputs[1].Address = types.Address(frand.Entropy256())
+	if err := redirected.Validate(addr, n); err == nil {
+		t.Fatal("expected error for change sent to another address")
+	} else if _, err := redirected.Sign(privKey, n); err == nil {
+		t.Fatal("expected error signing change sent to another address")
+	}
+
+	// change indices must refer to an output
+	missing := ut
+	missing.Change = []int{2}
+	if err := missing.Validate(addr, n); err == nil {
+		t.Fatal("expected error for missing change output")
+	}
+}
+
+func TestCoinControlAndLabels(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	w, err := test.NewWallet(types.GeneratePrivateKey(), t.TempDir(), log.Named("wallet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// fund the wallet with two outputs
+	if err := w.MineBlocks(w.Address(), 2); err != nil {
+		t.Fatal(err)
+	} else if err := w.MineBlocks(types.VoidAddress, int(stypes.MaturityDelay)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(500 * time.Millisecond) // sleep for consensus sync
+
+	utxos, err := w.Store().UnspentSiacoinElements()
+	if err != nil {
+		t.Fatal(err)
+	} else if len(utxos) != 2 {
+		t.Fatalf("expected 2 outputs, got %v", len(utxos))
+	}
+	input := utxos[1]
+
+	// pay two outputs from an explicitly selected input
+	minerFee := types.Siacoins(1)
+	txn := types.Transaction{
+		MinerFees: []types.Currency{minerFee},
+		SiacoinOutputs: []types.SiacoinOutput{
+			{Address: types.Address{1}, Value: types.Siacoins(10)},
+			{Address: types.Address{2}, Value: types.Siacoins(20)},
+		},
+	}
+	toSign, release, err := w.FundTransactionWithInputs(&txn, types.Siacoins(31), []types.SiacoinOutputID{input.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	if len(txn.SiacoinInputs) != 1 || txn.SiacoinInputs[0].ParentID != input.ID {
+		t.Fatalf("expected input %v, got %v", input.ID, txn.SiacoinInputs)
+	} else if len(txn.SiacoinOutputs) != 3 {
+		t.Fatalf("expected 3 outputs, got %v", len(txn.SiacoinOutputs))
+	}
+
+	// locked and unknown inputs cannot be selected
+	if _, _, err := w.FundTransactionWithInputs(&types.Transaction{}, types.Siacoins(1), []types.SiacoinOutputID{input.ID}); err == nil {
+		t.Fatal("expected error selecting a locked input")
+	} else if _, _, err := w.FundTransactionWithInputs(&types.Transaction{}, types.Siacoins(1), []types.SiacoinOutputID{{1}}); err == nil {
+		t.Fatal("expected error selecting an unknown input")
+	}
+
+	// label the transaction before it is broadcast
+	if err := w.SetTransactionLabel(txn.ID(), "Co-owner payouts"); err != nil {
+		t.Fatal(err)
+	} else if err := w.SignTransaction(w.TipState(), &txn, toSign, types.CoveredFields{WholeTransaction: true}); err != nil {
+		t.Fatal(err)
+	} else if err := w.TPool().AcceptTransactionSet([]types.Transaction{txn}); err != nil {
+		t.Fatal(err)
+	} else if err := w.MineBlocks(types.VoidAddress, 1); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(500 * time.Millisecond) // sleep for consensus sync
+
+	txns, err := w.SearchTransactions(wallet.TransactionFilter{Label: "co-owner", Limit: 100})
+	if err != nil {
+		t.Fatal(err)
+	} else if len(txns) != 1 || txns[0].ID != txn.ID() {
+		t.Fatalf("expected transaction %v, got %+v", txn.ID(), txns)
+	} else if txns[0].Label != "Co-owner payouts" {
+		t.Fatalf("expected label %q, got %q", "Co-owner payouts", txns[0].Label)
+	}
+
+	// wildcards should be matched literally
+	for _, label := range []string{"rent", "%", "co_owner"} {
+		if txns, err := w.SearchTransactions(wallet.TransactionFilter{Label: label, Limit: 100}); err != nil {
+			t.Fatal(err)
+		} else if len(txns) != 0 {
+			t.Fatalf("expected no transactions matching %q, got %v", label, len(txns))
+		}
+	}
+
+	// removing the label should remove it from the results
+	if err := w.SetTransactionLabel(txn.ID(), ""); err != nil {
+		t.Fatal(err)
+	} else if txns, err := w.SearchTransactions(wallet.TransactionFilter{Label: "co-owner", Limit: 100}); err != nil {
+		t.Fatal(err)
+	} else if len(txns) != 0 {
+		t.Fatalf("expected no transactions, got %v", len(txns))
+	} else if txns, err := w.Transactions(100, 0); err != nil {
+		t.Fatal(err)
+	} else if len(txns) != 3 {
+		t.Fatalf("expected 3 transactions, got %v", len(txns))
+	}
+}
+
+func TestTransactionFilters(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	w, err := test.NewWallet(types.GeneratePrivateKey(), t.TempDir(), log.Named("wallet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// fund the wallet with two miner payouts
+	if err := w.MineBlocks(w.Address(), 2); err != nil {
+		t.Fatal(err)
+	} else if err := w.MineBlocks(types.VoidAddress, int(stypes.MaturityDelay)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(500 * time.Millisecond) // sleep for consensus sync
+	payoutHeight := w.ScanHeight()
+
+	// send siacoins out of the wallet
+	txn := types.Transaction{
+		MinerFees:      []types.Currency{types.Siacoins(1)},
+		SiacoinOutputs: []types.SiacoinOutput{{Address: types.VoidAddress, Value: types.Siacoins(10)}},
+	}
+	toSign, release, err := w.FundTransaction(&txn, types.Siacoins(11))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	if err := w.SignTransaction(w.TipState(), &txn, toSign, types.CoveredFields{WholeTransaction: true}); err != nil {
+		t.Fatal(err)
+	} else if err := w.TPool().AcceptTransactionSet([]types.Transaction{txn}); err != nil {
+		t.Fatal(err)
+	} else if err := w.MineBlocks(types.VoidAddress, 1); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(500 * time.Millisecond) // sleep for consensus sync
+
+	tests := []struct {
+		name     string
+		filter   wallet.TransactionFilter
+		expected int
+	}{
+		{"all", wallet.TransactionFilter{}, 3},
+		{"miner", wallet.TransactionFilter{Sources: []wallet.TransactionSource{wallet.TxnSourceMinerPayout}}, 2},
+		{"sources", wallet.TransactionFilter{Sources: []wallet.TransactionSource{wallet.TxnSourceMinerPayout, wallet.TxnSourceTransaction}}, 3},
+		{"contract", wallet.TransactionFilter{Sources: []wallet.TransactionSource{wallet.TxnSourceContract}}, 0},
+		{"incoming", wallet.TransactionFilter{Direction: wallet.TxnDirectionIncoming}, 2},
+		{"outgoing", wallet.TransactionFilter{Direction: wallet.TxnDirectionOutgoing}, 1},
+		{"incoming offset", wallet.TransactionFilter{Direction: wallet.TxnDirectionIncoming, Offset: 1}, 1},
+		{"min height", wallet.TransactionFilter{MinHeight: payoutHeight + 1}, 1},
+		{"max height", wallet.TransactionFilter{MaxHeight: payoutHeight}, 2},
+		{"after", wallet.TransactionFilter{After: time.Now().Add(time.Hour)}, 0},
+		{"before", wallet.TransactionFilter{Before: time.Now().Add(time.Hour)}, 3},
+	}
+	for _, tt := range tests {
+		tt.filter.Limit = 100
+		txns, err := w.SearchTransactions(tt.filter)
+		if err != nil {
+			t.Fatal(err)
+		} else if len(txns) != tt.expected {
+			t.Fatalf("%s: expected %v transactions, got %v", tt.name, tt.expected, len(txns))
+		}
+		for _, txn := range txns {
+			if tt.filter.Direction != "" && txn.Direction() != tt.filter.Direction {
+				t.Fatalf("%s: expected direction %v, got %v", tt.name, tt.filter.Direction, txn.Direction())
+			}
+		}
+	}
+
+	if txns, err := w.SearchTransactions(wallet.TransactionFilter{Direction: wallet.TxnDirectionIncoming, Limit: 1}); err != nil {
+		t.Fatal(err)
+	} else if len(txns) != 1 {
+		t.Fatalf("expected 1 transaction, got %v", len(txns))
+	}
+}